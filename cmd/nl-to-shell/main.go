@@ -11,6 +11,7 @@ import (
 
 	"github.com/kanishka-sahoo/nl-to-shell/internal/cli"
 	"github.com/kanishka-sahoo/nl-to-shell/internal/errors"
+	"github.com/kanishka-sahoo/nl-to-shell/internal/executor"
 	"github.com/kanishka-sahoo/nl-to-shell/internal/performance"
 	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
 )
@@ -130,18 +131,28 @@ func (app *Application) Run() error {
 	}()
 
 	// Wait for either completion or signal
-	select {
-	case err := <-errChan:
-		if err != nil {
-			app.monitor.RecordCounter("application.errors", 1, map[string]string{
-				"error_type": fmt.Sprintf("%T", err),
-			})
-			return err
+	for {
+		var sig os.Signal
+		select {
+		case err := <-errChan:
+			if err != nil {
+				app.monitor.RecordCounter("application.errors", 1, map[string]string{
+					"error_type": fmt.Sprintf("%T", err),
+				})
+				return err
+			}
+			app.monitor.RecordCounter("application.successful_completions", 1, nil)
+			return nil
+
+		case sig = <-sigChan:
+		}
+
+		// Ctrl-C while a command runs is forwarded to the command by the
+		// executor and only stops the command
+		if sig == syscall.SIGINT && executor.CommandRunning() {
+			continue
 		}
-		app.monitor.RecordCounter("application.successful_completions", 1, nil)
-		return nil
 
-	case sig := <-sigChan:
 		app.monitor.RecordCounter("application.signal_shutdowns", 1, map[string]string{
 			"signal": sig.String(),
 		})
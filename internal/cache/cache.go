@@ -301,15 +301,25 @@ func CacheKey(components ...string) string {
 func CacheKeyFromContext(ctx *types.Context) string {
 	components := []string{
 		ctx.WorkingDirectory,
-		fmt.Sprintf("%d", len(ctx.Files)),
+		ContextFingerprint(ctx),
+		fmt.Sprintf("%t", ctx.PlanMode),
 	}
 
-	if ctx.GitInfo != nil {
-		components = append(components,
-			ctx.GitInfo.CurrentBranch,
-			ctx.GitInfo.WorkingTreeStatus,
-			fmt.Sprintf("%t", ctx.GitInfo.HasUncommittedChanges),
-		)
+	// Follow-up requests in a session depend on the earlier turns and the
+	// environment they left behind
+	for _, turn := range ctx.Conversation {
+		components = append(components, turn.Input, turn.Command, turn.Output)
+	}
+	if ctx.Shell != nil {
+		var exported []string
+		for key, value := range ctx.Shell.Environment {
+			exported = append(exported, fmt.Sprintf("export %s=%s", key, value))
+		}
+		sort.Strings(exported)
+		components = append(components, exported...)
+		for _, key := range ctx.Shell.Unset {
+			components = append(components, "unset "+key)
+		}
 	}
 
 	// Add environment variables that might affect commands
@@ -327,6 +337,38 @@ func CacheKeyFromContext(ctx *types.Context) string {
 	return CacheKey(components...)
 }
 
+// ContextFingerprint summarizes the directory listing and git state of a
+// context, so responses generated for a directory can be dropped once either
+// changes
+func ContextFingerprint(ctx *types.Context) string {
+	if ctx == nil {
+		return CacheKey()
+	}
+
+	var components []string
+	if ctx.GitInfo != nil {
+		components = append(components,
+			ctx.GitInfo.CurrentBranch,
+			ctx.GitInfo.WorkingTreeStatus,
+			fmt.Sprintf("%t", ctx.GitInfo.HasUncommittedChanges),
+		)
+	} else {
+		components = append(components, "", "", "")
+	}
+
+	names := make([]string, 0, len(ctx.Files))
+	for _, file := range ctx.Files {
+		if file.IsDir {
+			names = append(names, file.Name+"/")
+		} else {
+			names = append(names, file.Name)
+		}
+	}
+	sort.Strings(names)
+
+	return CacheKey(append(components, names...)...)
+}
+
 // CacheKeyFromPrompt generates a cache key from prompt and context
 func CacheKeyFromPrompt(prompt string, ctx *types.Context, provider string, model string) string {
 	components := []string{
@@ -276,3 +276,32 @@ func TestCacheKeyFromPrompt(t *testing.T) {
 		t.Fatal("Different prompt should generate different key")
 	}
 }
+
+func TestProviderCache_CachedResponsesUseNoTokens(t *testing.T) {
+	pc := NewProviderCache()
+	defer pc.Close()
+
+	ctx := &types.Context{WorkingDirectory: "/test/dir"}
+	usage := types.TokenUsage{InputTokens: 120, OutputTokens: 30}
+
+	response := &types.CommandResponse{Command: "ls -la", Confidence: 0.9, Usage: usage}
+	if err := pc.SetCommandResponse("list files", ctx, "openai", "gpt-4", response); err != nil {
+		t.Fatalf("Failed to cache command response: %v", err)
+	}
+	cached, exists := pc.GetCommandResponse("list files", ctx, "openai", "gpt-4")
+	if !exists || cached.Command != "ls -la" || cached.Usage != (types.TokenUsage{}) {
+		t.Errorf("Expected the cached command without usage, got %+v", cached)
+	}
+	if response.Usage != usage {
+		t.Error("Caching should not change the original response")
+	}
+
+	validation := &types.ValidationResponse{IsCorrect: true, Usage: usage}
+	if err := pc.SetValidationResponse("ls", "out", "list files", "openai", "gpt-4", validation); err != nil {
+		t.Fatalf("Failed to cache validation response: %v", err)
+	}
+	cachedValidation, exists := pc.GetValidationResponse("ls", "out", "list files", "openai", "gpt-4")
+	if !exists || !cachedValidation.IsCorrect || cachedValidation.Usage != (types.TokenUsage{}) {
+		t.Errorf("Expected the cached validation without usage, got %+v", cachedValidation)
+	}
+}
@@ -1,10 +1,7 @@
 package cache
 
 import (
-	"encoding/json"
 	"fmt"
-	"os"
-	"path/filepath"
 	"sync"
 	"time"
 )
@@ -24,6 +21,7 @@ type ManagerConfig struct {
 	Enabled           bool          `json:"enabled"`
 	PersistentStorage bool          `json:"persistent_storage"`
 	StoragePath       string        `json:"storage_path"`
+	EncryptionKey     []byte        `json:"-"` // Key the persistent store is encrypted with
 	MaxTotalSize      int64         `json:"max_total_size"`
 	GlobalTTL         time.Duration `json:"global_ttl"`
 	ContextConfig     *CacheConfig  `json:"context_config"`
@@ -75,11 +73,11 @@ func NewManager(config *ManagerConfig) *Manager {
 		config:         config,
 	}
 
-	// Load persistent cache if enabled
+	// Open persistent storage for provider responses if enabled
 	if config.PersistentStorage && config.StoragePath != "" {
-		if err := manager.loadPersistentCache(); err != nil {
-			// Log error but don't fail - continue with empty cache
-			fmt.Printf("Warning: Failed to load persistent cache: %v\n", err)
+		if err := manager.openPersistentStore(); err != nil {
+			// Log error but don't fail - continue with the in-memory cache only
+			fmt.Printf("Warning: Failed to open persistent cache: %v\n", err)
 		}
 	}
 
@@ -135,26 +133,17 @@ func (m *Manager) GetStats() CombinedStats {
 	}
 }
 
-// Close closes all caches and saves persistent data if enabled
+// Close closes all caches. Persistent entries are written as they are
+// stored, so nothing is left to save.
 func (m *Manager) Close() error {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
-	var err error
-
-	// Save persistent cache if enabled
-	if m.config.PersistentStorage && m.config.StoragePath != "" {
-		if saveErr := m.savePersistentCache(); saveErr != nil {
-			err = fmt.Errorf("failed to save persistent cache: %w", saveErr)
-		}
-	}
-
-	// Close all caches
 	m.contextCache.Close()
 	m.providerCache.Close()
 	m.configCache.Close()
 
-	return err
+	return nil
 }
 
 // Invalidate invalidates cache entries based on criteria
@@ -182,70 +171,14 @@ func (m *Manager) Invalidate(criteria InvalidationCriteria) {
 	}
 }
 
-// loadPersistentCache loads cache data from persistent storage
-func (m *Manager) loadPersistentCache() error {
-	if m.config.StoragePath == "" {
-		return fmt.Errorf("no storage path configured")
-	}
-
-	// Ensure storage directory exists
-	if err := os.MkdirAll(filepath.Dir(m.config.StoragePath), 0700); err != nil {
-		return fmt.Errorf("failed to create storage directory: %w", err)
-	}
-
-	// Check if cache file exists
-	if _, err := os.Stat(m.config.StoragePath); os.IsNotExist(err) {
-		return nil // No cache file to load
-	}
-
-	// Read cache file
-	data, err := os.ReadFile(m.config.StoragePath)
+// openPersistentStore opens the encrypted store at the storage path and
+// makes the provider cache read and write command responses through it
+func (m *Manager) openPersistentStore() error {
+	store, err := NewPersistentStore(DefaultPersistentStoreConfig(m.config.StoragePath, m.config.EncryptionKey))
 	if err != nil {
-		return fmt.Errorf("failed to read cache file: %w", err)
-	}
-
-	// Parse cache data
-	var persistentData PersistentCacheData
-	if err := json.Unmarshal(data, &persistentData); err != nil {
-		return fmt.Errorf("failed to parse cache data: %w", err)
+		return err
 	}
-
-	// Load data into caches (implementation would depend on specific requirements)
-	// For security reasons, we might only want to cache certain types of data persistently
-
-	return nil
-}
-
-// savePersistentCache saves cache data to persistent storage
-func (m *Manager) savePersistentCache() error {
-	if m.config.StoragePath == "" {
-		return fmt.Errorf("no storage path configured")
-	}
-
-	// Prepare data for persistence (only non-sensitive data)
-	persistentData := PersistentCacheData{
-		Timestamp: time.Now(),
-		Stats:     m.GetStats(),
-		// Note: We don't persist actual cache entries for security reasons
-		// Only metadata and statistics
-	}
-
-	// Marshal to JSON
-	data, err := json.MarshalIndent(persistentData, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal cache data: %w", err)
-	}
-
-	// Ensure storage directory exists
-	if err := os.MkdirAll(filepath.Dir(m.config.StoragePath), 0700); err != nil {
-		return fmt.Errorf("failed to create storage directory: %w", err)
-	}
-
-	// Write to file
-	if err := os.WriteFile(m.config.StoragePath, data, 0600); err != nil {
-		return fmt.Errorf("failed to write cache file: %w", err)
-	}
-
+	m.providerCache.SetPersistentStore(store)
 	return nil
 }
 
@@ -327,13 +260,6 @@ type CombinedStats struct {
 	Metrics  ProviderCacheMetrics `json:"metrics"`
 }
 
-// PersistentCacheData represents data that can be persisted to disk
-type PersistentCacheData struct {
-	Timestamp time.Time     `json:"timestamp"`
-	Stats     CombinedStats `json:"stats"`
-	// Note: Actual cache entries are not persisted for security reasons
-}
-
 // GetTotalSize returns the total size of all caches
 func (cs *CombinedStats) GetTotalSize() int64 {
 	return cs.Context.TotalSize + cs.Provider.TotalSize + cs.Config.TotalSize
@@ -0,0 +1,351 @@
+package cache
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+// PersistentKeySize is the size in bytes of the key the persistent store is
+// encrypted with
+const PersistentKeySize = 32
+
+// PersistentStoreConfig represents configuration for the persistent store
+type PersistentStoreConfig struct {
+	Path       string        // File the entries are kept in
+	Key        []byte        // AES-256 key the file is encrypted with
+	TTL        time.Duration // How long a response is kept
+	MaxSize    int64         // Maximum total size of the responses in bytes
+	MaxEntries int           // Maximum number of responses
+}
+
+// DefaultPersistentStoreConfig returns the default persistent store
+// configuration for the file at path, encrypted with key
+func DefaultPersistentStoreConfig(path string, key []byte) *PersistentStoreConfig {
+	return &PersistentStoreConfig{
+		Path:       path,
+		Key:        key,
+		TTL:        24 * time.Hour,
+		MaxSize:    10 * 1024 * 1024, // 10MB
+		MaxEntries: 1000,
+	}
+}
+
+// PersistentStore keeps command generation responses on disk between runs,
+// encrypted with AES-GCM. Responses are keyed by CacheKeyFromPrompt and
+// dropped when they expire, when the limits are exceeded, or when the
+// directory listing or git state of the directory they were generated in
+// changes.
+type PersistentStore struct {
+	config *PersistentStoreConfig
+	aead   cipher.AEAD
+	mutex  sync.Mutex
+}
+
+// persistentEntry is a response kept in the persistent store
+type persistentEntry struct {
+	Directory   string // Working directory the response was generated in
+	Fingerprint string // ContextFingerprint of the directory at the time
+	Response    *types.CommandResponse
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+	LastAccess  time.Time
+	Hits        int64
+	Size        int64
+}
+
+// persistentData is the decrypted content of the persistent store file
+type persistentData struct {
+	Entries       map[string]*persistentEntry
+	Hits          int64
+	Misses        int64
+	Invalidations int64 // Entries dropped because their directory changed
+}
+
+// PersistentStats represents statistics of the persistent store
+type PersistentStats struct {
+	Path           string
+	Entries        int
+	ExpiredEntries int
+	TotalSize      int64
+	FileSize       int64
+	MaxSize        int64
+	MaxEntries     int
+	TTL            time.Duration
+	Hits           int64
+	Misses         int64
+	Invalidations  int64
+	Oldest         time.Time
+	Newest         time.Time
+}
+
+// NewPersistentStore creates a persistent store. The file is only read and
+// written when responses are looked up or stored.
+func NewPersistentStore(config *PersistentStoreConfig) (*PersistentStore, error) {
+	if config == nil || config.Path == "" {
+		return nil, fmt.Errorf("no storage path configured")
+	}
+	if len(config.Key) != PersistentKeySize {
+		return nil, fmt.Errorf("encryption key must be %d bytes, got %d", PersistentKeySize, len(config.Key))
+	}
+
+	block, err := aes.NewCipher(config.Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+
+	return &PersistentStore{config: config, aead: aead}, nil
+}
+
+// GetCommandResponse retrieves a stored command generation response. Every
+// response stored for the working directory is dropped when its directory
+// listing or git state no longer matches the context.
+func (ps *PersistentStore) GetCommandResponse(prompt string, ctx *types.Context, provider, model string) (*types.CommandResponse, bool) {
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+
+	data, err := ps.load()
+	if err != nil {
+		return nil, false
+	}
+
+	now := time.Now()
+	ps.invalidate(data, ctx.WorkingDirectory, ContextFingerprint(ctx))
+
+	key := CacheKeyFromPrompt(prompt, ctx, provider, model)
+	entry, exists := data.Entries[key]
+	if !exists || now.After(entry.ExpiresAt) {
+		delete(data.Entries, key)
+		data.Misses++
+		ps.save(data)
+		return nil, false
+	}
+
+	entry.Hits++
+	entry.LastAccess = now
+	data.Hits++
+	ps.save(data)
+	return entry.Response, true
+}
+
+// SetCommandResponse stores a command generation response, evicting the least
+// recently used responses when the limits are exceeded
+func (ps *PersistentStore) SetCommandResponse(prompt string, ctx *types.Context, provider, model string, response *types.CommandResponse, ttl time.Duration) error {
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+
+	if ttl <= 0 || (ps.config.TTL > 0 && ttl > ps.config.TTL) {
+		ttl = ps.config.TTL
+	}
+
+	size, err := calculateResponseSize(response)
+	if err != nil {
+		return err
+	}
+	if ps.config.MaxSize > 0 && size > ps.config.MaxSize {
+		return fmt.Errorf("response size %d exceeds maximum cache size %d", size, ps.config.MaxSize)
+	}
+
+	// A file that cannot be read or decrypted is replaced
+	data, err := ps.load()
+	if err != nil {
+		data = &persistentData{Entries: make(map[string]*persistentEntry)}
+	}
+
+	now := time.Now()
+	fingerprint := ContextFingerprint(ctx)
+	ps.invalidate(data, ctx.WorkingDirectory, fingerprint)
+
+	data.Entries[CacheKeyFromPrompt(prompt, ctx, provider, model)] = &persistentEntry{
+		Directory:   ctx.WorkingDirectory,
+		Fingerprint: fingerprint,
+		Response:    response,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(ttl),
+		LastAccess:  now,
+		Size:        size,
+	}
+	ps.enforceLimits(data, now)
+
+	return ps.save(data)
+}
+
+// Clear removes every stored response
+func (ps *PersistentStore) Clear() error {
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+
+	if err := os.Remove(ps.config.Path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove cache file: %w", err)
+	}
+	return nil
+}
+
+// Stats returns statistics of the persistent store
+func (ps *PersistentStore) Stats() (PersistentStats, error) {
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+
+	stats := PersistentStats{
+		Path:       ps.config.Path,
+		MaxSize:    ps.config.MaxSize,
+		MaxEntries: ps.config.MaxEntries,
+		TTL:        ps.config.TTL,
+	}
+
+	data, err := ps.load()
+	if err != nil {
+		return stats, err
+	}
+	if info, err := os.Stat(ps.config.Path); err == nil {
+		stats.FileSize = info.Size()
+	}
+
+	now := time.Now()
+	stats.Entries = len(data.Entries)
+	stats.Hits = data.Hits
+	stats.Misses = data.Misses
+	stats.Invalidations = data.Invalidations
+	for _, entry := range data.Entries {
+		stats.TotalSize += entry.Size
+		if now.After(entry.ExpiresAt) {
+			stats.ExpiredEntries++
+		}
+		if stats.Oldest.IsZero() || entry.CreatedAt.Before(stats.Oldest) {
+			stats.Oldest = entry.CreatedAt
+		}
+		if entry.CreatedAt.After(stats.Newest) {
+			stats.Newest = entry.CreatedAt
+		}
+	}
+
+	return stats, nil
+}
+
+// invalidate drops the entries stored for directory whose fingerprint differs
+// from fingerprint
+func (ps *PersistentStore) invalidate(data *persistentData, directory, fingerprint string) {
+	for key, entry := range data.Entries {
+		if entry.Directory == directory && entry.Fingerprint != fingerprint {
+			delete(data.Entries, key)
+			data.Invalidations++
+		}
+	}
+}
+
+// enforceLimits drops expired entries, then the least recently used entries
+// until the entry and size limits are met
+func (ps *PersistentStore) enforceLimits(data *persistentData, now time.Time) {
+	var totalSize int64
+	keys := make([]string, 0, len(data.Entries))
+	for key, entry := range data.Entries {
+		if now.After(entry.ExpiresAt) {
+			delete(data.Entries, key)
+			continue
+		}
+		totalSize += entry.Size
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return data.Entries[keys[i]].LastAccess.Before(data.Entries[keys[j]].LastAccess)
+	})
+	for _, key := range keys {
+		overEntries := ps.config.MaxEntries > 0 && len(data.Entries) > ps.config.MaxEntries
+		overSize := ps.config.MaxSize > 0 && totalSize > ps.config.MaxSize
+		if !overEntries && !overSize {
+			break
+		}
+		totalSize -= data.Entries[key].Size
+		delete(data.Entries, key)
+	}
+}
+
+// load reads and decrypts the store file; a missing file is an empty store
+func (ps *PersistentStore) load() (*persistentData, error) {
+	data := &persistentData{Entries: make(map[string]*persistentEntry)}
+
+	encrypted, err := os.ReadFile(ps.config.Path)
+	if os.IsNotExist(err) {
+		return data, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache file: %w", err)
+	}
+
+	nonceSize := ps.aead.NonceSize()
+	if len(encrypted) < nonceSize {
+		return nil, fmt.Errorf("cache file is truncated")
+	}
+	plaintext, err := ps.aead.Open(nil, encrypted[:nonceSize], encrypted[nonceSize:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt cache file: %w", err)
+	}
+
+	if err := json.Unmarshal(plaintext, data); err != nil {
+		return nil, fmt.Errorf("failed to parse cache file: %w", err)
+	}
+	if data.Entries == nil {
+		data.Entries = make(map[string]*persistentEntry)
+	}
+	return data, nil
+}
+
+// save encrypts and writes the store file, replacing it atomically
+func (ps *PersistentStore) save(data *persistentData) error {
+	plaintext, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cache data: %w", err)
+	}
+
+	nonce := make([]byte, ps.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	encrypted := ps.aead.Seal(nonce, nonce, plaintext, nil)
+
+	dir := filepath.Dir(ps.config.Path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create storage directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(ps.config.Path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(encrypted); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), ps.config.Path); err != nil {
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	return nil
+}
+
+// calculateResponseSize estimates the stored size of a response
+func calculateResponseSize(response *types.CommandResponse) (int64, error) {
+	data, err := json.Marshal(response)
+	if err != nil {
+		return 0, fmt.Errorf("failed to calculate response size: %w", err)
+	}
+	return int64(len(data)), nil
+}
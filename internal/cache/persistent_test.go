@@ -0,0 +1,212 @@
+package cache
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+func newTestPersistentStore(t *testing.T) *PersistentStore {
+	t.Helper()
+	config := DefaultPersistentStoreConfig(filepath.Join(t.TempDir(), "responses.cache"), bytes.Repeat([]byte{7}, PersistentKeySize))
+	store, err := NewPersistentStore(config)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	return store
+}
+
+func testPersistentContext() *types.Context {
+	return &types.Context{
+		WorkingDirectory: "/test/dir",
+		Files:            []types.FileInfo{{Name: "main.go"}, {Name: "docs", IsDir: true}},
+		GitInfo:          &types.GitContext{IsRepository: true, CurrentBranch: "main", WorkingTreeStatus: "clean"},
+		Environment:      map[string]string{"SHELL": "/bin/bash"},
+	}
+}
+
+func TestPersistentStore_SurvivesReopening(t *testing.T) {
+	store := newTestPersistentStore(t)
+	ctx := testPersistentContext()
+	response := &types.CommandResponse{Command: "ls -la", Confidence: 0.9}
+
+	if err := store.SetCommandResponse("list files", ctx, "openai", "gpt-4o", response, 0); err != nil {
+		t.Fatalf("failed to store response: %v", err)
+	}
+
+	// A new store on the same file, as in the next run, finds the response
+	reopened, err := NewPersistentStore(store.config)
+	if err != nil {
+		t.Fatalf("failed to reopen store: %v", err)
+	}
+	cached, exists := reopened.GetCommandResponse("list files", ctx, "openai", "gpt-4o")
+	if !exists || cached.Command != "ls -la" {
+		t.Fatalf("expected the stored response, got %+v, %v", cached, exists)
+	}
+	if _, exists := reopened.GetCommandResponse("list files", ctx, "openai", "gpt-4o-mini"); exists {
+		t.Error("expected another model to miss")
+	}
+
+	stats, err := reopened.Stats()
+	if err != nil {
+		t.Fatalf("failed to read stats: %v", err)
+	}
+	if stats.Entries != 1 || stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("expected 1 entry, 1 hit and 1 miss, got %+v", stats)
+	}
+}
+
+func TestPersistentStore_Encrypted(t *testing.T) {
+	store := newTestPersistentStore(t)
+	ctx := testPersistentContext()
+	if err := store.SetCommandResponse("list files", ctx, "openai", "gpt-4o", &types.CommandResponse{Command: "ls -la"}, 0); err != nil {
+		t.Fatalf("failed to store response: %v", err)
+	}
+
+	data, err := os.ReadFile(store.config.Path)
+	if err != nil {
+		t.Fatalf("failed to read cache file: %v", err)
+	}
+	if bytes.Contains(data, []byte("ls -la")) || bytes.Contains(data, []byte("/test/dir")) {
+		t.Error("expected the cache file not to contain plaintext")
+	}
+	if info, err := os.Stat(store.config.Path); err == nil && info.Mode().Perm() != 0600 {
+		t.Errorf("expected cache file permissions 0600, got %v", info.Mode().Perm())
+	}
+
+	// Another key cannot read the file, and storing replaces it
+	other := *store.config
+	other.Key = bytes.Repeat([]byte{9}, PersistentKeySize)
+	otherStore, err := NewPersistentStore(&other)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	if _, exists := otherStore.GetCommandResponse("list files", ctx, "openai", "gpt-4o"); exists {
+		t.Error("expected a store with another key to miss")
+	}
+	if _, err := otherStore.Stats(); err == nil {
+		t.Error("expected stats to fail with another key")
+	}
+	if err := otherStore.SetCommandResponse("list files", ctx, "openai", "gpt-4o", &types.CommandResponse{Command: "ls"}, 0); err != nil {
+		t.Fatalf("expected an unreadable file to be replaced, got %v", err)
+	}
+	if cached, exists := otherStore.GetCommandResponse("list files", ctx, "openai", "gpt-4o"); !exists || cached.Command != "ls" {
+		t.Errorf("expected the replacing response, got %+v, %v", cached, exists)
+	}
+
+	if _, err := NewPersistentStore(&PersistentStoreConfig{Path: store.config.Path, Key: []byte("short")}); err == nil {
+		t.Error("expected a short key to be rejected")
+	}
+}
+
+func TestPersistentStore_InvalidatesChangedDirectory(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(ctx *types.Context)
+	}{
+		{"file added", func(ctx *types.Context) {
+			ctx.Files = append(ctx.Files, types.FileInfo{Name: "new.txt"})
+		}},
+		{"file renamed", func(ctx *types.Context) { ctx.Files[0].Name = "app.go" }},
+		{"branch switched", func(ctx *types.Context) { ctx.GitInfo.CurrentBranch = "feature" }},
+		{"working tree changed", func(ctx *types.Context) {
+			ctx.GitInfo.WorkingTreeStatus = "M main.go"
+			ctx.GitInfo.HasUncommittedChanges = true
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newTestPersistentStore(t)
+			ctx := testPersistentContext()
+			store.SetCommandResponse("list files", ctx, "openai", "gpt-4o", &types.CommandResponse{Command: "ls"}, 0)
+			store.SetCommandResponse("show status", ctx, "openai", "gpt-4o", &types.CommandResponse{Command: "git status"}, 0)
+
+			otherDir := testPersistentContext()
+			otherDir.WorkingDirectory = "/other/dir"
+			store.SetCommandResponse("list files", otherDir, "openai", "gpt-4o", &types.CommandResponse{Command: "ls"}, 0)
+
+			tt.change(ctx)
+			if _, exists := store.GetCommandResponse("list files", ctx, "openai", "gpt-4o"); exists {
+				t.Fatal("expected a changed directory to miss")
+			}
+
+			stats, _ := store.Stats()
+			if stats.Entries != 1 || stats.Invalidations != 2 {
+				t.Errorf("expected both entries of the changed directory dropped, got %+v", stats)
+			}
+			if _, exists := store.GetCommandResponse("list files", otherDir, "openai", "gpt-4o"); !exists {
+				t.Error("expected other directories to keep their entries")
+			}
+		})
+	}
+}
+
+func TestPersistentStore_TTLAndLimits(t *testing.T) {
+	store := newTestPersistentStore(t)
+	ctx := testPersistentContext()
+
+	store.SetCommandResponse("short lived", ctx, "openai", "gpt-4o", &types.CommandResponse{Command: "date"}, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if _, exists := store.GetCommandResponse("short lived", ctx, "openai", "gpt-4o"); exists {
+		t.Error("expected an expired response to miss")
+	}
+
+	store.config.MaxEntries = 2
+	for _, prompt := range []string{"first", "second"} {
+		store.SetCommandResponse(prompt, ctx, "openai", "gpt-4o", &types.CommandResponse{Command: prompt}, 0)
+		time.Sleep(2 * time.Millisecond)
+	}
+	store.GetCommandResponse("first", ctx, "openai", "gpt-4o") // Now more recently used than second
+	store.SetCommandResponse("third", ctx, "openai", "gpt-4o", &types.CommandResponse{Command: "third"}, 0)
+
+	if _, exists := store.GetCommandResponse("second", ctx, "openai", "gpt-4o"); exists {
+		t.Error("expected the least recently used response to be evicted")
+	}
+	for _, prompt := range []string{"first", "third"} {
+		if _, exists := store.GetCommandResponse(prompt, ctx, "openai", "gpt-4o"); !exists {
+			t.Errorf("expected %q to be kept", prompt)
+		}
+	}
+
+	store.config.MaxSize = 10
+	if err := store.SetCommandResponse("huge", ctx, "openai", "gpt-4o", &types.CommandResponse{Command: "a response larger than the limit"}, 0); err == nil {
+		t.Error("expected a response larger than the size limit to be rejected")
+	}
+
+	if err := store.Clear(); err != nil {
+		t.Fatalf("failed to clear: %v", err)
+	}
+	if stats, err := store.Stats(); err != nil || stats.Entries != 0 {
+		t.Errorf("expected an empty store after clearing, got %+v, %v", stats, err)
+	}
+}
+
+func TestProviderCache_PersistentStore(t *testing.T) {
+	store := newTestPersistentStore(t)
+	ctx := testPersistentContext()
+
+	first := NewProviderCache()
+	defer first.Close()
+	first.SetPersistentStore(store)
+	response := &types.CommandResponse{Command: "ls -la", Confidence: 0.9, Usage: types.TokenUsage{InputTokens: 100}}
+	if err := first.SetCommandResponse("list files", ctx, "openai", "gpt-4o", response); err != nil {
+		t.Fatalf("failed to cache response: %v", err)
+	}
+
+	// A provider cache in a later run starts empty in memory
+	second := NewProviderCache()
+	defer second.Close()
+	second.SetPersistentStore(store)
+	cached, exists := second.GetCommandResponse("list files", ctx, "openai", "gpt-4o")
+	if !exists || cached.Command != "ls -la" {
+		t.Fatalf("expected the persisted response, got %+v, %v", cached, exists)
+	}
+	if cached.Usage.Total() != 0 {
+		t.Errorf("expected a persisted response to use no tokens, got %+v", cached.Usage)
+	}
+}
@@ -10,7 +10,8 @@ import (
 
 // ProviderCache manages caching for LLM provider responses
 type ProviderCache struct {
-	cache *Cache
+	cache      *Cache
+	persistent *PersistentStore // Keeps command responses between runs; nil when disabled
 }
 
 // NewProviderCache creates a new provider cache
@@ -34,7 +35,14 @@ func NewProviderCacheWithConfig(config *CacheConfig) *ProviderCache {
 	}
 }
 
-// GetCommandResponse retrieves cached command generation response
+// SetPersistentStore makes command responses outlive the process by reading
+// and writing them through store
+func (pc *ProviderCache) SetPersistentStore(store *PersistentStore) {
+	pc.persistent = store
+}
+
+// GetCommandResponse retrieves cached command generation response, falling
+// back to the persistent store when one is set
 func (pc *ProviderCache) GetCommandResponse(prompt string, ctx *types.Context, provider, model string) (*types.CommandResponse, bool) {
 	key := pc.commandResponseCacheKey(prompt, ctx, provider, model)
 
@@ -44,6 +52,13 @@ func (pc *ProviderCache) GetCommandResponse(prompt string, ctx *types.Context, p
 		}
 	}
 
+	if pc.persistent != nil {
+		if response, exists := pc.persistent.GetCommandResponse(prompt, ctx, provider, model); exists {
+			pc.cache.SetWithTTL(key, response, 60*time.Minute)
+			return response, true
+		}
+	}
+
 	return nil, false
 }
 
@@ -58,7 +73,23 @@ func (pc *ProviderCache) SetCommandResponse(prompt string, ctx *types.Context, p
 		ttl = 15 * time.Minute
 	}
 
-	return pc.cache.SetWithTTL(key, response, ttl)
+	// A cached response costs no tokens when it is used again
+	cached := *response
+	cached.Usage = types.TokenUsage{}
+	if err := pc.cache.SetWithTTL(key, &cached, ttl); err != nil {
+		return err
+	}
+
+	if pc.persistent != nil {
+		// Confident responses are kept for the store's TTL, the rest no longer
+		// than they are kept in memory
+		persistentTTL := time.Duration(0)
+		if response.Confidence < 0.7 {
+			persistentTTL = ttl
+		}
+		return pc.persistent.SetCommandResponse(prompt, ctx, provider, model, &cached, persistentTTL)
+	}
+	return nil
 }
 
 // GetValidationResponse retrieves cached validation response
@@ -85,7 +116,10 @@ func (pc *ProviderCache) SetValidationResponse(command, output, intent, provider
 		ttl = 10 * time.Minute
 	}
 
-	return pc.cache.SetWithTTL(key, response, ttl)
+	// A cached response costs no tokens when it is used again
+	cached := *response
+	cached.Usage = types.TokenUsage{}
+	return pc.cache.SetWithTTL(key, &cached, ttl)
 }
 
 // InvalidateProvider invalidates all cache entries for a specific provider
@@ -0,0 +1,499 @@
+package cli
+
+import (
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/config"
+	"github.com/kanishka-sahoo/nl-to-shell/internal/manager"
+	"github.com/kanishka-sahoo/nl-to-shell/internal/safety"
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+// Credential store entry holding the audit log HMAC key
+const (
+	auditKeyService = "audit"
+	auditKeyAccount = "hmac_key"
+)
+
+// Audit command flags
+var (
+	auditSince   string
+	auditUntil   string
+	auditUser    string
+	auditSession string
+	auditAction  string
+	auditLevel   string
+	auditLimit   int
+	auditFormat  string
+	auditOutput  string
+)
+
+// auditCmd represents the audit command
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Inspect the command audit log",
+	Long: `Inspect the audit log of generated, confirmed, bypassed, blocked and executed commands.
+Entries are recorded with the session ID, user and exit code of each command.`,
+}
+
+// auditListCmd represents the audit list command
+var auditListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List audit log entries",
+	Long:  `List audit log entries, oldest first, optionally filtered by time, user, session, action or danger level.`,
+	Example: `  # Show commands executed in the last day
+  nl-to-shell audit list --since 24h --action executed
+
+  # Show bypassed commands of a user
+  nl-to-shell audit list --user alice --action bypassed`,
+	Args: cobra.NoArgs,
+	RunE: executeAuditList,
+}
+
+// auditShowCmd represents the audit show command
+var auditShowCmd = &cobra.Command{
+	Use:   "show <command-id>",
+	Short: "Show the audit trail of a command",
+	Long:  `Show every audit log entry recorded for a command, from generation to execution.`,
+	Args:  cobra.ExactArgs(1),
+	RunE:  executeAuditShow,
+}
+
+// auditVerifyCmd represents the audit verify command
+var auditVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Verify the audit log hash chain",
+	Long: `Verify the hash chain linking audit log entries, across rotated files.
+Reports entries that were edited, deleted, inserted or reordered, and entries
+removed from the end of the log according to the anchor kept next to it. Keyed
+entries and the anchor are checked with the audit key from the credential store.`,
+	Args: cobra.NoArgs,
+	RunE: executeAuditVerify,
+}
+
+// auditKeygenCmd represents the audit keygen command
+var auditKeygenCmd = &cobra.Command{
+	Use:   "keygen",
+	Short: "Generate the audit log HMAC key",
+	Long: `Generate a random secret, stored in the credential store, that keys the audit
+log hash chain with HMAC-SHA256 so entries cannot be rewritten without it.`,
+	Args: cobra.NoArgs,
+	RunE: executeAuditKeygen,
+}
+
+// auditExportCmd represents the audit export command
+var auditExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export audit log entries",
+	Long:  `Export audit log entries as JSON or CSV, using the same filters as 'audit list'.`,
+	Example: `  # Export this week's audit log as CSV
+  nl-to-shell audit export --since 168h --format csv --output audit.csv`,
+	Args: cobra.NoArgs,
+	RunE: executeAuditExport,
+}
+
+func init() {
+	auditCmd.AddCommand(auditListCmd)
+	auditCmd.AddCommand(auditShowCmd)
+	auditCmd.AddCommand(auditExportCmd)
+	auditCmd.AddCommand(auditVerifyCmd)
+	auditCmd.AddCommand(auditKeygenCmd)
+
+	for _, cmd := range []*cobra.Command{auditListCmd, auditExportCmd} {
+		cmd.Flags().StringVar(&auditSince, "since", "", "Only entries at or after this time (RFC 3339 or a duration such as 24h)")
+		cmd.Flags().StringVar(&auditUntil, "until", "", "Only entries at or before this time (RFC 3339 or a duration such as 1h)")
+		cmd.Flags().StringVar(&auditUser, "user", "", "Only entries of this user")
+		cmd.Flags().StringVar(&auditSession, "session", "", "Only entries of this session")
+		cmd.Flags().StringVar(&auditAction, "action", "", "Only entries with this action (generated, confirmed, bypassed, blocked, executed, validated)")
+		cmd.Flags().StringVar(&auditLevel, "level", "", "Only entries with this danger level (safe, warning, dangerous, critical)")
+	}
+	auditListCmd.Flags().IntVar(&auditLimit, "limit", 0, "Show only the most recent N entries")
+	auditExportCmd.Flags().StringVar(&auditFormat, "format", "json", "Export format (json, csv)")
+	auditExportCmd.Flags().StringVarP(&auditOutput, "output", "o", "", "Write to a file instead of stdout")
+	auditKeygenCmd.Flags().Bool("force", false, "Replace an existing key (entries keyed with it can no longer be verified)")
+}
+
+// auditLogPath returns the location of the audit log
+func auditLogPath() string {
+	return filepath.Join(config.DefaultConfigDirectory(), safety.AuditLogFileName)
+}
+
+// newAuditLogger opens the audit log in the configuration directory, keyed
+// with the audit key when one is stored
+func newAuditLogger() (*safety.FileAuditLogger, error) {
+	return safety.NewFileAuditLoggerWithOptions(auditLogPath(), &safety.AuditLogOptions{
+		HMACKey: auditKey(),
+		MaxSize: safety.DefaultAuditLogMaxSize,
+	})
+}
+
+// auditKey returns the audit log HMAC key from the credential store, or nil
+func auditKey() []byte {
+	secret, err := config.NewCredentialManager(config.DefaultConfigDirectory()).Retrieve(auditKeyService, auditKeyAccount)
+	if err != nil || secret == "" {
+		return nil
+	}
+	if key, err := hex.DecodeString(secret); err == nil {
+		return key
+	}
+	return []byte(secret)
+}
+
+// enableAuditing records the manager's commands in the audit log under sessionID.
+// Commands still run when the log cannot be opened.
+func enableAuditing(commandManager *manager.Manager, sessionID string) {
+	logger, err := newAuditLogger()
+	if err != nil {
+		globalLogger.LogError(&types.NLShellError{
+			Type:      types.ErrTypeConfiguration,
+			Message:   "failed to open audit log",
+			Cause:     err,
+			Severity:  types.SeverityWarning,
+			Timestamp: time.Now(),
+		})
+		if verbose {
+			fmt.Fprintf(os.Stderr, "Warning: Could not open audit log: %v\n", err)
+		}
+		return
+	}
+	commandManager.SetAuditLogger(logger, sessionID, currentUserID())
+}
+
+// newSessionID returns an identifier grouping the audit entries of one run or session
+func newSessionID() string {
+	return fmt.Sprintf("session_%d", time.Now().UnixNano())
+}
+
+// currentUserID identifies the user commands are audited under
+func currentUserID() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return os.Getenv("USERNAME")
+}
+
+// auditFilterFromFlags builds an audit filter from the audit command flags
+func auditFilterFromFlags(now time.Time) (*types.AuditFilter, error) {
+	filter := &types.AuditFilter{
+		UserID:    auditUser,
+		SessionID: auditSession,
+	}
+
+	if auditSince != "" {
+		since, err := parseAuditTime(auditSince, now)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --since: %w", err)
+		}
+		filter.StartTime = &since
+	}
+	if auditUntil != "" {
+		until, err := parseAuditTime(auditUntil, now)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --until: %w", err)
+		}
+		filter.EndTime = &until
+	}
+	if auditAction != "" {
+		action, err := safety.ParseAuditAction(auditAction)
+		if err != nil {
+			return nil, err
+		}
+		filter.Action = &action
+	}
+	if auditLevel != "" {
+		level, err := safety.ParseDangerLevel(auditLevel)
+		if err != nil {
+			return nil, err
+		}
+		filter.DangerLevel = &level
+	}
+
+	return filter, nil
+}
+
+// parseAuditTime accepts an RFC 3339 timestamp, a date, or a duration before now
+func parseAuditTime(value string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("expected an RFC 3339 time, a date or a duration, got %q", value)
+}
+
+// readAuditLog returns the audit log entries matching filter
+func readAuditLog(filter *types.AuditFilter) ([]*types.AuditEntry, error) {
+	logger, err := newAuditLogger()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := logger.GetAuditLog(filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	return entries, nil
+}
+
+// executeAuditList handles the audit list command
+func executeAuditList(cmd *cobra.Command, args []string) error {
+	filter, err := auditFilterFromFlags(time.Now())
+	if err != nil {
+		return err
+	}
+	entries, err := readAuditLog(filter)
+	if err != nil {
+		return err
+	}
+
+	if len(entries) == 0 {
+		fmt.Fprintln(cmd.OutOrStdout(), "No audit entries found.")
+		return nil
+	}
+	if auditLimit > 0 && len(entries) > auditLimit {
+		entries = entries[len(entries)-auditLimit:]
+	}
+
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tACTION\tLEVEL\tUSER\tSESSION\tEXIT\tCOMMAND ID\tCOMMAND")
+	for _, entry := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			entry.Timestamp.Local().Format("2006-01-02 15:04:05"),
+			entry.Action, entry.DangerLevel, entry.UserID, entry.SessionID,
+			formatExitCode(entry.ExitCode), entry.CommandID, entry.Command)
+	}
+	return w.Flush()
+}
+
+// executeAuditShow handles the audit show command
+func executeAuditShow(cmd *cobra.Command, args []string) error {
+	entries, err := readAuditLog(nil)
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	found := false
+	for _, entry := range entries {
+		if entry.CommandID != args[0] {
+			continue
+		}
+		if !found {
+			fmt.Fprintf(out, "Command ID: %s\n", entry.CommandID)
+			fmt.Fprintf(out, "Command: %s\n", entry.Command)
+			fmt.Fprintf(out, "User: %s\n", entry.UserID)
+			fmt.Fprintf(out, "Session: %s\n", entry.SessionID)
+			fmt.Fprintln(out, "\nTrail:")
+			found = true
+		}
+		fmt.Fprintf(out, "  %s  %-9s  %s", entry.Timestamp.Local().Format(time.RFC3339), entry.Action, entry.DangerLevel)
+		if entry.ExitCode != nil {
+			fmt.Fprintf(out, "  exit code %d", *entry.ExitCode)
+		}
+		if entry.Reason != "" {
+			fmt.Fprintf(out, "  (%s)", entry.Reason)
+		}
+		fmt.Fprintln(out)
+	}
+
+	if !found {
+		return fmt.Errorf("no audit entries found for command %q", args[0])
+	}
+	return nil
+}
+
+// executeAuditExport handles the audit export command
+func executeAuditExport(cmd *cobra.Command, args []string) error {
+	filter, err := auditFilterFromFlags(time.Now())
+	if err != nil {
+		return err
+	}
+	entries, err := readAuditLog(filter)
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	if auditOutput != "" {
+		file, err := os.OpenFile(auditOutput, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		if err != nil {
+			return fmt.Errorf("failed to create export file: %w", err)
+		}
+		defer file.Close()
+		out = file
+	}
+
+	switch strings.ToLower(auditFormat) {
+	case "json":
+		return writeAuditJSON(out, entries)
+	case "csv":
+		return writeAuditCSV(out, entries)
+	default:
+		return fmt.Errorf("unsupported export format %q (use json or csv)", auditFormat)
+	}
+}
+
+// executeAuditVerify handles the audit verify command
+func executeAuditVerify(cmd *cobra.Command, args []string) error {
+	logger, err := newAuditLogger()
+	if err != nil {
+		return err
+	}
+	result, err := logger.Verify()
+	if err != nil {
+		return fmt.Errorf("failed to verify audit log: %w", err)
+	}
+
+	out := cmd.OutOrStdout()
+	fmt.Fprintf(out, "Files checked: %d\n", len(result.Files))
+	fmt.Fprintf(out, "Chained entries: %d\n", result.Entries)
+	if result.Unchained > 0 {
+		fmt.Fprintf(out, "Entries written before chaining: %d\n", result.Unchained)
+	}
+	fmt.Fprintf(out, "HMAC-keyed: %v\n", result.Keyed)
+	if result.HeadHash != "" {
+		fmt.Fprintf(out, "Head hash: %s\n", result.HeadHash)
+		switch {
+		case !result.Anchored:
+			fmt.Fprintln(out, "Note: the log has no anchor, so entries removed from its end cannot be detected; keep a copy of the head hash elsewhere to check it")
+		case !result.Keyed:
+			fmt.Fprintln(out, "Note: without an audit key, entries removed from the end of the log go undetected if the anchor is rewritten too; keep a copy of the head hash elsewhere to check it")
+		}
+	}
+
+	if result.Valid() {
+		fmt.Fprintln(out, "✅ Audit log chain is intact")
+		return nil
+	}
+
+	fmt.Fprintln(out, "❌ Audit log chain is broken:")
+	for _, problem := range result.Problems {
+		if problem.Line > 0 {
+			fmt.Fprintf(out, "  - %s:%d: %s\n", problem.File, problem.Line, problem.Description)
+		} else {
+			fmt.Fprintf(out, "  - %s: %s\n", problem.File, problem.Description)
+		}
+	}
+	return fmt.Errorf("audit log verification failed with %d problem(s)", len(result.Problems))
+}
+
+// executeAuditKeygen handles the audit keygen command
+func executeAuditKeygen(cmd *cobra.Command, args []string) error {
+	force, _ := cmd.Flags().GetBool("force")
+	if auditKey() != nil && !force {
+		return fmt.Errorf("an audit key already exists; use --force to replace it")
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return fmt.Errorf("failed to generate audit key: %w", err)
+	}
+	if err := config.NewCredentialManager(config.DefaultConfigDirectory()).Store(auditKeyService, auditKeyAccount, hex.EncodeToString(key)); err != nil {
+		return fmt.Errorf("failed to store audit key: %w", err)
+	}
+
+	// Entries written so far stay unkeyed; any unkeyed entry after them is reported
+	logger, err := safety.NewFileAuditLoggerWithOptions(auditLogPath(), &safety.AuditLogOptions{
+		HMACKey: key,
+		MaxSize: safety.DefaultAuditLogMaxSize,
+	})
+	if err == nil {
+		err = logger.BeginKeyedChain()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record the start of the keyed audit chain: %w", err)
+	}
+
+	fmt.Fprintln(cmd.OutOrStdout(), "✅ Audit key stored; new audit entries will be HMAC-keyed")
+	return nil
+}
+
+// auditRecord is the exported form of an audit entry, with names instead of enum values
+type auditRecord struct {
+	Timestamp   time.Time `json:"timestamp"`
+	Action      string    `json:"action"`
+	DangerLevel string    `json:"danger_level"`
+	UserID      string    `json:"user_id"`
+	SessionID   string    `json:"session_id"`
+	CommandID   string    `json:"command_id"`
+	Command     string    `json:"command"`
+	ExitCode    *int      `json:"exit_code"`
+	Reason      string    `json:"reason,omitempty"`
+	SourceIP    string    `json:"source_ip,omitempty"`
+}
+
+// writeAuditJSON writes entries as an indented JSON array
+func writeAuditJSON(w io.Writer, entries []*types.AuditEntry) error {
+	records := make([]auditRecord, 0, len(entries))
+	for _, entry := range entries {
+		records = append(records, auditRecord{
+			Timestamp:   entry.Timestamp,
+			Action:      entry.Action.String(),
+			DangerLevel: entry.DangerLevel.String(),
+			UserID:      entry.UserID,
+			SessionID:   entry.SessionID,
+			CommandID:   entry.CommandID,
+			Command:     entry.Command,
+			ExitCode:    entry.ExitCode,
+			Reason:      entry.Reason,
+			SourceIP:    entry.SourceIP,
+		})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(records)
+}
+
+// writeAuditCSV writes entries as CSV with a header row
+func writeAuditCSV(w io.Writer, entries []*types.AuditEntry) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"timestamp", "action", "danger_level", "user_id", "session_id", "command_id", "command", "exit_code", "reason"}); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := writer.Write([]string{
+			entry.Timestamp.Format(time.RFC3339Nano),
+			entry.Action.String(),
+			entry.DangerLevel.String(),
+			entry.UserID,
+			entry.SessionID,
+			entry.CommandID,
+			entry.Command,
+			formatExitCode(entry.ExitCode),
+			entry.Reason,
+		}); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// formatExitCode renders an optional exit code, empty for commands that did not run
+func formatExitCode(code *int) string {
+	if code == nil {
+		return ""
+	}
+	return strconv.Itoa(*code)
+}
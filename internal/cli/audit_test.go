@@ -0,0 +1,130 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+func TestParseAuditTime(t *testing.T) {
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		value    string
+		expected time.Time
+		wantErr  bool
+	}{
+		{"duration", "24h", now.Add(-24 * time.Hour), false},
+		{"rfc3339", "2024-05-01T08:30:00Z", time.Date(2024, 5, 1, 8, 30, 0, 0, time.UTC), false},
+		{"date", "2024-05-01", time.Date(2024, 5, 1, 0, 0, 0, 0, time.Local), false},
+		{"invalid", "last tuesday", time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseAuditTime(tt.value, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseAuditTime(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if !got.Equal(tt.expected) {
+				t.Errorf("parseAuditTime(%q) = %v, want %v", tt.value, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestAuditCommands(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("HOME", dir)
+	t.Setenv("APPDATA", dir)
+
+	logger, err := newAuditLogger()
+	if err != nil {
+		t.Fatalf("failed to create audit logger: %v", err)
+	}
+	exitCode := 1
+	now := time.Now()
+	entries := []*types.AuditEntry{
+		{Timestamp: now.Add(-2 * time.Hour), Command: "rm -rf build", UserID: "alice", SessionID: "s1", CommandID: "cmd_1", Action: types.AuditActionGenerated, DangerLevel: types.Dangerous},
+		{Timestamp: now.Add(-2 * time.Hour), Command: "rm -rf build", UserID: "alice", SessionID: "s1", CommandID: "cmd_1", Action: types.AuditActionConfirmed, DangerLevel: types.Dangerous},
+		{Timestamp: now.Add(-time.Hour), Command: "rm -rf build", UserID: "alice", SessionID: "s1", CommandID: "cmd_1", Action: types.AuditActionExecuted, DangerLevel: types.Dangerous, ExitCode: &exitCode},
+		{Timestamp: now, Command: "ls", UserID: "bob", SessionID: "s2", CommandID: "cmd_2", Action: types.AuditActionGenerated, DangerLevel: types.Safe},
+	}
+	for _, entry := range entries {
+		if err := logger.LogAuditEvent(entry); err != nil {
+			t.Fatalf("failed to log audit event: %v", err)
+		}
+	}
+
+	run := func(t *testing.T, fn func(*cobra.Command, []string) error, args []string, setFlags func()) string {
+		t.Helper()
+		defer func() {
+			auditSince, auditUntil, auditUser, auditSession, auditAction, auditLevel = "", "", "", "", "", ""
+			auditFormat, auditOutput, auditLimit = "json", "", 0
+		}()
+		if setFlags != nil {
+			setFlags()
+		}
+
+		var out bytes.Buffer
+		cmd := &cobra.Command{}
+		cmd.SetOut(&out)
+		if err := fn(cmd, args); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return out.String()
+	}
+
+	t.Run("list filters by user and action", func(t *testing.T) {
+		out := run(t, executeAuditList, nil, func() { auditUser, auditAction = "alice", "executed" })
+		if !strings.Contains(out, "cmd_1") || !strings.Contains(out, "Executed") {
+			t.Errorf("expected the executed entry, got:\n%s", out)
+		}
+		if strings.Contains(out, "Generated") || strings.Contains(out, "bob") {
+			t.Errorf("expected other entries to be filtered out, got:\n%s", out)
+		}
+	})
+
+	t.Run("show prints the trail of a command", func(t *testing.T) {
+		out := run(t, executeAuditShow, []string{"cmd_1"}, nil)
+		for _, want := range []string{"Generated", "Confirmed", "Executed", "exit code 1"} {
+			if !strings.Contains(out, want) {
+				t.Errorf("expected %q in output, got:\n%s", want, out)
+			}
+		}
+	})
+
+	t.Run("export json since", func(t *testing.T) {
+		out := run(t, executeAuditExport, nil, func() { auditSince = "90m" })
+		var records []auditRecord
+		if err := json.Unmarshal([]byte(out), &records); err != nil {
+			t.Fatalf("invalid JSON export: %v\n%s", err, out)
+		}
+		if len(records) != 2 {
+			t.Fatalf("expected 2 records, got %d", len(records))
+		}
+		if records[0].Action != "Executed" || records[0].ExitCode == nil || *records[0].ExitCode != 1 {
+			t.Errorf("unexpected first record: %+v", records[0])
+		}
+	})
+
+	t.Run("export csv by session", func(t *testing.T) {
+		out := run(t, executeAuditExport, nil, func() { auditFormat, auditSession = "csv", "s2" })
+		rows, err := csv.NewReader(strings.NewReader(out)).ReadAll()
+		if err != nil {
+			t.Fatalf("invalid CSV export: %v", err)
+		}
+		if len(rows) != 2 || rows[1][5] != "cmd_2" || rows[1][7] != "" {
+			t.Errorf("unexpected CSV rows: %v", rows)
+		}
+	})
+}
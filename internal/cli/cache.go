@@ -0,0 +1,194 @@
+package cli
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/cache"
+	"github.com/kanishka-sahoo/nl-to-shell/internal/config"
+	"github.com/kanishka-sahoo/nl-to-shell/internal/errors"
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+// Credential store entry holding the response cache encryption key
+const (
+	cacheKeyService = "cache"
+	cacheKeyAccount = "encryption_key"
+)
+
+// cacheCmd represents the cache command
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Inspect or clear the response cache",
+	Long: `Generated commands are cached on disk, encrypted with a key kept in the
+credential store, so asking for the same thing again in the same directory does
+not call the provider. A cached command is reused until it expires, and every
+command cached for a directory is dropped once the directory's file listing or
+git state changes. Set UserPreferences.ResponseCache.Disabled in the
+configuration to keep generated commands in memory only.`,
+}
+
+// cacheStatsCmd represents the cache stats command
+var cacheStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show response cache statistics",
+	Args:  cobra.NoArgs,
+	RunE:  executeCacheStats,
+}
+
+// cacheClearCmd represents the cache clear command
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Remove every cached response",
+	Args:  cobra.NoArgs,
+	RunE:  executeCacheClear,
+}
+
+func init() {
+	cacheCmd.AddCommand(cacheStatsCmd)
+	cacheCmd.AddCommand(cacheClearCmd)
+}
+
+// executeCacheStats handles the cache stats command
+func executeCacheStats(cmd *cobra.Command, args []string) error {
+	out := cmd.OutOrStdout()
+	cfg := loadCacheConfig()
+	if cfg.UserPreferences.ResponseCache.Disabled {
+		fmt.Fprintln(out, "Response cache: disabled")
+	}
+
+	store, err := responseCacheStore(cfg, false)
+	if err != nil {
+		return err
+	}
+	if store == nil {
+		fmt.Fprintln(out, "No responses cached.")
+		return nil
+	}
+
+	stats, err := store.Stats()
+	if err != nil {
+		return fmt.Errorf("failed to read the response cache (run 'nl-to-shell cache clear' to reset it): %w", err)
+	}
+
+	fmt.Fprintf(out, "Cache file: %s\n", stats.Path)
+	fmt.Fprintf(out, "Entries: %d of %d (%d expired)\n", stats.Entries, stats.MaxEntries, stats.ExpiredEntries)
+	fmt.Fprintf(out, "Size: %s of %s (%s on disk)\n", formatBytes(stats.TotalSize), formatBytes(stats.MaxSize), formatBytes(stats.FileSize))
+	fmt.Fprintf(out, "TTL: %v\n", stats.TTL)
+	lookups := stats.Hits + stats.Misses
+	if lookups > 0 {
+		fmt.Fprintf(out, "Hits: %d of %d lookups (%.1f%%)\n", stats.Hits, lookups, float64(stats.Hits)/float64(lookups)*100)
+	} else {
+		fmt.Fprintln(out, "Hits: 0 of 0 lookups")
+	}
+	fmt.Fprintf(out, "Invalidated by directory changes: %d\n", stats.Invalidations)
+	if stats.Entries > 0 {
+		fmt.Fprintf(out, "Oldest: %s\n", stats.Oldest.Local().Format("2006-01-02 15:04:05"))
+		fmt.Fprintf(out, "Newest: %s\n", stats.Newest.Local().Format("2006-01-02 15:04:05"))
+	}
+	return nil
+}
+
+// executeCacheClear handles the cache clear command
+func executeCacheClear(cmd *cobra.Command, args []string) error {
+	store, err := responseCacheStore(loadCacheConfig(), false)
+	if err != nil {
+		return err
+	}
+	if store != nil {
+		err = store.Clear()
+	} else if removeErr := os.Remove(responseCachePath()); removeErr != nil && !os.IsNotExist(removeErr) {
+		// A cache left behind without its key can never be read
+		err = fmt.Errorf("failed to remove cache file: %w", removeErr)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(cmd.OutOrStdout(), "✅ Response cache cleared")
+	return nil
+}
+
+// loadCacheConfig returns the configuration, or the defaults when it cannot be read
+func loadCacheConfig() *types.Config {
+	cfg, err := config.NewManager().Load()
+	if err != nil {
+		return &types.Config{}
+	}
+	return cfg
+}
+
+// responseCachePath returns the file the response cache is kept in
+func responseCachePath() string {
+	return filepath.Join(config.DefaultConfigDirectory(), "responses.cache")
+}
+
+// openResponseCache returns the persistent response cache for the providers, or
+// nil when it is disabled. Commands are still generated when it cannot be opened.
+func openResponseCache(cfg *types.Config) *cache.PersistentStore {
+	if cfg.UserPreferences.ResponseCache.Disabled {
+		return nil
+	}
+	store, err := responseCacheStore(cfg, true)
+	if err != nil {
+		globalLogger.LogError(errors.NewConfigurationError("response cache unavailable", err))
+		return nil
+	}
+	return store
+}
+
+// responseCacheStore opens the response cache with the limits in cfg. Without
+// an encryption key nothing can have been cached, so it returns nil unless
+// create is set, in which case a key is generated and stored.
+func responseCacheStore(cfg *types.Config, create bool) (*cache.PersistentStore, error) {
+	credentials := config.NewCredentialManager(config.DefaultConfigDirectory())
+
+	var key []byte
+	if secret, err := credentials.Retrieve(cacheKeyService, cacheKeyAccount); err == nil && secret != "" {
+		key, _ = hex.DecodeString(secret)
+	}
+	if len(key) != cache.PersistentKeySize {
+		if !create {
+			return nil, nil
+		}
+		key = make([]byte, cache.PersistentKeySize)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("failed to generate cache key: %w", err)
+		}
+		if err := credentials.Store(cacheKeyService, cacheKeyAccount, hex.EncodeToString(key)); err != nil {
+			return nil, fmt.Errorf("failed to store cache key: %w", err)
+		}
+	}
+
+	storeConfig := cache.DefaultPersistentStoreConfig(responseCachePath(), key)
+	settings := cfg.UserPreferences.ResponseCache
+	if settings.TTL > 0 {
+		storeConfig.TTL = settings.TTL
+	}
+	if settings.MaxSize > 0 {
+		storeConfig.MaxSize = settings.MaxSize
+	}
+	if settings.MaxEntries > 0 {
+		storeConfig.MaxEntries = settings.MaxEntries
+	}
+	return cache.NewPersistentStore(storeConfig)
+}
+
+// formatBytes formats a size in bytes with a binary unit
+func formatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+func TestCacheStatsAndClear(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("HOME", dir)
+	t.Setenv("APPDATA", dir)
+
+	runCacheCommand := func(run func(*cobra.Command, []string) error) string {
+		t.Helper()
+		var out bytes.Buffer
+		cmd := &cobra.Command{}
+		cmd.SetOut(&out)
+		if err := run(cmd, nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return out.String()
+	}
+
+	if out := runCacheCommand(executeCacheStats); !strings.Contains(out, "No responses cached.") {
+		t.Errorf("expected an empty cache before any command was generated, got:\n%s", out)
+	}
+
+	if store := openResponseCache(&types.Config{UserPreferences: types.UserPreferences{ResponseCache: types.ResponseCacheSettings{Disabled: true}}}); store != nil {
+		t.Error("expected no response cache when it is disabled")
+	}
+
+	store := openResponseCache(&types.Config{})
+	if store == nil {
+		t.Fatal("expected the response cache to open")
+	}
+	ctx := &types.Context{WorkingDirectory: dir}
+	if err := store.SetCommandResponse("list files", ctx, "openai", "gpt-4o", &types.CommandResponse{Command: "ls"}, 0); err != nil {
+		t.Fatalf("failed to cache response: %v", err)
+	}
+	store.GetCommandResponse("list files", ctx, "openai", "gpt-4o")
+
+	out := runCacheCommand(executeCacheStats)
+	for _, want := range []string{"responses.cache", "Entries: 1 of 1000", "Hits: 1 of 1 lookups"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in the stats, got:\n%s", want, out)
+		}
+	}
+
+	if out := runCacheCommand(executeCacheClear); !strings.Contains(out, "Response cache cleared") {
+		t.Errorf("unexpected clear output:\n%s", out)
+	}
+	if out := runCacheCommand(executeCacheStats); !strings.Contains(out, "Entries: 0 of 1000") {
+		t.Errorf("expected no entries after clearing, got:\n%s", out)
+	}
+}
@@ -236,6 +236,69 @@ func TestDisplayResultsVerboseMode(t *testing.T) {
 	}
 }
 
+func TestDisplayResultsStreamedOutput(t *testing.T) {
+	oldStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	result := &types.FullResult{
+		CommandResult: &types.CommandResult{
+			Command: &types.Command{Generated: "echo streamed"},
+			Safety:  &types.SafetyResult{DangerLevel: types.Safe},
+		},
+		ExecutionResult: &types.ExecutionResult{
+			ExitCode: 0,
+			Stdout:   "streamed\n",
+			Success:  true,
+			Streamed: true,
+		},
+	}
+
+	err := displayResults(result, "print streamed")
+
+	w.Close()
+	os.Stdout = oldStdout
+
+	if err != nil {
+		t.Errorf("displayResults failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	buf.ReadFrom(r)
+	output := buf.String()
+
+	if !strings.Contains(output, "Exit code: 0") {
+		t.Errorf("Output should contain the exit code, got: %s", output)
+	}
+	if strings.Contains(output, "Output:") {
+		t.Errorf("Streamed output should not be printed again, got: %s", output)
+	}
+}
+
+func TestLiveOutputWriterPrintsHeaderOnce(t *testing.T) {
+	oldStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	stdout, _ := newLiveOutputWriters()
+	stdout.Write([]byte("one\n"))
+	stdout.Write([]byte("two\n"))
+
+	w.Close()
+	os.Stdout = oldStdout
+
+	var buf bytes.Buffer
+	buf.ReadFrom(r)
+	output := buf.String()
+
+	if strings.Count(output, "--- Live Output ---") != 1 {
+		t.Errorf("Expected the header exactly once, got: %s", output)
+	}
+	if !strings.Contains(output, "one\ntwo\n") {
+		t.Errorf("Expected output to be forwarded, got: %s", output)
+	}
+}
+
 func TestCreateLLMProviderSuccess(t *testing.T) {
 	cfg := &types.Config{
 		DefaultProvider: "openai",
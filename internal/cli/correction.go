@@ -0,0 +1,67 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+// enableAutoCorrection turns on the auto-correction loop when --auto-correct is set,
+// asking the user before each correction runs
+func enableAutoCorrection(options *types.ExecutionOptions) {
+	if autoCorrect <= 0 {
+		return
+	}
+	options.MaxCorrections = autoCorrect
+	options.ConfirmCorrection = confirmCorrection
+}
+
+// confirmCorrection shows a suggested correction with its safety check and asks
+// the user whether to run it
+func confirmCorrection(correction *types.CommandResult, validation *types.ValidationResult) bool {
+	fmt.Println("\n❌ Command may not have achieved the intended result")
+	if validation.Explanation != "" {
+		fmt.Printf("Explanation: %s\n", validation.Explanation)
+	}
+	fmt.Printf("🔧 Suggested correction: %s\n", correction.Command.Generated)
+
+	if correction.Safety != nil && correction.Safety.DangerLevel > types.Safe {
+		fmt.Printf("⚠️  Safety level: %s\n", correction.Safety.DangerLevel.String())
+		for _, warning := range correction.Safety.Warnings {
+			fmt.Printf("  - %s\n", warning)
+		}
+	}
+	fmt.Print("Run the correction? (y/N): ")
+
+	var response string
+	fmt.Scanln(&response)
+	response = strings.ToLower(strings.TrimSpace(response))
+	return response == "y" || response == "yes"
+}
+
+// displayAttempts lists the commands run for an auto-corrected request
+func displayAttempts(attempts []*types.ExecutionAttempt) {
+	if len(attempts) < 2 {
+		return
+	}
+
+	fmt.Println("\n--- Correction Attempts ---")
+	for i, attempt := range attempts {
+		status := "declined"
+		if attempt.ExecutionResult != nil {
+			status = fmt.Sprintf("exit code %d", attempt.ExecutionResult.ExitCode)
+			if stopped := stopReason(attempt.ExecutionResult); stopped != "" {
+				status = stopped
+			}
+			if attempt.ValidationResult != nil {
+				if attempt.ValidationResult.IsCorrect {
+					status += ", ✅"
+				} else {
+					status += ", ❌"
+				}
+			}
+		}
+		fmt.Printf("  %d. %s (%s)\n", i+1, attempt.CommandResult.Command.Generated, status)
+	}
+}
@@ -0,0 +1,408 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/config"
+	"github.com/kanishka-sahoo/nl-to-shell/internal/history"
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+	"github.com/kanishka-sahoo/nl-to-shell/internal/usage"
+)
+
+// History command flags
+var (
+	historySince     string
+	historySession   string
+	historyLimit     int
+	historyOlderThan string
+	historyKeep      int
+)
+
+// historyCmd represents the history command
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Browse and rerun previously generated commands",
+	Long: `Browse the history of generated commands, kept across runs in the configuration
+directory. Each entry records the request, the generated command, the provider and
+model, the working directory, the safety level, and the exit code, duration and
+validation verdict of executed commands.`,
+}
+
+// historyListCmd represents the history list command
+var historyListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List recent history entries",
+	Long:  `List history entries, oldest first, optionally filtered by time or session.`,
+	Example: `  # Show the last 20 commands
+  nl-to-shell history list
+
+  # Show every command from the last week
+  nl-to-shell history list --since 168h --limit 0`,
+	Args: cobra.NoArgs,
+	RunE: executeHistoryList,
+}
+
+// historySearchCmd represents the history search command
+var historySearchCmd = &cobra.Command{
+	Use:   "search <query>",
+	Short: "Search the history",
+	Long: `Search history entries whose request, command or working directory contain
+every word of the query, ignoring case.`,
+	Example: `  # Find the commands that dealt with docker images
+  nl-to-shell history search docker image`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: executeHistorySearch,
+}
+
+// historyShowCmd represents the history show command
+var historyShowCmd = &cobra.Command{
+	Use:   "show <id>",
+	Short: "Show a history entry",
+	Args:  cobra.ExactArgs(1),
+	RunE:  executeHistoryShow,
+}
+
+// historyRerunCmd represents the history rerun command
+var historyRerunCmd = &cobra.Command{
+	Use:   "rerun <id>",
+	Short: "Run a command from the history again",
+	Long: `Run a command from the history again in the current directory. The command is
+safety-checked again under the current policies before it runs, and
+requires confirmation like a newly generated command.`,
+	Example: `  # Preview a previous command
+  nl-to-shell history rerun 42 --dry-run`,
+	Args: cobra.ExactArgs(1),
+	RunE: executeHistoryRerun,
+}
+
+// historyPruneCmd represents the history prune command
+var historyPruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove old history entries",
+	Example: `  # Forget commands older than 30 days
+  nl-to-shell history prune --older-than 720h
+
+  # Keep only the 1000 most recent commands
+  nl-to-shell history prune --keep 1000`,
+	Args: cobra.NoArgs,
+	RunE: executeHistoryPrune,
+}
+
+func init() {
+	historyCmd.AddCommand(historyListCmd)
+	historyCmd.AddCommand(historySearchCmd)
+	historyCmd.AddCommand(historyShowCmd)
+	historyCmd.AddCommand(historyRerunCmd)
+	historyCmd.AddCommand(historyPruneCmd)
+
+	for _, cmd := range []*cobra.Command{historyListCmd, historySearchCmd} {
+		cmd.Flags().StringVar(&historySince, "since", "", "Only entries at or after this time (RFC 3339 or a duration such as 24h)")
+		cmd.Flags().StringVar(&historySession, "session", "", "Only entries of this session")
+		cmd.Flags().IntVar(&historyLimit, "limit", 20, "Show only the most recent N entries (0 for all)")
+	}
+	historyPruneCmd.Flags().StringVar(&historyOlderThan, "older-than", "", "Remove entries before this time (RFC 3339, a date or a duration such as 720h)")
+	historyPruneCmd.Flags().IntVar(&historyKeep, "keep", 0, "Remove all but the most recent N entries")
+}
+
+// newHistoryStore opens the command history in the configuration directory
+func newHistoryStore() (*history.FileStore, error) {
+	return history.NewFileStore(filepath.Join(config.DefaultConfigDirectory(), history.FileName))
+}
+
+// recordHistory adds the outcome of a command to the history, with the tokens
+// it used priced by prices. Failures are logged rather than returned so the
+// history never blocks a command.
+func recordHistory(result *types.FullResult, sessionID string, prices *usage.PriceTable) {
+	if result == nil {
+		return
+	}
+	// Each attempt of an auto-corrected command is recorded on its own
+	for _, attempt := range result.Attempts {
+		recordHistory(&types.FullResult{
+			CommandResult:    attempt.CommandResult,
+			ExecutionResult:  attempt.ExecutionResult,
+			ValidationResult: attempt.ValidationResult,
+		}, sessionID, prices)
+	}
+	if len(result.Attempts) > 0 || result.CommandResult == nil || result.CommandResult.Command == nil {
+		return
+	}
+
+	command := result.CommandResult.Command
+	entry := &types.HistoryEntry{
+		SessionID:  sessionID,
+		CommandID:  command.ID,
+		Input:      command.Original,
+		Command:    command.Generated,
+		Provider:   result.CommandResult.Provider,
+		Model:      result.CommandResult.Model,
+		WorkingDir: command.WorkingDir,
+		Usage:      result.CommandResult.Usage,
+	}
+	if result.CommandResult.Safety != nil {
+		entry.DangerLevel = result.CommandResult.Safety.DangerLevel
+	}
+	if result.ExecutionResult != nil {
+		entry.Executed = true
+		// A background job's exit code is recorded in the job once it finishes
+		if result.ExecutionResult.Job == nil {
+			exitCode := result.ExecutionResult.ExitCode
+			entry.ExitCode = &exitCode
+			entry.Duration = result.ExecutionResult.Duration
+		}
+	}
+	if result.ValidationResult != nil {
+		correct := result.ValidationResult.IsCorrect
+		entry.ResultCorrect = &correct
+		entry.Usage.Add(result.ValidationResult.Usage)
+	}
+	entry.Cost, _ = prices.Cost(entry.Provider, entry.Model, entry.Usage)
+
+	store, err := newHistoryStore()
+	if err == nil {
+		err = store.Add(entry)
+	}
+	if err != nil {
+		globalLogger.LogError(&types.NLShellError{
+			Type:      types.ErrTypeConfiguration,
+			Message:   "failed to record command history",
+			Cause:     err,
+			Severity:  types.SeverityWarning,
+			Timestamp: time.Now(),
+		})
+	}
+}
+
+// resultUsage returns the tokens used to generate a command and validate the
+// results of every attempt to run it
+func resultUsage(result *types.FullResult) types.TokenUsage {
+	if len(result.Attempts) > 0 {
+		var tokens types.TokenUsage
+		for _, attempt := range result.Attempts {
+			tokens.Add(resultUsage(&types.FullResult{
+				CommandResult:    attempt.CommandResult,
+				ValidationResult: attempt.ValidationResult,
+			}))
+		}
+		return tokens
+	}
+
+	var tokens types.TokenUsage
+	if result.CommandResult != nil {
+		tokens.Add(result.CommandResult.Usage)
+	}
+	if result.ValidationResult != nil {
+		tokens.Add(result.ValidationResult.Usage)
+	}
+	return tokens
+}
+
+// historyFilterFromFlags builds a history filter from the history command flags
+func historyFilterFromFlags(query string, now time.Time) (*types.HistoryFilter, error) {
+	filter := &types.HistoryFilter{
+		Query:     query,
+		SessionID: historySession,
+		Limit:     historyLimit,
+	}
+	if historySince != "" {
+		since, err := parseAuditTime(historySince, now)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --since: %w", err)
+		}
+		filter.Since = &since
+	}
+	return filter, nil
+}
+
+// executeHistoryList handles the history list command
+func executeHistoryList(cmd *cobra.Command, args []string) error {
+	return listHistory(cmd, "")
+}
+
+// executeHistorySearch handles the history search command
+func executeHistorySearch(cmd *cobra.Command, args []string) error {
+	return listHistory(cmd, strings.Join(args, " "))
+}
+
+// listHistory prints the history entries matching query and the flags
+func listHistory(cmd *cobra.Command, query string) error {
+	filter, err := historyFilterFromFlags(query, time.Now())
+	if err != nil {
+		return err
+	}
+	store, err := newHistoryStore()
+	if err != nil {
+		return err
+	}
+	entries, err := store.List(filter)
+	if err != nil {
+		return fmt.Errorf("failed to read command history: %w", err)
+	}
+
+	if len(entries) == 0 {
+		fmt.Fprintln(cmd.OutOrStdout(), "No history entries found.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tTIME\tLEVEL\tEXIT\tREQUEST\tCOMMAND")
+	for _, entry := range entries {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n",
+			entry.ID, entry.Timestamp.Local().Format("2006-01-02 15:04:05"), entry.DangerLevel,
+			formatExitCode(entry.ExitCode), truncateRequest(entry.Input, 40), entry.Command)
+	}
+	return w.Flush()
+}
+
+// truncateRequest shortens a request to at most max characters for a table cell
+func truncateRequest(request string, max int) string {
+	runes := []rune(request)
+	if len(runes) <= max {
+		return request
+	}
+	return string(runes[:max-3]) + "..."
+}
+
+// getHistoryEntry returns the history entry whose ID is given as an argument
+func getHistoryEntry(arg string) (*types.HistoryEntry, error) {
+	id, err := strconv.Atoi(arg)
+	if err != nil || id <= 0 {
+		return nil, fmt.Errorf("invalid history ID %q", arg)
+	}
+	store, err := newHistoryStore()
+	if err != nil {
+		return nil, err
+	}
+	return store.Get(id)
+}
+
+// executeHistoryShow handles the history show command
+func executeHistoryShow(cmd *cobra.Command, args []string) error {
+	entry, err := getHistoryEntry(args[0])
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	fmt.Fprintf(out, "ID: %d\n", entry.ID)
+	fmt.Fprintf(out, "Time: %s\n", entry.Timestamp.Local().Format(time.RFC3339))
+	fmt.Fprintf(out, "Request: %s\n", entry.Input)
+	fmt.Fprintf(out, "Command: %s\n", entry.Command)
+	if entry.Provider != "" {
+		providerName := entry.Provider
+		if entry.Model != "" {
+			providerName += " (" + entry.Model + ")"
+		}
+		fmt.Fprintf(out, "Provider: %s\n", providerName)
+	}
+	if entry.Usage.Total() > 0 {
+		fmt.Fprintf(out, "Tokens: %d input, %d output (%s)\n", entry.Usage.InputTokens, entry.Usage.OutputTokens, usage.FormatCost(entry.Cost))
+	}
+	fmt.Fprintf(out, "Working directory: %s\n", entry.WorkingDir)
+	fmt.Fprintf(out, "Safety level: %s\n", entry.DangerLevel)
+	fmt.Fprintf(out, "Session: %s\n", entry.SessionID)
+	fmt.Fprintf(out, "Command ID: %s\n", entry.CommandID)
+
+	if !entry.Executed {
+		fmt.Fprintln(out, "Executed: no")
+		return nil
+	}
+	fmt.Fprintf(out, "Exit code: %s\n", formatExitCode(entry.ExitCode))
+	fmt.Fprintf(out, "Duration: %v\n", entry.Duration)
+	if entry.ResultCorrect != nil {
+		verdict := "❌ may not have achieved the intended result"
+		if *entry.ResultCorrect {
+			verdict = "✅ achieved the intended result"
+		}
+		fmt.Fprintf(out, "Validation: %s\n", verdict)
+	}
+	return nil
+}
+
+// executeHistoryRerun handles the history rerun command
+func executeHistoryRerun(cmd *cobra.Command, args []string) error {
+	entry, err := getHistoryEntry(args[0])
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadCommandConfig()
+	if err != nil {
+		return err
+	}
+
+	// The provider is only used to validate the result
+	commandManager, err := newCommandManager(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create LLM provider: %w", err)
+	}
+	sessionID := newSessionID()
+	enableAuditing(commandManager, sessionID)
+	enableJobs(commandManager, cfg)
+
+	ctx := context.Background()
+	commandResult, err := commandManager.PrepareCommand(ctx, entry.Input, entry.Command)
+	if err != nil {
+		return fmt.Errorf("failed to prepare command: %w", err)
+	}
+	if commandResult.Command.WorkingDir != entry.WorkingDir && entry.WorkingDir != "" {
+		fmt.Fprintf(os.Stderr, "Note: this command was first run in %s\n", entry.WorkingDir)
+	}
+
+	options := &types.ExecutionOptions{
+		DryRun:           dryRun,
+		SkipConfirmation: skipConfirmation,
+		ValidateResults:  validateResults,
+		Timeout:          cfg.UserPreferences.DefaultTimeout,
+		Background:       background,
+	}
+	enableAutoCorrection(options)
+	enableSandbox(options, cfg)
+	if streamOutput {
+		options.Stdout, options.Stderr = newLiveOutputWriters()
+	}
+
+	result, err := commandManager.ExecuteResult(ctx, commandResult, entry.Input, options)
+	if err != nil {
+		return fmt.Errorf("command execution failed: %w", err)
+	}
+	recordHistory(result, sessionID, usage.NewPriceTable(cfg.Prices))
+	return displayResults(result, entry.Input)
+}
+
+// executeHistoryPrune handles the history prune command
+func executeHistoryPrune(cmd *cobra.Command, args []string) error {
+	if historyOlderThan == "" && historyKeep <= 0 {
+		return fmt.Errorf("specify --older-than, --keep or both")
+	}
+
+	opts := history.PruneOptions{Keep: historyKeep}
+	if historyOlderThan != "" {
+		before, err := parseAuditTime(historyOlderThan, time.Now())
+		if err != nil {
+			return fmt.Errorf("invalid --older-than: %w", err)
+		}
+		opts.Before = &before
+	}
+
+	store, err := newHistoryStore()
+	if err != nil {
+		return err
+	}
+	removed, err := store.Prune(opts)
+	if err != nil {
+		return fmt.Errorf("failed to prune command history: %w", err)
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "✅ Removed %d history entries\n", removed)
+	return nil
+}
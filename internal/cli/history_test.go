@@ -0,0 +1,110 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+	"github.com/kanishka-sahoo/nl-to-shell/internal/usage"
+)
+
+func TestHistoryCommands(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("HOME", dir)
+	t.Setenv("APPDATA", dir)
+
+	recordHistory(&types.FullResult{
+		CommandResult: &types.CommandResult{
+			Command:  &types.Command{ID: "cmd_1", Original: "list docker containers", Generated: "docker ps", WorkingDir: "/srv"},
+			Safety:   &types.SafetyResult{DangerLevel: types.Safe},
+			Provider: "openai",
+			Model:    "gpt-4o",
+		},
+		ExecutionResult:  &types.ExecutionResult{ExitCode: 0, Duration: 120 * time.Millisecond},
+		ValidationResult: &types.ValidationResult{IsCorrect: true},
+	}, "s1", usage.NewPriceTable(nil))
+	recordHistory(&types.FullResult{
+		CommandResult: &types.CommandResult{
+			Command: &types.Command{ID: "cmd_2", Original: "delete build output", Generated: "rm -rf build", WorkingDir: "/srv"},
+			Safety:  &types.SafetyResult{DangerLevel: types.Dangerous, RequiresConfirmation: true},
+		},
+		RequiresConfirmation: true,
+	}, "s1", usage.NewPriceTable(nil))
+
+	run := func(t *testing.T, fn func(*cobra.Command, []string) error, args []string, setFlags func()) (string, error) {
+		t.Helper()
+		defer func() {
+			historySince, historySession, historyOlderThan = "", "", ""
+			historyLimit, historyKeep = 20, 0
+		}()
+		if setFlags != nil {
+			setFlags()
+		}
+
+		var out bytes.Buffer
+		cmd := &cobra.Command{}
+		cmd.SetOut(&out)
+		err := fn(cmd, args)
+		return out.String(), err
+	}
+
+	t.Run("list", func(t *testing.T) {
+		out, err := run(t, executeHistoryList, nil, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !strings.Contains(out, "docker ps") || !strings.Contains(out, "rm -rf build") {
+			t.Errorf("expected both entries, got:\n%s", out)
+		}
+	})
+
+	t.Run("search", func(t *testing.T) {
+		out, err := run(t, executeHistorySearch, []string{"Docker"}, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !strings.Contains(out, "docker ps") || strings.Contains(out, "rm -rf build") {
+			t.Errorf("expected only the docker entry, got:\n%s", out)
+		}
+	})
+
+	t.Run("show", func(t *testing.T) {
+		out, err := run(t, executeHistoryShow, []string{"1"}, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, want := range []string{"Request: list docker containers", "Provider: openai (gpt-4o)", "Exit code: 0", "✅"} {
+			if !strings.Contains(out, want) {
+				t.Errorf("expected %q in output, got:\n%s", want, out)
+			}
+		}
+
+		out, err = run(t, executeHistoryShow, []string{"2"}, nil)
+		if err != nil || !strings.Contains(out, "Executed: no") || !strings.Contains(out, "Dangerous") {
+			t.Errorf("expected the unexecuted dangerous entry, got %v:\n%s", err, out)
+		}
+
+		if _, err := run(t, executeHistoryShow, []string{"abc"}, nil); err == nil {
+			t.Error("expected an error for an invalid ID")
+		}
+	})
+
+	t.Run("prune", func(t *testing.T) {
+		if _, err := run(t, executeHistoryPrune, nil, nil); err == nil {
+			t.Error("expected an error without --older-than or --keep")
+		}
+
+		out, err := run(t, executeHistoryPrune, nil, func() { historyKeep = 1 })
+		if err != nil || !strings.Contains(out, "Removed 1") {
+			t.Fatalf("expected one entry removed, got %v: %s", err, out)
+		}
+		if _, err := run(t, executeHistoryShow, []string{"1"}, nil); err == nil {
+			t.Error("expected the oldest entry to be pruned")
+		}
+	})
+}
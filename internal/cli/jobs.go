@@ -0,0 +1,321 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/config"
+	"github.com/kanishka-sahoo/nl-to-shell/internal/jobs"
+	"github.com/kanishka-sahoo/nl-to-shell/internal/manager"
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+// Jobs command flags
+var (
+	jobsFollow bool
+)
+
+// jobsCmd represents the jobs command
+var jobsCmd = &cobra.Command{
+	Use:   "jobs",
+	Short: "Manage commands running in the background",
+	Long: `Manage background jobs, started with --background or with /bg in a session.
+Each job's output goes to a log in the configuration directory and its status
+is kept there too, so jobs keep running and can be checked on after the CLI exits.
+The result of a finished job is validated the next time the jobs are listed or
+waited for.`,
+}
+
+// jobsListCmd represents the jobs list command
+var jobsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List background jobs",
+	Args:  cobra.NoArgs,
+	RunE:  executeJobsList,
+}
+
+// jobsLogsCmd represents the jobs logs command
+var jobsLogsCmd = &cobra.Command{
+	Use:   "logs <id>",
+	Short: "Show the output of a background job",
+	Example: `  # Follow the output of job 3 until it finishes
+  nl-to-shell jobs logs 3 --follow`,
+	Args: cobra.ExactArgs(1),
+	RunE: executeJobsLogs,
+}
+
+// jobsWaitCmd represents the jobs wait command
+var jobsWaitCmd = &cobra.Command{
+	Use:   "wait <id>",
+	Short: "Wait for a background job to finish",
+	Args:  cobra.ExactArgs(1),
+	RunE:  executeJobsWait,
+}
+
+// jobsKillCmd represents the jobs kill command
+var jobsKillCmd = &cobra.Command{
+	Use:   "kill <id>",
+	Short: "Stop a background job and every process it started",
+	Args:  cobra.ExactArgs(1),
+	RunE:  executeJobsKill,
+}
+
+func init() {
+	jobsCmd.AddCommand(jobsListCmd)
+	jobsCmd.AddCommand(jobsLogsCmd)
+	jobsCmd.AddCommand(jobsWaitCmd)
+	jobsCmd.AddCommand(jobsKillCmd)
+
+	jobsLogsCmd.Flags().BoolVarP(&jobsFollow, "follow", "f", false, "Keep showing new output until the job finishes")
+}
+
+// newJobManager opens the background jobs in the configuration directory
+func newJobManager() (*jobs.Manager, error) {
+	return jobs.NewManager(filepath.Join(config.DefaultConfigDirectory(), jobs.DirName))
+}
+
+// enableJobs lets the manager start commands as background jobs, which run
+// with the executor settings in cfg. It returns the job manager, or nil when
+// the jobs directory cannot be created.
+func enableJobs(commandManager *manager.Manager, cfg *types.Config) *jobs.Manager {
+	jobManager, err := newJobManager()
+	if err != nil {
+		if verbose {
+			fmt.Fprintf(os.Stderr, "Warning: Background jobs are not available: %v\n", err)
+		}
+		return nil
+	}
+	jobManager.SetExecutorSettings(types.ExecutorSettings{
+		Shell:  cfg.UserPreferences.Shell,
+		Mode:   cfg.UserPreferences.ExecutionMode,
+		Limits: cfg.UserPreferences.Limits,
+	})
+	commandManager.SetJobManager(jobManager)
+	return jobManager
+}
+
+// validateFinishedJobs validates the results of jobs that finished since they
+// were last checked. Without a usable provider they are left for later.
+func validateFinishedJobs(ctx context.Context, jobManager *jobs.Manager) {
+	cfg, err := loadCommandConfig()
+	if err != nil {
+		return
+	}
+	commandManager, err := newCommandManager(cfg)
+	if err != nil {
+		if verbose {
+			fmt.Fprintf(os.Stderr, "Warning: Finished jobs were not validated: %v\n", err)
+		}
+		return
+	}
+	commandManager.SetJobManager(jobManager)
+	if _, err := commandManager.ValidateJobs(ctx); err != nil && verbose {
+		fmt.Fprintf(os.Stderr, "Warning: Finished jobs were not validated: %v\n", err)
+	}
+}
+
+// executeJobsList handles the jobs list command
+func executeJobsList(cmd *cobra.Command, args []string) error {
+	jobManager, err := newJobManager()
+	if err != nil {
+		return err
+	}
+	validateFinishedJobs(cmd.Context(), jobManager)
+	return listJobs(cmd.OutOrStdout(), jobManager)
+}
+
+// executeJobsLogs handles the jobs logs command
+func executeJobsLogs(cmd *cobra.Command, args []string) error {
+	jobManager, id, err := jobArgument(args[0])
+	if err != nil {
+		return err
+	}
+	return showJobLog(cmd.Context(), cmd.OutOrStdout(), jobManager, id, jobsFollow)
+}
+
+// executeJobsWait handles the jobs wait command
+func executeJobsWait(cmd *cobra.Command, args []string) error {
+	jobManager, id, err := jobArgument(args[0])
+	if err != nil {
+		return err
+	}
+	return waitForJob(cmd.Context(), cmd.OutOrStdout(), jobManager, id, func() {
+		validateFinishedJobs(cmd.Context(), jobManager)
+	})
+}
+
+// executeJobsKill handles the jobs kill command
+func executeJobsKill(cmd *cobra.Command, args []string) error {
+	jobManager, id, err := jobArgument(args[0])
+	if err != nil {
+		return err
+	}
+	return killJob(cmd.OutOrStdout(), jobManager, id)
+}
+
+// jobArgument opens the job manager and parses the job ID given as an argument
+func jobArgument(arg string) (*jobs.Manager, int, error) {
+	id, err := parseJobID(arg)
+	if err != nil {
+		return nil, 0, err
+	}
+	jobManager, err := newJobManager()
+	if err != nil {
+		return nil, 0, err
+	}
+	return jobManager, id, nil
+}
+
+func parseJobID(arg string) (int, error) {
+	id, err := strconv.Atoi(arg)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid job ID %q", arg)
+	}
+	return id, nil
+}
+
+// listJobs prints every job, oldest first
+func listJobs(out io.Writer, jobManager *jobs.Manager) error {
+	list, err := jobManager.List()
+	if err != nil {
+		return fmt.Errorf("failed to read jobs: %w", err)
+	}
+	if len(list) == 0 {
+		fmt.Fprintln(out, "No background jobs.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSTATUS\tEXIT\tSTARTED\tDURATION\tRESULT\tCOMMAND")
+	for _, job := range list {
+		exitCode := ""
+		if job.Status == types.JobSucceeded || job.Status == types.JobFailed {
+			exitCode = strconv.Itoa(job.ExitCode)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%v\t%s\t%s\n",
+			job.ID, job.Status, exitCode, job.StartedAt.Local().Format("2006-01-02 15:04:05"),
+			jobDuration(job), jobVerdict(job), job.Command.Generated)
+	}
+	return w.Flush()
+}
+
+// showJobLog prints the job's output, and with follow keeps printing what it
+// writes until it finishes
+func showJobLog(ctx context.Context, out io.Writer, jobManager *jobs.Manager, id int, follow bool) error {
+	job, err := jobManager.Get(id)
+	if err != nil {
+		return err
+	}
+	log, err := os.Open(job.LogFile)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to open job log: %w", err)
+	}
+	if log != nil {
+		defer log.Close()
+		io.Copy(out, log)
+	}
+
+	for follow && job.Status == types.JobRunning {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(200 * time.Millisecond):
+		}
+		if job, err = jobManager.Get(id); err != nil {
+			return err
+		}
+		if log == nil {
+			if log, err = os.Open(job.LogFile); err != nil {
+				continue
+			}
+			defer log.Close()
+		}
+		// Copy what was written since, including the last output once the job finished
+		io.Copy(out, log)
+	}
+	return nil
+}
+
+// waitForJob waits for the job to finish, has its result validated and
+// prints how it ended
+func waitForJob(ctx context.Context, out io.Writer, jobManager *jobs.Manager, id int, validate func()) error {
+	job, err := jobManager.Wait(ctx, id)
+	if err != nil {
+		return err
+	}
+	if validate != nil {
+		validate()
+		if job, err = jobManager.Get(id); err != nil {
+			return err
+		}
+	}
+	displayJob(out, job)
+	return nil
+}
+
+// killJob stops the job and prints how it ended
+func killJob(out io.Writer, jobManager *jobs.Manager, id int) error {
+	job, err := jobManager.Kill(id)
+	if err != nil {
+		return err
+	}
+	displayJob(out, job)
+	return nil
+}
+
+// displayJobStarted tells the user how to check on a job they just started
+func displayJobStarted(job *types.Job) {
+	fmt.Printf("\n🚀 Started background job %d\n", job.ID)
+	fmt.Printf("Log: %s\n", job.LogFile)
+	fmt.Printf("Check on it with: nl-to-shell jobs wait %d\n", job.ID)
+}
+
+// displayJob prints how a finished job ended and the verdict on its result
+func displayJob(out io.Writer, job *types.Job) {
+	fmt.Fprintf(out, "Job %d %s", job.ID, job.Status)
+	if job.Status == types.JobSucceeded || job.Status == types.JobFailed {
+		fmt.Fprintf(out, " (exit code %d, %v)", job.ExitCode, jobDuration(job))
+	}
+	fmt.Fprintf(out, ": %s\n", job.Command.Generated)
+	if job.Error != "" {
+		fmt.Fprintf(out, "Error: %s\n", job.Error)
+	}
+	if job.Validation != nil {
+		if job.Validation.IsCorrect {
+			fmt.Fprintln(out, "✅ The job achieved the intended result")
+		} else {
+			fmt.Fprintln(out, "❌ The job may not have achieved the intended result")
+			fmt.Fprintf(out, "Explanation: %s\n", job.Validation.Explanation)
+		}
+	}
+	fmt.Fprintf(out, "Log: %s\n", job.LogFile)
+}
+
+// jobDuration returns how long the job ran, or has been running
+func jobDuration(job *types.Job) time.Duration {
+	end := job.FinishedAt
+	if job.Status == types.JobRunning || end.IsZero() {
+		end = time.Now()
+	}
+	return end.Sub(job.StartedAt).Round(time.Second)
+}
+
+// jobVerdict marks a validated job's result ✅ or ❌
+func jobVerdict(job *types.Job) string {
+	switch {
+	case job.Validation == nil:
+		return ""
+	case job.Validation.IsCorrect:
+		return "✅"
+	default:
+		return "❌"
+	}
+}
@@ -0,0 +1,131 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/jobs"
+	mocks "github.com/kanishka-sahoo/nl-to-shell/internal/testing"
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+func newTestJobs(t *testing.T) *jobs.Manager {
+	t.Helper()
+	jobManager, err := jobs.NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create job manager: %v", err)
+	}
+
+	started := time.Now().Add(-time.Minute)
+	for _, job := range []*types.Job{
+		{ID: 1, Command: &types.Command{Generated: "make build"}, Status: types.JobSucceeded, StartedAt: started, FinishedAt: started.Add(30 * time.Second),
+			Validation: &types.ValidationResult{IsCorrect: true}},
+		{ID: 2, Command: &types.Command{Generated: "make test"}, Status: types.JobFailed, ExitCode: 2, StartedAt: started, FinishedAt: started.Add(time.Second),
+			Validation: &types.ValidationResult{IsCorrect: false, Explanation: "the tests failed"}},
+		{ID: 3, Command: &types.Command{Generated: "make release"}, Status: types.JobRunning, StartedAt: started},
+	} {
+		if err := jobManager.Update(job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return jobManager
+}
+
+func TestListJobs(t *testing.T) {
+	var out bytes.Buffer
+	if err := listJobs(&out, newTestJobs(t)); err != nil {
+		t.Fatalf("listJobs failed: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[0], "ID") {
+		t.Fatalf("expected a header and three jobs, got:\n%s", out.String())
+	}
+	for i, want := range []string{"succeeded  0 ", "failed     2 ", "running      "} {
+		if !strings.Contains(lines[i+1], want) {
+			t.Errorf("expected %q in %q", want, lines[i+1])
+		}
+	}
+	if !strings.Contains(lines[1], "✅") || !strings.Contains(lines[2], "❌") || !strings.HasSuffix(lines[3], "make release") {
+		t.Errorf("unexpected jobs listed:\n%s", out.String())
+	}
+
+	out.Reset()
+	empty, _ := jobs.NewManager(t.TempDir())
+	listJobs(&out, empty)
+	if !strings.Contains(out.String(), "No background jobs") {
+		t.Errorf("expected no jobs to be reported, got %q", out.String())
+	}
+}
+
+func TestWaitForJob(t *testing.T) {
+	jobManager := newTestJobs(t)
+
+	var out bytes.Buffer
+	validated := false
+	if err := waitForJob(context.Background(), &out, jobManager, 2, func() { validated = true }); err != nil {
+		t.Fatalf("waitForJob failed: %v", err)
+	}
+	if !validated {
+		t.Error("expected the job to be validated")
+	}
+	for _, want := range []string{"Job 2 failed (exit code 2, 1s): make test", "the tests failed"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected %q in:\n%s", want, out.String())
+		}
+	}
+
+	if err := waitForJob(context.Background(), &out, jobManager, 9, nil); err == nil {
+		t.Error("expected waiting for a missing job to fail")
+	}
+}
+
+func TestBackgroundRequest(t *testing.T) {
+	resetGlobalFlags()
+
+	request, inBackground := backgroundRequest("/bg  Build the Docker image")
+	if request != "Build the Docker image" || !inBackground {
+		t.Errorf("expected a background request, got %q, %v", request, inBackground)
+	}
+	if request, inBackground = backgroundRequest("list files"); request != "list files" || inBackground {
+		t.Errorf("expected a foreground request, got %q, %v", request, inBackground)
+	}
+
+	background = true
+	defer resetGlobalFlags()
+	if _, inBackground = backgroundRequest("list files"); !inBackground {
+		t.Error("expected --background to start every command in the background")
+	}
+}
+
+func TestSessionState_JobsCommand(t *testing.T) {
+	validations := 0
+	session := &SessionState{
+		manager: &mocks.MockCommandManager{
+			ValidateJobsFunc: func(ctx context.Context) ([]*types.Job, error) {
+				validations++
+				return nil, nil
+			},
+		},
+		jobs:        newTestJobs(t),
+		watchedJobs: []int{1, 3},
+	}
+
+	for _, input := range []string{"/jobs", "/JOBS list", "/jobs logs 1", "/bg"} {
+		if handled, shouldExit := session.handleSpecialCommand(input); !handled || shouldExit {
+			t.Errorf("expected %q to be handled without exiting, got handled=%v exit=%v", input, handled, shouldExit)
+		}
+	}
+	if validations != 2 {
+		t.Errorf("expected listing the jobs to validate them, got %d validations", validations)
+	}
+
+	// Finished jobs are reported once, running ones stay watched
+	session.reportFinishedJobs()
+	if len(session.watchedJobs) != 1 || session.watchedJobs[0] != 3 {
+		t.Errorf("expected only the running job to stay watched, got %v", session.watchedJobs)
+	}
+}
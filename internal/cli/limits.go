@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+// displayExceededLimits explains which resource limits a command ran into and
+// where its full output went when it was truncated
+func displayExceededLimits(result *types.ExecutionResult) {
+	var limits types.ResourceLimits
+	if result.Command != nil {
+		limits = result.Command.Limits
+	}
+	for _, limit := range result.LimitsExceeded {
+		switch limit {
+		case types.LimitOutput:
+			fmt.Printf("✂️  Output truncated at %s\n", formatBytes(limits.MaxOutput))
+			for _, file := range []string{result.StdoutFile, result.StderrFile} {
+				if file != "" {
+					fmt.Printf("   Full output: %s\n", file)
+				}
+			}
+		case types.LimitCPUTime:
+			fmt.Printf("⛔ Stopped by the cpu time limit (%v)\n", limits.CPUTime)
+		case types.LimitMemory:
+			fmt.Printf("⛔ Ran out of memory under the limit (%s)\n", formatBytes(limits.Memory))
+		case types.LimitFileSize:
+			fmt.Printf("⛔ Stopped by the file size limit (%s)\n", formatBytes(limits.FileSize))
+		case types.LimitProcesses:
+			fmt.Printf("⛔ Could not start more processes under the limit (%d)\n", limits.Processes)
+		}
+	}
+}
+
+// formatLimits describes the resource limits that are set, or returns ""
+func formatLimits(limits types.ResourceLimits) string {
+	var parts []string
+	if limits.MaxOutput > 0 {
+		parts = append(parts, "output "+formatBytes(limits.MaxOutput))
+	}
+	if limits.CPUTime > 0 {
+		parts = append(parts, fmt.Sprintf("cpu %v", limits.CPUTime))
+	}
+	if limits.Memory > 0 {
+		parts = append(parts, "memory "+formatBytes(limits.Memory))
+	}
+	if limits.FileSize > 0 {
+		parts = append(parts, "file size "+formatBytes(limits.FileSize))
+	}
+	if limits.Processes > 0 {
+		parts = append(parts, fmt.Sprintf("processes %d", limits.Processes))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,19 @@
+package cli
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+func TestFormatLimits(t *testing.T) {
+	if got := formatLimits(types.ResourceLimits{}); got != "" {
+		t.Errorf("expected nothing without limits, got %q", got)
+	}
+
+	got := formatLimits(types.ResourceLimits{MaxOutput: 10 << 20, CPUTime: time.Minute, Processes: 64})
+	if want := "output 10.0 MiB, cpu 1m0s, processes 64"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
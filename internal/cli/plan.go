@@ -0,0 +1,271 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/config"
+	"github.com/kanishka-sahoo/nl-to-shell/internal/manager"
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+	"github.com/kanishka-sahoo/nl-to-shell/internal/usage"
+)
+
+// Plan command flags
+var planSave string
+
+// planCmd represents the plan command
+var planCmd = &cobra.Command{
+	Use:   "plan <request>",
+	Short: "Break a request into an ordered sequence of commands",
+	Long: `Ask the provider to break a request that needs several commands into ordered
+steps, each with an explanation. Every step is safety-checked on its own and
+confirmed before it runs, and the plan stops at the first step that fails.
+Plans can be saved and replayed later.`,
+	Example: `  # Plan and run a multi-step request
+  nl-to-shell plan "create a venv, install requirements and run the tests"
+
+  # Save the plan to replay it in another checkout
+  nl-to-shell plan --save setup.json "create a venv and install requirements"
+  nl-to-shell plan replay setup.json`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: executePlan,
+}
+
+// planReplayCmd represents the plan replay command
+var planReplayCmd = &cobra.Command{
+	Use:   "replay <file>",
+	Short: "Run a saved plan again",
+	Long: `Run a plan saved with --save in the current directory. Each step is
+safety-checked again under the current policies before it runs.`,
+	Args: cobra.ExactArgs(1),
+	RunE: executePlanReplay,
+}
+
+func init() {
+	planCmd.AddCommand(planReplayCmd)
+	planCmd.Flags().StringVar(&planSave, "save", "", "Save the plan to this file")
+}
+
+// executePlan handles the plan command
+func executePlan(cmd *cobra.Command, args []string) error {
+	input := strings.Join(args, " ")
+
+	cfg, err := loadCommandConfig()
+	if err != nil {
+		return err
+	}
+	if err := checkSpendLimits(cfg); err != nil {
+		return err
+	}
+	commandManager, err := newCommandManager(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create LLM provider: %w", err)
+	}
+	sessionID := newSessionID()
+	enableAuditing(commandManager, sessionID)
+
+	ctx := context.Background()
+	plan, err := commandManager.GeneratePlan(ctx, input)
+	if err != nil {
+		return fmt.Errorf("plan generation failed: %w", err)
+	}
+
+	displayPlan(plan)
+	if planSave != "" {
+		if err := savePlan(planSave, plan); err != nil {
+			return err
+		}
+		fmt.Printf("Plan saved to %s\n", planSave)
+	}
+
+	return runPlan(ctx, commandManager, plan, cfg, sessionID)
+}
+
+// executePlanReplay handles the plan replay command
+func executePlanReplay(cmd *cobra.Command, args []string) error {
+	plan, err := loadPlan(args[0])
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadCommandConfig()
+	if err != nil {
+		return err
+	}
+	commandManager, err := newCommandManager(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create LLM provider: %w", err)
+	}
+	sessionID := newSessionID()
+	enableAuditing(commandManager, sessionID)
+
+	// The plan's tokens were counted when it was first run
+	plan.Usage = types.TokenUsage{}
+
+	displayPlan(plan)
+	if cwd, err := os.Getwd(); err == nil && plan.WorkingDir != "" && plan.WorkingDir != cwd {
+		fmt.Fprintf(os.Stderr, "Note: this plan was created in %s\n", plan.WorkingDir)
+	}
+
+	return runPlan(context.Background(), commandManager, plan, cfg, sessionID)
+}
+
+// loadCommandConfig loads the configuration for commands that run generated
+// commands outside the main pipeline, applying the provider flag
+func loadCommandConfig() (*types.Config, error) {
+	cfg, err := config.NewManager().Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+	if provider != "" {
+		cfg.DefaultProvider = provider
+	}
+	return cfg, nil
+}
+
+// runPlan runs plan step by step, asking before each step unless confirmation
+// is skipped, then records the steps in the history and shows a summary
+func runPlan(ctx context.Context, commandManager *manager.Manager, plan *types.Plan, cfg *types.Config, sessionID string) error {
+	options := &types.ExecutionOptions{
+		DryRun:           dryRun,
+		SkipConfirmation: skipConfirmation,
+		Timeout:          cfg.UserPreferences.DefaultTimeout,
+	}
+	if !skipConfirmation {
+		options.ConfirmStep = confirmPlanStep
+	}
+	enableSandbox(options, cfg)
+	if streamOutput {
+		options.Stdout, options.Stderr = newLiveOutputWriters()
+	}
+
+	result, err := commandManager.ExecutePlan(ctx, plan, options)
+	if err != nil {
+		return fmt.Errorf("plan execution failed: %w", err)
+	}
+
+	for _, step := range result.Steps {
+		recordHistory(&types.FullResult{
+			CommandResult:   step.CommandResult,
+			ExecutionResult: step.ExecutionResult,
+			DryRunResult:    step.DryRunResult,
+		}, sessionID, usage.NewPriceTable(cfg.Prices))
+	}
+
+	displayPlanSummary(result)
+	return nil
+}
+
+// savePlan writes plan to path as JSON
+func savePlan(path string, plan *types.Plan) error {
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode plan: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to save plan: %w", err)
+	}
+	return nil
+}
+
+// loadPlan reads a plan saved with savePlan
+func loadPlan(path string) (*types.Plan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plan: %w", err)
+	}
+
+	var plan types.Plan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return nil, fmt.Errorf("invalid plan file %s: %w", path, err)
+	}
+	for _, step := range plan.Steps {
+		if strings.TrimSpace(step.Command) == "" {
+			return nil, fmt.Errorf("invalid plan file %s: a step has no command", path)
+		}
+	}
+	if len(plan.Steps) == 0 {
+		return nil, fmt.Errorf("invalid plan file %s: the plan has no steps", path)
+	}
+	return &plan, nil
+}
+
+// displayPlan shows the steps of a plan before it runs
+func displayPlan(plan *types.Plan) {
+	fmt.Printf("Plan for: %s\n", plan.Request)
+	if plan.Explanation != "" {
+		fmt.Println(plan.Explanation)
+	}
+	for i, step := range plan.Steps {
+		fmt.Printf("  %d. %s\n", i+1, step.Command)
+		if step.Explanation != "" {
+			fmt.Printf("     %s\n", step.Explanation)
+		}
+	}
+}
+
+// confirmPlanStep shows a step with its safety check and asks the user whether to run it
+func confirmPlanStep(index int, step *types.PlanStep, result *types.CommandResult) bool {
+	fmt.Printf("\nStep %d: %s\n", index+1, step.Command)
+	if result.Safety != nil && result.Safety.DangerLevel > types.Safe {
+		fmt.Printf("⚠️  Safety level: %s\n", result.Safety.DangerLevel.String())
+		for _, warning := range result.Safety.Warnings {
+			fmt.Printf("  - %s\n", warning)
+		}
+	}
+	fmt.Print("Run this step? (y/N): ")
+
+	var response string
+	fmt.Scanln(&response)
+	response = strings.ToLower(strings.TrimSpace(response))
+	return response == "y" || response == "yes"
+}
+
+// displayPlanSummary shows the outcome of every step of a plan
+func displayPlanSummary(result *types.PlanResult) {
+	fmt.Println("\n--- Plan Summary ---")
+	for i, step := range result.Plan.Steps {
+		var status string
+		switch {
+		case i >= len(result.Steps):
+			status = "⏹  not run"
+		case result.Steps[i].DryRunResult != nil:
+			status = "🔍 " + result.Steps[i].DryRunResult.Analysis
+		case result.Steps[i].Declined:
+			status = "⏹  declined"
+		case result.Steps[i].RequiresConfirmation:
+			status = "⚠️  requires confirmation (use --skip-confirmation to bypass)"
+		case result.Steps[i].ExecutionResult != nil:
+			execution := result.Steps[i].ExecutionResult
+			mark := "✅"
+			if !execution.Success || execution.ExitCode != 0 {
+				mark = "❌"
+			}
+			status = fmt.Sprintf("%s exit code %d, %v", mark, execution.ExitCode, execution.Duration)
+			if stopped := stopReason(execution); stopped != "" {
+				status = fmt.Sprintf("%s %s, %v", mark, stopped, execution.Duration)
+			}
+		}
+		fmt.Printf("  %d. %s\n     %s\n", i+1, step.Command, status)
+	}
+
+	switch {
+	case result.Completed:
+		fmt.Printf("\n✅ Plan completed: %d of %d steps succeeded\n", len(result.Plan.Steps), len(result.Plan.Steps))
+	case dryRun:
+		fmt.Println("\n(Dry run mode - no steps executed)")
+	default:
+		succeeded := 0
+		for _, step := range result.Steps {
+			if step.ExecutionResult != nil && step.ExecutionResult.Success && step.ExecutionResult.ExitCode == 0 {
+				succeeded++
+			}
+		}
+		fmt.Printf("\n❌ Plan stopped after %d of %d steps succeeded\n", succeeded, len(result.Plan.Steps))
+	}
+}
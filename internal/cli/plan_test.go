@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+func TestSaveAndLoadPlan(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plan.json")
+	plan := &types.Plan{
+		ID:      "plan_1",
+		Request: "create a venv and run the tests",
+		Steps: []types.PlanStep{
+			{Command: "python3 -m venv .venv", Explanation: "Create the environment"},
+			{Command: ".venv/bin/pytest", Explanation: "Run the tests"},
+		},
+		WorkingDir: "/srv/app",
+		Provider:   "openai",
+		CreatedAt:  time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+	}
+
+	if err := savePlan(path, plan); err != nil {
+		t.Fatalf("savePlan() error = %v", err)
+	}
+	loaded, err := loadPlan(path)
+	if err != nil {
+		t.Fatalf("loadPlan() error = %v", err)
+	}
+	if !reflect.DeepEqual(loaded, plan) {
+		t.Errorf("loadPlan() = %+v, want %+v", loaded, plan)
+	}
+}
+
+func TestLoadPlan_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	tests := map[string]string{
+		"not-json.json":   "steps: 1",
+		"no-steps.json":   `{"Request":"nothing","Steps":[]}`,
+		"empty-step.json": `{"Request":"build","Steps":[{"Command":" "}]}`,
+	}
+
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name)
+			if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := loadPlan(path); err == nil {
+				t.Error("expected an error for an invalid plan")
+			}
+		})
+	}
+}
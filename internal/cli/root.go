@@ -3,8 +3,12 @@ package cli
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -17,9 +21,11 @@ import (
 	"github.com/kanishka-sahoo/nl-to-shell/internal/llm"
 	"github.com/kanishka-sahoo/nl-to-shell/internal/manager"
 	"github.com/kanishka-sahoo/nl-to-shell/internal/performance"
+	"github.com/kanishka-sahoo/nl-to-shell/internal/plugins"
 	"github.com/kanishka-sahoo/nl-to-shell/internal/safety"
 	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
 	"github.com/kanishka-sahoo/nl-to-shell/internal/updater"
+	"github.com/kanishka-sahoo/nl-to-shell/internal/usage"
 	"github.com/kanishka-sahoo/nl-to-shell/internal/validator"
 )
 
@@ -32,6 +38,10 @@ var (
 	skipConfirmation bool
 	validateResults  bool
 	sessionMode      bool
+	streamOutput     bool
+	autoCorrect      int
+	sandboxed        bool
+	background       bool
 
 	// Global infrastructure
 	globalMonitor *performance.Monitor
@@ -178,16 +188,27 @@ func init() {
 	// Global flags that apply to all commands
 	rootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "Preview the command without executing it")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose output for detailed information")
-	rootCmd.PersistentFlags().StringVar(&provider, "provider", "", "LLM provider to use (openai, anthropic, gemini, openrouter, ollama)")
+	rootCmd.PersistentFlags().StringVar(&provider, "provider", "", "LLM provider to use (openai, anthropic, gemini, openrouter, ollama, or a configured provider name)")
 	rootCmd.PersistentFlags().StringVar(&model, "model", "", "Model to use")
 	rootCmd.PersistentFlags().BoolVar(&skipConfirmation, "skip-confirmation", false, "Skip confirmation prompts")
 	rootCmd.PersistentFlags().BoolVar(&validateResults, "validate-results", true, "Validate command results")
+	rootCmd.PersistentFlags().BoolVar(&streamOutput, "stream", true, "Show the provider's response and command output live as they arrive")
+	rootCmd.PersistentFlags().IntVar(&autoCorrect, "auto-correct", 0, "Offer the validator's correction of a failed command, up to N times")
+	rootCmd.PersistentFlags().Lookup("auto-correct").NoOptDefVal = "3"
+	rootCmd.PersistentFlags().BoolVar(&sandboxed, "sandbox", false, "Run the command isolated and ask before applying the file changes it makes")
+	rootCmd.PersistentFlags().BoolVar(&background, "background", false, "Start the command as a background job and return right away")
 
 	// Add subcommands
 	rootCmd.AddCommand(generateCmd)
 	rootCmd.AddCommand(configCmd)
 	rootCmd.AddCommand(sessionCmd)
 	rootCmd.AddCommand(updateCmd)
+	rootCmd.AddCommand(auditCmd)
+	rootCmd.AddCommand(historyCmd)
+	rootCmd.AddCommand(planCmd)
+	rootCmd.AddCommand(usageCmd)
+	rootCmd.AddCommand(cacheCmd)
+	rootCmd.AddCommand(jobsCmd)
 	rootCmd.AddCommand(versionCmd)
 	rootCmd.AddCommand(helpCmd)
 	rootCmd.AddCommand(completionCmd)
@@ -367,6 +388,10 @@ func GetGlobalFlags() GlobalFlags {
 		SkipConfirmation: skipConfirmation,
 		ValidateResults:  validateResults,
 		SessionMode:      sessionMode,
+		StreamOutput:     streamOutput,
+		AutoCorrect:      autoCorrect,
+		Sandbox:          sandboxed,
+		Background:       background,
 	}
 }
 
@@ -379,6 +404,10 @@ type GlobalFlags struct {
 	SkipConfirmation bool
 	ValidateResults  bool
 	SessionMode      bool
+	StreamOutput     bool
+	AutoCorrect      int
+	Sandbox          bool
+	Background       bool
 }
 
 // executeCommandGeneration handles the main command generation flow with comprehensive monitoring
@@ -443,15 +472,14 @@ func executeCommandGeneration(input string) error {
 		cfg.DefaultProvider = provider
 	}
 
+	if err := checkSpendLimits(cfg); err != nil {
+		return err
+	}
+
 	// Create components with monitoring
 	componentTimer := globalMonitor.StartTimer("command_generation.component_creation", nil)
 
-	contextGatherer := contextpkg.NewGatherer()
-	safetyValidator := safety.NewValidator()
-	commandExecutor := executor.NewExecutor()
-
-	// Create LLM provider with error handling
-	llmProvider, err := createLLMProvider(cfg)
+	commandManager, err := newCommandManager(cfg)
 	if err != nil {
 		componentTimer.Stop()
 
@@ -473,18 +501,10 @@ func executeCommandGeneration(input string) error {
 
 		return fmt.Errorf("failed to create LLM provider: %w", err)
 	}
-
-	resultValidator := validator.NewResultValidator(llmProvider)
-
-	// Create command manager
-	commandManager := manager.NewManager(
-		contextGatherer,
-		llmProvider,
-		safetyValidator,
-		commandExecutor,
-		resultValidator,
-		cfg,
-	)
+	sessionID := newSessionID()
+	enableAuditing(commandManager, sessionID)
+	enableResponseStreaming(commandManager)
+	enableJobs(commandManager, cfg)
 
 	componentTimer.Stop()
 	globalMonitor.RecordCounter("command_generation.component_creation_success", 1, nil)
@@ -495,6 +515,12 @@ func executeCommandGeneration(input string) error {
 		SkipConfirmation: skipConfirmation,
 		ValidateResults:  validateResults,
 		Timeout:          cfg.UserPreferences.DefaultTimeout,
+		Background:       background,
+	}
+	enableAutoCorrection(options)
+	enableSandbox(options, cfg)
+	if streamOutput {
+		options.Stdout, options.Stderr = newLiveOutputWriters()
 	}
 
 	// Execute the full pipeline with monitoring
@@ -538,8 +564,19 @@ func executeCommandGeneration(input string) error {
 		"provider": cfg.DefaultProvider,
 	})
 
-	// Record result metrics
-	recordResultMetrics(result, cfg.DefaultProvider)
+	// A fallback provider may have answered instead of the default one
+	answeredBy := cfg.DefaultProvider
+	if result.CommandResult != nil && result.CommandResult.Provider != "" {
+		answeredBy = result.CommandResult.Provider
+	}
+	if answeredBy != cfg.DefaultProvider {
+		fmt.Fprintf(os.Stderr, "Note: %s failed, the command was generated by %s\n", cfg.DefaultProvider, answeredBy)
+	}
+
+	// Record result metrics and history
+	prices := usage.NewPriceTable(cfg.Prices)
+	recordResultMetrics(result, answeredBy, prices)
+	recordHistory(result, sessionID, prices)
 
 	// Display results with monitoring
 	displayTimer := globalMonitor.StartTimer("command_generation.result_display", nil)
@@ -564,7 +601,54 @@ func executeCommandGeneration(input string) error {
 	return displayErr
 }
 
-// createLLMProvider creates an LLM provider based on configuration
+// newCommandManager creates a command manager and its components for cfg,
+// failing when the LLM provider cannot be created
+func newCommandManager(cfg *types.Config) (*manager.Manager, error) {
+	llmProvider, err := createLLMProvider(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return manager.NewManager(
+		newContextGatherer(cfg),
+		llmProvider,
+		safety.NewValidatorWithPolicies(config.DefaultConfigDirectory()),
+		executor.NewExecutorWithConfig(&executor.ExecutorConfig{
+			Timeout: cfg.UserPreferences.DefaultTimeout,
+			Shell:   cfg.UserPreferences.Shell,
+			Mode:    cfg.UserPreferences.ExecutionMode,
+			Limits:  cfg.UserPreferences.Limits,
+		}),
+		validator.NewAdvancedResultValidator(llmProvider, autoCorrect > 0),
+		cfg,
+	), nil
+}
+
+// newContextGatherer creates the context gatherer, registering the built-in
+// plugins and the external plugins in the plugin directory when plugins are enabled
+func newContextGatherer(cfg *types.Config) interfaces.ContextGatherer {
+	gatherer := contextpkg.NewGatherer()
+	if cfg.UserPreferences.EnablePlugins {
+		if err := plugins.RegisterBuiltinPlugins(gatherer); err != nil {
+			globalLogger.LogError(errors.NewPluginError("failed to register built-in plugins", err))
+		}
+		if loader, ok := gatherer.(interface{ LoadPlugins(string) error }); ok {
+			if err := loader.LoadPlugins(pluginDirectory()); err != nil {
+				globalLogger.LogError(errors.NewPluginError("failed to load external plugins", err))
+			}
+		}
+	}
+	return gatherer
+}
+
+// pluginDirectory returns the directory external plugin executables are loaded from
+func pluginDirectory() string {
+	return filepath.Join(config.DefaultConfigDirectory(), "plugins")
+}
+
+// createLLMProvider creates an LLM provider based on configuration. When
+// fallback providers are configured, requests move on to them in order if the
+// default provider fails.
 func createLLMProvider(cfg *types.Config) (interfaces.LLMProvider, error) {
 	providerName := cfg.DefaultProvider
 	if provider != "" {
@@ -585,11 +669,44 @@ func createLLMProvider(cfg *types.Config) (interfaces.LLMProvider, error) {
 
 	// Create provider using factory
 	factory := llm.NewProviderFactory()
-	return factory.CreateProvider(providerName, providerConfig)
+	if store := openResponseCache(cfg); store != nil {
+		factory.SetPersistentCache(store)
+	}
+	primary, err := factory.CreateProvider(providerName, providerConfig)
+	if err != nil || len(cfg.FallbackProviders) == 0 {
+		return primary, err
+	}
+
+	providers := []interfaces.LLMProvider{primary}
+	seen := map[string]bool{providerName: true}
+	for _, name := range cfg.FallbackProviders {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		// A fallback that cannot be created is skipped rather than failing the run
+		fallbackConfig, err := configManager.GetProviderConfig(name)
+		if err != nil {
+			globalLogger.LogError(errors.NewConfigurationError(fmt.Sprintf("skipping fallback provider %s", name), err))
+			continue
+		}
+		fallback, err := factory.CreateProvider(name, fallbackConfig)
+		if err != nil {
+			globalLogger.LogError(errors.NewConfigurationError(fmt.Sprintf("skipping fallback provider %s", name), err))
+			continue
+		}
+		providers = append(providers, fallback)
+	}
+	if len(providers) == 1 {
+		return primary, nil
+	}
+	return llm.NewFallbackProvider(providers...), nil
 }
 
-// recordResultMetrics records metrics about the command generation results
-func recordResultMetrics(result *types.FullResult, provider string) {
+// recordResultMetrics records metrics about the command generation results,
+// pricing the tokens they used with prices
+func recordResultMetrics(result *types.FullResult, provider string, prices *usage.PriceTable) {
 	if result == nil {
 		return
 	}
@@ -623,6 +740,19 @@ func recordResultMetrics(result *types.FullResult, provider string) {
 		globalMonitor.RecordDuration("command_generation.execution_time", result.ExecutionResult.Duration, tags)
 	}
 
+	// Record token usage and its cost
+	if tokens := resultUsage(result); tokens.Total() > 0 {
+		usageTags := map[string]string{
+			"provider": provider,
+			"model":    result.CommandResult.Model,
+		}
+		globalMonitor.RecordCounter("llm.tokens.input", float64(tokens.InputTokens), usageTags)
+		globalMonitor.RecordCounter("llm.tokens.output", float64(tokens.OutputTokens), usageTags)
+		if cost, ok := prices.Cost(provider, result.CommandResult.Model, tokens); ok {
+			globalMonitor.RecordGauge("llm.cost", cost, "usd", usageTags)
+		}
+	}
+
 	// Record validation metrics
 	if result.ValidationResult != nil {
 		globalMonitor.RecordCounter("command_generation.validations", 1, map[string]string{
@@ -654,10 +784,18 @@ func displayResults(result *types.FullResult, originalInput string) error {
 	// Display confidence and metadata
 	if verbose {
 		fmt.Printf("Confidence: %.2f\n", result.CommandResult.Confidence)
+		if result.CommandResult.PromptTokens > 0 {
+			fmt.Printf("Estimated prompt tokens: %d\n", result.CommandResult.PromptTokens)
+		}
 
 		// Safely access provider information
 		provider := "unknown"
-		if result.CommandResult.Command.Context != nil && result.CommandResult.Command.Context.Environment != nil {
+		if result.CommandResult.Provider != "" {
+			provider = result.CommandResult.Provider
+			if result.CommandResult.Model != "" {
+				provider += " (" + result.CommandResult.Model + ")"
+			}
+		} else if result.CommandResult.Command.Context != nil && result.CommandResult.Command.Context.Environment != nil {
 			if p, exists := result.CommandResult.Command.Context.Environment["PROVIDER"]; exists {
 				provider = p
 			}
@@ -703,21 +841,35 @@ func displayResults(result *types.FullResult, originalInput string) error {
 		return nil
 	}
 
+	displayAttempts(result.Attempts)
+
+	// A background job is checked on later with the jobs command
+	if result.ExecutionResult != nil && result.ExecutionResult.Job != nil {
+		displayJobStarted(result.ExecutionResult.Job)
+		return nil
+	}
+
 	// Display execution results (maintain backward compatibility)
 	if result.ExecutionResult != nil {
 		fmt.Println("\n--- Execution Results ---")
 		fmt.Printf("Exit code: %d\n", result.ExecutionResult.ExitCode)
 		fmt.Printf("Duration: %v\n", result.ExecutionResult.Duration)
+		if stopped := stopReason(result.ExecutionResult); stopped != "" {
+			fmt.Printf("⛔ %s\n", stopped)
+		}
 
-		if result.ExecutionResult.Stdout != "" {
+		// Streamed output has already been shown while the command ran
+		if result.ExecutionResult.Stdout != "" && !result.ExecutionResult.Streamed {
 			fmt.Println("Output:")
 			fmt.Println(result.ExecutionResult.Stdout)
 		}
 
-		if result.ExecutionResult.Stderr != "" {
+		if result.ExecutionResult.Stderr != "" && !result.ExecutionResult.Streamed {
 			fmt.Println("Error output:")
 			fmt.Println(result.ExecutionResult.Stderr)
 		}
+		displayExceededLimits(result.ExecutionResult)
+		displaySandboxChanges(result.ExecutionResult)
 
 		// Display validation results (maintain backward compatibility)
 		if result.ValidationResult != nil {
@@ -745,6 +897,97 @@ func displayResults(result *types.FullResult, originalInput string) error {
 	return nil
 }
 
+// stopReason describes why a command was stopped before it finished, or
+// returns "" when it ran to completion
+func stopReason(result *types.ExecutionResult) string {
+	switch {
+	case result.Interrupted:
+		return "Interrupted by user"
+	case result.TimedOut:
+		return "Timed out"
+	default:
+		return ""
+	}
+}
+
+// newLiveOutputWriters returns writers that stream command output to the terminal,
+// printing a header before the first chunk of output
+func newLiveOutputWriters() (io.Writer, io.Writer) {
+	header := &sync.Once{}
+	return &liveOutputWriter{out: os.Stdout, header: header}, &liveOutputWriter{out: os.Stderr, header: header}
+}
+
+// liveOutputWriter forwards command output to a terminal stream
+type liveOutputWriter struct {
+	out    io.Writer
+	header *sync.Once
+}
+
+// Write prints the live output header once, then forwards p
+func (w *liveOutputWriter) Write(p []byte) (int, error) {
+	w.header.Do(func() {
+		fmt.Println("--- Live Output ---")
+	})
+	return w.out.Write(p)
+}
+
+// enableResponseStreaming shows command responses while streaming providers
+// generate them, when live output is enabled
+func enableResponseStreaming(commandManager *manager.Manager) {
+	if streamOutput {
+		commandManager.SetStreamHandler(newResponsePreview(os.Stderr))
+	}
+}
+
+// previewLabels names the response fields shown while a response streams
+var previewLabels = map[string]string{
+	"explanation": "Explanation",
+	"command":     "Command",
+}
+
+// responsePreview shows the explanation and command of a response as a
+// provider streams it
+type responsePreview struct {
+	out     io.Writer
+	preview *llm.ResponsePreview
+	field   string // Field being shown, empty before the first one
+}
+
+// newResponsePreview creates a preview writing to out
+func newResponsePreview(out io.Writer) *responsePreview {
+	p := &responsePreview{out: out}
+	p.reset()
+	return p
+}
+
+// Text shows the fields completed by a piece of the response
+func (p *responsePreview) Text(text string) {
+	p.preview.Write(text)
+}
+
+// Done ends the line of the last field shown and prepares for the next response
+func (p *responsePreview) Done() {
+	if p.field != "" {
+		fmt.Fprintln(p.out)
+	}
+	p.reset()
+}
+
+// reset starts following a new response
+func (p *responsePreview) reset() {
+	p.field = ""
+	p.preview = llm.NewResponsePreview(func(field, text string) {
+		if field != p.field {
+			if p.field != "" {
+				fmt.Fprintln(p.out)
+			}
+			fmt.Fprintf(p.out, "%s: ", previewLabels[field])
+			p.field = field
+		}
+		fmt.Fprint(p.out, text)
+	})
+}
+
 // executeUpdateCheck handles the update check command
 func executeUpdateCheck(cmd *cobra.Command, args []string) error {
 	ctx := context.Background()
@@ -922,15 +1165,39 @@ func executeConfigShow(cmd *cobra.Command, args []string) error {
 	fmt.Println("⚙️  Current Configuration")
 	fmt.Println("========================")
 	fmt.Printf("Default Provider: %s\n", cfg.DefaultProvider)
+	if len(cfg.FallbackProviders) > 0 {
+		fmt.Printf("Fallback Providers: %s\n", strings.Join(cfg.FallbackProviders, ", "))
+	}
 
 	fmt.Println("\nConfigured Providers:")
 	for name, providerCfg := range cfg.Providers {
 		fmt.Printf("  %s:\n", name)
+		if providerCfg.Type != "" {
+			fmt.Printf("    Type: %s\n", providerCfg.Type)
+		}
 		fmt.Printf("    Default Model: %s\n", providerCfg.DefaultModel)
 		fmt.Printf("    Timeout: %v\n", providerCfg.Timeout)
+		if providerCfg.PromptTokenBudget > 0 {
+			fmt.Printf("    Prompt Token Budget: %d\n", providerCfg.PromptTokenBudget)
+		}
 		if providerCfg.BaseURL != "" {
 			fmt.Printf("    Base URL: %s\n", providerCfg.BaseURL)
 		}
+		if len(providerCfg.Headers) > 0 {
+			// Header values may carry credentials, so only their names are shown
+			headers := make([]string, 0, len(providerCfg.Headers))
+			for header := range providerCfg.Headers {
+				headers = append(headers, header)
+			}
+			sort.Strings(headers)
+			fmt.Printf("    Headers: %s\n", strings.Join(headers, ", "))
+		}
+		if providerCfg.Capabilities.JSONMode {
+			fmt.Printf("    JSON Mode: enabled\n")
+		}
+		if providerCfg.Capabilities.NoStreaming {
+			fmt.Printf("    Streaming: disabled\n")
+		}
 		// Mask API key
 		if providerCfg.APIKey != "" {
 			fmt.Printf("    API Key: %s***\n", providerCfg.APIKey[:min(8, len(providerCfg.APIKey))])
@@ -944,6 +1211,42 @@ func executeConfigShow(cmd *cobra.Command, args []string) error {
 	fmt.Printf("  Max File List Size: %d\n", cfg.UserPreferences.MaxFileListSize)
 	fmt.Printf("  Enable Plugins: %v\n", cfg.UserPreferences.EnablePlugins)
 	fmt.Printf("  Auto Update: %v\n", cfg.UserPreferences.AutoUpdate)
+	if cfg.UserPreferences.Shell != "" {
+		fmt.Printf("  Shell: %s\n", cfg.UserPreferences.Shell)
+	} else {
+		fmt.Printf("  Shell: (detected)\n")
+	}
+	fmt.Printf("  Execution Mode: %s\n", cfg.UserPreferences.ExecutionMode.String())
+	limits := cfg.UserPreferences.SpendLimits
+	if limits.DailyLimit > 0 || limits.MonthlyLimit > 0 {
+		action := "warn"
+		if limits.Refuse {
+			action = "refuse"
+		}
+		fmt.Printf("  Spend Limits: daily %s, monthly %s (%s)\n", formatSpendLimit(limits.DailyLimit), formatSpendLimit(limits.MonthlyLimit), action)
+	}
+	if cfg.UserPreferences.ResponseCache.Disabled {
+		fmt.Printf("  Response Cache: disabled\n")
+	}
+	if cfg.UserPreferences.Sandbox {
+		fmt.Printf("  Sandbox: enabled\n")
+	}
+	if limits := formatLimits(cfg.UserPreferences.Limits); limits != "" {
+		fmt.Printf("  Resource Limits: %s\n", limits)
+	}
+
+	if len(cfg.Prices) > 0 {
+		fmt.Println("\nPrices (USD per million tokens):")
+		models := make([]string, 0, len(cfg.Prices))
+		for model := range cfg.Prices {
+			models = append(models, model)
+		}
+		sort.Strings(models)
+		for _, model := range models {
+			price := cfg.Prices[model]
+			fmt.Printf("  %s: %g input, %g output\n", model, price.InputPerMillion, price.OutputPerMillion)
+		}
+	}
 
 	fmt.Println("\nUpdate Settings:")
 	fmt.Printf("  Auto Check: %v\n", cfg.UpdateSettings.AutoCheck)
@@ -955,6 +1258,14 @@ func executeConfigShow(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// formatSpendLimit formats a spend limit, of which 0 means no limit
+func formatSpendLimit(limit float64) string {
+	if limit <= 0 {
+		return "none"
+	}
+	return usage.FormatCost(limit)
+}
+
 // executeConfigReset handles the config reset command
 func executeConfigReset(cmd *cobra.Command, args []string) error {
 	timer := globalMonitor.StartTimer("config.reset", nil)
@@ -262,6 +262,9 @@ func TestGetGlobalFlags(t *testing.T) {
 	if flags.ValidateResults != false {
 		t.Error("expected ValidateResults to be false")
 	}
+	if flags.StreamOutput != true {
+		t.Error("expected StreamOutput to default to true")
+	}
 }
 
 func TestVersionCommand(t *testing.T) {
@@ -390,4 +393,6 @@ func resetGlobalFlags() {
 	skipConfirmation = false
 	validateResults = true
 	sessionMode = false
+	streamOutput = true
+	background = false
 }
@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+// sandboxEnabled reports whether commands run in the sandbox, from the
+// --sandbox flag or the sandbox preference
+func sandboxEnabled(cfg *types.Config) bool {
+	return sandboxed || (cfg != nil && cfg.UserPreferences.Sandbox)
+}
+
+// enableSandbox runs commands in the sandbox when it is enabled, asking before
+// their file changes are applied unless confirmation is skipped. Dry runs
+// always use it to find the files a command would change.
+func enableSandbox(options *types.ExecutionOptions, cfg *types.Config) {
+	if !sandboxEnabled(cfg) && !options.DryRun {
+		return
+	}
+	options.Sandbox = true
+	if !options.SkipConfirmation {
+		options.ApplyChanges = confirmChanges
+	}
+}
+
+// confirmChanges lists the file changes of a sandboxed command and asks the
+// user whether to apply them
+func confirmChanges(result *types.ExecutionResult) bool {
+	fmt.Printf("\n📦 The command made %d change(s) in the sandbox:\n", len(result.Changes))
+	for _, line := range changeLines(result.Changes) {
+		fmt.Printf("  %s\n", line)
+	}
+	fmt.Print("Apply these changes? (y/N): ")
+
+	var response string
+	fmt.Scanln(&response)
+	response = strings.ToLower(strings.TrimSpace(response))
+	return response == "y" || response == "yes"
+}
+
+// settleSandboxChanges applies or discards the file changes of a command the
+// session ran in the sandbox
+func settleSandboxChanges(result *types.ExecutionResult) error {
+	if result == nil || result.Pending == nil {
+		return nil
+	}
+	pending := result.Pending
+	result.Pending = nil
+
+	if !skipConfirmation && !confirmChanges(result) {
+		return pending.Discard()
+	}
+	if err := pending.Apply(); err != nil {
+		return err
+	}
+	result.ChangesApplied = true
+	return nil
+}
+
+// displaySandboxChanges shows what a sandboxed command changed and whether the
+// changes were applied
+func displaySandboxChanges(result *types.ExecutionResult) {
+	if !result.Sandboxed {
+		return
+	}
+
+	fmt.Println("\n--- Sandbox Changes ---")
+	if len(result.Changes) == 0 {
+		fmt.Println("No files changed")
+		return
+	}
+	for _, line := range changeLines(result.Changes) {
+		fmt.Printf("  %s\n", line)
+	}
+	if result.ChangesApplied {
+		fmt.Println("✅ Applied to the working directory")
+	} else {
+		fmt.Println("🗑️  Discarded")
+	}
+}
+
+// changeLines formats file changes one per line, marked + when created, ~
+// when modified and - when deleted
+func changeLines(changes []types.FileChange) []string {
+	marks := map[types.FileChangeKind]string{
+		types.FileCreated:  "+",
+		types.FileModified: "~",
+		types.FileDeleted:  "-",
+	}
+
+	lines := make([]string, 0, len(changes))
+	for _, change := range changes {
+		path := change.Path
+		if change.IsDir {
+			path += "/"
+		}
+		lines = append(lines, fmt.Sprintf("%s %s (%s)", marks[change.Kind], path, change.Kind))
+	}
+	return lines
+}
@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+func TestEnableSandbox(t *testing.T) {
+	defer func(previous bool) { sandboxed = previous }(sandboxed)
+	sandboxed = false
+
+	options := &types.ExecutionOptions{}
+	enableSandbox(options, &types.Config{})
+	if options.Sandbox {
+		t.Error("expected the sandbox to be off by default")
+	}
+
+	// Dry runs predict changes in the sandbox
+	options = &types.ExecutionOptions{DryRun: true}
+	enableSandbox(options, &types.Config{})
+	if !options.Sandbox {
+		t.Error("expected dry runs to use the sandbox")
+	}
+
+	options = &types.ExecutionOptions{}
+	enableSandbox(options, &types.Config{UserPreferences: types.UserPreferences{Sandbox: true}})
+	if !options.Sandbox || options.ApplyChanges == nil {
+		t.Error("expected the preference to enable the sandbox and ask before applying changes")
+	}
+
+	sandboxed = true
+	options = &types.ExecutionOptions{SkipConfirmation: true}
+	enableSandbox(options, &types.Config{})
+	if !options.Sandbox || options.ApplyChanges != nil {
+		t.Error("expected --sandbox with --skip-confirmation to apply changes without asking")
+	}
+}
+
+func TestChangeLines(t *testing.T) {
+	lines := changeLines([]types.FileChange{
+		{Path: "build", Kind: types.FileCreated, IsDir: true},
+		{Path: "main.go", Kind: types.FileModified},
+		{Path: "old.txt", Kind: types.FileDeleted},
+	})
+	want := []string{"+ build/ (created)", "~ main.go (modified)", "- old.txt (deleted)"}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("expected %q, got %q", want, lines)
+	}
+}
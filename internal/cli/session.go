@@ -5,18 +5,20 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/kanishka-sahoo/nl-to-shell/internal/config"
-	contextpkg "github.com/kanishka-sahoo/nl-to-shell/internal/context"
 	"github.com/kanishka-sahoo/nl-to-shell/internal/errors"
 	"github.com/kanishka-sahoo/nl-to-shell/internal/executor"
 	"github.com/kanishka-sahoo/nl-to-shell/internal/interfaces"
+	"github.com/kanishka-sahoo/nl-to-shell/internal/jobs"
 	"github.com/kanishka-sahoo/nl-to-shell/internal/manager"
 	"github.com/kanishka-sahoo/nl-to-shell/internal/performance"
 	"github.com/kanishka-sahoo/nl-to-shell/internal/safety"
 	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+	"github.com/kanishka-sahoo/nl-to-shell/internal/usage"
 	"github.com/kanishka-sahoo/nl-to-shell/internal/validator"
 )
 
@@ -33,6 +35,11 @@ type SessionState struct {
 	commandCount    int
 	successCount    int
 	errorCount      int
+
+	// Background jobs; watchedJobs are the ones started in this session that
+	// have not been reported finished yet
+	jobs        *jobs.Manager
+	watchedJobs []int
 }
 
 // NewSessionState creates a new session state
@@ -62,9 +69,14 @@ func NewSessionState() (*SessionState, error) {
 	}
 
 	// Create components
-	contextGatherer := contextpkg.NewGatherer()
-	safetyValidator := safety.NewValidator()
-	commandExecutor := executor.NewExecutor()
+	contextGatherer := newContextGatherer(cfg)
+	safetyValidator := safety.NewValidatorWithPolicies(config.DefaultConfigDirectory())
+	commandExecutor := executor.NewExecutorWithConfig(&executor.ExecutorConfig{
+		Timeout: cfg.UserPreferences.DefaultTimeout,
+		Shell:   cfg.UserPreferences.Shell,
+		Mode:    cfg.UserPreferences.ExecutionMode,
+		Limits:  cfg.UserPreferences.Limits,
+	})
 
 	// Create LLM provider
 	llmProvider, err := createLLMProvider(cfg)
@@ -72,7 +84,7 @@ func NewSessionState() (*SessionState, error) {
 		return nil, fmt.Errorf("failed to create LLM provider: %w", err)
 	}
 
-	resultValidator := validator.NewResultValidator(llmProvider)
+	resultValidator := validator.NewAdvancedResultValidator(llmProvider, autoCorrect > 0)
 
 	// Create command manager
 	commandManager := manager.NewManager(
@@ -84,7 +96,13 @@ func NewSessionState() (*SessionState, error) {
 		cfg,
 	)
 
-	sessionID := fmt.Sprintf("session_%d", time.Now().UnixNano())
+	// cd, export and source in one command carry over to the next
+	commandManager.TrackShellState("")
+
+	sessionID := newSessionID()
+	enableAuditing(commandManager, sessionID)
+	enableResponseStreaming(commandManager)
+	jobManager := enableJobs(commandManager, cfg)
 
 	// Initialize session-specific monitoring
 	sessionMonitor := performance.NewMonitor(&performance.MonitorConfig{
@@ -110,6 +128,7 @@ func NewSessionState() (*SessionState, error) {
 		commandCount:    0,
 		successCount:    0,
 		errorCount:      0,
+		jobs:            jobManager,
 	}
 
 	// Record session start
@@ -142,15 +161,19 @@ func RunInteractiveSession() error {
 	fmt.Println("  help    - Show this help message")
 	fmt.Println("  history - Show command history")
 	fmt.Println("  clear   - Clear command history")
+	fmt.Println("  forget  - Forget earlier requests, so the next one starts fresh")
 	fmt.Println("  config  - Show current configuration")
 	fmt.Println("  stats   - Show session statistics")
+	fmt.Println("  /bg     - Run the command for a request as a background job: /bg <request>")
+	fmt.Println("  /jobs   - List background jobs, or /jobs logs|wait|kill <id>")
 	fmt.Println("  exit    - Exit the session")
 	fmt.Println()
 
 	scanner := bufio.NewScanner(os.Stdin)
 
 	for {
-		fmt.Print("nl-to-shell> ")
+		session.reportFinishedJobs()
+		fmt.Print(session.promptText())
 
 		if !scanner.Scan() {
 			// EOF or error
@@ -180,7 +203,8 @@ func RunInteractiveSession() error {
 			"session_id": session.sessionID,
 		})
 
-		err := session.processCommand(input)
+		request, inBackground := backgroundRequest(input)
+		err := session.processCommand(request, inBackground)
 		commandTimer.Stop()
 
 		session.commandCount++
@@ -247,6 +271,39 @@ func RunInteractiveSession() error {
 	return nil
 }
 
+// promptText returns the input prompt, showing the session's working directory
+func (s *SessionState) promptText() string {
+	state := s.shellState()
+	if state == nil || state.WorkingDirectory == "" {
+		return "nl-to-shell> "
+	}
+	return fmt.Sprintf("nl-to-shell %s> ", abbreviateHome(state.WorkingDirectory))
+}
+
+// shellState returns the directory and environment left by the session's
+// commands, or nil when the manager does not track them
+func (s *SessionState) shellState() *types.ShellState {
+	if tracker, ok := s.manager.(interface{ ShellState() *types.ShellState }); ok {
+		return tracker.ShellState()
+	}
+	return nil
+}
+
+// abbreviateHome replaces the home directory at the start of path with ~
+func abbreviateHome(path string) string {
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" {
+		return path
+	}
+	if path == home {
+		return "~"
+	}
+	if rel, ok := strings.CutPrefix(path, home+string(os.PathSeparator)); ok {
+		return "~" + string(os.PathSeparator) + rel
+	}
+	return path
+}
+
 // handleSpecialCommand handles special session commands with monitoring
 func (s *SessionState) handleSpecialCommand(input string) (handled bool, shouldExit bool) {
 	command := strings.ToLower(input)
@@ -259,7 +316,15 @@ func (s *SessionState) handleSpecialCommand(input string) (handled bool, shouldE
 		})
 	}
 
+	if fields := strings.Fields(input); len(fields) > 0 && strings.ToLower(fields[0]) == "/jobs" {
+		s.handleJobsCommand(fields[1:])
+		return true, false
+	}
+
 	switch command {
+	case "/bg":
+		fmt.Println("Usage: /bg <request>")
+		return true, false
 	case "help":
 		s.showHelp()
 		return true, false
@@ -269,6 +334,9 @@ func (s *SessionState) handleSpecialCommand(input string) (handled bool, shouldE
 	case "clear":
 		s.clearHistory()
 		return true, false
+	case "forget", "/forget":
+		s.forgetConversation()
+		return true, false
 	case "config":
 		s.showConfig()
 		return true, false
@@ -282,6 +350,98 @@ func (s *SessionState) handleSpecialCommand(input string) (handled bool, shouldE
 	}
 }
 
+// backgroundRequest returns the request in input and whether its command
+// should start as a background job, which "/bg <request>" or --background ask for
+func backgroundRequest(input string) (string, bool) {
+	fields := strings.Fields(input)
+	if len(fields) > 1 && strings.ToLower(fields[0]) == "/bg" {
+		return strings.TrimSpace(strings.TrimSpace(input)[len(fields[0]):]), true
+	}
+	return input, background
+}
+
+// handleJobsCommand lists the background jobs, or shows the output of, waits
+// for or kills the one given by ID
+func (s *SessionState) handleJobsCommand(args []string) {
+	if s.jobs == nil {
+		fmt.Println("Background jobs are not available.")
+		return
+	}
+	ctx := context.Background()
+	validate := func() {
+		if _, err := s.manager.ValidateJobs(ctx); err != nil {
+			fmt.Printf("Warning: Finished jobs were not validated: %v\n", err)
+		}
+	}
+
+	if len(args) == 0 || (len(args) == 1 && strings.ToLower(args[0]) == "list") {
+		validate()
+		if err := listJobs(os.Stdout, s.jobs); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+		return
+	}
+	if len(args) != 2 {
+		fmt.Println("Usage: /jobs [list | logs <id> | wait <id> | kill <id>]")
+		return
+	}
+
+	id, err := parseJobID(args[1])
+	if err == nil {
+		switch strings.ToLower(args[0]) {
+		case "logs":
+			err = showJobLog(ctx, os.Stdout, s.jobs, id, false)
+		case "wait":
+			err = waitForJob(ctx, os.Stdout, s.jobs, id, validate)
+			s.forgetJob(id)
+		case "kill":
+			err = killJob(os.Stdout, s.jobs, id)
+			s.forgetJob(id)
+		default:
+			err = fmt.Errorf("unknown jobs command %q", args[0])
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	}
+}
+
+// reportFinishedJobs announces the session's background jobs that have
+// finished, with the verdict on their results
+func (s *SessionState) reportFinishedJobs() {
+	if s.jobs == nil || len(s.watchedJobs) == 0 {
+		return
+	}
+	if _, err := s.manager.ValidateJobs(context.Background()); err != nil && verbose {
+		fmt.Fprintf(os.Stderr, "Warning: Finished jobs were not validated: %v\n", err)
+	}
+
+	running := s.watchedJobs[:0]
+	for _, id := range s.watchedJobs {
+		job, err := s.jobs.Get(id)
+		if err != nil {
+			continue
+		}
+		if job.Status == types.JobRunning {
+			running = append(running, id)
+			continue
+		}
+		fmt.Println()
+		displayJob(os.Stdout, job)
+	}
+	s.watchedJobs = running
+}
+
+// forgetJob stops watching a job that was reported finished some other way
+func (s *SessionState) forgetJob(id int) {
+	for i, watched := range s.watchedJobs {
+		if watched == id {
+			s.watchedJobs = append(s.watchedJobs[:i], s.watchedJobs[i+1:]...)
+			return
+		}
+	}
+}
+
 // recordSessionEndMetrics records metrics when the session ends
 func (s *SessionState) recordSessionEndMetrics() {
 	if s.monitor == nil {
@@ -318,10 +478,15 @@ func (s *SessionState) recordSessionEndMetrics() {
 	})
 }
 
-// processCommand processes a natural language command
-func (s *SessionState) processCommand(input string) error {
+// processCommand processes a natural language command, starting the command
+// as a background job when inBackground is set
+func (s *SessionState) processCommand(input string, inBackground bool) error {
 	ctx := context.Background()
 
+	if err := checkSpendLimits(s.config); err != nil {
+		return err
+	}
+
 	// Step 1: Generate command
 	commandResult, err := s.manager.GenerateCommand(ctx, input)
 	if err != nil {
@@ -332,18 +497,14 @@ func (s *SessionState) processCommand(input string) error {
 	fullResult := &types.FullResult{
 		CommandResult: commandResult,
 	}
+	defer func() { recordHistory(fullResult, s.sessionID, usage.NewPriceTable(s.config.Prices)) }()
 
-	// Step 2: Handle dry run mode
-	if dryRun {
-		// For session mode, we'll use the executor directly for dry run
-		// This is a simplified approach for the session
-		fmt.Printf("Generated command: %s\n", commandResult.Command.Generated)
-		fmt.Println("(Dry run mode - command not executed)")
-		return nil
-	}
-
-	// Step 3: Check safety requirements
-	if commandResult.Safety.RequiresConfirmation && !skipConfirmation {
+	// Step 2: Check safety requirements
+	if commandResult.Safety.RequiresConfirmation && skipConfirmation {
+		if err := s.manager.BypassConfirmation(commandResult); err != nil {
+			return fmt.Errorf("command validation failed: %w", err)
+		}
+	} else if commandResult.Safety.RequiresConfirmation {
 		fmt.Printf("⚠️  This command requires confirmation: %s\n", commandResult.Command.Generated)
 		fmt.Printf("Safety level: %s\n", commandResult.Safety.DangerLevel.String())
 		if len(commandResult.Safety.Warnings) > 0 {
@@ -356,25 +517,56 @@ func (s *SessionState) processCommand(input string) error {
 
 		var response string
 		fmt.Scanln(&response)
-		if strings.ToLower(response) != "y" && strings.ToLower(response) != "yes" {
+		confirmed := strings.ToLower(response) == "y" || strings.ToLower(response) == "yes"
+
+		// Records the answer and marks the command as validated once confirmed
+		s.manager.RecordConfirmation(commandResult, confirmed)
+		if !confirmed {
 			fmt.Println("Command cancelled.")
 			return nil
 		}
+	}
 
-		// Mark as validated after user confirmation
-		commandResult.Command.Validated = true
+	// Step 3: Handle dry run mode, predicting file changes in a sandbox as a
+	// one-shot dry run does. The command is only run there once it has passed
+	// the safety check or been confirmed.
+	if dryRun {
+		commandResult.Command.Sandbox = true
+		dryRunResult, err := s.manager.DryRun(ctx, commandResult.Command)
+		if err != nil {
+			return fmt.Errorf("dry run failed: %w", err)
+		}
+		fullResult.DryRunResult = dryRunResult
+		return displayResults(fullResult, input)
 	}
 
 	// Step 4: Execute command if validated
 	if commandResult.Command.Validated || commandResult.Safety.IsSafe {
-		executionResult, err := s.manager.ExecuteCommand(ctx, commandResult.Command)
+		commandResult.Command.Sandbox = sandboxEnabled(s.config)
+		commandResult.Command.Background = inBackground
+		var executionResult *types.ExecutionResult
+		if streamOutput {
+			fmt.Printf("Running: %s\n", commandResult.Command.Generated)
+			stdout, stderr := newLiveOutputWriters()
+			executionResult, err = s.manager.ExecuteCommandStreaming(ctx, commandResult.Command, stdout, stderr)
+		} else {
+			executionResult, err = s.manager.ExecuteCommand(ctx, commandResult.Command)
+		}
 		if err != nil {
 			return fmt.Errorf("command execution failed: %w", err)
 		}
 		fullResult.ExecutionResult = executionResult
+		if executionResult.Job != nil {
+			// The job's result is validated and reported once it finishes
+			s.watchedJobs = append(s.watchedJobs, executionResult.Job.ID)
+			return displayResults(fullResult, input)
+		}
+		if err := settleSandboxChanges(executionResult); err != nil {
+			return fmt.Errorf("command execution failed: %w", err)
+		}
 
 		// Step 5: Validate results if requested
-		if validateResults {
+		if validateResults || autoCorrect > 0 {
 			validationResult, err := s.manager.ValidateResult(ctx, executionResult, input)
 			if err != nil {
 				// Don't fail the entire operation if validation fails
@@ -383,6 +575,21 @@ func (s *SessionState) processCommand(input string) error {
 				fullResult.ValidationResult = validationResult
 			}
 		}
+
+		// Step 6: Offer corrections of a failed command if enabled
+		if autoCorrect > 0 {
+			options := &types.ExecutionOptions{SkipConfirmation: skipConfirmation}
+			enableAutoCorrection(options)
+			enableSandbox(options, s.config)
+			if streamOutput {
+				options.Stdout, options.Stderr = newLiveOutputWriters()
+			}
+			correctedResult, err := s.manager.CorrectResult(ctx, fullResult, input, options)
+			if err != nil {
+				return fmt.Errorf("command correction failed: %w", err)
+			}
+			fullResult = correctedResult
+		}
 	}
 
 	// Display results
@@ -401,12 +608,17 @@ func (s *SessionState) showHelp() {
 	fmt.Println("    find all .txt files")
 	fmt.Println("    show disk usage")
 	fmt.Println("    create a new directory called 'test'")
+	fmt.Println("  Directories changed and variables exported by one command carry over to the next.")
 	fmt.Println()
 	fmt.Println("Special Commands:")
 	fmt.Println("  help    - Show this help message")
 	fmt.Println("  history - Show your command history")
 	fmt.Println("  clear   - Clear command history")
+	fmt.Println("  forget  - Forget earlier requests, so the next one starts fresh")
 	fmt.Println("  config  - Show current configuration")
+	fmt.Println("  /bg <request>               - Run the command for the request as a background job")
+	fmt.Println("  /jobs                       - List background jobs")
+	fmt.Println("  /jobs logs|wait|kill <id>   - Show the output of, wait for or stop a background job")
 	fmt.Println("  exit    - Exit the session")
 	fmt.Println()
 	fmt.Println("Global Flags (set when starting session):")
@@ -416,6 +628,9 @@ func (s *SessionState) showHelp() {
 	fmt.Printf("  --model: %s\n", model)
 	fmt.Printf("  --skip-confirmation: %v\n", skipConfirmation)
 	fmt.Printf("  --validate-results: %v\n", validateResults)
+	fmt.Printf("  --stream: %v\n", streamOutput)
+	fmt.Printf("  --auto-correct: %d\n", autoCorrect)
+	fmt.Printf("  --background: %v\n", background)
 	fmt.Println()
 }
 
@@ -442,6 +657,12 @@ func (s *SessionState) clearHistory() {
 	fmt.Println("✅ Command history cleared.")
 }
 
+// forgetConversation stops passing earlier requests to the provider
+func (s *SessionState) forgetConversation() {
+	s.manager.ForgetConversation()
+	fmt.Println("✅ Conversation context cleared. The next request starts fresh.")
+}
+
 // showConfig displays the current configuration
 func (s *SessionState) showConfig() {
 	fmt.Println("\n⚙️  Current Configuration")
@@ -449,12 +670,30 @@ func (s *SessionState) showConfig() {
 	fmt.Printf("Session ID: %s\n", s.sessionID)
 	fmt.Printf("Session Duration: %v\n", time.Since(s.startTime).Round(time.Second))
 	fmt.Printf("Default Provider: %s\n", s.config.DefaultProvider)
+	if state := s.shellState(); state != nil {
+		fmt.Printf("Working Directory: %s\n", state.WorkingDirectory)
+		if len(state.Environment) > 0 {
+			names := make([]string, 0, len(state.Environment))
+			for name := range state.Environment {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			fmt.Printf("Exported Variables: %s\n", strings.Join(names, ", "))
+		}
+		if len(state.Unset) > 0 {
+			fmt.Printf("Unset Variables: %s\n", strings.Join(state.Unset, ", "))
+		}
+	}
 
 	if verbose {
 		fmt.Printf("Max File List Size: %d\n", s.config.UserPreferences.MaxFileListSize)
 		fmt.Printf("Default Timeout: %v\n", s.config.UserPreferences.DefaultTimeout)
 		fmt.Printf("Plugins Enabled: %v\n", s.config.UserPreferences.EnablePlugins)
 		fmt.Printf("Auto Update: %v\n", s.config.UserPreferences.AutoUpdate)
+		fmt.Printf("Execution Mode: %s\n", s.config.UserPreferences.ExecutionMode.String())
+		if s.config.UserPreferences.Shell != "" {
+			fmt.Printf("Shell: %s\n", s.config.UserPreferences.Shell)
+		}
 	}
 
 	fmt.Println("\nCurrent Flags:")
@@ -462,6 +701,8 @@ func (s *SessionState) showConfig() {
 	fmt.Printf("  Verbose: %v\n", verbose)
 	fmt.Printf("  Skip Confirmation: %v\n", skipConfirmation)
 	fmt.Printf("  Validate Results: %v\n", validateResults)
+	fmt.Printf("  Stream Output: %v\n", streamOutput)
+	fmt.Printf("  Auto-Correct Attempts: %d\n", autoCorrect)
 
 	if provider != "" {
 		fmt.Printf("  Provider Override: %s\n", provider)
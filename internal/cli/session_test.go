@@ -1,10 +1,12 @@
 package cli
 
 import (
+	"context"
 	"strings"
 	"testing"
 	"time"
 
+	mocks "github.com/kanishka-sahoo/nl-to-shell/internal/testing"
 	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
 )
 
@@ -170,6 +172,56 @@ func TestSessionState_ClearHistory(t *testing.T) {
 	}
 }
 
+func TestSessionState_ForgetConversation(t *testing.T) {
+	forgotten := false
+	session := &SessionState{
+		manager: &mocks.MockCommandManager{
+			ForgetConversationFunc: func() { forgotten = true },
+		},
+	}
+
+	handled, shouldExit := session.handleSpecialCommand("/forget")
+	if !handled || shouldExit {
+		t.Errorf("expected /forget to be handled without exiting, got handled=%v exit=%v", handled, shouldExit)
+	}
+	if !forgotten {
+		t.Error("expected the conversation to be forgotten")
+	}
+}
+
+func TestSessionState_DryRun(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("HOME", dir)
+	t.Setenv("APPDATA", dir)
+	resetGlobalFlags()
+	dryRun = true
+	defer resetGlobalFlags()
+
+	var dryRunCommand *types.Command
+	session := &SessionState{
+		config: &types.Config{},
+		manager: &mocks.MockCommandManager{
+			DryRunFunc: func(ctx context.Context, cmd *types.Command) (*types.DryRunResult, error) {
+				dryRunCommand = cmd
+				return &types.DryRunResult{Command: cmd, Predictions: []string{"Will create archive/"}, Sandboxed: true}, nil
+			},
+			ExecuteCommandFunc: func(ctx context.Context, cmd *types.Command) (*types.ExecutionResult, error) {
+				t.Error("expected a dry run not to execute the command")
+				return nil, nil
+			},
+		},
+	}
+
+	if err := session.processCommand("move the logs into an archive folder", false); err != nil {
+		t.Fatalf("processCommand() error = %v", err)
+	}
+	// The file changes are predicted in the sandbox, as for a one-shot dry run
+	if dryRunCommand == nil || !dryRunCommand.Sandbox {
+		t.Errorf("expected the dry run to run the command in the sandbox, got %+v", dryRunCommand)
+	}
+}
+
 func TestSessionState_GetSessionState(t *testing.T) {
 	expectedID := "test_session_123"
 	expectedTime := time.Now()
@@ -0,0 +1,167 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/config"
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+	"github.com/kanishka-sahoo/nl-to-shell/internal/usage"
+)
+
+// Usage command flags
+var (
+	usageBy    string
+	usageSince string
+)
+
+// usageCmd represents the usage command
+var usageCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "Report the tokens and cost of provider calls",
+	Long: `Report the tokens providers used to generate and validate commands, and what
+they cost, totalled by day, provider or model. Usage is read from the command
+history and priced when it was recorded, using the built-in price table with the
+prices in the configuration applied over it. Spend limits set in the user
+preferences are shown with what has been spent against them.`,
+	Example: `  # Show this month's usage by day
+  nl-to-shell usage
+
+  # Show the last week's usage by model
+  nl-to-shell usage --by model --since 168h`,
+	Args: cobra.NoArgs,
+	RunE: executeUsage,
+}
+
+func init() {
+	usageCmd.Flags().StringVar(&usageBy, "by", "day", "Group usage by day, provider or model")
+	usageCmd.Flags().StringVar(&usageSince, "since", "", "Only usage at or after this time (RFC 3339, a date or a duration such as 168h); defaults to the start of the month")
+}
+
+// executeUsage handles the usage command
+func executeUsage(cmd *cobra.Command, args []string) error {
+	grouping, err := usage.ParseGrouping(usageBy)
+	if err != nil {
+		return fmt.Errorf("invalid --by: %w", err)
+	}
+	now := time.Now()
+	since := usage.MonthStart(now)
+	if usageSince != "" {
+		if since, err = parseAuditTime(usageSince, now); err != nil {
+			return fmt.Errorf("invalid --since: %w", err)
+		}
+	}
+
+	entries, err := readUsageHistory(since)
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	rows := usage.Summarize(entries, grouping)
+	if len(rows) == 0 {
+		fmt.Fprintf(out, "No usage recorded since %s.\n", since.Local().Format("2006-01-02 15:04"))
+	} else {
+		var total usage.Row
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "%s\tREQUESTS\tINPUT\tOUTPUT\tCOST\n", strings.ToUpper(string(grouping)))
+		for _, row := range rows {
+			fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%s\n", row.Key, row.Requests, row.Usage.InputTokens, row.Usage.OutputTokens, usage.FormatCost(row.Cost))
+			total.Requests += row.Requests
+			total.Usage.Add(row.Usage)
+			total.Cost += row.Cost
+		}
+		fmt.Fprintf(w, "TOTAL\t%d\t%d\t%d\t%s\n", total.Requests, total.Usage.InputTokens, total.Usage.OutputTokens, usage.FormatCost(total.Cost))
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+
+	// Spend limits are shown only when the configuration can be read
+	cfg, err := config.NewManager().Load()
+	if err != nil {
+		return nil
+	}
+	limits := cfg.UserPreferences.SpendLimits
+	if limits.DailyLimit <= 0 && limits.MonthlyLimit <= 0 {
+		return nil
+	}
+	spend, err := currentSpend(now)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(out)
+	if limits.DailyLimit > 0 {
+		fmt.Fprintf(out, "Daily limit: %s of %s spent\n", usage.FormatCost(spend.Today), usage.FormatCost(limits.DailyLimit))
+	}
+	if limits.MonthlyLimit > 0 {
+		fmt.Fprintf(out, "Monthly limit: %s of %s spent\n", usage.FormatCost(spend.ThisMonth), usage.FormatCost(limits.MonthlyLimit))
+	}
+	return nil
+}
+
+// readUsageHistory returns the history entries recorded at or after since
+func readUsageHistory(since time.Time) ([]*types.HistoryEntry, error) {
+	store, err := newHistoryStore()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := store.List(&types.HistoryFilter{Since: &since})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read command history: %w", err)
+	}
+	return entries, nil
+}
+
+// currentSpend returns what provider calls have cost today and this month
+func currentSpend(now time.Time) (usage.Spend, error) {
+	entries, err := readUsageHistory(usage.MonthStart(now))
+	if err != nil {
+		return usage.Spend{}, err
+	}
+	return usage.SpendAt(entries, now), nil
+}
+
+// checkSpendLimits is called before a provider is asked for a command. Once a
+// spend limit is reached it refuses when the limits say so, and otherwise
+// warns. A history that cannot be read does not block the command.
+func checkSpendLimits(cfg *types.Config) error {
+	limits := cfg.UserPreferences.SpendLimits
+	if limits.DailyLimit <= 0 && limits.MonthlyLimit <= 0 {
+		return nil
+	}
+
+	spend, err := currentSpend(time.Now())
+	if err != nil {
+		globalLogger.LogError(&types.NLShellError{
+			Type:      types.ErrTypeConfiguration,
+			Message:   "failed to check spend limits",
+			Cause:     err,
+			Severity:  types.SeverityWarning,
+			Timestamp: time.Now(),
+		})
+		return nil
+	}
+
+	reached := usage.ReachedLimit(limits, spend)
+	if reached == "" {
+		return nil
+	}
+	if limits.Refuse {
+		return &types.NLShellError{
+			Type:    types.ErrTypeConfiguration,
+			Message: reached + "; raise the limit in the configuration to continue",
+			Context: map[string]interface{}{
+				"spent_today":      spend.Today,
+				"spent_this_month": spend.ThisMonth,
+			},
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Warning: %s\n", reached)
+	return nil
+}
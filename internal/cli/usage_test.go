@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+	"github.com/kanishka-sahoo/nl-to-shell/internal/usage"
+)
+
+func TestUsageReportAndSpendLimits(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("HOME", dir)
+	t.Setenv("APPDATA", dir)
+
+	prices := usage.NewPriceTable(map[string]types.ModelPrice{
+		"gpt-4o": {InputPerMillion: 1000, OutputPerMillion: 2000},
+	})
+	recordHistory(&types.FullResult{
+		CommandResult: &types.CommandResult{
+			Command:  &types.Command{ID: "cmd_1", Original: "list files", Generated: "ls -la"},
+			Provider: "openai",
+			Model:    "gpt-4o",
+			Usage:    types.TokenUsage{InputTokens: 1000, OutputTokens: 100},
+		},
+		ExecutionResult:  &types.ExecutionResult{ExitCode: 0},
+		ValidationResult: &types.ValidationResult{IsCorrect: true, Usage: types.TokenUsage{InputTokens: 500, OutputTokens: 50}},
+	}, "s1", prices)
+	recordHistory(&types.FullResult{
+		CommandResult: &types.CommandResult{
+			Command:  &types.Command{ID: "cmd_2", Original: "show disk usage", Generated: "df -h"},
+			Provider: "ollama",
+			Model:    "llama3.2",
+			Usage:    types.TokenUsage{InputTokens: 800, OutputTokens: 40},
+		},
+	}, "s1", prices)
+
+	entry, err := getHistoryEntry("1")
+	if err != nil {
+		t.Fatalf("failed to read the history: %v", err)
+	}
+	// 1500 input tokens at $1000 and 150 output tokens at $2000 per million
+	if entry.Usage != (types.TokenUsage{InputTokens: 1500, OutputTokens: 150}) || entry.Cost != 1.8 {
+		t.Errorf("expected generation and validation usage and its cost, got %+v costing %g", entry.Usage, entry.Cost)
+	}
+
+	t.Run("report", func(t *testing.T) {
+		defer func() { usageBy, usageSince = "day", "" }()
+		usageBy = "model"
+
+		var out bytes.Buffer
+		cmd := &cobra.Command{}
+		cmd.SetOut(&out)
+		if err := executeUsage(cmd, nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, want := range []string{"MODEL", "ollama/llama3.2", "openai/gpt-4o", "1500", "$1.80", "TOTAL"} {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("expected %q in the report, got:\n%s", want, out.String())
+			}
+		}
+	})
+
+	t.Run("spend limits", func(t *testing.T) {
+		cfg := &types.Config{}
+		if err := checkSpendLimits(cfg); err != nil {
+			t.Errorf("expected no limits to allow the command, got %v", err)
+		}
+
+		cfg.UserPreferences.SpendLimits = types.SpendLimits{DailyLimit: 5}
+		if err := checkSpendLimits(cfg); err != nil {
+			t.Errorf("expected spend within the limit to allow the command, got %v", err)
+		}
+
+		cfg.UserPreferences.SpendLimits = types.SpendLimits{MonthlyLimit: 1}
+		if err := checkSpendLimits(cfg); err != nil {
+			t.Errorf("expected a reached limit only to warn, got %v", err)
+		}
+
+		cfg.UserPreferences.SpendLimits.Refuse = true
+		err := checkSpendLimits(cfg)
+		if err == nil || !strings.Contains(err.Error(), "monthly spend limit of $1.00 reached") {
+			t.Errorf("expected a reached limit to refuse the command, got %v", err)
+		}
+	})
+}
@@ -183,6 +183,16 @@ func (m *Manager) GetConfigPath() string {
 	return m.configPath
 }
 
+// DefaultConfigDirectory returns the platform configuration directory, or the
+// current directory if it cannot be determined
+func DefaultConfigDirectory() string {
+	configDir, err := getConfigDirectory()
+	if err != nil {
+		return "."
+	}
+	return configDir
+}
+
 // GetConfigDirectory returns the configuration directory path
 func (m *Manager) GetConfigDirectory() string {
 	return m.configDir
@@ -205,6 +215,9 @@ func (m *Manager) getDefaultConfig() *types.Config {
 			MaxFileListSize:  100,
 			EnablePlugins:    true,
 			AutoUpdate:       true,
+			Limits: types.ResourceLimits{
+				MaxOutput: 10 * 1024 * 1024,
+			},
 		},
 		UpdateSettings: types.UpdateSettings{
 			AutoCheck:          true,
@@ -232,6 +245,9 @@ func (m *Manager) mergeWithDefaults(config *types.Config, defaults *types.Config
 	if config.UserPreferences.MaxFileListSize == 0 {
 		config.UserPreferences.MaxFileListSize = defaults.UserPreferences.MaxFileListSize
 	}
+	if config.UserPreferences.Limits.MaxOutput == 0 {
+		config.UserPreferences.Limits.MaxOutput = defaults.UserPreferences.Limits.MaxOutput
+	}
 
 	// Merge update settings with defaults
 	if config.UpdateSettings.CheckInterval == 0 {
@@ -360,6 +376,9 @@ func (m *Manager) setupProviders(config *types.Config) error {
 			return fmt.Errorf("failed to select default provider: %w", err)
 		}
 	}
+	if len(selectedProviders) > 1 {
+		m.selectFallbackProviders(config, selectedProviders)
+	}
 
 	return nil
 }
@@ -485,6 +504,40 @@ func (m *Manager) selectDefaultProvider(config *types.Config, selectedProviders
 	return nil
 }
 
+// selectFallbackProviders lets the user choose the providers tried, in order,
+// when the default provider fails
+func (m *Manager) selectFallbackProviders(config *types.Config, selectedProviders map[string]bool) {
+	var providers []string
+	for _, provider := range []string{"openai", "anthropic", "google", "ollama"} {
+		if selectedProviders[provider] && provider != config.DefaultProvider {
+			providers = append(providers, provider)
+		}
+	}
+
+	fmt.Printf("\nFallback providers when %s fails:\n", config.DefaultProvider)
+	for i, provider := range providers {
+		fmt.Printf("%d. %s\n", i+1, provider)
+	}
+	fmt.Print("Enter provider numbers in order (comma-separated, e.g., 1,2) or press Enter for none: ")
+	input := readInput()
+
+	config.FallbackProviders = nil
+	for _, part := range strings.Split(input, ",") {
+		var providerIndex int
+		if _, err := fmt.Sscanf(strings.TrimSpace(part), "%d", &providerIndex); err != nil {
+			continue
+		}
+		if providerIndex < 1 || providerIndex > len(providers) {
+			fmt.Printf("Invalid provider number: %d\n", providerIndex)
+			continue
+		}
+		config.FallbackProviders = append(config.FallbackProviders, providers[providerIndex-1])
+	}
+	if len(config.FallbackProviders) > 0 {
+		fmt.Printf("✓ Fallback providers: %s\n", strings.Join(config.FallbackProviders, ", "))
+	}
+}
+
 // setupUserPreferences handles interactive user preferences configuration
 func (m *Manager) setupUserPreferences(config *types.Config) error {
 	fmt.Println("\n=== User Preferences ===")
@@ -598,11 +651,14 @@ func (m *Manager) testConfiguration(config *types.Config) error {
 	}
 
 	if providerConfig.APIKey == "" {
-		return fmt.Errorf("no API key configured for provider %s", config.DefaultProvider)
+		if !optionalAPIKey(providerConfig) {
+			return fmt.Errorf("no API key configured for provider %s", config.DefaultProvider)
+		}
+		fmt.Printf("✓ Default provider %s needs no API key\n", config.DefaultProvider)
+	} else {
+		fmt.Printf("✓ Default provider %s has API key configured\n", config.DefaultProvider)
 	}
 
-	fmt.Printf("✓ Default provider %s has API key configured\n", config.DefaultProvider)
-
 	// Test other configured providers
 	for provider := range config.Providers {
 		if provider == config.DefaultProvider {
@@ -616,7 +672,9 @@ func (m *Manager) testConfiguration(config *types.Config) error {
 		}
 
 		if providerConfig.APIKey == "" {
-			fmt.Printf("⚠ Warning: No API key configured for provider %s\n", provider)
+			if !optionalAPIKey(providerConfig) {
+				fmt.Printf("⚠ Warning: No API key configured for provider %s\n", provider)
+			}
 			continue
 		}
 
@@ -628,6 +686,12 @@ func (m *Manager) testConfiguration(config *types.Config) error {
 
 // Helper functions
 
+// optionalAPIKey reports whether a provider can be used without an API key,
+// as self-hosted OpenAI-compatible servers often are
+func optionalAPIKey(providerConfig *types.ProviderConfig) bool {
+	return providerConfig.Type == "openai-compatible"
+}
+
 // readInput reads a line of input from stdin
 func readInput() string {
 	var input string
@@ -325,9 +325,11 @@ func generateMasterKey() []byte {
 
 // GetCredentialFromEnv retrieves a credential from environment variables
 func GetCredentialFromEnv(service, account string) string {
-	// Convert to uppercase for environment variable names
-	serviceUpper := strings.ToUpper(service)
-	accountUpper := strings.ToUpper(account)
+	// Convert to uppercase for environment variable names; named provider
+	// instances such as lm-studio use underscores
+	envName := strings.NewReplacer("-", "_", ".", "_")
+	serviceUpper := envName.Replace(strings.ToUpper(service))
+	accountUpper := envName.Replace(strings.ToUpper(account))
 
 	// Try common environment variable patterns
 	envVars := []string{
@@ -327,6 +327,7 @@ func TestGetCredentialFromEnv(t *testing.T) {
 		{"anthropic", "default", "env-anthropic-key"},
 		{"google", "default", "env-google-token"},
 		{"custom_service", "default", "env-custom-key"},
+		{"custom-service", "default", "env-custom-key"},
 		{"nonexistent", "default", ""},
 	}
 
@@ -0,0 +1,258 @@
+package context
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+const (
+	// ExternalPluginProtocolVersion is sent with every request to an external plugin
+	ExternalPluginProtocolVersion = 1
+
+	// DefaultExternalPluginTimeout bounds a gather call unless the plugin asks for less
+	DefaultExternalPluginTimeout = 5 * time.Second
+
+	// MaxExternalPluginTimeout is the longest gather timeout a plugin may ask for
+	MaxExternalPluginTimeout = 30 * time.Second
+
+	// externalPluginDescribeTimeout bounds the describe call made when loading a plugin
+	externalPluginDescribeTimeout = 2 * time.Second
+
+	// maxExternalPluginOutput limits how much a plugin may write to stdout or stderr
+	maxExternalPluginOutput = 1 << 20
+)
+
+// ExternalPluginRequest is written as JSON to an external plugin's stdin
+type ExternalPluginRequest struct {
+	ProtocolVersion int                    `json:"protocol_version"`
+	Method          string                 `json:"method"` // "describe" or "gather"
+	Context         *ExternalPluginContext `json:"context,omitempty"`
+}
+
+// ExternalPluginContext is the base context sent with a gather request
+type ExternalPluginContext struct {
+	WorkingDirectory string            `json:"working_directory"`
+	Files            []string          `json:"files,omitempty"`
+	GitBranch        string            `json:"git_branch,omitempty"`
+	Environment      map[string]string `json:"environment,omitempty"`
+}
+
+// ExternalPluginDescription is an external plugin's response to describe
+type ExternalPluginDescription struct {
+	Name      string `json:"name"`
+	Priority  int    `json:"priority"`
+	TimeoutMS int    `json:"timeout_ms,omitempty"` // Gather timeout, within MaxExternalPluginTimeout
+}
+
+// ExternalPluginResponse is an external plugin's response to gather
+type ExternalPluginResponse struct {
+	Data  map[string]interface{} `json:"data"`
+	Error string                 `json:"error,omitempty"`
+}
+
+// ExternalPlugin is a context plugin run as a separate executable that speaks
+// JSON over stdin and stdout. Each call starts a new process, so a plugin that
+// crashes or hangs cannot affect the caller or later calls.
+type ExternalPlugin struct {
+	path     string
+	name     string
+	priority int
+	timeout  time.Duration
+}
+
+// NewExternalPlugin describes the executable at path and returns it as a context plugin
+func NewExternalPlugin(ctx context.Context, path string) (*ExternalPlugin, error) {
+	plugin := &ExternalPlugin{path: path}
+
+	var description ExternalPluginDescription
+	if err := plugin.call(ctx, externalPluginDescribeTimeout, &ExternalPluginRequest{Method: "describe"}, &description); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(description.Name) == "" {
+		return nil, &types.NLShellError{
+			Type:    types.ErrTypePlugin,
+			Message: fmt.Sprintf("plugin %s did not describe its name", path),
+		}
+	}
+
+	plugin.name = description.Name
+	plugin.priority = description.Priority
+	plugin.timeout = DefaultExternalPluginTimeout
+	if description.TimeoutMS > 0 {
+		plugin.timeout = time.Duration(description.TimeoutMS) * time.Millisecond
+		if plugin.timeout > MaxExternalPluginTimeout {
+			plugin.timeout = MaxExternalPluginTimeout
+		}
+	}
+
+	return plugin, nil
+}
+
+// Name returns the name the plugin described
+func (p *ExternalPlugin) Name() string {
+	return p.name
+}
+
+// Priority returns the priority the plugin described
+func (p *ExternalPlugin) Priority() int {
+	return p.priority
+}
+
+// Path returns the plugin executable's path
+func (p *ExternalPlugin) Path() string {
+	return p.path
+}
+
+// Timeout returns how long a gather call may run
+func (p *ExternalPlugin) Timeout() time.Duration {
+	return p.timeout
+}
+
+// GatherContext runs the plugin's gather method with the base context
+func (p *ExternalPlugin) GatherContext(ctx context.Context, baseContext *types.Context) (map[string]interface{}, error) {
+	request := &ExternalPluginRequest{
+		Method:  "gather",
+		Context: newExternalPluginContext(baseContext),
+	}
+
+	var response ExternalPluginResponse
+	if err := p.call(ctx, p.timeout, request, &response); err != nil {
+		return nil, err
+	}
+	if response.Error != "" {
+		return nil, &types.NLShellError{
+			Type:    types.ErrTypePlugin,
+			Message: fmt.Sprintf("plugin %s failed: %s", p.name, response.Error),
+		}
+	}
+
+	return response.Data, nil
+}
+
+// call runs the plugin with request on stdin and decodes its stdout into response
+func (p *ExternalPlugin) call(ctx context.Context, timeout time.Duration, request *ExternalPluginRequest, response interface{}) error {
+	request.ProtocolVersion = ExternalPluginProtocolVersion
+	input, err := json.Marshal(request)
+	if err != nil {
+		return &types.NLShellError{
+			Type:    types.ErrTypePlugin,
+			Message: "failed to encode plugin request",
+			Cause:   err,
+		}
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(callCtx, p.path)
+	// Plugins gather context from the user's working directory
+	cmd.Dir = filepath.Dir(p.path)
+	if request.Context != nil && request.Context.WorkingDirectory != "" {
+		cmd.Dir = request.Context.WorkingDirectory
+	}
+	cmd.Stdin = bytes.NewReader(input)
+	stdout := &limitedBuffer{limit: maxExternalPluginOutput}
+	stderr := &limitedBuffer{limit: maxExternalPluginOutput}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	// Do not wait for children that inherited the output pipes after a kill
+	cmd.WaitDelay = time.Second
+
+	runErr := cmd.Run()
+	if callCtx.Err() == context.DeadlineExceeded {
+		return &types.NLShellError{
+			Type:    types.ErrTypeTimeout,
+			Message: fmt.Sprintf("plugin %s timed out after %v", p.path, timeout),
+			Context: map[string]interface{}{"method": request.Method},
+		}
+	}
+	if runErr != nil {
+		return &types.NLShellError{
+			Type:    types.ErrTypePlugin,
+			Message: fmt.Sprintf("plugin %s failed: %s", p.path, strings.TrimSpace(stderr.String())),
+			Cause:   runErr,
+			Context: map[string]interface{}{"method": request.Method},
+		}
+	}
+	if stdout.truncated {
+		return &types.NLShellError{
+			Type:    types.ErrTypePlugin,
+			Message: fmt.Sprintf("plugin %s wrote more than %d bytes", p.path, maxExternalPluginOutput),
+			Context: map[string]interface{}{"method": request.Method},
+		}
+	}
+
+	if err := json.Unmarshal(stdout.Bytes(), response); err != nil {
+		return &types.NLShellError{
+			Type:    types.ErrTypePlugin,
+			Message: fmt.Sprintf("plugin %s returned invalid JSON", p.path),
+			Cause:   err,
+			Context: map[string]interface{}{"method": request.Method},
+		}
+	}
+	return nil
+}
+
+// newExternalPluginContext converts the base context to its protocol form
+func newExternalPluginContext(baseContext *types.Context) *ExternalPluginContext {
+	if baseContext == nil {
+		return &ExternalPluginContext{}
+	}
+
+	pluginContext := &ExternalPluginContext{
+		WorkingDirectory: baseContext.WorkingDirectory,
+		Environment:      baseContext.Environment,
+	}
+	for _, file := range baseContext.Files {
+		pluginContext.Files = append(pluginContext.Files, file.Name)
+	}
+	if baseContext.GitInfo != nil && baseContext.GitInfo.IsRepository {
+		pluginContext.GitBranch = baseContext.GitInfo.CurrentBranch
+	}
+	return pluginContext
+}
+
+// isExternalPluginFile reports whether a directory entry is a plugin executable
+func isExternalPluginFile(info os.FileInfo) bool {
+	if !info.Mode().IsRegular() || strings.HasPrefix(info.Name(), ".") {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		switch strings.ToLower(filepath.Ext(info.Name())) {
+		case ".exe", ".bat", ".cmd":
+			return true
+		}
+		return false
+	}
+	return info.Mode().Perm()&0111 != 0
+}
+
+// limitedBuffer keeps up to limit bytes and discards the rest
+type limitedBuffer struct {
+	bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+// Write implements io.Writer, always reporting success so the plugin is not
+// killed by a broken pipe before its exit status is known
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.Len(); room < len(p) {
+		b.truncated = true
+		if room > 0 {
+			b.Buffer.Write(p[:room])
+		}
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
@@ -0,0 +1,170 @@
+package context
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+// writePluginScript writes an executable shell script plugin that answers
+// describe with description and runs gather as the given shell commands
+func writePluginScript(t *testing.T, dir, name, description, gather string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script plugins are not supported on Windows")
+	}
+
+	script := `#!/bin/sh
+request=$(cat)
+case "$request" in
+  *'"method":"describe"'*) echo '` + description + `' ;;
+  *)
+` + gather + `
+  ;;
+esac
+`
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatalf("Failed to write plugin script: %v", err)
+	}
+	return path
+}
+
+func TestExternalPlugin_Describe(t *testing.T) {
+	path := writePluginScript(t, t.TempDir(), "terraform", `{"name":"terraform","priority":42,"timeout_ms":1500}`, `echo '{}'`)
+
+	plugin, err := NewExternalPlugin(context.Background(), path)
+	if err != nil {
+		t.Fatalf("NewExternalPlugin() error = %v", err)
+	}
+	if plugin.Name() != "terraform" || plugin.Priority() != 42 || plugin.Timeout() != 1500*time.Millisecond {
+		t.Errorf("unexpected description: name=%q priority=%d timeout=%v", plugin.Name(), plugin.Priority(), plugin.Timeout())
+	}
+	if plugin.Path() != path {
+		t.Errorf("expected path %s, got %s", path, plugin.Path())
+	}
+}
+
+func TestExternalPlugin_GatherResponses(t *testing.T) {
+	dir := t.TempDir()
+	workDir := t.TempDir()
+
+	tests := []struct {
+		name    string
+		gather  string
+		wantErr string
+		check   func(t *testing.T, data map[string]interface{})
+	}{
+		{
+			name:   "data",
+			gather: `echo '{"data":{"workspace":"staging","cwd":"'"$(pwd)"'"}}'`,
+			check: func(t *testing.T, data map[string]interface{}) {
+				if data["workspace"] != "staging" {
+					t.Errorf("expected workspace data, got %v", data)
+				}
+				// Plugins run in the user's working directory
+				if cwd, _ := filepath.EvalSymlinks(data["cwd"].(string)); cwd != mustEvalSymlinks(t, workDir) {
+					t.Errorf("expected plugin to run in %s, got %v", workDir, data["cwd"])
+				}
+			},
+		},
+		{name: "plugin error", gather: `echo '{"error":"no terraform state"}'`, wantErr: "no terraform state"},
+		{name: "crash", gather: `echo "segfault" >&2; exit 139`, wantErr: "segfault"},
+		{name: "invalid json", gather: `echo 'not json'`, wantErr: "invalid JSON"},
+		{name: "timeout", gather: `sleep 5`, wantErr: "timed out"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writePluginScript(t, dir, strings.ReplaceAll(tt.name, " ", "-"),
+				`{"name":"test","priority":1,"timeout_ms":300}`, tt.gather)
+			plugin, err := NewExternalPlugin(context.Background(), path)
+			if err != nil {
+				t.Fatalf("NewExternalPlugin() error = %v", err)
+			}
+
+			start := time.Now()
+			data, err := plugin.GatherContext(context.Background(), &types.Context{WorkingDirectory: workDir})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				if time.Since(start) > 3*time.Second {
+					t.Errorf("plugin call was not bounded by its timeout: %v", time.Since(start))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GatherContext() error = %v", err)
+			}
+			tt.check(t, data)
+		})
+	}
+}
+
+func TestExternalPlugin_TimeoutIsCapped(t *testing.T) {
+	path := writePluginScript(t, t.TempDir(), "slow", `{"name":"slow","timeout_ms":3600000}`, `echo '{}'`)
+
+	plugin, err := NewExternalPlugin(context.Background(), path)
+	if err != nil {
+		t.Fatalf("NewExternalPlugin() error = %v", err)
+	}
+	if plugin.Timeout() != MaxExternalPluginTimeout {
+		t.Errorf("expected timeout capped at %v, got %v", MaxExternalPluginTimeout, plugin.Timeout())
+	}
+}
+
+func TestNewExternalPlugin_InvalidDescription(t *testing.T) {
+	path := writePluginScript(t, t.TempDir(), "nameless", `{"priority":1}`, `echo '{}'`)
+
+	_, err := NewExternalPlugin(context.Background(), path)
+	var nlErr *types.NLShellError
+	if !errors.As(err, &nlErr) || nlErr.Type != types.ErrTypePlugin {
+		t.Errorf("expected a plugin error for a nameless plugin, got %v", err)
+	}
+}
+
+func TestLoadPluginsExecutables(t *testing.T) {
+	dir := t.TempDir()
+	writePluginScript(t, dir, "first", `{"name":"first","priority":10}`, `echo '{"data":{"n":1}}'`)
+	writePluginScript(t, dir, "second", `{"name":"second","priority":20}`, `echo '{"data":{"n":2}}'`)
+	writePluginScript(t, dir, "broken", `not json`, `echo '{}'`)
+	if err := os.WriteFile(filepath.Join(dir, "README.txt"), []byte("not a plugin"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	pm := NewPluginManager()
+	if err := pm.LoadPlugins(dir); err != nil {
+		t.Fatalf("LoadPlugins() error = %v", err)
+	}
+
+	plugins := pm.GetPlugins()
+	if len(plugins) != 2 || plugins[0].Name() != "second" || plugins[1].Name() != "first" {
+		names := make([]string, 0, len(plugins))
+		for _, plugin := range plugins {
+			names = append(names, plugin.Name())
+		}
+		t.Fatalf("expected the two valid plugins by priority, got %v", names)
+	}
+
+	data := pm.ExecutePlugins(context.Background(), &types.Context{WorkingDirectory: dir})
+	if len(data) != 2 {
+		t.Errorf("expected data from both plugins, got %v", data)
+	}
+}
+
+func mustEvalSymlinks(t *testing.T, path string) string {
+	t.Helper()
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		t.Fatalf("EvalSymlinks(%s) error = %v", path, err)
+	}
+	return resolved
+}
@@ -59,6 +59,13 @@ func (g *Gatherer) GatherContext(ctx context.Context) (*types.Context, error) {
 		}
 	}
 
+	return g.GatherContextIn(ctx, workingDir)
+}
+
+// GatherContextIn collects environmental context information for workingDir
+// rather than the process's working directory, as for a session that has
+// changed directory
+func (g *Gatherer) GatherContextIn(ctx context.Context, workingDir string) (*types.Context, error) {
 	// Create base context
 	contextData := &types.Context{
 		WorkingDirectory: workingDir,
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"plugin"
 	"sort"
 	"sync"
 
@@ -58,7 +57,9 @@ func (pm *PluginManager) RegisterPlugin(contextPlugin interfaces.ContextPlugin)
 	return nil
 }
 
-// LoadPlugins loads plugins from a directory (for dynamic plugin loading)
+// LoadPlugins loads external plugins from the executables in a directory.
+// Each executable is described over the JSON-over-stdio protocol (see
+// ExternalPlugin); files that fail to load are skipped with a warning.
 func (pm *PluginManager) LoadPlugins(pluginDir string) error {
 	if pluginDir == "" {
 		return &types.NLShellError{
@@ -80,8 +81,8 @@ func (pm *PluginManager) LoadPlugins(pluginDir string) error {
 			return nil
 		}
 
-		// Only process .so files (shared libraries)
-		if filepath.Ext(path) != ".so" {
+		// Only process executables
+		if !isExternalPluginFile(info) {
 			return nil
 		}
 
@@ -117,44 +118,11 @@ func (pm *PluginManager) GetPlugins() []interfaces.ContextPlugin {
 	return plugins
 }
 
-// loadPluginFromFile loads a plugin from a shared library file
+// loadPluginFromFile describes an external plugin executable and registers it
 func (pm *PluginManager) loadPluginFromFile(path string) error {
-	// Open the plugin
-	p, err := plugin.Open(path)
+	contextPlugin, err := NewExternalPlugin(context.Background(), path)
 	if err != nil {
-		return &types.NLShellError{
-			Type:    types.ErrTypeValidation,
-			Message: fmt.Sprintf("failed to open plugin %s", path),
-			Cause:   err,
-		}
-	}
-
-	// Look for the NewPlugin function
-	newPluginSymbol, err := p.Lookup("NewPlugin")
-	if err != nil {
-		return &types.NLShellError{
-			Type:    types.ErrTypeValidation,
-			Message: fmt.Sprintf("plugin %s does not export NewPlugin function", path),
-			Cause:   err,
-		}
-	}
-
-	// Cast to the expected function type
-	newPluginFunc, ok := newPluginSymbol.(func() interfaces.ContextPlugin)
-	if !ok {
-		return &types.NLShellError{
-			Type:    types.ErrTypeValidation,
-			Message: fmt.Sprintf("plugin %s NewPlugin function has wrong signature", path),
-		}
-	}
-
-	// Create the plugin instance
-	contextPlugin := newPluginFunc()
-	if contextPlugin == nil {
-		return &types.NLShellError{
-			Type:    types.ErrTypeValidation,
-			Message: fmt.Sprintf("plugin %s NewPlugin function returned nil", path),
-		}
+		return err
 	}
 
 	// Register the plugin
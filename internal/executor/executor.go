@@ -2,7 +2,9 @@ package executor
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"strings"
@@ -17,6 +19,20 @@ import (
 type Executor struct {
 	defaultTimeout time.Duration
 	workingDir     string
+	shell          string
+	mode           types.ExecutionMode
+	limits         types.ResourceLimits
+}
+
+// outputWaitDelay bounds how long Execute waits for output after the command exits
+const outputWaitDelay = 2 * time.Second
+
+// ExecutorConfig holds configuration for creating an executor
+type ExecutorConfig struct {
+	Timeout time.Duration        // Default timeout for commands without their own timeout
+	Shell   string               // Shell used when the command does not specify one
+	Mode    types.ExecutionMode  // Whether commands run through the shell or directly
+	Limits  types.ResourceLimits // Limits for commands that do not set their own
 }
 
 // NewExecutor creates a new command executor with default settings
@@ -35,8 +51,32 @@ func NewExecutorWithTimeout(timeout time.Duration) interfaces.CommandExecutor {
 	}
 }
 
+// NewExecutorWithConfig creates a new command executor from the given configuration
+func NewExecutorWithConfig(config *ExecutorConfig) interfaces.CommandExecutor {
+	e := &Executor{
+		defaultTimeout: 30 * time.Second,
+		workingDir:     "",
+	}
+	if config != nil {
+		if config.Timeout > 0 {
+			e.defaultTimeout = config.Timeout
+		}
+		e.shell = config.Shell
+		e.mode = config.Mode
+		e.limits = config.Limits
+	}
+	return e
+}
+
 // Execute runs the given command and returns the execution result
 func (e *Executor) Execute(ctx context.Context, cmd *types.Command) (*types.ExecutionResult, error) {
+	return e.ExecuteStreaming(ctx, cmd, nil, nil)
+}
+
+// ExecuteStreaming runs the given command, writing its stdout and stderr to the
+// given writers as it is produced. The output is also captured in the result.
+// Either writer may be nil.
+func (e *Executor) ExecuteStreaming(ctx context.Context, cmd *types.Command, stdout, stderr io.Writer) (*types.ExecutionResult, error) {
 	if cmd == nil {
 		err := &types.NLShellError{
 			Type:    types.ErrTypeExecution,
@@ -59,12 +99,20 @@ func (e *Executor) Execute(ctx context.Context, cmd *types.Command) (*types.Exec
 		timeout = cmd.Timeout
 	}
 
-	// Create context with timeout
-	execCtx, cancel := context.WithTimeout(ctx, timeout)
+	// Create context with timeout. Interactive commands wait on the user and
+	// background jobs run until they finish, so only an explicit command
+	// timeout applies to them.
+	var execCtx context.Context
+	var cancel context.CancelFunc
+	if (cmd.Interactive || cmd.Background) && cmd.Timeout == 0 {
+		execCtx, cancel = context.WithCancel(ctx)
+	} else {
+		execCtx, cancel = context.WithTimeout(ctx, timeout)
+	}
 	defer cancel()
 
 	// Parse the command and arguments
-	cmdParts, err := e.parseCommand(cmd.Generated)
+	cmdParts, needsShell, err := e.parseCommandLine(cmd.Generated)
 	if err != nil {
 		return &types.ExecutionResult{
 			Command:  cmd,
@@ -88,8 +136,39 @@ func (e *Executor) Execute(ctx context.Context, cmd *types.Command) (*types.Exec
 		}, nil
 	}
 
-	// Create the exec.Cmd
-	execCmd := exec.CommandContext(execCtx, cmdParts[0], cmdParts[1:]...)
+	// A command that changes the shell's directory or environment runs through
+	// the shell with a script that records them, when the caller asks for them.
+	// The sandbox hides the files they are recorded in.
+	script := strings.TrimSpace(cmd.Generated)
+	var stateDir string
+	if cmd.CaptureState && !cmd.Sandbox && e.mode != types.ExecutionModeDirect && ChangesShellState(script) {
+		if dir, err := os.MkdirTemp("", "nl-to-shell-state-*"); err == nil {
+			defer os.RemoveAll(dir)
+			if wrapped, ok := stateCaptureScript(e.shellFor(cmd), script, dir); ok {
+				script = wrapped
+				stateDir = dir
+				needsShell = true
+			}
+		}
+	}
+
+	// Create the exec.Cmd, either through the shell or directly
+	var execCmd *exec.Cmd
+	if stateDir != "" || e.useShell(needsShell) {
+		shellPath, shellArgs, err := resolveShell(e.shellFor(cmd))
+		if err != nil {
+			return &types.ExecutionResult{
+				Command:  cmd,
+				ExitCode: -1,
+				Success:  false,
+				Duration: time.Since(startTime),
+				Error:    err,
+			}, err
+		}
+		execCmd = exec.CommandContext(execCtx, shellPath, append(shellArgs, script)...)
+	} else {
+		execCmd = exec.CommandContext(execCtx, cmdParts[0], cmdParts[1:]...)
+	}
 
 	// Set working directory
 	workingDir := cmd.WorkingDir
@@ -103,33 +182,144 @@ func (e *Executor) Execute(ctx context.Context, cmd *types.Command) (*types.Exec
 	}
 	execCmd.Dir = workingDir
 
-	// Set environment variables
-	if len(cmd.Environment) > 0 {
-		env := os.Environ()
+	// Set environment variables, without the ones the command unsets
+	if len(cmd.Environment) > 0 || len(cmd.Unset) > 0 {
+		env := withoutVariables(os.Environ(), cmd.Unset)
 		for key, value := range cmd.Environment {
 			env = append(env, fmt.Sprintf("%s=%s", key, value))
 		}
 		execCmd.Env = env
 	}
 
-	// Execute the command
-	stdout, stderr, exitCode, err := e.runCommand(execCmd, execCtx)
+	// A sandboxed command runs isolated, with its file changes held back. The
+	// sandbox applies the resource limits itself.
+	limits := e.commandLimits(cmd)
+	var box *sandbox
+	if cmd.Sandbox {
+		box, err = newSandbox(workingDir)
+		if err == nil {
+			if err = box.wrap(execCmd, limits); err != nil {
+				box.Discard()
+			}
+		}
+	} else {
+		err = limitResources(execCmd, limits)
+	}
+	if err != nil {
+		return &types.ExecutionResult{
+			Command:  cmd,
+			ExitCode: -1,
+			Success:  false,
+			Duration: time.Since(startTime),
+			Error:    err,
+		}, err
+	}
+
+	// Execute the command. Interactive commands own the terminal, so their
+	// output always goes to it rather than to the given writers.
+	group := newProcessGroup(execCmd, cmd.Interactive)
+	var stdoutCapture, stderrCapture *outputCapture
+	var exitCode int
+	if cmd.Interactive {
+		stdoutCapture, stderrCapture = newOutputCaptures(os.Stdout, os.Stderr, limits.MaxOutput)
+		exitCode, err = e.runInteractive(group, execCtx, stdoutCapture, stderrCapture)
+	} else {
+		stdoutCapture, stderrCapture = newOutputCaptures(stdout, stderr, limits.MaxOutput)
+		exitCode, err = e.runCommand(group, execCtx, stdoutCapture, stderrCapture)
+	}
 	duration := time.Since(startTime)
 
 	result := &types.ExecutionResult{
-		Command:  cmd,
-		ExitCode: exitCode,
-		Stdout:   stdout,
-		Stderr:   stderr,
-		Duration: duration,
-		Success:  exitCode == 0 && err == nil,
-		Error:    err,
+		Command:    cmd,
+		ExitCode:   exitCode,
+		Stdout:     stdoutCapture.String(),
+		Stderr:     stderrCapture.String(),
+		Duration:   duration,
+		Success:    exitCode == 0 && err == nil,
+		Error:      err,
+		Streamed:   cmd.Interactive || stdout != nil || stderr != nil,
+		StdoutFile: stdoutCapture.Close(),
+		StderrFile: stderrCapture.Close(),
+	}
+	// The user interrupting the command, or shutting the application down, is
+	// told apart from the command running out of time. A command that finished
+	// on its own was not interrupted, whatever happened to the context since.
+	result.Interrupted = group.interrupted.Load() || (err != nil && errors.Is(ctx.Err(), context.Canceled))
+	result.TimedOut = !result.Interrupted && errors.Is(execCtx.Err(), context.DeadlineExceeded)
+	if result.Interrupted {
+		result.Success = false
+		result.Error = &types.NLShellError{
+			Type:    types.ErrTypeExecution,
+			Message: "command interrupted by user",
+			Cause:   ctx.Err(),
+		}
+	}
+	result.LimitsExceeded = exceededLimits(limits, execCmd.ProcessState, stdoutCapture.truncated || stderrCapture.truncated, result)
+	if stateDir != "" {
+		startEnv := execCmd.Env
+		if startEnv == nil {
+			startEnv = os.Environ()
+		}
+		result.FinalState = readShellState(stateDir, startEnv)
+	}
+	if err := setupError(result.ExitCode, result.Stderr); err != nil {
+		if box != nil {
+			box.Discard()
+		}
+		result.Success = false
+		result.Error = err
+		return result, err
+	}
+	if box != nil {
+		if err := e.collectSandbox(box, result); err != nil {
+			return result, err
+		}
 	}
 
 	return result, nil
 }
 
-// DryRun analyzes the command without executing it
+// collectSandbox records the file changes of a command that ran in box. The
+// sandbox is kept for the caller to apply or discard when there are changes.
+func (e *Executor) collectSandbox(box *sandbox, result *types.ExecutionResult) error {
+	changes, err := box.collectChanges()
+	if err != nil {
+		box.Discard()
+		result.Error = err
+		return err
+	}
+
+	result.Sandboxed = true
+	result.Changes = changes
+	if len(changes) > 0 {
+		result.Pending = box
+	} else {
+		box.Discard()
+	}
+	return nil
+}
+
+// withoutVariables returns the KEY=value entries of env whose key is not in names
+func withoutVariables(env []string, names []string) []string {
+	if len(names) == 0 {
+		return env
+	}
+	unset := make(map[string]bool, len(names))
+	for _, name := range names {
+		unset[name] = true
+	}
+	kept := make([]string, 0, len(env))
+	for _, entry := range env {
+		key, _, _ := strings.Cut(entry, "=")
+		if !unset[key] {
+			kept = append(kept, entry)
+		}
+	}
+	return kept
+}
+
+// DryRun analyzes the command without executing it. A validated command that
+// is to run in the sandbox is run there to predict the files it would change.
 func (e *Executor) DryRun(cmd *types.Command) (*types.DryRunResult, error) {
 	if cmd == nil {
 		return nil, &types.NLShellError{
@@ -139,7 +329,7 @@ func (e *Executor) DryRun(cmd *types.Command) (*types.DryRunResult, error) {
 	}
 
 	// Parse the command to validate syntax
-	cmdParts, err := e.parseCommand(cmd.Generated)
+	cmdParts, needsShell, err := e.parseCommandLine(cmd.Generated)
 	if err != nil {
 		return &types.DryRunResult{
 			Command:  cmd,
@@ -163,6 +353,12 @@ func (e *Executor) DryRun(cmd *types.Command) (*types.DryRunResult, error) {
 	// Perform comprehensive command analysis
 	analysis := e.analyzeCommand(cmdParts)
 	predictions := e.generatePredictions(cmdParts, cmd)
+	if e.useShell(needsShell) {
+		predictions = append(predictions, fmt.Sprintf("Command will run through the %s shell", shellName(e.shellFor(cmd))))
+	}
+	if cmd.Interactive {
+		predictions = append(predictions, "Command is interactive and will be attached to the terminal")
+	}
 
 	// Validate command structure and arguments
 	validationResults := e.validateCommandStructure(cmdParts, cmd)
@@ -170,169 +366,161 @@ func (e *Executor) DryRun(cmd *types.Command) (*types.DryRunResult, error) {
 	// Combine analysis with validation results
 	fullAnalysis := fmt.Sprintf("%s\n\nValidation Results:\n%s", analysis, validationResults)
 
-	return &types.DryRunResult{
+	result := &types.DryRunResult{
 		Command:     cmd,
 		Analysis:    fullAnalysis,
 		Predictions: predictions,
-	}, nil
+	}
+	if cmd.Sandbox && !cmd.Interactive && sandboxSupported {
+		// The sandbox does not contain everything a command can do, so only
+		// a command that passed the safety check or was confirmed is run
+		if cmd.Validated {
+			e.predictChanges(result)
+		} else {
+			result.Predictions = append(result.Predictions, "Not run in the sandbox to predict file changes, as the command has not been confirmed")
+		}
+	}
+	return result, nil
+}
+
+// predictChanges runs the command in the sandbox to find the files it would
+// change, and discards the changes
+func (e *Executor) predictChanges(result *types.DryRunResult) {
+	trial := *result.Command
+	trial.CaptureState = false
+	trial.Timeout = dryRunTimeout
+	if e.defaultTimeout < trial.Timeout {
+		trial.Timeout = e.defaultTimeout
+	}
+	if result.Command.Timeout > 0 && result.Command.Timeout < trial.Timeout {
+		trial.Timeout = result.Command.Timeout
+	}
+
+	execution, err := e.Execute(context.Background(), &trial)
+	if err != nil {
+		result.Predictions = append(result.Predictions, fmt.Sprintf("Could not run the command in the sandbox: %v", err))
+		return
+	}
+	if execution.Pending != nil {
+		execution.Pending.Discard()
+	}
+
+	result.Sandboxed = true
+	result.Changes = execution.Changes
+	switch {
+	case execution.Error != nil:
+		result.Predictions = append(result.Predictions, fmt.Sprintf("Did not finish in the sandbox within %v; changes up to then are shown", trial.Timeout))
+	case execution.ExitCode != 0:
+		result.Predictions = append(result.Predictions, fmt.Sprintf("Exited with status %d in the sandbox, which has no network access", execution.ExitCode))
+	}
+	if len(execution.Changes) == 0 {
+		result.Predictions = append(result.Predictions, "Will not change any files in the working directory")
+	}
+	result.Predictions = append(result.Predictions, changePredictions(execution.Changes)...)
 }
 
 // parseCommand parses a shell command string into command and arguments
 func (e *Executor) parseCommand(cmdStr string) ([]string, error) {
+	parts, _, err := e.parseCommandLine(cmdStr)
+	return parts, err
+}
+
+// parseCommandLine tokenizes a command string and reports whether it needs a shell
+func (e *Executor) parseCommandLine(cmdStr string) ([]string, bool, error) {
 	cmdStr = strings.TrimSpace(cmdStr)
 	if cmdStr == "" {
-		return nil, &types.NLShellError{
+		return nil, false, &types.NLShellError{
 			Type:    types.ErrTypeExecution,
 			Message: "empty command string",
 		}
 	}
 
-	// Simple shell parsing - split by spaces but respect quotes
-	var parts []string
-	var current strings.Builder
-	inQuotes := false
-	quoteChar := byte(0)
-
-	for i := 0; i < len(cmdStr); i++ {
-		char := cmdStr[i]
+	return splitCommand(cmdStr)
+}
 
-		switch char {
-		case '"', '\'':
-			if !inQuotes {
-				inQuotes = true
-				quoteChar = char
-			} else if char == quoteChar {
-				inQuotes = false
-				quoteChar = 0
-			} else {
-				current.WriteByte(char)
-			}
-		case ' ', '\t':
-			if inQuotes {
-				current.WriteByte(char)
-			} else if current.Len() > 0 {
-				parts = append(parts, current.String())
-				current.Reset()
-			}
-		default:
-			current.WriteByte(char)
-		}
+// useShell reports whether a command should be run through the shell
+func (e *Executor) useShell(needsShell bool) bool {
+	switch e.mode {
+	case types.ExecutionModeShell:
+		return true
+	case types.ExecutionModeDirect:
+		return false
+	default:
+		return needsShell
 	}
+}
 
-	if current.Len() > 0 {
-		parts = append(parts, current.String())
+// shellFor returns the shell to use for a command: the command's own shell,
+// then the executor's configured shell, then the platform default
+func (e *Executor) shellFor(cmd *types.Command) string {
+	if cmd != nil && cmd.Shell != "" {
+		return cmd.Shell
 	}
-
-	if inQuotes {
-		return nil, &types.NLShellError{
-			Type:    types.ErrTypeExecution,
-			Message: "unclosed quote in command",
-		}
+	if e.shell != "" {
+		return e.shell
 	}
-
-	return parts, nil
+	return defaultShell()
 }
 
-// runCommand executes the command and captures output
-func (e *Executor) runCommand(cmd *exec.Cmd, ctx context.Context) (stdout, stderr string, exitCode int, err error) {
-	// Capture stdout and stderr
-	stdoutPipe, err := cmd.StdoutPipe()
-	if err != nil {
-		return "", "", -1, &types.NLShellError{
-			Type:    types.ErrTypeExecution,
-			Message: "failed to create stdout pipe",
-			Cause:   err,
-		}
-	}
+// runCommand executes the command and captures output, forwarding it to the
+// optional sinks while the command runs
+func (e *Executor) runCommand(group *processGroup, ctx context.Context, stdout, stderr *outputCapture) (exitCode int, err error) {
+	cmd := group.cmd
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
 
-	stderrPipe, err := cmd.StderrPipe()
-	if err != nil {
-		return "", "", -1, &types.NLShellError{
-			Type:    types.ErrTypeExecution,
-			Message: "failed to create stderr pipe",
-			Cause:   err,
-		}
-	}
+	// Don't wait forever on background processes that inherited the output pipes
+	cmd.WaitDelay = outputWaitDelay
 
 	// Start the command
-	if err := cmd.Start(); err != nil {
-		return "", "", -1, &types.NLShellError{
+	if err := group.start(); err != nil {
+		return -1, &types.NLShellError{
 			Type:    types.ErrTypeExecution,
 			Message: "failed to start command",
 			Cause:   err,
 		}
 	}
 
-	// Read output concurrently
-	stdoutChan := make(chan string, 1)
-	stderrChan := make(chan string, 1)
-
-	go func() {
-		buf := make([]byte, 4096)
-		var output strings.Builder
-		for {
-			n, err := stdoutPipe.Read(buf)
-			if n > 0 {
-				output.Write(buf[:n])
-			}
-			if err != nil {
-				break
-			}
-		}
-		stdoutChan <- output.String()
-	}()
+	// Wait for command to complete; this also waits for the output to be copied
+	err = group.wait()
 
-	go func() {
-		buf := make([]byte, 4096)
-		var output strings.Builder
-		for {
-			n, err := stderrPipe.Read(buf)
-			if n > 0 {
-				output.Write(buf[:n])
-			}
-			if err != nil {
-				break
-			}
-		}
-		stderrChan <- output.String()
-	}()
-
-	// Wait for command to complete
-	err = cmd.Wait()
+	return commandExitCode(cmd, ctx, err)
+}
 
-	// Get the outputs
-	stdout = <-stdoutChan
-	stderr = <-stderrChan
+// commandExitCode converts the error returned by cmd.Wait into an exit code.
+// Timeouts and failures to run the command are returned as errors.
+func commandExitCode(cmd *exec.Cmd, ctx context.Context, err error) (int, error) {
+	if err == nil {
+		return 0, nil
+	}
 
-	// Determine exit code
-	exitCode = 0
-	if err != nil {
-		// Check if the context was cancelled first
-		if ctx != nil && ctx.Err() != nil {
-			return stdout, stderr, -1, &types.NLShellError{
-				Type:    types.ErrTypeTimeout,
-				Message: "command execution timed out",
-				Cause:   ctx.Err(),
-			}
+	// Check if the context was cancelled first
+	if ctx != nil && ctx.Err() != nil {
+		return -1, &types.NLShellError{
+			Type:    types.ErrTypeTimeout,
+			Message: "command execution timed out",
+			Cause:   ctx.Err(),
 		}
+	}
 
-		if exitError, ok := err.(*exec.ExitError); ok {
-			if status, ok := exitError.Sys().(syscall.WaitStatus); ok {
-				exitCode = status.ExitStatus()
-			} else {
-				exitCode = 1
-			}
-		} else {
-			// Command failed to start or other error
-			return stdout, stderr, -1, &types.NLShellError{
-				Type:    types.ErrTypeExecution,
-				Message: "command execution failed",
-				Cause:   err,
-			}
+	if exitError, ok := err.(*exec.ExitError); ok {
+		if status, ok := exitError.Sys().(syscall.WaitStatus); ok {
+			return status.ExitStatus(), nil
 		}
+		return 1, nil
+	}
+
+	if errors.Is(err, exec.ErrWaitDelay) && cmd.ProcessState != nil {
+		// The command exited but a background child kept the output open
+		return cmd.ProcessState.ExitCode(), nil
 	}
 
-	return stdout, stderr, exitCode, nil
+	// Command failed to start or other error
+	return -1, &types.NLShellError{
+		Type:    types.ErrTypeExecution,
+		Message: "command execution failed",
+		Cause:   err,
+	}
 }
 
 // analyzeCommand provides analysis of what the command will do
@@ -419,7 +607,11 @@ func (e *Executor) generatePredictions(cmdParts []string, cmd *types.Command) []
 	if timeout == 0 {
 		timeout = e.defaultTimeout
 	}
-	predictions = append(predictions, fmt.Sprintf("Command will timeout after %v if not completed", timeout))
+	if cmd.Interactive && cmd.Timeout == 0 {
+		predictions = append(predictions, "Command will run until it exits (interactive commands have no default timeout)")
+	} else {
+		predictions = append(predictions, fmt.Sprintf("Command will timeout after %v if not completed", timeout))
+	}
 
 	// Command-specific predictions
 	switch executable {
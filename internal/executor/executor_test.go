@@ -1,6 +1,7 @@
 package executor
 
 import (
+	"bytes"
 	"context"
 	"runtime"
 	"strings"
@@ -295,6 +296,62 @@ func TestExecutor_Execute_WithEnvironment(t *testing.T) {
 	}
 }
 
+func TestExecutor_ExecuteStreaming(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Uses a POSIX shell")
+	}
+
+	executor := NewExecutor()
+	ctx := context.Background()
+
+	cmd := &types.Command{
+		ID:        "test-stream",
+		Generated: "echo out; echo err 1>&2",
+		Timestamp: time.Now(),
+	}
+
+	var stdout, stderr bytes.Buffer
+	result, err := executor.ExecuteStreaming(ctx, cmd, &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !result.Success {
+		t.Errorf("Expected command to succeed, got exit code %d", result.ExitCode)
+	}
+	if !result.Streamed {
+		t.Error("Expected result to be marked as streamed")
+	}
+
+	// Output is both forwarded to the sinks and captured in the result
+	if stdout.String() != "out\n" || result.Stdout != "out\n" {
+		t.Errorf("Expected stdout 'out', got sink %q and result %q", stdout.String(), result.Stdout)
+	}
+	if stderr.String() != "err\n" || result.Stderr != "err\n" {
+		t.Errorf("Expected stderr 'err', got sink %q and result %q", stderr.String(), result.Stderr)
+	}
+}
+
+func TestExecutor_ExecuteStreaming_NilSinks(t *testing.T) {
+	executor := NewExecutor()
+
+	cmd := &types.Command{
+		ID:        "test-stream-nil",
+		Generated: "echo hello",
+		Timestamp: time.Now(),
+	}
+
+	result, err := executor.ExecuteStreaming(context.Background(), cmd, nil, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Streamed {
+		t.Error("Expected result without sinks not to be marked as streamed")
+	}
+	if !strings.Contains(result.Stdout, "hello") {
+		t.Errorf("Expected stdout to contain 'hello', got: %s", result.Stdout)
+	}
+}
+
 func TestExecutor_DryRun_NilCommand(t *testing.T) {
 	executor := NewExecutor()
 
@@ -0,0 +1,141 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"strings"
+)
+
+// interactivePrograms take over the terminal or prompt the user for input
+var interactivePrograms = map[string]bool{
+	"less": true, "more": true, "most": true, "man": true,
+	"vi": true, "vim": true, "nvim": true, "nano": true, "emacs": true, "pico": true, "micro": true,
+	"top": true, "htop": true, "btop": true, "watch": true,
+	"ssh": true, "telnet": true, "ftp": true, "sftp": true,
+	"sudo": true, "su": true, "passwd": true,
+	"tmux": true, "screen": true,
+}
+
+// replPrograms start an interactive session when run without arguments
+var replPrograms = map[string]bool{
+	"python": true, "python3": true, "node": true, "irb": true, "ghci": true,
+	"psql": true, "mysql": true, "sqlite3": true, "redis-cli": true,
+	"sh": true, "bash": true, "zsh": true, "fish": true,
+}
+
+// commandWrappers run the command that follows them
+var commandWrappers = map[string]bool{
+	"env": true, "command": true, "exec": true, "nohup": true, "time": true,
+}
+
+// commandSeparators split a command line into the commands it runs
+var commandSeparators = map[string]bool{
+	"|": true, "||": true, "&&": true, ";": true, "&": true,
+}
+
+// IsInteractiveCommand reports whether a command is likely to need the user's
+// terminal, such as pagers, editors, password prompts and REPLs
+func IsInteractiveCommand(command string) bool {
+	words, _, err := splitCommand(strings.TrimSpace(command))
+	if err != nil {
+		return false
+	}
+
+	var segment []string
+	for _, word := range append(words, ";") {
+		if !commandSeparators[word] {
+			segment = append(segment, word)
+			continue
+		}
+		if isInteractiveSegment(segment) {
+			return true
+		}
+		segment = nil
+	}
+
+	return false
+}
+
+// isInteractiveSegment checks a single command of a command line
+func isInteractiveSegment(args []string) bool {
+	for len(args) > 0 && (assignmentPrefix.MatchString(args[0]) || commandWrappers[args[0]]) {
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		return false
+	}
+
+	program := shellName(args[0])
+	switch {
+	case interactivePrograms[program]:
+		return true
+	case replPrograms[program]:
+		return len(args) == 1
+	case program == "git":
+		return isInteractiveGit(args[1:])
+	default:
+		return false
+	}
+}
+
+// isInteractiveGit checks whether a git invocation opens a pager or an editor
+func isInteractiveGit(args []string) bool {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--no-pager" || arg == "-P":
+			return false
+		case arg == "-C" || arg == "-c":
+			i++ // Skip the option value
+		case strings.HasPrefix(arg, "-"):
+			continue
+		default:
+			return isInteractiveGitSubcommand(arg, args[i+1:])
+		}
+	}
+	return false
+}
+
+// isInteractiveGitSubcommand checks a git subcommand and its arguments
+func isInteractiveGitSubcommand(subcommand string, args []string) bool {
+	switch subcommand {
+	case "log", "diff", "show", "blame", "shortlog", "reflog":
+		return true
+	case "commit":
+		// Without a message git opens an editor
+		for _, arg := range args {
+			if arg == "--no-edit" || strings.HasPrefix(arg, "--message") || strings.HasPrefix(arg, "--file") || strings.HasPrefix(arg, "--fixup") {
+				return false
+			}
+			if strings.HasPrefix(arg, "-") && !strings.HasPrefix(arg, "--") && strings.ContainsAny(arg, "mFC") {
+				return false
+			}
+		}
+		return true
+	case "rebase":
+		return hasAnyArg(args, "-i", "--interactive")
+	case "add", "checkout", "reset", "restore", "stash":
+		return hasAnyArg(args, "-p", "--patch", "-i", "--interactive")
+	default:
+		return false
+	}
+}
+
+// hasAnyArg reports whether args contains any of the given flags
+func hasAnyArg(args []string, flags ...string) bool {
+	for _, arg := range args {
+		for _, flag := range flags {
+			if arg == flag {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// runAttached runs the command with the user's stdin attached, copying its
+// output to the terminal. It is used when no pseudo-terminal is available.
+func (e *Executor) runAttached(group *processGroup, ctx context.Context, stdout, stderr *outputCapture) (exitCode int, err error) {
+	group.cmd.Stdin = os.Stdin
+	return e.runCommand(group, ctx, stdout, stderr)
+}
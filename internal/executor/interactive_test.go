@@ -0,0 +1,104 @@
+package executor
+
+import (
+	"context"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+func TestIsInteractiveCommand(t *testing.T) {
+	tests := []struct {
+		command     string
+		interactive bool
+	}{
+		{"ls -la", false},
+		{"less /var/log/syslog", true},
+		{"vim main.go", true},
+		{"/usr/bin/nano notes.txt", true},
+		{"sudo apt update", true},
+		{"ssh user@example.com", true},
+		{"top", true},
+		{"cat file.txt | less", true},
+		{"make && vim Makefile", true},
+		{"EDITOR=vim env nano x", true},
+		{"python3", true},
+		{"python3 script.py", false},
+		{"node -e 'console.log(1)'", false},
+		{"git status", false},
+		{"git log --oneline", true},
+		{"git -C repo diff", true},
+		{"git --no-pager log", false},
+		{"git commit", true},
+		{"git commit -m 'fix'", false},
+		{"git commit -am 'fix'", false},
+		{"git commit --amend --no-edit", false},
+		{"git rebase -i HEAD~3", true},
+		{"git rebase main", false},
+		{"git add -p", true},
+		{"git add .", false},
+		{"echo 'less is more'", false},
+		{"echo 'unclosed", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsInteractiveCommand(tt.command); got != tt.interactive {
+			t.Errorf("IsInteractiveCommand(%q) = %v, want %v", tt.command, got, tt.interactive)
+		}
+	}
+}
+
+func TestExecutor_Execute_InteractiveWithoutTerminal(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("echo is not an executable on Windows")
+	}
+
+	executor := NewExecutorWithTimeout(5 * time.Second)
+	cmd := &types.Command{
+		ID:          "interactive-1",
+		Generated:   "echo attached",
+		Interactive: true,
+		Timestamp:   time.Now(),
+	}
+
+	result, err := executor.Execute(context.Background(), cmd)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !result.Success {
+		t.Fatalf("Expected success, got exit code %d: %v", result.ExitCode, result.Error)
+	}
+	if !strings.Contains(result.Stdout, "attached") {
+		t.Errorf("Expected output to be captured, got: %q", result.Stdout)
+	}
+	if !result.Streamed {
+		t.Error("Expected interactive output to be reported as streamed")
+	}
+}
+
+func TestExecutor_DryRun_ReportsInteractive(t *testing.T) {
+	executor := NewExecutor()
+	cmd := &types.Command{
+		ID:          "dry-interactive",
+		Generated:   "less README.md",
+		Interactive: true,
+		Timestamp:   time.Now(),
+	}
+
+	result, err := executor.DryRun(cmd)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	predictions := strings.Join(result.Predictions, "\n")
+	if !strings.Contains(predictions, "attached to the terminal") {
+		t.Errorf("Expected an interactive prediction, got: %v", result.Predictions)
+	}
+	if strings.Contains(predictions, "will timeout after") {
+		t.Errorf("Expected no default timeout for interactive command, got: %v", result.Predictions)
+	}
+}
@@ -0,0 +1,65 @@
+package executor
+
+import (
+	"os"
+	"regexp"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+// memoryErrors and processErrors match what commands print when an allocation
+// or a fork fails, which is all a memory or process limit leaves behind
+var (
+	memoryErrors  = regexp.MustCompile(`(?i)cannot allocate memory|out of memory|memory exhausted|MemoryError|bad_alloc|failed to allocate`)
+	processErrors = regexp.MustCompile(`(?i)fork: |resource temporarily unavailable`)
+)
+
+// commandLimits returns the limits for cmd, taking each limit the command does
+// not set from the executor's
+func (e *Executor) commandLimits(cmd *types.Command) types.ResourceLimits {
+	limits := cmd.Limits
+	if limits.MaxOutput == 0 {
+		limits.MaxOutput = e.limits.MaxOutput
+	}
+	if limits.CPUTime == 0 {
+		limits.CPUTime = e.limits.CPUTime
+	}
+	if limits.Memory == 0 {
+		limits.Memory = e.limits.Memory
+	}
+	if limits.FileSize == 0 {
+		limits.FileSize = e.limits.FileSize
+	}
+	if limits.Processes == 0 {
+		limits.Processes = e.limits.Processes
+	}
+	return limits
+}
+
+// hasRlimits reports whether limits sets any limit applied as an rlimit
+func hasRlimits(limits types.ResourceLimits) bool {
+	return limits.CPUTime > 0 || limits.Memory > 0 || limits.FileSize > 0 || limits.Processes > 0
+}
+
+// exceededLimits works out which limits a finished command ran into, from how
+// it exited and what it printed
+func exceededLimits(limits types.ResourceLimits, state *os.ProcessState, truncated bool, result *types.ExecutionResult) []types.ResourceLimit {
+	var exceeded []types.ResourceLimit
+	if truncated {
+		exceeded = append(exceeded, types.LimitOutput)
+	}
+	if result.Success {
+		return exceeded
+	}
+
+	if limit, ok := signalledLimit(limits, state, result.ExitCode); ok {
+		exceeded = append(exceeded, limit)
+	}
+	if limits.Memory > 0 && memoryErrors.MatchString(result.Stderr) {
+		exceeded = append(exceeded, types.LimitMemory)
+	}
+	if limits.Processes > 0 && processErrors.MatchString(result.Stderr) {
+		exceeded = append(exceeded, types.LimitProcesses)
+	}
+	return exceeded
+}
@@ -0,0 +1,139 @@
+//go:build linux
+
+package executor
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"syscall"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+// limitsConfigVar passes the resource limits to the re-executed binary that
+// sets them and then runs the command
+const limitsConfigVar = "NL_TO_SHELL_LIMITS"
+
+// limitsConfig is what the re-executed binary needs to run a limited command
+type limitsConfig struct {
+	Limits types.ResourceLimits
+	Path   string   // Program to run once the limits are set
+	Args   []string // Its arguments, including the program name
+}
+
+func init() {
+	data, ok := os.LookupEnv(limitsConfigVar)
+	if !ok {
+		return
+	}
+	os.Unsetenv(limitsConfigVar)
+	runLimited(data)
+}
+
+// runLimited sets the resource limits and replaces the process with the
+// command. It does not return.
+func runLimited(data string) {
+	var config limitsConfig
+	err := json.Unmarshal([]byte(data), &config)
+	if err == nil {
+		err = setResourceLimits(config.Limits)
+	}
+	if err == nil {
+		err = syscall.Exec(config.Path, config.Args, os.Environ())
+	}
+	fmt.Fprintf(os.Stderr, "%s%v\n", limitsErrorPrefix, err)
+	os.Exit(setupFailed)
+}
+
+// limitResources makes cmd set the rlimits in limits before running its
+// program, which they then apply to along with everything it starts. A
+// command whose program was not found is left to fail.
+func limitResources(cmd *exec.Cmd, limits types.ResourceLimits) error {
+	if !hasRlimits(limits) || cmd.Err != nil {
+		return nil
+	}
+
+	data, err := json.Marshal(&limitsConfig{Limits: limits, Path: cmd.Path, Args: cmd.Args})
+	if err != nil {
+		return err
+	}
+
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	cmd.Env = append(withoutVariables(env, []string{limitsConfigVar}), limitsConfigVar+"="+string(data))
+	cmd.Path = "/proc/self/exe"
+	cmd.Args = []string{"nl-to-shell-limits"}
+	return nil
+}
+
+// setResourceLimits lowers the rlimits of this process to limits
+func setResourceLimits(limits types.ResourceLimits) error {
+	if limits.CPUTime > 0 {
+		// SIGXCPU at the limit, then SIGKILL a second later for commands that catch it
+		seconds := uint64(math.Ceil(limits.CPUTime.Seconds()))
+		if err := lowerLimit(syscall.RLIMIT_CPU, seconds, seconds+1); err != nil {
+			return fmt.Errorf("failed to limit cpu time: %w", err)
+		}
+	}
+	if limits.Memory > 0 {
+		if err := lowerLimit(syscall.RLIMIT_AS, uint64(limits.Memory), uint64(limits.Memory)); err != nil {
+			return fmt.Errorf("failed to limit memory: %w", err)
+		}
+	}
+	if limits.FileSize > 0 {
+		if err := lowerLimit(syscall.RLIMIT_FSIZE, uint64(limits.FileSize), uint64(limits.FileSize)); err != nil {
+			return fmt.Errorf("failed to limit file size: %w", err)
+		}
+	}
+	if limits.Processes > 0 {
+		if err := lowerLimit(rlimitNproc, uint64(limits.Processes), uint64(limits.Processes)); err != nil {
+			return fmt.Errorf("failed to limit processes: %w", err)
+		}
+	}
+	return nil
+}
+
+// lowerLimit sets an rlimit, keeping it within the current hard limit, which
+// only privileged processes may raise
+func lowerLimit(resource int, soft, hard uint64) error {
+	var current syscall.Rlimit
+	if err := syscall.Getrlimit(resource, &current); err != nil {
+		return err
+	}
+	if hard > current.Max {
+		hard = current.Max
+	}
+	if soft > hard {
+		soft = hard
+	}
+	return syscall.Setrlimit(resource, &syscall.Rlimit{Cur: soft, Max: hard})
+}
+
+// signalledLimit reports the limit whose signal ended the command. Shells
+// exit with 128 plus the number of the signal that ended the command they ran.
+func signalledLimit(limits types.ResourceLimits, state *os.ProcessState, exitCode int) (types.ResourceLimit, bool) {
+	if state == nil {
+		return 0, false
+	}
+	var signal syscall.Signal
+	if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		signal = status.Signal()
+	} else if exitCode > 128 {
+		signal = syscall.Signal(exitCode - 128)
+	}
+
+	cpuTime := state.UserTime() + state.SystemTime()
+	switch {
+	case limits.CPUTime > 0 && (signal == syscall.SIGXCPU || signal == syscall.SIGKILL && cpuTime >= limits.CPUTime):
+		return types.LimitCPUTime, true
+	case limits.FileSize > 0 && signal == syscall.SIGXFSZ:
+		return types.LimitFileSize, true
+	default:
+		return 0, false
+	}
+}
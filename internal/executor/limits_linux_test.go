@@ -0,0 +1,131 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+func hasLimit(limits []types.ResourceLimit, limit types.ResourceLimit) bool {
+	for _, l := range limits {
+		if l == limit {
+			return true
+		}
+	}
+	return false
+}
+
+func TestExecutor_Limits_TruncatesOutput(t *testing.T) {
+	executor := NewExecutorWithConfig(&ExecutorConfig{Shell: "sh", Limits: types.ResourceLimits{MaxOutput: 1000}})
+
+	var streamed strings.Builder
+	result, err := executor.ExecuteStreaming(context.Background(), &types.Command{Generated: "yes | head -c 100000"}, &streamed, nil)
+	if err != nil || !result.Success {
+		t.Fatalf("expected the command to succeed, got %v: %s", err, result.Stderr)
+	}
+	if len(result.Stdout) != 1000 || !hasLimit(result.LimitsExceeded, types.LimitOutput) {
+		t.Errorf("expected 1000 bytes kept and the output limit reported, got %d bytes, %v", len(result.Stdout), result.LimitsExceeded)
+	}
+	if streamed.Len() != 100000 {
+		t.Errorf("expected the full output streamed, got %d bytes", streamed.Len())
+	}
+
+	if result.StdoutFile == "" {
+		t.Fatal("expected the full output to be spilled to a file")
+	}
+	defer os.Remove(result.StdoutFile)
+	if info, err := os.Stat(result.StdoutFile); err != nil || info.Size() != 100000 {
+		t.Errorf("expected the spill file to hold the full output, got %v, %v", info, err)
+	}
+	if result.StderrFile != "" {
+		t.Errorf("expected no spill file for stderr, got %s", result.StderrFile)
+	}
+
+	// Output within the limit is kept whole
+	result, _ = executor.Execute(context.Background(), &types.Command{Generated: "echo hello"})
+	if result.Stdout != "hello\n" || len(result.LimitsExceeded) != 0 || result.StdoutFile != "" {
+		t.Errorf("expected untouched output, got %q, %v", result.Stdout, result.LimitsExceeded)
+	}
+}
+
+func TestExecutor_Limits_CPUTime(t *testing.T) {
+	executor := NewExecutorWithConfig(&ExecutorConfig{Shell: "sh"})
+
+	start := time.Now()
+	result, err := executor.Execute(context.Background(), &types.Command{
+		Generated: "while :; do :; done",
+		Timeout:   20 * time.Second,
+		Limits:    types.ResourceLimits{CPUTime: time.Second},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Success || !hasLimit(result.LimitsExceeded, types.LimitCPUTime) {
+		t.Errorf("expected the cpu time limit to stop the command, got exit code %d, %v", result.ExitCode, result.LimitsExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("expected the command to stop after about a second, took %v", elapsed)
+	}
+}
+
+func TestExecutor_Limits_FileSize(t *testing.T) {
+	dir := t.TempDir()
+	executor := NewExecutorWithConfig(&ExecutorConfig{Shell: "sh"})
+
+	result, err := executor.Execute(context.Background(), &types.Command{
+		Generated:  "head -c 100000 /dev/zero > big.bin",
+		WorkingDir: dir,
+		Limits:     types.ResourceLimits{FileSize: 4096},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Success || !hasLimit(result.LimitsExceeded, types.LimitFileSize) {
+		t.Errorf("expected the file size limit to stop the command, got exit code %d, %v", result.ExitCode, result.LimitsExceeded)
+	}
+	if info, err := os.Stat(filepath.Join(dir, "big.bin")); err == nil && info.Size() > 4096 {
+		t.Errorf("expected the file to stop at the limit, got %d bytes", info.Size())
+	}
+
+	// The sandbox applies the limits itself
+	if box, err := newSandbox(dir); err == nil {
+		box.Discard()
+		result, err := executor.Execute(context.Background(), &types.Command{
+			Generated:  "head -c 100000 /dev/zero > big.bin",
+			WorkingDir: dir,
+			Sandbox:    true,
+			Limits:     types.ResourceLimits{FileSize: 4096},
+		})
+		if err == nil && result.Pending != nil {
+			result.Pending.Discard()
+		}
+		if err == nil && !hasLimit(result.LimitsExceeded, types.LimitFileSize) {
+			t.Errorf("expected the file size limit to apply in the sandbox, got exit code %d, %v", result.ExitCode, result.LimitsExceeded)
+		}
+	}
+}
+
+func TestExceededLimits_FromErrors(t *testing.T) {
+	limits := types.ResourceLimits{Memory: 1 << 20, Processes: 10}
+	failed := func(stderr string) *types.ExecutionResult {
+		return &types.ExecutionResult{ExitCode: 1, Stderr: stderr}
+	}
+
+	if got := exceededLimits(limits, nil, false, failed("sort: memory exhausted")); !hasLimit(got, types.LimitMemory) {
+		t.Errorf("expected the memory limit, got %v", got)
+	}
+	if got := exceededLimits(limits, nil, false, failed("sh: fork: Resource temporarily unavailable")); !hasLimit(got, types.LimitProcesses) {
+		t.Errorf("expected the process limit, got %v", got)
+	}
+	if got := exceededLimits(types.ResourceLimits{}, nil, false, failed("sort: memory exhausted")); len(got) != 0 {
+		t.Errorf("expected no limit when none is set, got %v", got)
+	}
+	if got := exceededLimits(limits, nil, false, &types.ExecutionResult{Success: true, Stderr: "out of memory"}); len(got) != 0 {
+		t.Errorf("expected no limit for a command that succeeded, got %v", got)
+	}
+}
@@ -0,0 +1,20 @@
+//go:build !linux
+
+package executor
+
+import (
+	"os"
+	"os/exec"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+// limitResources does nothing: rlimits are only applied on Linux, where a
+// command can be started with them already set
+func limitResources(cmd *exec.Cmd, limits types.ResourceLimits) error {
+	return nil
+}
+
+func signalledLimit(limits types.ResourceLimits, state *os.ProcessState, exitCode int) (types.ResourceLimit, bool) {
+	return 0, false
+}
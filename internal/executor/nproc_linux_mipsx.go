@@ -0,0 +1,6 @@
+//go:build linux && (mips || mipsle || mips64 || mips64le)
+
+package executor
+
+// rlimitNproc is RLIMIT_NPROC, which MIPS numbers differently
+const rlimitNproc = 8
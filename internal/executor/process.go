@@ -0,0 +1,159 @@
+package executor
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// killGracePeriod is how long a terminated command and the processes it
+// started have to exit before they are killed
+const killGracePeriod = 2 * time.Second
+
+// foregroundCommands counts the commands currently receiving the process's
+// interrupts
+var foregroundCommands atomic.Int32
+
+// CommandRunning reports whether a command is running in the foreground, in
+// which case an interrupt is meant for the command rather than the application
+func CommandRunning() bool {
+	return foregroundCommands.Load() > 0
+}
+
+// processGroup runs a command in a process group of its own, so that the
+// command and everything it starts are signalled together. Cancelling the
+// command's context terminates the group, and signals the process receives
+// while the command runs are forwarded to it.
+type processGroup struct {
+	cmd     *exec.Cmd
+	forward bool // Whether to forward signals; interactive commands get them from the terminal
+
+	mu        sync.Mutex
+	pgid      int         // The group the command leads, or 0 when it shares ours
+	killTimer *time.Timer // Kills the group once the grace period after terminate ends
+	exited    bool        // Whether wait returned; the group may no longer exist
+
+	interrupted atomic.Bool
+	stop        func()
+}
+
+// newProcessGroup prepares cmd to run in a new process group. Interactive
+// commands stay in ours, or get a session of their own with a pseudo-terminal,
+// so that they can use the user's terminal.
+func newProcessGroup(cmd *exec.Cmd, interactive bool) *processGroup {
+	g := &processGroup{cmd: cmd, forward: !interactive}
+	if !interactive {
+		setProcessGroup(cmd)
+	}
+	cmd.Cancel = func() error {
+		g.terminate()
+		return nil
+	}
+	return g
+}
+
+// start starts the command and forwards signals to it until wait returns
+func (g *processGroup) start() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if err := g.cmd.Start(); err != nil {
+		return err
+	}
+	g.pgid = processGroupOf(g.cmd.Process.Pid)
+
+	if !g.forward || g.pgid == 0 {
+		foregroundCommands.Add(1)
+		g.stop = func() { foregroundCommands.Add(-1) }
+		return nil
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, forwardedSignals...)
+	foregroundCommands.Add(1)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				g.forwardSignal(sig)
+			case <-done:
+				return
+			}
+		}
+	}()
+	g.stop = func() {
+		foregroundCommands.Add(-1)
+		signal.Stop(signals)
+		close(done)
+	}
+	return nil
+}
+
+// wait waits for the command to exit and stops forwarding signals to it.
+// Once the command is reaped its group id can be reused, so nothing is sent
+// to it afterwards.
+func (g *processGroup) wait() error {
+	err := g.cmd.Wait()
+	if g.stop != nil {
+		g.stop()
+	}
+
+	g.mu.Lock()
+	g.exited = true
+	if g.killTimer != nil {
+		g.killTimer.Stop()
+	}
+	g.mu.Unlock()
+	return err
+}
+
+// forwardSignal passes a signal on to the command. An interrupt reaches it as
+// is the first time, so that it can clean up; after that, and on SIGTERM, the
+// command is terminated.
+func (g *processGroup) forwardSignal(sig os.Signal) {
+	if isSuspend(sig) {
+		g.suspend()
+		return
+	}
+
+	if sig == os.Interrupt && !g.interrupted.Swap(true) {
+		g.signal(sig)
+		return
+	}
+	g.interrupted.Store(true)
+	g.terminate()
+}
+
+// terminate asks the command and everything it started to exit, and kills
+// whatever is left after the grace period
+func (g *processGroup) terminate() {
+	g.signal(terminateSignal)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.killTimer == nil && !g.exited {
+		g.killTimer = time.AfterFunc(killGracePeriod, func() {
+			g.signal(os.Kill)
+		})
+	}
+}
+
+// signal sends sig to the command's process group, or to the command alone
+// when it shares our group. Nothing is sent once the command has exited.
+func (g *processGroup) signal(sig os.Signal) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.exited {
+		return
+	}
+	if g.pgid != 0 {
+		signalProcessGroup(g.pgid, sig)
+	} else if g.cmd.Process != nil {
+		g.cmd.Process.Signal(sig)
+	}
+}
@@ -0,0 +1,51 @@
+//go:build !windows
+
+package executor
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// forwardedSignals are passed on to a running command
+var forwardedSignals = []os.Signal{syscall.SIGINT, syscall.SIGTERM, syscall.SIGTSTP}
+
+// terminateSignal asks a command to exit
+const terminateSignal = syscall.SIGTERM
+
+// setProcessGroup makes cmd the leader of a new process group
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+// processGroupOf returns the process group pid leads, or 0 when it is in
+// another process's group
+func processGroupOf(pid int) int {
+	if pgid, err := syscall.Getpgid(pid); err == nil && pgid == pid {
+		return pgid
+	}
+	return 0
+}
+
+// signalProcessGroup sends sig to every process in the group
+func signalProcessGroup(pgid int, sig os.Signal) {
+	if s, ok := sig.(syscall.Signal); ok {
+		syscall.Kill(-pgid, s)
+	}
+}
+
+func isSuspend(sig os.Signal) bool {
+	return sig == syscall.SIGTSTP
+}
+
+// suspend stops the command along with us when the user presses Ctrl-Z, and
+// resumes it when we are continued
+func (g *processGroup) suspend() {
+	g.signal(syscall.SIGTSTP)
+	syscall.Kill(os.Getpid(), syscall.SIGSTOP)
+	g.signal(syscall.SIGCONT)
+}
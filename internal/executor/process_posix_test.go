@@ -0,0 +1,108 @@
+//go:build !windows
+
+package executor
+
+import (
+	"context"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+// waitForExit waits for a process that is no longer our child to go away
+func waitForExit(t *testing.T, pid int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for syscall.Kill(pid, 0) == nil {
+		if time.Now().After(deadline) {
+			syscall.Kill(pid, syscall.SIGKILL)
+			t.Fatalf("expected process %d started by the command to be killed", pid)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestExecutor_Timeout_KillsProcessGroup(t *testing.T) {
+	executor := NewExecutorWithConfig(&ExecutorConfig{Shell: "sh"})
+
+	// The background sleep ignores SIGTERM, so it has to be killed
+	cmd := &types.Command{Generated: "trap '' TERM; sleep 30 & echo $!; wait", Timeout: 300 * time.Millisecond}
+	start := time.Now()
+	result, err := executor.Execute(context.Background(), cmd)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.TimedOut || result.Interrupted || result.Success {
+		t.Errorf("expected the command to time out, got %+v", result)
+	}
+	if elapsed := time.Since(start); elapsed > killGracePeriod+outputWaitDelay+time.Second {
+		t.Errorf("expected the command to be killed after the grace period, took %v", elapsed)
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(result.Stdout))
+	if err != nil {
+		t.Fatalf("expected the background process id, got %q", result.Stdout)
+	}
+	waitForExit(t, pid)
+}
+
+func TestExecutor_Interrupt_ForwardsToCommand(t *testing.T) {
+	executor := NewExecutorWithConfig(&ExecutorConfig{Shell: "sh"})
+
+	go func() {
+		for !CommandRunning() {
+			time.Sleep(10 * time.Millisecond)
+		}
+		syscall.Kill(syscall.Getpid(), syscall.SIGINT)
+	}()
+
+	result, err := executor.Execute(context.Background(), &types.Command{Generated: "sleep 30", Timeout: 10 * time.Second})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.Interrupted || result.TimedOut || result.Success {
+		t.Errorf("expected the command to be interrupted, got %+v", result)
+	}
+	if result.Error == nil || !strings.Contains(result.Error.Error(), "interrupted by user") {
+		t.Errorf("expected an interrupted error, got %v", result.Error)
+	}
+	if result.Duration > 5*time.Second {
+		t.Errorf("expected the interrupt to stop the command, took %v", result.Duration)
+	}
+	if CommandRunning() {
+		t.Error("expected no command to be running afterwards")
+	}
+}
+
+func TestExecutor_Cancel_ReportsInterrupted(t *testing.T) {
+	executor := NewExecutorWithConfig(&ExecutorConfig{Shell: "sh"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(200*time.Millisecond, cancel)
+	result, err := executor.Execute(ctx, &types.Command{Generated: "sleep 30"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.Interrupted || result.TimedOut {
+		t.Errorf("expected a cancelled command to be reported as interrupted, got %+v", result)
+	}
+}
+
+func TestProcessGroup_StopsKillTimerOnExit(t *testing.T) {
+	group := newProcessGroup(exec.CommandContext(context.Background(), "sleep", "30"), false)
+	if err := group.start(); err != nil {
+		t.Fatalf("failed to start: %v", err)
+	}
+	group.terminate()
+	group.wait()
+
+	// The group is gone, so its id must not be signalled after the grace period
+	if group.killTimer == nil || group.killTimer.Stop() {
+		t.Error("expected the kill timer to be stopped once the command exited")
+	}
+}
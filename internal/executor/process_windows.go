@@ -0,0 +1,24 @@
+package executor
+
+import (
+	"os"
+	"os/exec"
+)
+
+// forwardedSignals are passed on to a running command
+var forwardedSignals = []os.Signal{os.Interrupt}
+
+// terminateSignal asks a command to exit. Windows can only kill processes.
+var terminateSignal = os.Kill
+
+// setProcessGroup leaves cmd in our process group; only the command itself is
+// signalled on Windows
+func setProcessGroup(cmd *exec.Cmd) {}
+
+func processGroupOf(pid int) int { return 0 }
+
+func signalProcessGroup(pgid int, sig os.Signal) {}
+
+func isSuspend(sig os.Signal) bool { return false }
+
+func (g *processGroup) suspend() {}
@@ -0,0 +1,222 @@
+package executor
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+// runInteractive runs the command on a pseudo-terminal connected to the user's
+// terminal, so pagers, editors and password prompts behave as they would in a
+// shell. The terminal output is captured by stdout, which forwards it to the
+// user's terminal. Without a terminal on stdin the command is run with stdin
+// attached instead.
+func (e *Executor) runInteractive(group *processGroup, ctx context.Context, stdout, stderr *outputCapture) (exitCode int, err error) {
+	cmd := group.cmd
+	stdinFd := int(os.Stdin.Fd())
+	if !isTerminal(stdinFd) {
+		return e.runAttached(group, ctx, stdout, stderr)
+	}
+
+	master, slave, err := openPTY()
+	if err != nil {
+		return e.runAttached(group, ctx, stdout, stderr)
+	}
+	defer master.Close()
+
+	// Match the pseudo-terminal size to the user's terminal and keep it in sync
+	copyWindowSize(stdinFd, master)
+	resize := make(chan os.Signal, 1)
+	signal.Notify(resize, syscall.SIGWINCH)
+	defer func() {
+		signal.Stop(resize)
+		close(resize)
+	}()
+	go func() {
+		for range resize {
+			copyWindowSize(stdinFd, master)
+		}
+	}()
+
+	cmd.Stdin = slave
+	cmd.Stdout = slave
+	cmd.Stderr = slave
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setsid = true
+	cmd.SysProcAttr.Setctty = true
+
+	// The pseudo-terminal handles line editing and echo, so the user's
+	// terminal passes keystrokes through untouched
+	state, err := makeRaw(stdinFd)
+	if err != nil {
+		slave.Close()
+		return e.runAttached(group, ctx, stdout, stderr)
+	}
+	defer restoreTerminal(stdinFd, state)
+
+	if err := group.start(); err != nil {
+		slave.Close()
+		return -1, &types.NLShellError{
+			Type:    types.ErrTypeExecution,
+			Message: "failed to start command",
+			Cause:   err,
+		}
+	}
+	slave.Close()
+
+	// Forward the user's keystrokes until the command exits
+	stopInput, err := forwardInput(stdinFd, master)
+	if err == nil {
+		defer stopInput()
+	}
+
+	copied := make(chan struct{})
+	go func() {
+		// Reading the master fails with EIO once the command closes the terminal
+		io.Copy(stdout, master)
+		close(copied)
+	}()
+
+	err = group.wait()
+
+	// Don't wait forever on background processes that inherited the terminal
+	select {
+	case <-copied:
+	case <-time.After(outputWaitDelay):
+		master.Close()
+		<-copied
+	}
+
+	return commandExitCode(cmd, ctx, err)
+}
+
+// openPTY allocates a pseudo-terminal and returns its master and slave ends
+func openPTY() (*os.File, *os.File, error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var unlock int32
+	if err := fileIoctl(master, syscall.TIOCSPTLCK, unsafe.Pointer(&unlock)); err != nil {
+		master.Close()
+		return nil, nil, err
+	}
+
+	var number uint32
+	if err := fileIoctl(master, syscall.TIOCGPTN, unsafe.Pointer(&number)); err != nil {
+		master.Close()
+		return nil, nil, err
+	}
+
+	slave, err := os.OpenFile("/dev/pts/"+strconv.Itoa(int(number)), os.O_RDWR|syscall.O_NOCTTY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		master.Close()
+		return nil, nil, err
+	}
+
+	return master, slave, nil
+}
+
+// forwardInput copies the user's input to the pseudo-terminal. It reads from a
+// non-blocking duplicate of stdin so that the returned stop function can end
+// the copy without consuming input meant for the next prompt.
+func forwardInput(stdinFd int, master *os.File) (func(), error) {
+	dupFd, err := syscall.Dup(stdinFd)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.SetNonblock(dupFd, true); err != nil {
+		syscall.Close(dupFd)
+		return nil, err
+	}
+
+	input := os.NewFile(uintptr(dupFd), "stdin")
+	done := make(chan struct{})
+	go func() {
+		io.Copy(master, input)
+		close(done)
+	}()
+
+	return func() {
+		input.SetReadDeadline(time.Now())
+		<-done
+		input.Close()
+		// The non-blocking flag is shared with the original stdin
+		syscall.SetNonblock(stdinFd, false)
+	}, nil
+}
+
+// copyWindowSize applies the terminal size of fd to the pseudo-terminal
+func copyWindowSize(fd int, master *os.File) {
+	var size [4]uint16 // rows, columns, x pixels, y pixels
+	if ioctl(fd, syscall.TIOCGWINSZ, unsafe.Pointer(&size)) == nil {
+		fileIoctl(master, syscall.TIOCSWINSZ, unsafe.Pointer(&size))
+	}
+}
+
+// isTerminal reports whether fd refers to a terminal
+func isTerminal(fd int) bool {
+	var termios syscall.Termios
+	return ioctl(fd, syscall.TCGETS, unsafe.Pointer(&termios)) == nil
+}
+
+// makeRaw puts the terminal into raw mode and returns its previous state
+func makeRaw(fd int) (*syscall.Termios, error) {
+	var state syscall.Termios
+	if err := ioctl(fd, syscall.TCGETS, unsafe.Pointer(&state)); err != nil {
+		return nil, err
+	}
+
+	raw := state
+	raw.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP | syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	raw.Oflag &^= syscall.OPOST
+	raw.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	raw.Cflag &^= syscall.CSIZE | syscall.PARENB
+	raw.Cflag |= syscall.CS8
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+
+	if err := ioctl(fd, syscall.TCSETS, unsafe.Pointer(&raw)); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// restoreTerminal restores a terminal state saved by makeRaw
+func restoreTerminal(fd int, state *syscall.Termios) {
+	ioctl(fd, syscall.TCSETS, unsafe.Pointer(state))
+}
+
+// fileIoctl performs an ioctl on a file without switching it to blocking mode
+func fileIoctl(f *os.File, request uintptr, arg unsafe.Pointer) error {
+	conn, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var ioctlErr error
+	if err := conn.Control(func(fd uintptr) {
+		ioctlErr = ioctl(int(fd), request, arg)
+	}); err != nil {
+		return err
+	}
+	return ioctlErr
+}
+
+// ioctl performs an ioctl system call
+func ioctl(fd int, request uintptr, arg unsafe.Pointer) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), request, uintptr(arg)); errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package executor
+
+import (
+	"io"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestOpenPTY(t *testing.T) {
+	master, slave, err := openPTY()
+	if err != nil {
+		t.Skipf("pseudo-terminals are not available: %v", err)
+	}
+	defer master.Close()
+
+	if !isTerminal(int(slave.Fd())) {
+		t.Fatal("Expected the slave end to be a terminal")
+	}
+
+	cmd := exec.Command("sh", "-c", "test -t 0 && test -t 1 && echo on-a-tty")
+	cmd.Stdin = slave
+	cmd.Stdout = slave
+	cmd.Stderr = slave
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("Failed to start command: %v", err)
+	}
+	slave.Close()
+
+	output := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(master)
+		output <- string(data)
+	}()
+
+	if err := cmd.Wait(); err != nil {
+		t.Fatalf("Command failed: %v", err)
+	}
+	if got := <-output; !strings.Contains(got, "on-a-tty") {
+		t.Errorf("Expected the command to see a terminal, got: %q", got)
+	}
+}
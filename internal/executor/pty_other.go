@@ -0,0 +1,13 @@
+//go:build !linux
+
+package executor
+
+import (
+	"context"
+)
+
+// runInteractive runs the command with the user's terminal attached. Pseudo-terminals
+// are only supported on Linux, so other platforms attach stdin directly.
+func (e *Executor) runInteractive(group *processGroup, ctx context.Context, stdout, stderr *outputCapture) (exitCode int, err error) {
+	return e.runAttached(group, ctx, stdout, stderr)
+}
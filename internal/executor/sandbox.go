@@ -0,0 +1,71 @@
+package executor
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+// setupFailed is the exit status of a command whose sandbox or resource
+// limits could not be set up, as container runtimes use it. The reason follows
+// one of setupErrors' prefixes on stderr.
+const setupFailed = 125
+
+const (
+	sandboxErrorPrefix = "nl-to-shell sandbox: "
+	limitsErrorPrefix  = "nl-to-shell limits: "
+)
+
+// setupErrors describe the failures behind each stderr prefix
+var setupErrors = map[string]string{
+	sandboxErrorPrefix: "failed to set up the sandbox",
+	limitsErrorPrefix:  "failed to apply resource limits",
+}
+
+// dryRunTimeout bounds how long a dry run lets a command run in the sandbox
+const dryRunTimeout = 10 * time.Second
+
+// setupError returns the reason a command never ran when its exit status and
+// output show that its sandbox or resource limits could not be set up
+func setupError(exitCode int, stderr string) error {
+	if exitCode != setupFailed {
+		return nil
+	}
+	for prefix, message := range setupErrors {
+		if strings.HasPrefix(stderr, prefix) {
+			return &types.NLShellError{
+				Type:    types.ErrTypeExecution,
+				Message: message + ": " + strings.TrimSpace(strings.TrimPrefix(stderr, prefix)),
+			}
+		}
+	}
+	return nil
+}
+
+// within reports whether path is root or inside it
+func within(path, root string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// changePredictions describes the file changes a command made in the sandbox
+// as dry run predictions
+func changePredictions(changes []types.FileChange) []string {
+	verbs := map[types.FileChangeKind]string{
+		types.FileCreated:  "create",
+		types.FileModified: "modify",
+		types.FileDeleted:  "delete",
+	}
+
+	predictions := make([]string, 0, len(changes))
+	for _, change := range changes {
+		path := change.Path
+		if change.IsDir {
+			path += "/"
+		}
+		predictions = append(predictions, "Will "+verbs[change.Kind]+" "+path)
+	}
+	return predictions
+}
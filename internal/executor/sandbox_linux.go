@@ -0,0 +1,606 @@
+//go:build linux
+
+package executor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+// sandboxSupported reports whether this platform can run commands in the sandbox
+const sandboxSupported = true
+
+// sandboxConfigVar passes the sandbox setup to the re-executed binary that
+// prepares the namespaces and then runs the command
+const sandboxConfigVar = "NL_TO_SHELL_SANDBOX"
+
+// lockedMountFlags are mount flags a user namespace cannot clear, so they are
+// kept when a mount is made read-only. The statfs flags have the same values.
+const lockedMountFlags = syscall.MS_NOSUID | syscall.MS_NODEV | syscall.MS_NOEXEC |
+	syscall.MS_NOATIME | syscall.MS_NODIRATIME | syscall.MS_RELATIME
+
+// sandboxConfig is what the re-executed binary needs to set up the sandbox
+type sandboxConfig struct {
+	WorkingDir string   // Covered by a writable overlay
+	Dir        string   // Holds the overlay's upper and work directories
+	Scratch    string   // Covered by an empty tmpfs and used as TMPDIR
+	Hidden     []string // Covered by empty tmpfs mounts to hide the sockets in them
+	Path       string   // Program to run once the sandbox is set up
+	Args       []string // Its arguments, including the program name
+	Limits     types.ResourceLimits
+}
+
+func init() {
+	data, ok := os.LookupEnv(sandboxConfigVar)
+	if !ok {
+		return
+	}
+	os.Unsetenv(sandboxConfigVar)
+
+	// Only the first process of the sandbox's pid namespace sets it up
+	if os.Getpid() == 1 {
+		runSandbox(data)
+	}
+}
+
+// runSandbox sets up the sandbox in the namespaces this process was started
+// in and replaces the process with the command. It does not return.
+func runSandbox(data string) {
+	var config sandboxConfig
+	err := json.Unmarshal([]byte(data), &config)
+	if err == nil {
+		err = enterSandbox(&config)
+	}
+	if err == nil {
+		err = setResourceLimits(config.Limits)
+	}
+	if err == nil {
+		env := append(withoutVariables(os.Environ(), []string{"TMPDIR"}), "TMPDIR="+config.Scratch)
+		err = syscall.Exec(config.Path, config.Args, env)
+	}
+	fmt.Fprintf(os.Stderr, "%s%v\n", sandboxErrorPrefix, err)
+	os.Exit(setupFailed)
+}
+
+// enterSandbox makes every mount read-only, then covers the working directory
+// with an overlay whose changes go to config.Dir, and the scratch and hidden
+// directories with empty tmpfs mounts
+func enterSandbox(config *sandboxConfig) error {
+	// Nothing mounted here may reach the host
+	if err := syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("failed to make mounts private: %w", err)
+	}
+	mountPoints, err := readMountPoints()
+	if err != nil {
+		return err
+	}
+
+	// The overlay's upper directory stays writable until the overlay is
+	// mounted, which keeps its own reference to it
+	if err := syscall.Mount(config.Dir, config.Dir, "", syscall.MS_BIND, ""); err != nil {
+		return fmt.Errorf("failed to mount %s: %w", config.Dir, err)
+	}
+	for _, mountPoint := range mountPoints {
+		if err := remountReadOnly(mountPoint); err != nil {
+			return err
+		}
+	}
+	if err := mountOverlay(config); err != nil {
+		return err
+	}
+	if err := syscall.Unmount(config.Dir, syscall.MNT_DETACH); err != nil {
+		return fmt.Errorf("failed to unmount %s: %w", config.Dir, err)
+	}
+
+	if err := syscall.Mount("tmpfs", config.Scratch, "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, "mode=1777"); err != nil {
+		return fmt.Errorf("failed to mount a temporary directory on %s: %w", config.Scratch, err)
+	}
+
+	if err := hideDirectories(config); err != nil {
+		return err
+	}
+
+	// Without a proc of its own the command sees the host's processes, read-only
+	syscall.Mount("proc", "/proc", "proc", syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_NOEXEC, "")
+
+	// The process started in the directory under the overlay
+	return syscall.Chdir(config.WorkingDir)
+}
+
+// socketDirectories are where the host's services usually listen. A read-only
+// mount does not stop a command from connecting to a Unix socket, so these
+// are hidden in the sandbox.
+var socketDirectories = []string{"/run", "/var/run", "/tmp/.X11-unix"}
+
+// hiddenDirectories returns the directories to hide in a sandbox for a
+// command with the environment env
+func hiddenDirectories(env []string) []string {
+	dirs := append([]string(nil), socketDirectories...)
+	for _, entry := range env {
+		if value, ok := strings.CutPrefix(entry, "XDG_RUNTIME_DIR="); ok && filepath.IsAbs(value) {
+			dirs = append(dirs, value)
+		}
+	}
+	return dirs
+}
+
+// hideDirectories covers each existing hidden directory with an empty tmpfs.
+// Directories holding the working or scratch directory stay visible.
+func hideDirectories(config *sandboxConfig) error {
+	hidden := make(map[string]bool)
+	for _, dir := range config.Hidden {
+		resolved, err := filepath.EvalSymlinks(dir)
+		if err != nil {
+			continue
+		}
+		if hidden[resolved] || within(config.WorkingDir, resolved) || within(config.Scratch, resolved) {
+			continue
+		}
+		if info, err := os.Stat(resolved); err != nil || !info.IsDir() {
+			continue
+		}
+		if err := syscall.Mount("tmpfs", resolved, "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_NOEXEC, "mode=755"); err != nil {
+			return fmt.Errorf("failed to hide %s: %w", resolved, err)
+		}
+		hidden[resolved] = true
+	}
+	return nil
+}
+
+// readMountPoints returns the mount points of this mount namespace, parents
+// before the mounts on them
+func readMountPoints() ([]string, error) {
+	file, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mounts: %w", err)
+	}
+	defer file.Close()
+
+	var mountPoints []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) > 4 {
+			mountPoints = append(mountPoints, unescapeMountPath(fields[4]))
+		}
+	}
+	return mountPoints, scanner.Err()
+}
+
+// unescapeMountPath decodes the octal escapes mountinfo uses for spaces,
+// tabs, newlines and backslashes
+func unescapeMountPath(path string) string {
+	if !strings.Contains(path, `\`) {
+		return path
+	}
+	var decoded strings.Builder
+	for i := 0; i < len(path); i++ {
+		if path[i] == '\\' && i+3 < len(path) {
+			if value, err := strconv.ParseUint(path[i+1:i+4], 8, 8); err == nil {
+				decoded.WriteByte(byte(value))
+				i += 3
+				continue
+			}
+		}
+		decoded.WriteByte(path[i])
+	}
+	return decoded.String()
+}
+
+// remountReadOnly makes the mount at path read-only. Mounts the command could
+// not reach either are skipped.
+func remountReadOnly(path string) error {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		if errors.Is(err, syscall.ENOENT) || errors.Is(err, syscall.EACCES) {
+			return nil
+		}
+		return fmt.Errorf("failed to inspect mount %s: %w", path, err)
+	}
+
+	flags := uintptr(stat.Flags) & lockedMountFlags
+	if err := syscall.Mount("", path, "", syscall.MS_BIND|syscall.MS_REMOUNT|syscall.MS_RDONLY|flags, ""); err != nil {
+		return fmt.Errorf("failed to make %s read-only: %w", path, err)
+	}
+	return nil
+}
+
+// mountOverlay covers the working directory with an overlay. Unprivileged
+// overlays keep their metadata in user xattrs, which older kernels do not offer.
+func mountOverlay(config *sandboxConfig) error {
+	options := fmt.Sprintf("lowerdir=%s,upperdir=%s,workdir=%s",
+		overlayEscape(config.WorkingDir),
+		overlayEscape(filepath.Join(config.Dir, "upper")),
+		overlayEscape(filepath.Join(config.Dir, "work")))
+
+	err := syscall.Mount("overlay", config.WorkingDir, "overlay", 0, options+",userxattr")
+	if err != nil {
+		err = syscall.Mount("overlay", config.WorkingDir, "overlay", 0, options)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to mount an overlay on %s: %w", config.WorkingDir, err)
+	}
+	return nil
+}
+
+// overlayEscape escapes the characters that separate overlay mount options
+func overlayEscape(path string) string {
+	return strings.NewReplacer(`\`, `\\`, `,`, `\,`, `:`, `\:`).Replace(path)
+}
+
+// sandbox runs a command in its own user, mount, pid and network namespaces.
+// Everything but the working directory and a fresh temporary directory is
+// read-only, and changes to the working directory go to an overlay outside it
+// until they are applied. The usual socket directories are hidden, but Unix
+// sockets elsewhere can still be connected to, so the sandbox does not
+// contain a command that talks to a host service. Root inside the sandbox is
+// the user outside it.
+type sandbox struct {
+	workingDir string
+	dir        string
+}
+
+// newSandbox prepares a sandbox for a command running in workingDir
+func newSandbox(workingDir string) (*sandbox, error) {
+	workingDir, err := filepath.Abs(workingDir)
+	if err == nil {
+		workingDir, err = filepath.EvalSymlinks(workingDir)
+	}
+	if err != nil {
+		return nil, &types.NLShellError{
+			Type:    types.ErrTypeExecution,
+			Message: "failed to resolve the working directory for the sandbox",
+			Cause:   err,
+		}
+	}
+
+	dir, err := os.MkdirTemp("", "nl-to-shell-sandbox-*")
+	if err == nil {
+		dir, err = filepath.EvalSymlinks(dir)
+	}
+	if err != nil {
+		return nil, &types.NLShellError{
+			Type:    types.ErrTypeExecution,
+			Message: "failed to create the sandbox directory",
+			Cause:   err,
+		}
+	}
+	s := &sandbox{workingDir: workingDir, dir: dir}
+
+	// The overlay's layers cannot overlap
+	if within(dir, workingDir) {
+		s.Discard()
+		return nil, &types.NLShellError{
+			Type:    types.ErrTypeExecution,
+			Message: fmt.Sprintf("cannot sandbox commands in %s, which contains the temporary directory", workingDir),
+		}
+	}
+	for _, name := range []string{"upper", "work"} {
+		if err := os.Mkdir(filepath.Join(dir, name), 0700); err != nil {
+			s.Discard()
+			return nil, &types.NLShellError{
+				Type:    types.ErrTypeExecution,
+				Message: "failed to create the sandbox directory",
+				Cause:   err,
+			}
+		}
+	}
+	return s, nil
+}
+
+// wrap makes cmd start the sandbox, which then runs the program cmd would have
+// with the given limits. A command whose program was not found is left to fail.
+func (s *sandbox) wrap(cmd *exec.Cmd, limits types.ResourceLimits) error {
+	if cmd.Err != nil {
+		return nil
+	}
+
+	// Commands get an empty temporary directory. When the working directory is
+	// inside the system one, it stays visible and the sandbox's own is used.
+	scratch := os.TempDir()
+	if resolved, err := filepath.EvalSymlinks(scratch); err == nil {
+		scratch = resolved
+	}
+	if within(s.workingDir, scratch) {
+		scratch = s.dir
+	}
+
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	data, err := json.Marshal(&sandboxConfig{
+		WorkingDir: s.workingDir,
+		Dir:        s.dir,
+		Scratch:    scratch,
+		Hidden:     hiddenDirectories(env),
+		Path:       cmd.Path,
+		Args:       cmd.Args,
+		Limits:     limits,
+	})
+	if err != nil {
+		return err
+	}
+
+	cmd.Env = append(withoutVariables(env, []string{sandboxConfigVar}), sandboxConfigVar+"="+string(data))
+	cmd.Path = "/proc/self/exe"
+	cmd.Args = []string{"nl-to-shell-sandbox"}
+
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Cloneflags |= syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS | syscall.CLONE_NEWPID | syscall.CLONE_NEWNET
+	cmd.SysProcAttr.UidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getuid(), Size: 1}}
+	cmd.SysProcAttr.GidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getgid(), Size: 1}}
+	cmd.SysProcAttr.GidMappingsEnableSetgroups = false
+	return nil
+}
+
+// collectChanges compares the overlay's upper directory with the working
+// directory to find the files the command created, modified or deleted
+func (s *sandbox) collectChanges() ([]types.FileChange, error) {
+	upper := filepath.Join(s.dir, "upper")
+
+	var changes []types.FileChange
+	err := filepath.WalkDir(upper, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || path == upper {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(upper, path)
+		lower, lowerErr := os.Lstat(filepath.Join(s.workingDir, rel))
+		exists := lowerErr == nil
+
+		switch {
+		case isWhiteout(info):
+			if exists {
+				changes = append(changes, types.FileChange{Path: rel, Kind: types.FileDeleted, IsDir: lower.IsDir()})
+			}
+		case info.IsDir():
+			switch {
+			case !exists:
+				changes = append(changes, types.FileChange{Path: rel, Kind: types.FileCreated, IsDir: true})
+			case !lower.IsDir():
+				changes = append(changes, types.FileChange{Path: rel, Kind: types.FileModified, IsDir: true})
+			case isOpaque(path):
+				// The directory was removed and made again, so whatever it
+				// held that is not in the upper directory is gone
+				deleted, err := deletedEntries(filepath.Join(s.workingDir, rel), path, rel)
+				if err != nil {
+					return err
+				}
+				changes = append(changes, deleted...)
+			}
+		default:
+			switch {
+			case !exists:
+				changes = append(changes, types.FileChange{Path: rel, Kind: types.FileCreated})
+			case lower.IsDir() || fileChanged(path, info, filepath.Join(s.workingDir, rel), lower):
+				changes = append(changes, types.FileChange{Path: rel, Kind: types.FileModified})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, &types.NLShellError{
+			Type:    types.ErrTypeExecution,
+			Message: "failed to read the sandbox changes",
+			Cause:   err,
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes, nil
+}
+
+// deletedEntries lists the entries of the real directory that an opaque
+// directory in the upper directory no longer has
+func deletedEntries(realDir, upperDir, rel string) ([]types.FileChange, error) {
+	entries, err := os.ReadDir(realDir)
+	if err != nil {
+		return nil, err
+	}
+	var deleted []types.FileChange
+	for _, entry := range entries {
+		if _, err := os.Lstat(filepath.Join(upperDir, entry.Name())); err == nil {
+			continue
+		}
+		deleted = append(deleted, types.FileChange{Path: filepath.Join(rel, entry.Name()), Kind: types.FileDeleted, IsDir: entry.IsDir()})
+	}
+	return deleted, nil
+}
+
+// isWhiteout reports whether info is an overlay whiteout, which marks a
+// deleted file: a character device with device number 0
+func isWhiteout(info fs.FileInfo) bool {
+	if info.Mode()&fs.ModeCharDevice == 0 {
+		return false
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	return ok && stat.Rdev == 0
+}
+
+// isOpaque reports whether the overlay marked a directory as replacing the
+// directory beneath it rather than merging with it
+func isOpaque(path string) bool {
+	value := make([]byte, 1)
+	for _, name := range []string{"user.overlay.opaque", "trusted.overlay.opaque"} {
+		if n, err := syscall.Getxattr(path, name, value); err == nil && n == 1 && value[0] == 'y' {
+			return true
+		}
+	}
+	return false
+}
+
+// fileChanged reports whether a file in the upper directory differs from the
+// real one, which it may not when the command only opened it for writing
+func fileChanged(path string, info fs.FileInfo, realPath string, real fs.FileInfo) bool {
+	if info.Mode() != real.Mode() || info.Size() != real.Size() {
+		return true
+	}
+	if info.Mode()&fs.ModeSymlink != 0 {
+		target, err := os.Readlink(path)
+		realTarget, realErr := os.Readlink(realPath)
+		return err != nil || realErr != nil || target != realTarget
+	}
+	if !info.Mode().IsRegular() {
+		return true
+	}
+	same, err := sameContents(path, realPath)
+	return err != nil || !same
+}
+
+// sameContents compares two files of the same size
+func sameContents(a, b string) (bool, error) {
+	fileA, err := os.Open(a)
+	if err != nil {
+		return false, err
+	}
+	defer fileA.Close()
+	fileB, err := os.Open(b)
+	if err != nil {
+		return false, err
+	}
+	defer fileB.Close()
+
+	bufA, bufB := make([]byte, 64*1024), make([]byte, 64*1024)
+	for {
+		n, errA := io.ReadFull(fileA, bufA)
+		m, errB := io.ReadFull(fileB, bufB)
+		if n != m || !bytes.Equal(bufA[:n], bufB[:m]) {
+			return false, nil
+		}
+		if errA == io.EOF || errA == io.ErrUnexpectedEOF {
+			return errB == errA, nil
+		}
+		if errA != nil {
+			return false, errA
+		}
+		if errB != nil {
+			return false, errB
+		}
+	}
+}
+
+// Apply copies the command's changes into the real working directory and
+// removes the sandbox
+func (s *sandbox) Apply() error {
+	upper := filepath.Join(s.dir, "upper")
+
+	err := filepath.WalkDir(upper, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || path == upper {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(upper, path)
+		target := filepath.Join(s.workingDir, rel)
+		real, realErr := os.Lstat(target)
+
+		switch {
+		case isWhiteout(info):
+			return os.RemoveAll(target)
+		case info.IsDir():
+			if realErr == nil && (!real.IsDir() || isOpaque(path)) {
+				if err := os.RemoveAll(target); err != nil {
+					return err
+				}
+				realErr = fs.ErrNotExist
+			}
+			if realErr != nil {
+				return os.Mkdir(target, info.Mode().Perm())
+			}
+			return os.Chmod(target, info.Mode().Perm())
+		case info.Mode()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			if err := os.RemoveAll(target); err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case info.Mode().IsRegular():
+			if realErr == nil && !fileChanged(path, info, target, real) {
+				return nil
+			}
+			if realErr == nil && real.IsDir() {
+				if err := os.RemoveAll(target); err != nil {
+					return err
+				}
+			}
+			return copyFile(path, target, info.Mode().Perm())
+		default:
+			// Pipes, sockets and devices are left in the sandbox
+			return nil
+		}
+	})
+	if err != nil {
+		return &types.NLShellError{
+			Type:    types.ErrTypeExecution,
+			Message: "failed to apply the sandbox changes",
+			Cause:   err,
+		}
+	}
+	return s.Discard()
+}
+
+// copyFile replaces target with a copy of source, so a failed copy leaves
+// the target as it was
+func copyFile(source, target string, perm fs.FileMode) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.CreateTemp(filepath.Dir(target), ".nl-to-shell-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(out.Name())
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(out.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(out.Name(), target)
+}
+
+// Discard removes the sandbox without applying its changes
+func (s *sandbox) Discard() error {
+	// The overlay leaves directories in its work directory that even their
+	// owner cannot list
+	filepath.WalkDir(s.dir, func(path string, entry fs.DirEntry, err error) error {
+		if entry != nil && entry.IsDir() {
+			os.Chmod(path, 0700)
+		}
+		return nil
+	})
+	return os.RemoveAll(s.dir)
+}
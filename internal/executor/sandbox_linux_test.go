@@ -0,0 +1,214 @@
+package executor
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+// requireSandbox skips the test when namespaces are not available to this user
+func requireSandbox(t *testing.T) *Executor {
+	t.Helper()
+	executor := NewExecutorWithConfig(&ExecutorConfig{Shell: "sh"}).(*Executor)
+	result, err := executor.Execute(context.Background(), &types.Command{Generated: "true", WorkingDir: t.TempDir(), Sandbox: true})
+	if err != nil || !result.Success {
+		t.Skipf("the sandbox is not available: %v", err)
+	}
+	return executor
+}
+
+func writeTestFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestExecutor_Sandbox_HoldsChangesUntilApplied(t *testing.T) {
+	executor := requireSandbox(t)
+
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{
+		"edit.txt":      "before\n",
+		"remove.txt":    "gone\n",
+		"keep.txt":      "same\n",
+		"old/inner.txt": "inner\n",
+		"redo/old.txt":  "old\n",
+	})
+	outside := t.TempDir()
+
+	cmd := &types.Command{
+		Generated: "echo after > edit.txt && rm remove.txt && rm -r old && mkdir new && echo hi > new/file.txt && " +
+			"rm -r redo && mkdir redo && touch redo/new.txt && cat keep.txt > keep.txt.copy && rm keep.txt.copy && " +
+			"echo $$ && (echo escaped > " + shellQuote(filepath.Join(outside, "escaped.txt")) + " 2>/dev/null || echo blocked)",
+		WorkingDir: dir,
+		Sandbox:    true,
+	}
+	result, err := executor.Execute(context.Background(), cmd)
+	if err != nil || !result.Success {
+		t.Fatalf("expected the command to succeed, got %v: %s", err, result.Stderr)
+	}
+	if result.Stdout != "1\nblocked\n" {
+		t.Errorf("expected the command to be the first process and unable to write outside the working directory, got %q", result.Stdout)
+	}
+
+	want := []types.FileChange{
+		{Path: "edit.txt", Kind: types.FileModified},
+		{Path: "new", Kind: types.FileCreated, IsDir: true},
+		{Path: "new/file.txt", Kind: types.FileCreated},
+		{Path: "old", Kind: types.FileDeleted, IsDir: true},
+		{Path: "redo/new.txt", Kind: types.FileCreated},
+		{Path: "redo/old.txt", Kind: types.FileDeleted},
+		{Path: "remove.txt", Kind: types.FileDeleted},
+	}
+	if !reflect.DeepEqual(result.Changes, want) {
+		t.Errorf("expected changes\n%+v\ngot\n%+v", want, result.Changes)
+	}
+
+	// Nothing has changed yet
+	if data, _ := os.ReadFile(filepath.Join(dir, "edit.txt")); string(data) != "before\n" {
+		t.Errorf("expected edit.txt unchanged before applying, got %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "new")); !os.IsNotExist(err) {
+		t.Error("expected new/ not to exist before applying")
+	}
+
+	if result.Pending == nil {
+		t.Fatal("expected pending changes")
+	}
+	if err := result.Pending.Apply(); err != nil {
+		t.Fatalf("failed to apply changes: %v", err)
+	}
+
+	if data, _ := os.ReadFile(filepath.Join(dir, "edit.txt")); string(data) != "after\n" {
+		t.Errorf("expected edit.txt changed, got %q", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "new", "file.txt")); string(data) != "hi\n" {
+		t.Errorf("expected new/file.txt created, got %q", data)
+	}
+	for _, name := range []string{"remove.txt", "old", "redo/old.txt", "keep.txt.copy"} {
+		if _, err := os.Lstat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("expected %s not to exist after applying", name)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "redo", "new.txt")); err != nil {
+		t.Errorf("expected redo/new.txt created: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "escaped.txt")); !os.IsNotExist(err) {
+		t.Error("expected nothing written outside the working directory")
+	}
+}
+
+func TestExecutor_Sandbox_Discard(t *testing.T) {
+	executor := requireSandbox(t)
+
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{"data.txt": "original\n"})
+
+	result, err := executor.Execute(context.Background(), &types.Command{Generated: "rm data.txt && touch made.txt", WorkingDir: dir, Sandbox: true})
+	if err != nil || result.Pending == nil {
+		t.Fatalf("expected pending changes, got %v, %+v", err, result)
+	}
+	sandboxDir := result.Pending.(*sandbox).dir
+	if err := result.Pending.Discard(); err != nil {
+		t.Fatalf("failed to discard changes: %v", err)
+	}
+	if _, err := os.Stat(sandboxDir); !os.IsNotExist(err) {
+		t.Error("expected the sandbox to be removed")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 || entries[0].Name() != "data.txt" {
+		t.Errorf("expected the directory untouched, got %v", entries)
+	}
+
+	// A command that changes nothing leaves nothing to apply
+	result, err = executor.Execute(context.Background(), &types.Command{Generated: "cat data.txt", WorkingDir: dir, Sandbox: true})
+	if err != nil || !result.Sandboxed || result.Pending != nil || len(result.Changes) != 0 {
+		t.Errorf("expected no changes, got %v, %+v", err, result)
+	}
+}
+
+func TestExecutor_DryRun_Sandbox(t *testing.T) {
+	executor := requireSandbox(t)
+
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{"notes.txt": "notes\n"})
+
+	result, err := executor.DryRun(&types.Command{Generated: "mv notes.txt archive.txt", WorkingDir: dir, Sandbox: true, Validated: true})
+	if err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	if !result.Sandboxed {
+		t.Fatalf("expected the dry run to use the sandbox, got predictions %v", result.Predictions)
+	}
+	want := []types.FileChange{
+		{Path: "archive.txt", Kind: types.FileCreated},
+		{Path: "notes.txt", Kind: types.FileDeleted},
+	}
+	if !reflect.DeepEqual(result.Changes, want) {
+		t.Errorf("expected changes %+v, got %+v", want, result.Changes)
+	}
+	predictions := strings.Join(result.Predictions, "\n")
+	for _, prediction := range []string{"Will create archive.txt", "Will delete notes.txt"} {
+		if !strings.Contains(predictions, prediction) {
+			t.Errorf("expected prediction %q, got:\n%s", prediction, predictions)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "notes.txt")); err != nil {
+		t.Error("expected the dry run not to move the file")
+	}
+}
+
+func TestExecutor_DryRun_UnconfirmedIsNotRun(t *testing.T) {
+	executor := NewExecutorWithConfig(&ExecutorConfig{Shell: "sh"}).(*Executor)
+	dir := t.TempDir()
+
+	result, err := executor.DryRun(&types.Command{Generated: "touch made.txt", WorkingDir: dir, Sandbox: true})
+	if err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	if result.Sandboxed || !strings.Contains(strings.Join(result.Predictions, "\n"), "has not been confirmed") {
+		t.Errorf("expected an unconfirmed command not to run in the sandbox, got predictions %v", result.Predictions)
+	}
+}
+
+func TestExecutor_Sandbox_HidesSockets(t *testing.T) {
+	executor := requireSandbox(t)
+
+	runtimeDir := t.TempDir()
+	t.Setenv("XDG_RUNTIME_DIR", runtimeDir)
+	listener, err := net.Listen("unix", filepath.Join(runtimeDir, "service.sock"))
+	if err != nil {
+		t.Skipf("cannot listen on a Unix socket: %v", err)
+	}
+	defer listener.Close()
+
+	result, err := executor.Execute(context.Background(), &types.Command{
+		Generated:  `if [ -S "$XDG_RUNTIME_DIR/service.sock" ]; then echo visible; else echo hidden; fi; ls /run | wc -l`,
+		WorkingDir: t.TempDir(),
+		Sandbox:    true,
+	})
+	if err != nil || !result.Success {
+		t.Fatalf("expected the command to succeed, got %v: %s", err, result.Stderr)
+	}
+	if fields := strings.Fields(result.Stdout); len(fields) != 2 || fields[0] != "hidden" || fields[1] != "0" {
+		t.Errorf("expected the socket directories to be empty in the sandbox, got %q", result.Stdout)
+	}
+}
+
+func TestUnescapeMountPath(t *testing.T) {
+	if got := unescapeMountPath(`/mnt/with\040space\134slash`); got != `/mnt/with space\slash` {
+		t.Errorf("unexpected path %q", got)
+	}
+}
@@ -0,0 +1,30 @@
+//go:build !linux
+
+package executor
+
+import (
+	"os/exec"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+// sandboxSupported reports whether this platform can run commands in the sandbox
+const sandboxSupported = false
+
+// sandbox needs Linux namespaces, so other platforms cannot create one
+type sandbox struct{}
+
+func newSandbox(workingDir string) (*sandbox, error) {
+	return nil, &types.NLShellError{
+		Type:    types.ErrTypeExecution,
+		Message: "sandboxed execution requires Linux",
+	}
+}
+
+func (s *sandbox) wrap(cmd *exec.Cmd, limits types.ResourceLimits) error { return nil }
+
+func (s *sandbox) collectChanges() ([]types.FileChange, error) { return nil, nil }
+
+func (s *sandbox) Apply() error { return nil }
+
+func (s *sandbox) Discard() error { return nil }
@@ -0,0 +1,171 @@
+package executor
+
+import (
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+const (
+	// shellOperatorChars are unquoted characters that introduce pipes, redirects,
+	// command lists, subshells or expansions
+	shellOperatorChars = "|&;<>()$`"
+	// shellGlobChars are unquoted characters that trigger pathname or brace expansion
+	shellGlobChars = "*?[{"
+)
+
+// assignmentPrefix matches a leading NAME=value assignment
+var assignmentPrefix = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*=`)
+
+// splitCommand tokenizes a command string using POSIX shell quoting rules.
+// It returns the resulting words and whether the command relies on shell
+// features (pipes, redirects, expansions, globs, ...) that only a real shell
+// can interpret.
+func splitCommand(cmdStr string) ([]string, bool, error) {
+	var words []string
+	var current strings.Builder
+	inWord := false
+	needsShell := assignmentPrefix.MatchString(cmdStr)
+
+	flush := func() {
+		if inWord {
+			words = append(words, current.String())
+			current.Reset()
+			inWord = false
+		}
+	}
+
+	for i := 0; i < len(cmdStr); i++ {
+		char := cmdStr[i]
+
+		switch char {
+		case ' ', '\t':
+			flush()
+		case '\n':
+			// Multiple command lines
+			flush()
+			needsShell = true
+		case '\\':
+			if i+1 >= len(cmdStr) {
+				return nil, false, &types.NLShellError{
+					Type:    types.ErrTypeExecution,
+					Message: "trailing backslash in command",
+				}
+			}
+			i++
+			if cmdStr[i] == '\n' {
+				// Line continuation
+				continue
+			}
+			current.WriteByte(cmdStr[i])
+			inWord = true
+		case '\'':
+			// Everything up to the next single quote is literal
+			end := strings.IndexByte(cmdStr[i+1:], '\'')
+			if end < 0 {
+				return nil, false, &types.NLShellError{
+					Type:    types.ErrTypeExecution,
+					Message: "unclosed quote in command",
+				}
+			}
+			current.WriteString(cmdStr[i+1 : i+1+end])
+			inWord = true
+			i += end + 1
+		case '"':
+			inWord = true
+			closed := false
+			for i++; i < len(cmdStr); i++ {
+				c := cmdStr[i]
+				if c == '"' {
+					closed = true
+					break
+				}
+				// Inside double quotes a backslash only escapes $ ` " \ and newline
+				if c == '\\' && i+1 < len(cmdStr) && strings.IndexByte("$`\"\\\n", cmdStr[i+1]) >= 0 {
+					i++
+					if cmdStr[i] != '\n' {
+						current.WriteByte(cmdStr[i])
+					}
+					continue
+				}
+				if c == '$' || c == '`' {
+					needsShell = true
+				}
+				current.WriteByte(c)
+			}
+			if !closed {
+				return nil, false, &types.NLShellError{
+					Type:    types.ErrTypeExecution,
+					Message: "unclosed quote in command",
+				}
+			}
+		default:
+			if strings.IndexByte(shellOperatorChars, char) >= 0 || strings.IndexByte(shellGlobChars, char) >= 0 {
+				needsShell = true
+			} else if !inWord && (char == '~' || char == '#') {
+				// Tilde expansion or comment at the start of a word
+				needsShell = true
+			}
+			current.WriteByte(char)
+			inWord = true
+		}
+	}
+
+	flush()
+
+	return words, needsShell, nil
+}
+
+// defaultShell returns the shell used when neither the command nor the executor specifies one
+func defaultShell() string {
+	if runtime.GOOS == "windows" {
+		return "cmd"
+	}
+	return "sh"
+}
+
+// shellName normalizes a shell name or path to its base name (e.g. /usr/bin/zsh -> zsh)
+func shellName(shell string) string {
+	name := filepath.Base(strings.TrimSpace(shell))
+	return strings.TrimSuffix(strings.ToLower(name), ".exe")
+}
+
+// shellArgs returns the arguments that make the named shell execute a command string
+func shellArgs(name string) []string {
+	switch name {
+	case "powershell", "pwsh":
+		return []string{"-NoProfile", "-NonInteractive", "-Command"}
+	case "cmd":
+		return []string{"/C"}
+	default:
+		// bash, zsh, sh, dash, ksh, fish and most other shells accept -c
+		return []string{"-c"}
+	}
+}
+
+// resolveShell locates the given shell and returns its path and the arguments
+// that precede the command string
+func resolveShell(shell string) (string, []string, error) {
+	if strings.TrimSpace(shell) == "" {
+		shell = defaultShell()
+	}
+
+	path := shell
+	if !strings.ContainsAny(shell, `/\`) {
+		resolved, err := exec.LookPath(shell)
+		if err != nil {
+			return "", nil, &types.NLShellError{
+				Type:    types.ErrTypeExecution,
+				Message: "shell not found: " + shell,
+				Cause:   err,
+			}
+		}
+		path = resolved
+	}
+
+	return path, shellArgs(shellName(shell)), nil
+}
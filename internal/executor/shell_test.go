@@ -0,0 +1,322 @@
+package executor
+
+import (
+	"context"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+func TestSplitCommand(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		expected   []string
+		needsShell bool
+		hasError   bool
+	}{
+		{
+			name:     "simple command",
+			input:    "ls -la /tmp",
+			expected: []string{"ls", "-la", "/tmp"},
+		},
+		{
+			name:     "single quotes are literal",
+			input:    `echo 'a "b" $HOME'`,
+			expected: []string{"echo", `a "b" $HOME`},
+		},
+		{
+			name:     "double quotes with escapes",
+			input:    `echo "say \"hi\" \\ \n"`,
+			expected: []string{"echo", `say "hi" \ \n`},
+		},
+		{
+			name:     "adjacent quoted segments form one word",
+			input:    `echo foo'bar'"baz"`,
+			expected: []string{"echo", "foobarbaz"},
+		},
+		{
+			name:     "empty quotes produce an empty argument",
+			input:    `printf '%s' ""`,
+			expected: []string{"printf", "%s", ""},
+		},
+		{
+			name:     "backslash escapes outside quotes",
+			input:    `echo hello\ world \|`,
+			expected: []string{"echo", "hello world", "|"},
+		},
+		{
+			name:       "pipe",
+			input:      "ls | wc -l",
+			expected:   []string{"ls", "|", "wc", "-l"},
+			needsShell: true,
+		},
+		{
+			name:       "redirect",
+			input:      "echo hi > out.txt",
+			expected:   []string{"echo", "hi", ">", "out.txt"},
+			needsShell: true,
+		},
+		{
+			name:       "command list",
+			input:      "mkdir a && cd a",
+			expected:   []string{"mkdir", "a", "&&", "cd", "a"},
+			needsShell: true,
+		},
+		{
+			name:       "command substitution",
+			input:      "echo $(date)",
+			expected:   []string{"echo", "$(date)"},
+			needsShell: true,
+		},
+		{
+			name:       "expansion inside double quotes",
+			input:      `echo "$HOME"`,
+			expected:   []string{"echo", "$HOME"},
+			needsShell: true,
+		},
+		{
+			name:       "glob",
+			input:      "ls *.go",
+			expected:   []string{"ls", "*.go"},
+			needsShell: true,
+		},
+		{
+			name:       "tilde at word start",
+			input:      "ls ~/src",
+			expected:   []string{"ls", "~/src"},
+			needsShell: true,
+		},
+		{
+			name:     "tilde inside word",
+			input:    "echo a~b",
+			expected: []string{"echo", "a~b"},
+		},
+		{
+			name:       "environment assignment prefix",
+			input:      "FOO=bar env",
+			expected:   []string{"FOO=bar", "env"},
+			needsShell: true,
+		},
+		{
+			name:     "quoted operators do not need a shell",
+			input:    `grep "a|b" '*.txt'`,
+			expected: []string{"grep", "a|b", "*.txt"},
+		},
+		{
+			name:     "unclosed single quote",
+			input:    `echo 'hello`,
+			hasError: true,
+		},
+		{
+			name:     "unclosed double quote",
+			input:    `echo "hello`,
+			hasError: true,
+		},
+		{
+			name:     "trailing backslash",
+			input:    `echo hello\`,
+			hasError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			words, needsShell, err := splitCommand(tt.input)
+
+			if tt.hasError {
+				if err == nil {
+					t.Errorf("Expected error for input %q", tt.input)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Unexpected error for input %q: %v", tt.input, err)
+			}
+
+			if needsShell != tt.needsShell {
+				t.Errorf("Expected needsShell=%v for input %q, got %v", tt.needsShell, tt.input, needsShell)
+			}
+
+			if len(words) != len(tt.expected) {
+				t.Fatalf("Expected %d words, got %d (%q) for input %q", len(tt.expected), len(words), words, tt.input)
+			}
+
+			for i, expected := range tt.expected {
+				if words[i] != expected {
+					t.Errorf("Expected word %d to be %q, got %q", i, expected, words[i])
+				}
+			}
+		})
+	}
+}
+
+func TestShellName(t *testing.T) {
+	tests := map[string]string{
+		"bash":                        "bash",
+		"/usr/bin/zsh":                "zsh",
+		"/usr/local/bin/fish":         "fish",
+		"powershell.exe":              "powershell",
+		"  /bin/sh ":                  "sh",
+		"C:/Windows/System32/CMD.EXE": "cmd",
+	}
+
+	for input, expected := range tests {
+		if got := shellName(input); got != expected {
+			t.Errorf("shellName(%q) = %q, want %q", input, got, expected)
+		}
+	}
+}
+
+func TestShellArgs(t *testing.T) {
+	tests := map[string][]string{
+		"bash":      {"-c"},
+		"fish":      {"-c"},
+		"cmd":       {"/C"},
+		"pwsh":      {"-NoProfile", "-NonInteractive", "-Command"},
+		"unknownsh": {"-c"},
+	}
+
+	for name, expected := range tests {
+		got := shellArgs(name)
+		if strings.Join(got, " ") != strings.Join(expected, " ") {
+			t.Errorf("shellArgs(%q) = %v, want %v", name, got, expected)
+		}
+	}
+}
+
+func TestResolveShell_NotFound(t *testing.T) {
+	if _, _, err := resolveShell("nonexistentshell12345"); err == nil {
+		t.Error("Expected error for unknown shell")
+	}
+}
+
+func TestExecutor_shellFor(t *testing.T) {
+	e := &Executor{shell: "zsh"}
+
+	if got := e.shellFor(&types.Command{Shell: "fish"}); got != "fish" {
+		t.Errorf("Expected command shell to take precedence, got %q", got)
+	}
+	if got := e.shellFor(&types.Command{}); got != "zsh" {
+		t.Errorf("Expected executor shell, got %q", got)
+	}
+
+	e = &Executor{}
+	if got := e.shellFor(&types.Command{}); got != defaultShell() {
+		t.Errorf("Expected default shell %q, got %q", defaultShell(), got)
+	}
+}
+
+func TestExecutor_useShell(t *testing.T) {
+	tests := []struct {
+		mode       types.ExecutionMode
+		needsShell bool
+		expected   bool
+	}{
+		{types.ExecutionModeAuto, false, false},
+		{types.ExecutionModeAuto, true, true},
+		{types.ExecutionModeShell, false, true},
+		{types.ExecutionModeDirect, true, false},
+	}
+
+	for _, tt := range tests {
+		e := &Executor{mode: tt.mode}
+		if got := e.useShell(tt.needsShell); got != tt.expected {
+			t.Errorf("useShell(%v) in mode %s = %v, want %v", tt.needsShell, tt.mode, got, tt.expected)
+		}
+	}
+}
+
+func TestExecutor_Execute_ShellFeatures(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("POSIX shell features are not available on Windows")
+	}
+
+	executor := NewExecutorWithConfig(&ExecutorConfig{Shell: "sh"})
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		command  string
+		expected string
+		env      map[string]string
+		exitCode int
+	}{
+		{name: "pipe", command: "printf 'a\\nb\\nc\\n' | wc -l", expected: "3"},
+		{name: "command list", command: "true && echo ok", expected: "ok"},
+		{name: "substitution", command: "echo $(echo nested)", expected: "nested"},
+		{name: "variable expansion", command: `echo "$NL_SHELL_TEST"`, expected: "expanded", env: map[string]string{"NL_SHELL_TEST": "expanded"}},
+		{name: "exit status", command: "false || exit 3", exitCode: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := &types.Command{
+				ID:          "shell-" + tt.name,
+				Generated:   tt.command,
+				Environment: tt.env,
+				Timestamp:   time.Now(),
+			}
+
+			result, err := executor.Execute(ctx, cmd)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result.ExitCode != tt.exitCode {
+				t.Errorf("Expected exit code %d, got %d (stderr: %s)", tt.exitCode, result.ExitCode, result.Stderr)
+			}
+			if !strings.Contains(result.Stdout, tt.expected) {
+				t.Errorf("Expected stdout to contain %q, got: %q", tt.expected, result.Stdout)
+			}
+		})
+	}
+}
+
+func TestExecutor_Execute_DirectModeDoesNotInterpret(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("echo is not an executable on Windows")
+	}
+
+	executor := NewExecutorWithConfig(&ExecutorConfig{Mode: types.ExecutionModeDirect})
+	cmd := &types.Command{
+		ID:        "direct-1",
+		Generated: "echo a | b",
+		Timestamp: time.Now(),
+	}
+
+	result, err := executor.Execute(context.Background(), cmd)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(result.Stdout, "a | b") {
+		t.Errorf("Expected pipe to be passed literally, got: %q", result.Stdout)
+	}
+}
+
+func TestExecutor_DryRun_ReportsShell(t *testing.T) {
+	executor := NewExecutorWithConfig(&ExecutorConfig{Shell: "/bin/bash"})
+	cmd := &types.Command{
+		ID:        "dry-shell",
+		Generated: "ls | wc -l",
+		Timestamp: time.Now(),
+	}
+
+	result, err := executor.DryRun(cmd)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	found := false
+	for _, prediction := range result.Predictions {
+		if strings.Contains(prediction, "bash shell") {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected a prediction mentioning the bash shell, got: %v", result.Predictions)
+	}
+}
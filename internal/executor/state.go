@@ -0,0 +1,132 @@
+package executor
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+// stateBuiltins matches shell builtins that change the directory or the
+// environment of the shell itself, at the start of a command or after a
+// command separator
+var stateBuiltins = regexp.MustCompile(`(^|[;&|(\n{]|\bthen|\bdo|\belse)\s*(cd|pushd|popd|export|unset|source|\.|set|setenv|unsetenv)(\s|;|$)`)
+
+// ignoredStateVars are environment variables the shell maintains itself, so a
+// change to them is not a change the command made
+var ignoredStateVars = map[string]bool{
+	"_":      true,
+	"SHLVL":  true,
+	"PWD":    true,
+	"OLDPWD": true,
+}
+
+// ChangesShellState reports whether a command uses builtins that change the
+// working directory or environment of the shell running it, such as cd,
+// pushd, export, unset or source
+func ChangesShellState(command string) bool {
+	return stateBuiltins.MatchString(command)
+}
+
+// stateCaptureScript wraps command so that, once it finishes, the shell writes
+// its working directory and environment to files in dir and exits with the
+// command's status. It returns false for shells whose syntax is not known.
+func stateCaptureScript(shell, command, dir string) (string, bool) {
+	dirFile := shellQuote(filepath.Join(dir, "dir"))
+	envFile := shellQuote(filepath.Join(dir, "env"))
+
+	switch shellName(shell) {
+	case "sh", "bash", "zsh", "dash", "ksh", "mksh", "ash", "busybox":
+		return command + "\n" +
+			"__nl_to_shell_status=$?\n" +
+			"pwd > " + dirFile + "\n" +
+			"env -0 > " + envFile + " 2>/dev/null || env > " + envFile + "\n" +
+			"exit $__nl_to_shell_status\n", true
+	case "fish":
+		return command + "\n" +
+			"set __nl_to_shell_status $status\n" +
+			"pwd > " + dirFile + "\n" +
+			"env -0 > " + envFile + " 2>/dev/null; or env > " + envFile + "\n" +
+			"exit $__nl_to_shell_status\n", true
+	default:
+		return "", false
+	}
+}
+
+// shellQuote quotes s as a single word for POSIX shells and fish
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'"
+}
+
+// readShellState reads the working directory and environment written by a
+// state capture script to dir, returning what changed from the environment
+// the command started with. It returns nil when the command exited before
+// they were written.
+func readShellState(dir string, startEnv []string) *types.ShellState {
+	workingDir, err := os.ReadFile(filepath.Join(dir, "dir"))
+	if err != nil {
+		return nil
+	}
+	envData, err := os.ReadFile(filepath.Join(dir, "env"))
+	if err != nil {
+		return nil
+	}
+
+	state := &types.ShellState{
+		WorkingDirectory: strings.TrimRight(string(workingDir), "\n"),
+		Environment:      make(map[string]string),
+	}
+
+	before := parseEnvironment(startEnv)
+	after := parseEnvOutput(envData)
+	for key, value := range after {
+		if ignoredStateVars[key] {
+			continue
+		}
+		if previous, ok := before[key]; !ok || previous != value {
+			state.Environment[key] = value
+		}
+	}
+	for key := range before {
+		if _, ok := after[key]; !ok && !ignoredStateVars[key] {
+			state.Unset = append(state.Unset, key)
+		}
+	}
+	sort.Strings(state.Unset)
+
+	return state
+}
+
+// parseEnvironment converts KEY=value entries into a map, later entries
+// overriding earlier ones as they do for a process
+func parseEnvironment(entries []string) map[string]string {
+	env := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		if key, value, ok := strings.Cut(entry, "="); ok && key != "" {
+			env[key] = value
+		}
+	}
+	return env
+}
+
+// parseEnvOutput parses the output of env, NUL-separated when env supports
+// -0. In newline-separated output a line without = continues the value before it.
+func parseEnvOutput(data []byte) map[string]string {
+	if bytes.IndexByte(data, 0) >= 0 {
+		return parseEnvironment(strings.Split(strings.TrimRight(string(data), "\x00"), "\x00"))
+	}
+
+	var entries []string
+	for _, line := range strings.Split(strings.TrimRight(string(data), "\n"), "\n") {
+		if !assignmentPrefix.MatchString(line) && len(entries) > 0 {
+			entries[len(entries)-1] += "\n" + line
+			continue
+		}
+		entries = append(entries, line)
+	}
+	return parseEnvironment(entries)
+}
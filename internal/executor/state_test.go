@@ -0,0 +1,106 @@
+package executor
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+func TestChangesShellState(t *testing.T) {
+	tests := []struct {
+		command string
+		want    bool
+	}{
+		{"cd src", true},
+		{"mkdir build && cd build", true},
+		{"pushd /tmp; ls; popd", true},
+		{"export GOFLAGS=-mod=mod", true},
+		{"unset DEBUG", true},
+		{"source .venv/bin/activate", true},
+		{". ./env.sh", true},
+		{"if [ -d src ]; then cd src; fi", true},
+		{"ls -la", false},
+		{"./configure", false},
+		{"echo cd src", false},
+		{"git config --get user.name", false},
+	}
+
+	for _, tt := range tests {
+		if got := ChangesShellState(tt.command); got != tt.want {
+			t.Errorf("ChangesShellState(%q) = %v, want %v", tt.command, got, tt.want)
+		}
+	}
+}
+
+func TestParseEnvOutput(t *testing.T) {
+	want := map[string]string{"A": "1", "MULTI": "line one\nline two", "EMPTY": ""}
+
+	if got := parseEnvOutput([]byte("A=1\x00MULTI=line one\nline two\x00EMPTY=\x00")); !reflect.DeepEqual(got, want) {
+		t.Errorf("NUL-separated: got %q, want %q", got, want)
+	}
+	if got := parseEnvOutput([]byte("A=1\nMULTI=line one\nline two\nEMPTY=\n")); !reflect.DeepEqual(got, want) {
+		t.Errorf("newline-separated: got %q, want %q", got, want)
+	}
+}
+
+func TestExecutor_Execute_CapturesShellState(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("state capture requires a POSIX shell")
+	}
+
+	dir := t.TempDir()
+	sub := filepath.Join(dir, "src")
+	executor := NewExecutorWithConfig(&ExecutorConfig{Shell: "sh"})
+
+	t.Setenv("NL_TO_SHELL_STATE_REMOVED", "1")
+	cmd := &types.Command{
+		Generated:    "mkdir src && cd src && export GREETING='hello world' CHANGED=after && unset NL_TO_SHELL_STATE_REMOVED",
+		WorkingDir:   dir,
+		Environment:  map[string]string{"CHANGED": "before", "KEPT": "same"},
+		CaptureState: true,
+	}
+	result, err := executor.Execute(context.Background(), cmd)
+	if err != nil || !result.Success {
+		t.Fatalf("expected the command to succeed, got %v: %s", err, result.Stderr)
+	}
+
+	state := result.FinalState
+	if state == nil {
+		t.Fatal("expected the shell state to be captured")
+	}
+	if resolved, _ := filepath.EvalSymlinks(sub); state.WorkingDirectory != sub && state.WorkingDirectory != resolved {
+		t.Errorf("expected working directory %s, got %s", sub, state.WorkingDirectory)
+	}
+	if want := map[string]string{"GREETING": "hello world", "CHANGED": "after"}; !reflect.DeepEqual(state.Environment, want) {
+		t.Errorf("expected changed variables %v, got %v", want, state.Environment)
+	}
+	if want := []string{"NL_TO_SHELL_STATE_REMOVED"}; !reflect.DeepEqual(state.Unset, want) {
+		t.Errorf("expected unset variables %v, got %v", want, state.Unset)
+	}
+
+	// The next command starts from the captured state
+	next, err := executor.Execute(context.Background(), &types.Command{
+		Generated:   `echo "$GREETING from $(basename "$PWD") ${NL_TO_SHELL_STATE_REMOVED:-unset}"`,
+		WorkingDir:  state.WorkingDirectory,
+		Environment: state.Environment,
+		Unset:       state.Unset,
+	})
+	if err != nil || next.Stdout != "hello world from src unset\n" {
+		t.Errorf("expected the state to carry over, got %q, %v", next.Stdout, err)
+	}
+
+	// The exit status of the command is kept, and commands that cannot change
+	// the state are not wrapped
+	failed, _ := executor.Execute(context.Background(), &types.Command{Generated: "cd src; false", WorkingDir: dir, CaptureState: true})
+	if failed.ExitCode != 1 || failed.FinalState == nil {
+		t.Errorf("expected exit code 1 with the state captured, got %d, %+v", failed.ExitCode, failed.FinalState)
+	}
+	plain, _ := executor.Execute(context.Background(), &types.Command{Generated: "ls", WorkingDir: dir, CaptureState: true})
+	if plain.FinalState != nil {
+		t.Errorf("expected no state for a command without state builtins, got %+v", plain.FinalState)
+	}
+}
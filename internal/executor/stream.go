@@ -0,0 +1,83 @@
+package executor
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"sync"
+)
+
+// outputCapture records a command's output stream while forwarding each chunk
+// to an optional sink as it arrives. Past limit bytes, the capture keeps what
+// it has and the full output goes to a temporary file instead.
+type outputCapture struct {
+	buf       bytes.Buffer
+	sink      io.Writer
+	mu        *sync.Mutex // Shared between stdout and stderr so a common sink never interleaves a chunk
+	limit     int64
+	truncated bool
+	spill     *os.File
+}
+
+// newOutputCaptures creates captures for stdout and stderr that forward to the
+// given sinks, each keeping at most limit bytes when limit is positive
+func newOutputCaptures(stdout, stderr io.Writer, limit int64) (*outputCapture, *outputCapture) {
+	mu := &sync.Mutex{}
+	return &outputCapture{sink: stdout, mu: mu, limit: limit}, &outputCapture{sink: stderr, mu: mu, limit: limit}
+}
+
+// Write captures p and forwards it to the sink. Sink and spill file errors are
+// ignored so that a closed terminal or a full disk never interrupts the command.
+func (c *outputCapture) Write(p []byte) (int, error) {
+	c.capture(p)
+
+	if c.sink != nil {
+		c.mu.Lock()
+		c.sink.Write(p)
+		c.mu.Unlock()
+	}
+
+	return len(p), nil
+}
+
+func (c *outputCapture) capture(p []byte) {
+	if c.limit <= 0 {
+		c.buf.Write(p)
+		return
+	}
+
+	if !c.truncated {
+		room := c.limit - int64(c.buf.Len())
+		if int64(len(p)) <= room {
+			c.buf.Write(p)
+			return
+		}
+
+		// Everything captured so far goes to the file before what follows it
+		c.truncated = true
+		if spill, err := os.CreateTemp("", "nl-to-shell-output-*.log"); err == nil {
+			c.spill = spill
+			c.spill.Write(c.buf.Bytes())
+		}
+		c.buf.Write(p[:room])
+	}
+
+	if c.spill != nil {
+		c.spill.Write(p)
+	}
+}
+
+// String returns everything captured so far, up to the limit
+func (c *outputCapture) String() string {
+	return c.buf.String()
+}
+
+// Close closes the spill file and returns its path, or "" when the output
+// was not truncated
+func (c *outputCapture) Close() string {
+	if c.spill == nil {
+		return ""
+	}
+	c.spill.Close()
+	return c.spill.Name()
+}
@@ -0,0 +1,349 @@
+// Package history stores generated commands across runs so they can be
+// searched, inspected and run again
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+// FileName is the name of the history file in the configuration directory
+const FileName = "history.log"
+
+// lastIDSuffix names the file next to the history that records the highest ID
+// assigned, so IDs are not reused once the newest entries are pruned
+const lastIDSuffix = ".lastid"
+
+// tailChunkSize is how much of the end of the file is read to find the last entry
+const tailChunkSize = 64 * 1024
+
+// PruneOptions selects the entries removed by Prune
+type PruneOptions struct {
+	Before *time.Time // Remove entries recorded before this time
+	Keep   int        // Remove all but the newest Keep entries; 0 keeps all
+}
+
+// FileStore keeps the command history as JSON lines in a file readable only by the user
+type FileStore struct {
+	path  string
+	mutex sync.Mutex
+}
+
+// NewFileStore creates a history store backed by the file at path
+func NewFileStore(path string) (*FileStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create history directory: %w", err)
+	}
+	return &FileStore{path: path}, nil
+}
+
+// Path returns the history file's path
+func (s *FileStore) Path() string {
+	return s.path
+}
+
+// Add appends entry to the history, assigning the next ID and, when unset, the
+// timestamp. IDs are never reused, even for entries that were pruned.
+func (s *FileStore) Add(entry *types.HistoryEntry) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	lastID, err := s.lastID()
+	if err != nil {
+		return err
+	}
+	entry.ID = lastID + 1
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal history entry: %w", err)
+	}
+	data = append(data, '\n')
+
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open history file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(data); err != nil {
+		return fmt.Errorf("failed to write history entry: %w", err)
+	}
+	return nil
+}
+
+// List returns the entries matching filter, oldest first
+func (s *FileStore) List(filter *types.HistoryFilter) ([]*types.HistoryEntry, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	entries, err := s.readAll()
+	if err != nil {
+		return nil, err
+	}
+
+	matched := []*types.HistoryEntry{}
+	for _, entry := range entries {
+		if matchesFilter(entry, filter) {
+			matched = append(matched, entry)
+		}
+	}
+	if filter != nil && filter.Limit > 0 && len(matched) > filter.Limit {
+		matched = matched[len(matched)-filter.Limit:]
+	}
+	return matched, nil
+}
+
+// Get returns the entry with the given ID
+func (s *FileStore) Get(id int) (*types.HistoryEntry, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	entries, err := s.readAll()
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.ID == id {
+			return entry, nil
+		}
+	}
+	return nil, fmt.Errorf("history entry %d not found", id)
+}
+
+// Prune removes the entries selected by opts, returning how many were removed.
+// IDs of the remaining entries are kept.
+func (s *FileStore) Prune(opts PruneOptions) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	entries, err := s.readAll()
+	if err != nil {
+		return 0, err
+	}
+
+	kept := make([]*types.HistoryEntry, 0, len(entries))
+	for _, entry := range entries {
+		if opts.Before == nil || !entry.Timestamp.Before(*opts.Before) {
+			kept = append(kept, entry)
+		}
+	}
+	if opts.Keep > 0 && len(kept) > opts.Keep {
+		kept = kept[len(kept)-opts.Keep:]
+	}
+
+	removed := len(entries) - len(kept)
+	if removed == 0 {
+		return 0, nil
+	}
+	// Remember the newest ID before it may be removed
+	if err := s.recordLastID(entries[len(entries)-1].ID); err != nil {
+		return 0, err
+	}
+	if err := s.rewrite(kept); err != nil {
+		return 0, err
+	}
+	return removed, nil
+}
+
+// rewrite replaces the history file with entries, atomically
+func (s *FileStore) rewrite(entries []*types.HistoryEntry) error {
+	temp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create history file: %w", err)
+	}
+	defer os.Remove(temp.Name())
+
+	writer := bufio.NewWriter(temp)
+	encoder := json.NewEncoder(writer)
+	encoder.SetEscapeHTML(false)
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			temp.Close()
+			return fmt.Errorf("failed to marshal history entry: %w", err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		temp.Close()
+		return fmt.Errorf("failed to write history file: %w", err)
+	}
+	if err := temp.Chmod(0600); err != nil {
+		temp.Close()
+		return fmt.Errorf("failed to write history file: %w", err)
+	}
+	if err := temp.Close(); err != nil {
+		return fmt.Errorf("failed to write history file: %w", err)
+	}
+
+	if err := os.Rename(temp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to replace history file: %w", err)
+	}
+	return nil
+}
+
+// readAll reads every entry, skipping malformed lines
+func (s *FileStore) readAll() ([]*types.HistoryEntry, error) {
+	file, err := os.Open(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open history file: %w", err)
+	}
+	defer file.Close()
+
+	var entries []*types.HistoryEntry
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if entry := decodeEntry(line); entry != nil {
+			entries = append(entries, entry)
+		}
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read history file: %w", err)
+		}
+	}
+}
+
+// lastID returns the highest ID assigned so far, 0 for a new history
+func (s *FileStore) lastID() (int, error) {
+	last, err := s.lastEntry()
+	if err != nil {
+		return 0, err
+	}
+	id, err := s.recordedLastID()
+	if err != nil {
+		return 0, err
+	}
+	if last != nil && last.ID > id {
+		id = last.ID
+	}
+	return id, nil
+}
+
+// recordedLastID returns the ID recorded by the last Prune, or 0
+func (s *FileStore) recordedLastID() (int, error) {
+	data, err := os.ReadFile(s.path + lastIDSuffix)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to read history ID: %w", err)
+	}
+	id, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, fmt.Errorf("invalid history ID in %s: %w", s.path+lastIDSuffix, err)
+	}
+	return id, nil
+}
+
+// recordLastID records id as the highest assigned, unless a higher one already is
+func (s *FileStore) recordLastID(id int) error {
+	recorded, err := s.recordedLastID()
+	if err != nil {
+		return err
+	}
+	if recorded >= id {
+		return nil
+	}
+	if err := os.WriteFile(s.path+lastIDSuffix, []byte(strconv.Itoa(id)+"\n"), 0600); err != nil {
+		return fmt.Errorf("failed to record history ID: %w", err)
+	}
+	return nil
+}
+
+// lastEntry returns the newest entry, or nil for an empty history
+func (s *FileStore) lastEntry() (*types.HistoryEntry, error) {
+	file, err := os.Open(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open history file: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history file: %w", err)
+	}
+
+	// Entries are small, so the last one is within the final chunk
+	offset := info.Size() - tailChunkSize
+	if offset < 0 {
+		offset = 0
+	}
+	tail := make([]byte, info.Size()-offset)
+	if _, err := file.ReadAt(tail, offset); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to read history file: %w", err)
+	}
+
+	lines := bytes.Split(tail, []byte("\n"))
+	for i := len(lines) - 1; i >= 0; i-- {
+		if entry := decodeEntry(lines[i]); entry != nil {
+			return entry, nil
+		}
+	}
+
+	// The final chunk holds no complete entry; fall back to reading everything
+	if offset > 0 {
+		entries, err := s.readAll()
+		if err != nil || len(entries) == 0 {
+			return nil, err
+		}
+		return entries[len(entries)-1], nil
+	}
+	return nil, nil
+}
+
+// decodeEntry decodes a history line, returning nil for blank or malformed lines
+func decodeEntry(line []byte) *types.HistoryEntry {
+	if len(bytes.TrimSpace(line)) == 0 {
+		return nil
+	}
+	var entry types.HistoryEntry
+	if err := json.Unmarshal(line, &entry); err != nil || entry.ID == 0 {
+		return nil
+	}
+	return &entry
+}
+
+// matchesFilter reports whether entry matches every criterion of filter. A
+// query matches when each of its words appears in the request, command or directory.
+func matchesFilter(entry *types.HistoryEntry, filter *types.HistoryFilter) bool {
+	if filter == nil {
+		return true
+	}
+	if filter.Since != nil && entry.Timestamp.Before(*filter.Since) {
+		return false
+	}
+	if filter.SessionID != "" && entry.SessionID != filter.SessionID {
+		return false
+	}
+	if filter.Query != "" {
+		text := strings.ToLower(entry.Input + "\n" + entry.Command + "\n" + entry.WorkingDir)
+		for _, word := range strings.Fields(strings.ToLower(filter.Query)) {
+			if !strings.Contains(text, word) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,215 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+func newTestStore(t *testing.T) *FileStore {
+	t.Helper()
+	store, err := NewFileStore(filepath.Join(t.TempDir(), "nested", FileName))
+	if err != nil {
+		t.Fatalf("NewFileStore() error = %v", err)
+	}
+	return store
+}
+
+func TestFileStore_AddAndGet(t *testing.T) {
+	store := newTestStore(t)
+
+	exitCode := 0
+	for _, input := range []string{"list files", "show disk usage", "find large logs"} {
+		entry := &types.HistoryEntry{Input: input, Command: "echo " + input, ExitCode: &exitCode}
+		if err := store.Add(entry); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+		if entry.Timestamp.IsZero() {
+			t.Error("Add() should set the timestamp")
+		}
+	}
+
+	entry, err := store.Get(2)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if entry.Input != "show disk usage" || entry.ExitCode == nil || *entry.ExitCode != 0 {
+		t.Errorf("unexpected entry: %+v", entry)
+	}
+	if _, err := store.Get(42); err == nil {
+		t.Error("expected an error for a missing entry")
+	}
+
+	info, err := os.Stat(store.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("expected history file mode 0600, got %o", perm)
+	}
+}
+
+func TestFileStore_List(t *testing.T) {
+	store := newTestStore(t)
+	old := time.Now().Add(-48 * time.Hour)
+
+	entries := []*types.HistoryEntry{
+		{Timestamp: old, SessionID: "a", Input: "list Docker containers", Command: "docker ps"},
+		{SessionID: "a", Input: "show disk usage", Command: "df -h", WorkingDir: "/srv/docker"},
+		{SessionID: "b", Input: "count lines", Command: "wc -l *.go"},
+	}
+	for _, entry := range entries {
+		if err := store.Add(entry); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+	}
+
+	since := time.Now().Add(-time.Hour)
+	tests := []struct {
+		name   string
+		filter *types.HistoryFilter
+		want   []int
+	}{
+		{"all", nil, []int{1, 2, 3}},
+		{"query", &types.HistoryFilter{Query: "DOCKER"}, []int{1, 2}},
+		{"query words", &types.HistoryFilter{Query: "docker ps"}, []int{1}},
+		{"since", &types.HistoryFilter{Since: &since}, []int{2, 3}},
+		{"session", &types.HistoryFilter{SessionID: "b"}, []int{3}},
+		{"limit keeps newest", &types.HistoryFilter{Limit: 2}, []int{2, 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := store.List(tt.filter)
+			if err != nil {
+				t.Fatalf("List() error = %v", err)
+			}
+			ids := make([]int, 0, len(got))
+			for _, entry := range got {
+				ids = append(ids, entry.ID)
+			}
+			if len(ids) != len(tt.want) {
+				t.Fatalf("List() ids = %v, want %v", ids, tt.want)
+			}
+			for i := range ids {
+				if ids[i] != tt.want[i] {
+					t.Fatalf("List() ids = %v, want %v", ids, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestFileStore_Prune(t *testing.T) {
+	store := newTestStore(t)
+	old := time.Now().Add(-30 * 24 * time.Hour)
+
+	for i := 0; i < 5; i++ {
+		entry := &types.HistoryEntry{Input: "request", Command: "true"}
+		if i < 2 {
+			entry.Timestamp = old
+		}
+		if err := store.Add(entry); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+	}
+
+	cutoff := time.Now().Add(-24 * time.Hour)
+	removed, err := store.Prune(PruneOptions{Before: &cutoff})
+	if err != nil || removed != 2 {
+		t.Fatalf("Prune(before) = %d, %v; want 2 removed", removed, err)
+	}
+
+	removed, err = store.Prune(PruneOptions{Keep: 1})
+	if err != nil || removed != 2 {
+		t.Fatalf("Prune(keep) = %d, %v; want 2 removed", removed, err)
+	}
+
+	remaining, err := store.List(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(remaining) != 1 || remaining[0].ID != 5 {
+		t.Fatalf("expected only entry 5 to remain, got %+v", remaining)
+	}
+
+	// IDs keep increasing after a prune
+	next := &types.HistoryEntry{Input: "another", Command: "true"}
+	if err := store.Add(next); err != nil {
+		t.Fatal(err)
+	}
+	if next.ID != 6 {
+		t.Errorf("expected the next ID to be 6, got %d", next.ID)
+	}
+}
+
+func TestFileStore_PruneAllKeepsIDs(t *testing.T) {
+	store := newTestStore(t)
+	for i := 0; i < 3; i++ {
+		if err := store.Add(&types.HistoryEntry{Input: "request", Command: "true"}); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+	}
+
+	now := time.Now().Add(time.Second)
+	if removed, err := store.Prune(PruneOptions{Before: &now}); err != nil || removed != 3 {
+		t.Fatalf("Prune(before now) = %d, %v; want 3 removed", removed, err)
+	}
+
+	// An emptied history does not hand out the IDs of the removed entries again
+	next := &types.HistoryEntry{Input: "another", Command: "true"}
+	if err := store.Add(next); err != nil {
+		t.Fatal(err)
+	}
+	if next.ID != 4 {
+		t.Errorf("expected the next ID to be 4, got %d", next.ID)
+	}
+	if _, err := store.Get(1); err == nil {
+		t.Error("expected a pruned ID not to be found")
+	}
+
+	// Nor does a store reopened on the same file
+	reopened, err := NewFileStore(store.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reopened.Prune(PruneOptions{Before: &now}); err != nil {
+		t.Fatal(err)
+	}
+	if err := reopened.Add(next); err != nil {
+		t.Fatal(err)
+	}
+	if next.ID != 5 {
+		t.Errorf("expected the next ID to be 5, got %d", next.ID)
+	}
+}
+
+func TestFileStore_SkipsMalformedLines(t *testing.T) {
+	store := newTestStore(t)
+	if err := store.Add(&types.HistoryEntry{Input: "first", Command: "true"}); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.OpenFile(store.Path(), os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString("{not json\n")
+	file.Close()
+
+	entry := &types.HistoryEntry{Input: "second", Command: "true"}
+	if err := store.Add(entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.ID != 2 {
+		t.Errorf("expected ID 2 after a malformed line, got %d", entry.ID)
+	}
+
+	entries, err := store.List(nil)
+	if err != nil || len(entries) != 2 {
+		t.Errorf("expected 2 entries, got %d (%v)", len(entries), err)
+	}
+}
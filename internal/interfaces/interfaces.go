@@ -2,6 +2,7 @@ package interfaces
 
 import (
 	"context"
+	"io"
 
 	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
 )
@@ -13,12 +14,26 @@ type LLMProvider interface {
 	GetProviderInfo() types.ProviderInfo
 }
 
+// StreamingLLMProvider is implemented by providers that can stream command
+// generation, passing each piece of the response text to onText as it arrives
+type StreamingLLMProvider interface {
+	LLMProvider
+	GenerateCommandStream(ctx context.Context, prompt string, context *types.Context, onText func(text string)) (*types.CommandResponse, error)
+}
+
 // ContextGatherer defines the interface for gathering environmental context
 type ContextGatherer interface {
 	GatherContext(ctx context.Context) (*types.Context, error)
 	RegisterPlugin(plugin ContextPlugin) error
 }
 
+// DirectoryContextGatherer is implemented by context gatherers that can gather
+// the context of a directory other than the process's working directory
+type DirectoryContextGatherer interface {
+	ContextGatherer
+	GatherContextIn(ctx context.Context, workingDir string) (*types.Context, error)
+}
+
 // SafetyValidator defines the interface for validating command safety
 type SafetyValidator interface {
 	ValidateCommand(cmd *types.Command) (*types.SafetyResult, error)
@@ -30,6 +45,7 @@ type SafetyValidator interface {
 // CommandExecutor defines the interface for executing shell commands
 type CommandExecutor interface {
 	Execute(ctx context.Context, cmd *types.Command) (*types.ExecutionResult, error)
+	ExecuteStreaming(ctx context.Context, cmd *types.Command, stdout, stderr io.Writer) (*types.ExecutionResult, error)
 	DryRun(cmd *types.Command) (*types.DryRunResult, error)
 }
 
@@ -38,11 +54,27 @@ type ResultValidator interface {
 	ValidateResult(ctx context.Context, result *types.ExecutionResult, intent string) (*types.ValidationResult, error)
 }
 
+// JobManager runs commands detached from the CLI as background jobs and keeps
+// track of them across runs
+type JobManager interface {
+	Start(cmd *types.Command) (*types.Job, error)
+	List() ([]*types.Job, error)
+	Update(job *types.Job) error
+	Output(job *types.Job, limit int64) (string, error)
+}
+
 // CommandManager defines the interface for orchestrating the command generation pipeline
 type CommandManager interface {
 	GenerateCommand(ctx context.Context, input string) (*types.CommandResult, error)
 	ExecuteCommand(ctx context.Context, cmd *types.Command) (*types.ExecutionResult, error)
+	DryRun(ctx context.Context, cmd *types.Command) (*types.DryRunResult, error)
+	ExecuteCommandStreaming(ctx context.Context, cmd *types.Command, stdout, stderr io.Writer) (*types.ExecutionResult, error)
 	ValidateResult(ctx context.Context, result *types.ExecutionResult, intent string) (*types.ValidationResult, error)
+	RecordConfirmation(result *types.CommandResult, confirmed bool)
+	BypassConfirmation(result *types.CommandResult) error
+	CorrectResult(ctx context.Context, result *types.FullResult, input string, options *types.ExecutionOptions) (*types.FullResult, error)
+	ForgetConversation()
+	ValidateJobs(ctx context.Context) ([]*types.Job, error)
 }
 
 // ConfigManager defines the interface for configuration management
@@ -0,0 +1,312 @@
+// Package jobs runs commands detached from the CLI as background jobs. Each
+// job's status is kept in a file next to its output log, so jobs can be listed,
+// waited for and killed by later runs.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+// DirName is the name of the jobs directory in the configuration directory
+const DirName = "jobs"
+
+// pollInterval is how often a job's status is checked while waiting for it
+const pollInterval = 200 * time.Millisecond
+
+// killTimeout bounds how long Kill waits for a job to stop, beyond the grace
+// period the executor gives the command before killing it
+const killTimeout = 5 * time.Second
+
+// Manager starts background jobs and keeps their status in a directory,
+// as <id>.json alongside the output in <id>.log
+type Manager struct {
+	dir      string
+	settings types.ExecutorSettings
+}
+
+// NewManager creates a job manager keeping its jobs in dir
+func NewManager(dir string) (*Manager, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create jobs directory: %w", err)
+	}
+	return &Manager{dir: dir}, nil
+}
+
+// SetExecutorSettings sets the executor settings jobs started from now on run
+// their commands with, so they run as they would in the foreground
+func (m *Manager) SetExecutorSettings(settings types.ExecutorSettings) {
+	m.settings = settings
+}
+
+// Start starts cmd as a background job in a detached process that runs it to
+// completion and records how it ended
+func (m *Manager) Start(cmd *types.Command) (*types.Job, error) {
+	command := *cmd
+	command.Context = nil // Only needed to generate the command
+
+	job := &types.Job{
+		Command:   &command,
+		Executor:  m.settings,
+		Status:    types.JobRunning,
+		StartedAt: time.Now(),
+	}
+	if err := m.reserve(job); err != nil {
+		return nil, err
+	}
+
+	executable, err := os.Executable()
+	if err == nil {
+		process := exec.Command(executable)
+		process.Dir = command.WorkingDir
+		process.Env = append(os.Environ(), jobEnv+"="+m.jobFile(job.ID))
+		detach(process)
+		if err = process.Start(); err == nil {
+			job.PID = process.Process.Pid
+			// Reap the process when it exits while we are still running
+			go process.Wait()
+			return job, nil
+		}
+	}
+
+	job.Status = types.JobFailed
+	job.ExitCode = -1
+	job.Error = fmt.Sprintf("failed to start job: %v", err)
+	job.FinishedAt = time.Now()
+	m.Update(job)
+	return nil, &types.NLShellError{
+		Type:    types.ErrTypeExecution,
+		Message: "failed to start background job",
+		Cause:   err,
+	}
+}
+
+// reserve assigns job the next free ID and creates its file
+func (m *Manager) reserve(job *types.Job) error {
+	ids, err := m.ids()
+	if err != nil {
+		return err
+	}
+	id := 1
+	if len(ids) > 0 {
+		id = ids[len(ids)-1] + 1
+	}
+
+	for ; ; id++ {
+		file, err := os.OpenFile(m.jobFile(id), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to create job file: %w", err)
+		}
+		file.Close()
+		break
+	}
+
+	job.ID = id
+	job.LogFile = filepath.Join(m.dir, strconv.Itoa(id)+".log")
+	return m.Update(job)
+}
+
+// List returns every job, oldest first
+func (m *Manager) List() ([]*types.Job, error) {
+	ids, err := m.ids()
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := make([]*types.Job, 0, len(ids))
+	for _, id := range ids {
+		job, err := m.Get(id)
+		if err != nil {
+			continue // Being created, or unreadable
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// Get returns the job with the given ID. A running job whose process has
+// gone away is marked lost.
+func (m *Manager) Get(id int) (*types.Job, error) {
+	job, err := m.read(id)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status != types.JobRunning || job.PID == 0 || processAlive(job.PID) {
+		return job, nil
+	}
+
+	// The process may have recorded its result just before exiting
+	if job, err = m.read(id); err != nil || job.Status != types.JobRunning {
+		return job, err
+	}
+	job.Status = types.JobLost
+	job.ExitCode = -1
+	job.Error = "the job's process exited without recording its result"
+	job.FinishedAt = time.Now()
+	return job, m.Update(job)
+}
+
+// Update records job's current state, atomically
+func (m *Manager) Update(job *types.Job) error {
+	data, err := json.MarshalIndent(job, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal job: %w", err)
+	}
+
+	temp, err := os.CreateTemp(m.dir, strconv.Itoa(job.ID)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write job file: %w", err)
+	}
+	defer os.Remove(temp.Name())
+
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		return fmt.Errorf("failed to write job file: %w", err)
+	}
+	if err := temp.Close(); err != nil {
+		return fmt.Errorf("failed to write job file: %w", err)
+	}
+	if err := os.Rename(temp.Name(), m.jobFile(job.ID)); err != nil {
+		return fmt.Errorf("failed to replace job file: %w", err)
+	}
+	return nil
+}
+
+// Wait waits for the job to finish
+func (m *Manager) Wait(ctx context.Context, id int) (*types.Job, error) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		job, err := m.Get(id)
+		if err != nil || job.Status != types.JobRunning {
+			return job, err
+		}
+		select {
+		case <-ctx.Done():
+			return job, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Kill terminates a running job, along with every process it started, and
+// waits for it to stop
+func (m *Manager) Kill(id int) (*types.Job, error) {
+	job, err := m.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status != types.JobRunning {
+		return job, fmt.Errorf("job %d is not running", id)
+	}
+	if job.PID == 0 {
+		return job, fmt.Errorf("job %d has not started yet", id)
+	}
+	if err := terminateProcess(job.PID); err != nil {
+		return job, fmt.Errorf("failed to kill job %d: %w", id, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), killTimeout)
+	defer cancel()
+	job, err = m.Wait(ctx, id)
+	if err != nil {
+		return job, fmt.Errorf("job %d did not stop: %w", id, err)
+	}
+
+	// A process killed before it could record the result is lost rather than killed
+	if job.Status == types.JobLost {
+		job.Status = types.JobKilled
+		job.Error = ""
+		err = m.Update(job)
+	}
+	return job, err
+}
+
+// Output returns up to the last limit bytes of the job's output
+func (m *Manager) Output(job *types.Job, limit int64) (string, error) {
+	file, err := os.Open(job.LogFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to open job log: %w", err)
+	}
+	defer file.Close()
+
+	if info, err := file.Stat(); err == nil && limit > 0 && info.Size() > limit {
+		if _, err := file.Seek(-limit, io.SeekEnd); err != nil {
+			return "", fmt.Errorf("failed to read job log: %w", err)
+		}
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read job log: %w", err)
+	}
+	return string(data), nil
+}
+
+// ids returns the IDs of the jobs in the directory, in order
+func (m *Manager) ids() ([]int, error) {
+	entries, err := os.ReadDir(m.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read jobs directory: %w", err)
+	}
+
+	var ids []int
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok {
+			continue
+		}
+		if id, err := strconv.Atoi(name); err == nil && id > 0 {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+func (m *Manager) jobFile(id int) string {
+	return filepath.Join(m.dir, strconv.Itoa(id)+".json")
+}
+
+// read loads the job with the given ID as last recorded
+func (m *Manager) read(id int) (*types.Job, error) {
+	return readJob(m.jobFile(id), id)
+}
+
+func readJob(path string, id int) (*types.Job, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("job %d not found", id)
+		}
+		return nil, fmt.Errorf("failed to read job %d: %w", id, err)
+	}
+	var job types.Job
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, fmt.Errorf("failed to parse job %d: %w", id, err)
+	}
+	if job.Command == nil {
+		job.Command = &types.Command{}
+	}
+	return &job, nil
+}
@@ -0,0 +1,148 @@
+package jobs
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+func newTestManager(t *testing.T) *Manager {
+	t.Helper()
+	m, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create job manager: %v", err)
+	}
+	return m
+}
+
+func startJob(t *testing.T, m *Manager, command string) *types.Job {
+	t.Helper()
+	job, err := m.Start(&types.Command{Generated: command, Shell: "sh", WorkingDir: t.TempDir(), Background: true})
+	if err != nil {
+		t.Fatalf("failed to start job: %v", err)
+	}
+	return job
+}
+
+func waitForJob(t *testing.T, m *Manager, id int) *types.Job {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	job, err := m.Wait(ctx, id)
+	if err != nil {
+		t.Fatalf("failed waiting for job %d: %v", id, err)
+	}
+	return job
+}
+
+func TestManager_RunsJobToCompletion(t *testing.T) {
+	m := newTestManager(t)
+
+	job := startJob(t, m, "echo hello; echo oops >&2; exit 3")
+	if job.ID != 1 || job.Status != types.JobRunning || job.PID == 0 {
+		t.Errorf("expected a running job 1, got %+v", job)
+	}
+
+	job = waitForJob(t, m, job.ID)
+	if job.Status != types.JobFailed || job.ExitCode != 3 || job.FinishedAt.IsZero() {
+		t.Errorf("expected the job to fail with exit code 3, got %+v", job)
+	}
+	output, err := m.Output(job, 0)
+	if err != nil || output != "hello\noops\n" {
+		t.Errorf("expected the output in the log, got %q, %v", output, err)
+	}
+	if tail, _ := m.Output(job, 5); tail != "oops\n" {
+		t.Errorf("expected the end of the output, got %q", tail)
+	}
+
+	// The status outlives the manager that started the job
+	reopened, err := NewManager(m.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := startJob(t, reopened, "echo done")
+	if second.ID != 2 {
+		t.Errorf("expected the next job to be 2, got %d", second.ID)
+	}
+	if second = waitForJob(t, reopened, second.ID); second.Status != types.JobSucceeded || second.ExitCode != 0 {
+		t.Errorf("expected the job to succeed, got %+v", second)
+	}
+
+	jobs, err := reopened.List()
+	if err != nil || len(jobs) != 2 || jobs[0].Command.Generated != "echo hello; echo oops >&2; exit 3" {
+		t.Errorf("expected both jobs listed, got %v, %v", jobs, err)
+	}
+}
+
+func TestManager_RunsJobWithExecutorSettings(t *testing.T) {
+	m := newTestManager(t)
+	m.SetExecutorSettings(types.ExecutorSettings{Mode: types.ExecutionModeDirect})
+
+	// Run directly, as configured, the variable is not expanded by a shell
+	job := startJob(t, m, "echo $HOME")
+	if job.Executor.Mode != types.ExecutionModeDirect {
+		t.Errorf("expected the job to record the executor settings, got %+v", job.Executor)
+	}
+	job = waitForJob(t, m, job.ID)
+	if output, _ := m.Output(job, 0); job.Status != types.JobSucceeded || output != "$HOME\n" {
+		t.Errorf("expected the job to run without a shell, got %+v with output %q", job, output)
+	}
+}
+
+func TestManager_Kill(t *testing.T) {
+	m := newTestManager(t)
+	job := startJob(t, m, "sleep 30")
+
+	// Wait for the job's process to record itself
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		current, err := m.Get(job.ID)
+		if err == nil && current.PID != 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the job to start")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	killed, err := m.Kill(job.ID)
+	if err != nil {
+		t.Fatalf("failed to kill job: %v", err)
+	}
+	if killed.Status != types.JobKilled {
+		t.Errorf("expected the job to be killed, got %+v", killed)
+	}
+	if _, err := m.Kill(job.ID); err == nil {
+		t.Error("expected killing a finished job to fail")
+	}
+}
+
+func TestManager_MarksVanishedJobLost(t *testing.T) {
+	m := newTestManager(t)
+
+	exited := exec.Command("true")
+	if err := exited.Run(); err != nil {
+		t.Skip("true is not available")
+	}
+	job := &types.Job{Command: &types.Command{Generated: "make"}, Status: types.JobRunning, PID: exited.Process.Pid}
+	if err := m.reserve(job); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := m.Get(job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != types.JobLost || !strings.Contains(got.Error, "without recording") {
+		t.Errorf("expected the job to be lost, got %+v", got)
+	}
+
+	if _, err := m.Get(99); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("expected a missing job to be reported, got %v", err)
+	}
+}
@@ -0,0 +1,38 @@
+package jobs
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// detachedProcess starts a process without a console
+const detachedProcess = 0x00000008
+
+// detach starts the job's process without the console, so that it keeps
+// running after the console that started it is closed
+func detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP | detachedProcess,
+	}
+}
+
+// processAlive reports whether the process with the given ID exists
+func processAlive(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	process.Release()
+	return true
+}
+
+// terminateProcess kills the job's process; Windows cannot ask it to stop
+func terminateProcess(pid int) error {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	defer process.Release()
+	return process.Kill()
+}
@@ -0,0 +1,102 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/executor"
+	"github.com/kanishka-sahoo/nl-to-shell/internal/interfaces"
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+// jobEnv names the file of the job a re-executed CLI runs in the background
+const jobEnv = "NL_TO_SHELL_JOB"
+
+// A background job runs in a copy of the CLI, started with jobEnv set, that
+// runs the command and records how it ended before exiting
+func init() {
+	path := os.Getenv(jobEnv)
+	if path == "" {
+		return
+	}
+	os.Unsetenv(jobEnv)
+	if err := runJob(path); err != nil {
+		fmt.Fprintf(os.Stderr, "nl-to-shell job: %v\n", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// runJob runs the job recorded in path to completion, writing its output to
+// the job's log
+func runJob(path string) error {
+	id, err := strconv.Atoi(strings.TrimSuffix(filepath.Base(path), ".json"))
+	if err != nil {
+		return fmt.Errorf("invalid job file %s", path)
+	}
+	m := &Manager{dir: filepath.Dir(path)}
+	job, err := m.read(id)
+	if err != nil {
+		return err
+	}
+
+	job.PID = os.Getpid()
+	if err := m.Update(job); err != nil {
+		return err
+	}
+
+	log, err := os.OpenFile(job.LogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return finishJob(m, job, nil, fmt.Errorf("failed to open job log: %w", err))
+	}
+	defer log.Close()
+
+	result, err := jobExecutor(job).ExecuteStreaming(context.Background(), job.Command, log, log)
+	return finishJob(m, job, result, err)
+}
+
+// jobExecutor returns an executor with the settings recorded in the job
+func jobExecutor(job *types.Job) interfaces.CommandExecutor {
+	return executor.NewExecutorWithConfig(&executor.ExecutorConfig{
+		Shell:  job.Executor.Shell,
+		Mode:   job.Executor.Mode,
+		Limits: job.Executor.Limits,
+	})
+}
+
+// finishJob records the outcome of the job's command
+func finishJob(m *Manager, job *types.Job, result *types.ExecutionResult, err error) error {
+	job.FinishedAt = time.Now()
+	job.ExitCode = -1
+	if result != nil {
+		job.ExitCode = result.ExitCode
+		if err == nil {
+			err = result.Error
+		}
+
+		// The log already holds the full output
+		for _, file := range []string{result.StdoutFile, result.StderrFile} {
+			if file != "" {
+				os.Remove(file)
+			}
+		}
+	}
+
+	switch {
+	case result != nil && result.Interrupted:
+		job.Status = types.JobKilled
+	case err != nil:
+		job.Status = types.JobFailed
+		job.Error = err.Error()
+	case result.ExitCode == 0:
+		job.Status = types.JobSucceeded
+	default:
+		job.Status = types.JobFailed
+	}
+	return m.Update(job)
+}
@@ -14,10 +14,26 @@ import (
 
 // AnthropicRequest represents the request structure for Anthropic API
 type AnthropicRequest struct {
-	Model     string             `json:"model"`
-	MaxTokens int                `json:"max_tokens"`
-	Messages  []AnthropicMessage `json:"messages"`
-	System    string             `json:"system,omitempty"`
+	Model      string               `json:"model"`
+	MaxTokens  int                  `json:"max_tokens"`
+	Messages   []AnthropicMessage   `json:"messages"`
+	System     string               `json:"system,omitempty"`
+	Stream     bool                 `json:"stream,omitempty"`
+	Tools      []AnthropicTool      `json:"tools,omitempty"`
+	ToolChoice *AnthropicToolChoice `json:"tool_choice,omitempty"`
+}
+
+// AnthropicTool describes a tool the model can call with input matching its schema
+type AnthropicTool struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	InputSchema *jsonSchema `json:"input_schema"`
+}
+
+// AnthropicToolChoice selects the tool the model must call
+type AnthropicToolChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
 }
 
 // AnthropicMessage represents a message in the Anthropic chat format
@@ -41,8 +57,11 @@ type AnthropicResponse struct {
 
 // AnthropicContent represents content in the Anthropic response
 type AnthropicContent struct {
-	Type string `json:"type"`
-	Text string `json:"text"`
+	Type        string          `json:"type"`
+	Text        string          `json:"text"`
+	Name        string          `json:"name,omitempty"`
+	Input       json.RawMessage `json:"input,omitempty"`
+	PartialJSON string          `json:"partial_json,omitempty"`
 }
 
 // AnthropicUsage represents token usage information
@@ -51,6 +70,21 @@ type AnthropicUsage struct {
 	OutputTokens int `json:"output_tokens"`
 }
 
+// tokenUsage returns the usage as counted across providers
+func (u AnthropicUsage) tokenUsage() types.TokenUsage {
+	return types.TokenUsage{InputTokens: u.InputTokens, OutputTokens: u.OutputTokens}
+}
+
+// AnthropicStreamEvent represents one event of a streamed Anthropic response
+type AnthropicStreamEvent struct {
+	Type    string             `json:"type"`
+	Index   int                `json:"index"`
+	Delta   *AnthropicContent  `json:"delta,omitempty"`
+	Message *AnthropicResponse `json:"message,omitempty"` // Set on message_start, with the input token count
+	Usage   *AnthropicUsage    `json:"usage,omitempty"`   // Set on message_delta, with the output token count
+	Error   *AnthropicError    `json:"error,omitempty"`
+}
+
 // AnthropicError represents an error from the Anthropic API
 type AnthropicError struct {
 	Type    string `json:"type"`
@@ -59,10 +93,62 @@ type AnthropicError struct {
 
 // generateCommandInternal implements the actual Anthropic API call for command generation
 func (p *AnthropicProvider) generateCommandInternal(ctx context.Context, prompt string, context *types.Context) (*types.CommandResponse, error) {
-	// Build the system prompt
-	systemPrompt := p.promptBuilder.BuildSystemPrompt(context)
+	// Build the system prompt within the model's prompt token budget
+	systemPrompt, promptTokens := p.buildSystemPrompt(context, prompt, p.GetProviderInfo().Name, p.getModel())
 
-	// Create the request
+	// Make the API call
+	request := p.commandRequest(systemPrompt, prompt, false)
+	response, err := p.makeAPICall(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	usage := response.Usage.tokenUsage()
+
+	// Parse the response, asking the model to correct it when it does not match the schema
+	content, err := p.responseContent(response)
+	if err != nil {
+		return nil, err
+	}
+	result, err := decodeWithRepair(content, decodeCommandResponse, p.reask(ctx, request, &usage))
+	if err != nil {
+		return nil, err
+	}
+	result.PromptTokens = promptTokens
+	result.Provider = p.GetProviderInfo().Name
+	result.Model = p.getModel()
+	result.Usage = usage
+	return result, nil
+}
+
+// generateCommandStreamInternal implements the streamed Anthropic API call for command generation
+func (p *AnthropicProvider) generateCommandStreamInternal(ctx context.Context, prompt string, context *types.Context, onText func(string)) (*types.CommandResponse, error) {
+	// Build the system prompt within the model's prompt token budget
+	systemPrompt, promptTokens := p.buildSystemPrompt(context, prompt, p.GetProviderInfo().Name, p.getModel())
+
+	request := p.commandRequest(systemPrompt, prompt, true)
+	httpReq, err := p.newHTTPRequest(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	content, usage, err := p.streamResponse(httpReq, "Anthropic", streamSSE, decodeAnthropicStreamEvent, onText)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse the complete response, asking the model to correct it when it does not match the schema
+	result, err := decodeWithRepair(content, decodeCommandResponse, p.reask(ctx, request, &usage))
+	if err != nil {
+		return nil, err
+	}
+	result.PromptTokens = promptTokens
+	result.Provider = p.GetProviderInfo().Name
+	result.Model = p.getModel()
+	result.Usage = usage
+	return result, nil
+}
+
+// commandRequest creates the request for command generation
+func (p *AnthropicProvider) commandRequest(systemPrompt, prompt string, stream bool) AnthropicRequest {
 	request := AnthropicRequest{
 		Model:     p.getModel(),
 		MaxTokens: 500, // Reasonable limit for shell commands
@@ -73,16 +159,66 @@ func (p *AnthropicProvider) generateCommandInternal(ctx context.Context, prompt
 				Content: prompt,
 			},
 		},
+		Stream: stream,
 	}
+	withResponseTool(&request, "command_response", "Return the shell command for the user's request", commandResponseSchema)
+	return request
+}
 
-	// Make the API call
-	response, err := p.makeAPICall(ctx, request)
-	if err != nil {
-		return nil, err
+// withResponseTool makes the model respond by calling a tool whose input
+// matches schema, so the response is structured
+func withResponseTool(request *AnthropicRequest, name, description string, schema *jsonSchema) {
+	request.Tools = []AnthropicTool{{Name: name, Description: description, InputSchema: schema}}
+	request.ToolChoice = &AnthropicToolChoice{Type: "tool", Name: name}
+}
+
+// reask returns a reaskFunc continuing the conversation of request
+func (p *AnthropicProvider) reask(ctx context.Context, request AnthropicRequest, usage *types.TokenUsage) reaskFunc {
+	return func(previous, repairPrompt string) (string, error) {
+		request.Stream = false
+		request.Messages = append(request.Messages[:len(request.Messages):len(request.Messages)],
+			AnthropicMessage{Role: "assistant", Content: previous},
+			AnthropicMessage{Role: "user", Content: repairPrompt},
+		)
+		response, err := p.makeAPICall(ctx, request)
+		if err != nil {
+			return "", err
+		}
+		usage.Add(response.Usage.tokenUsage())
+		return p.responseContent(response)
 	}
+}
 
-	// Parse the response
-	return p.parseCommandResponse(response)
+// decodeAnthropicStreamEvent returns the text of one event of a streamed response
+func decodeAnthropicStreamEvent(payload []byte, usage *types.TokenUsage) (string, error) {
+	var event AnthropicStreamEvent
+	if err := decodeStreamPayload(payload, &event); err != nil {
+		return "", err
+	}
+	if event.Error != nil {
+		return "", &types.NLShellError{
+			Type:    types.ErrTypeProvider,
+			Message: fmt.Sprintf("Anthropic API error: %s", event.Error.Message),
+			Context: map[string]interface{}{
+				"error_type": event.Error.Type,
+			},
+		}
+	}
+	// Input tokens are counted when the message starts, output tokens as it ends
+	if event.Message != nil {
+		usage.InputTokens = event.Message.Usage.InputTokens
+	}
+	if event.Usage != nil {
+		usage.OutputTokens = event.Usage.OutputTokens
+	}
+	if event.Type != "content_block_delta" || event.Delta == nil {
+		return "", nil
+	}
+	// Tool input arrives as pieces of JSON, text as text
+	if event.Delta.Type == "input_json_delta" {
+		return event.Delta.PartialJSON, nil
+	}
+	return event.Delta.Text, nil
 }
 
 // validateResultInternal implements the actual Anthropic API call for result validation
@@ -102,15 +238,26 @@ func (p *AnthropicProvider) validateResultInternal(ctx context.Context, command,
 			},
 		},
 	}
+	withResponseTool(&request, "validation_response", "Return the assessment of the command's result", validationResponseSchema)
 
 	// Make the API call
 	response, err := p.makeAPICall(ctx, request)
 	if err != nil {
 		return nil, err
 	}
+	usage := response.Usage.tokenUsage()
 
-	// Parse the validation response
-	return p.parseValidationResponse(response)
+	// Parse the validation response, asking the model to correct it when it does not match the schema
+	content, err := p.responseContent(response)
+	if err != nil {
+		return nil, err
+	}
+	result, err := decodeWithRepair(content, decodeValidationResponse, p.reask(ctx, request, &usage))
+	if err != nil {
+		return nil, err
+	}
+	result.Usage = usage
+	return result, nil
 }
 
 // getModel returns the model to use for this provider
@@ -129,8 +276,8 @@ func (p *AnthropicProvider) getBaseURL() string {
 	return "https://api.anthropic.com" // Default Anthropic API URL
 }
 
-// makeAPICall makes an HTTP request to the Anthropic API
-func (p *AnthropicProvider) makeAPICall(ctx context.Context, request AnthropicRequest) (*AnthropicResponse, error) {
+// newHTTPRequest creates the HTTP request for an Anthropic API call
+func (p *AnthropicProvider) newHTTPRequest(ctx context.Context, request AnthropicRequest) (*http.Request, error) {
 	// Serialize the request
 	requestBody, err := json.Marshal(request)
 	if err != nil {
@@ -158,6 +305,15 @@ func (p *AnthropicProvider) makeAPICall(ctx context.Context, request AnthropicRe
 	if p.config != nil && p.config.APIKey != "" {
 		httpReq.Header.Set("x-api-key", p.config.APIKey)
 	}
+	return httpReq, nil
+}
+
+// makeAPICall makes an HTTP request to the Anthropic API
+func (p *AnthropicProvider) makeAPICall(ctx context.Context, request AnthropicRequest) (*AnthropicResponse, error) {
+	httpReq, err := p.newHTTPRequest(ctx, request)
+	if err != nil {
+		return nil, err
+	}
 
 	// Make the request
 	httpResp, err := p.httpClient.Do(httpReq)
@@ -219,73 +375,22 @@ func (p *AnthropicProvider) makeAPICall(ctx context.Context, request AnthropicRe
 	return &response, nil
 }
 
-// parseCommandResponse parses the Anthropic response for command generation
-func (p *AnthropicProvider) parseCommandResponse(response *AnthropicResponse) (*types.CommandResponse, error) {
+// responseContent returns the input of the tool call in a response, or its
+// text when the model answered without calling the tool
+func (p *AnthropicProvider) responseContent(response *AnthropicResponse) (string, error) {
 	if len(response.Content) == 0 {
-		return nil, &types.NLShellError{
+		return "", &types.NLShellError{
 			Type:    types.ErrTypeProvider,
 			Message: "no content in Anthropic response",
 		}
 	}
 
-	content := response.Content[0].Text
-
-	// Try to parse as JSON first
-	var jsonResponse struct {
-		Command      string   `json:"command"`
-		Explanation  string   `json:"explanation"`
-		Confidence   float64  `json:"confidence"`
-		Alternatives []string `json:"alternatives"`
-	}
-
-	if err := json.Unmarshal([]byte(content), &jsonResponse); err == nil {
-		return &types.CommandResponse{
-			Command:      jsonResponse.Command,
-			Explanation:  jsonResponse.Explanation,
-			Confidence:   jsonResponse.Confidence,
-			Alternatives: jsonResponse.Alternatives,
-		}, nil
-	}
-
-	// Fallback: treat the entire content as the command
-	return &types.CommandResponse{
-		Command:     strings.TrimSpace(content),
-		Explanation: "Generated by Anthropic Claude",
-		Confidence:  0.8, // Default confidence when we can't parse JSON
-	}, nil
-}
-
-// parseValidationResponse parses the Anthropic response for result validation
-func (p *AnthropicProvider) parseValidationResponse(response *AnthropicResponse) (*types.ValidationResponse, error) {
-	if len(response.Content) == 0 {
-		return nil, &types.NLShellError{
-			Type:    types.ErrTypeProvider,
-			Message: "no content in Anthropic response",
+	var text strings.Builder
+	for _, block := range response.Content {
+		if block.Type == "tool_use" {
+			return string(block.Input), nil
 		}
+		text.WriteString(block.Text)
 	}
-
-	content := response.Content[0].Text
-
-	// Try to parse as JSON
-	var jsonResponse struct {
-		IsCorrect   bool     `json:"is_correct"`
-		Explanation string   `json:"explanation"`
-		Suggestions []string `json:"suggestions"`
-		Correction  string   `json:"correction"`
-	}
-
-	if err := json.Unmarshal([]byte(content), &jsonResponse); err == nil {
-		return &types.ValidationResponse{
-			IsCorrect:   jsonResponse.IsCorrect,
-			Explanation: jsonResponse.Explanation,
-			Suggestions: jsonResponse.Suggestions,
-			Correction:  jsonResponse.Correction,
-		}, nil
-	}
-
-	// Fallback: treat the content as explanation
-	return &types.ValidationResponse{
-		IsCorrect:   false, // Conservative default
-		Explanation: strings.TrimSpace(content),
-	}, nil
+	return text.String(), nil
 }
@@ -306,55 +306,44 @@ func TestAnthropicProvider_RateLimitRetry(t *testing.T) {
 	}
 }
 
-func TestAnthropicProvider_FallbackParsing(t *testing.T) {
-	// Mock server that returns non-JSON response
+func TestAnthropicProvider_RepairsResponse(t *testing.T) {
+	// Mock server that answers with text, then with a tool call when asked to correct it
+	var requests []AnthropicRequest
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		response := AnthropicResponse{
-			ID:   "test-id",
-			Type: "message",
-			Role: "assistant",
-			Content: []AnthropicContent{
-				{
-					Type: "text",
-					Text: "ls -la", // Plain text, not JSON
-				},
-			},
-			Model:      "claude-3-haiku-20240307",
-			StopReason: "end_turn",
+		var request AnthropicRequest
+		json.NewDecoder(r.Body).Decode(&request)
+		requests = append(requests, request)
+
+		content := AnthropicContent{Type: "text", Text: "ls -la"} // Plain text, not JSON
+		if len(requests) > 1 {
+			content = AnthropicContent{
+				Type:  "tool_use",
+				Name:  "command_response",
+				Input: json.RawMessage(`{"command": "ls -la", "explanation": "List files", "confidence": 0.9}`),
+			}
 		}
-
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(response)
+		json.NewEncoder(w).Encode(AnthropicResponse{Type: "message", Role: "assistant", Content: []AnthropicContent{content}})
 	}))
 	defer server.Close()
 
-	config := &types.ProviderConfig{
-		APIKey:       "test-api-key",
-		BaseURL:      server.URL,
-		DefaultModel: "claude-3-haiku-20240307",
-		Timeout:      30 * time.Second,
-	}
-
-	retryConfig := DefaultRetryConfig()
-	provider := NewAnthropicProvider(config, retryConfig)
-
-	ctx := context.Background()
-
-	response, err := provider.GenerateCommand(ctx, "list files", &types.Context{})
+	provider := NewAnthropicProvider(&types.ProviderConfig{APIKey: "test-api-key", BaseURL: server.URL}, DefaultRetryConfig())
+	response, err := provider.GenerateCommand(context.Background(), "list files", &types.Context{})
 	if err != nil {
 		t.Fatalf("GenerateCommand failed: %v", err)
 	}
 
-	if response.Command != "ls -la" {
-		t.Errorf("Expected 'ls -la', got '%s'", response.Command)
+	if response.Command != "ls -la" || response.Explanation != "List files" {
+		t.Errorf("Expected the corrected response, got %+v", response)
 	}
-
-	if response.Confidence != 0.8 {
-		t.Errorf("Expected default confidence 0.8, got %f", response.Confidence)
+	if len(requests) != 2 {
+		t.Fatalf("Expected the model to be asked once to correct its response, got %d requests", len(requests))
 	}
-
-	if response.Explanation != "Generated by Anthropic Claude" {
-		t.Errorf("Expected default explanation, got '%s'", response.Explanation)
+	if len(requests[0].Tools) != 1 || requests[0].ToolChoice == nil || requests[0].ToolChoice.Name != requests[0].Tools[0].Name {
+		t.Errorf("Expected the model to be made to call the response tool, got %+v %+v", requests[0].Tools, requests[0].ToolChoice)
+	}
+	messages := requests[1].Messages
+	if len(messages) != 3 || messages[1].Role != "assistant" || messages[1].Content != "ls -la" || !strings.Contains(messages[2].Content, "expected a JSON object") {
+		t.Errorf("Expected the previous response and the problem in the repair request, got %+v", messages)
 	}
 }
 
@@ -0,0 +1,303 @@
+package llm
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+const (
+	// DefaultPromptTokenBudget caps the system prompt when no budget is
+	// configured. Context beyond it rarely changes the generated command but
+	// is paid for on every request.
+	DefaultPromptTokenBudget = 2000
+
+	// completionTokenReserve is left free in the context window for the response
+	completionTokenReserve = 500
+
+	// defaultContextWindow is assumed for models missing from modelContextWindows
+	defaultContextWindow = 8192
+
+	// ollamaContextWindow is Ollama's default num_ctx; the server truncates
+	// longer prompts whatever the model supports
+	ollamaContextWindow = 2048
+
+	// minPromptTokenBudget keeps the rules and response format when the
+	// context window is nearly filled by the request itself
+	minPromptTokenBudget = 256
+)
+
+// modelContextWindows maps model name prefixes to context window sizes in
+// tokens. More specific prefixes come first.
+var modelContextWindows = []struct {
+	prefix string
+	tokens int
+}{
+	{"gpt-4o", 128000},
+	{"gpt-4-turbo", 128000},
+	{"gpt-4-32k", 32768},
+	{"gpt-4.1", 1047576},
+	{"gpt-4", 8192},
+	{"gpt-3.5-turbo-instruct", 4096},
+	{"gpt-3.5-turbo", 16385},
+	{"o1", 200000},
+	{"o3", 200000},
+	{"o4", 200000},
+	{"claude-2", 100000},
+	{"claude", 200000},
+	{"gemini-1.5-pro", 2097152},
+	{"gemini-1.5", 1048576},
+	{"gemini-2", 1048576},
+	{"gemini-pro", 32760},
+	{"gemini", 32760},
+	{"llama3", 8192},
+	{"llama2", 4096},
+	{"codellama", 16384},
+	{"mistral", 32768},
+	{"mixtral", 32768},
+}
+
+// ContextWindow returns the context window size in tokens of a provider's model
+func ContextWindow(provider, model string) int {
+	if provider == "ollama" {
+		return ollamaContextWindow
+	}
+
+	// OpenRouter model names are prefixed with the vendor, e.g. anthropic/claude-3-haiku
+	name := strings.ToLower(model)
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	for _, window := range modelContextWindows {
+		if strings.HasPrefix(name, window.prefix) {
+			return window.tokens
+		}
+	}
+	return defaultContextWindow
+}
+
+// PromptBudget returns the tokens available to the system prompt for request:
+// the configured budget, or DefaultPromptTokenBudget when it is zero, limited
+// to what the model's context window leaves after the request and the response
+func PromptBudget(provider, model string, configured int, request string) int {
+	budget := configured
+	if budget <= 0 {
+		budget = DefaultPromptTokenBudget
+	}
+
+	available := ContextWindow(provider, model) - completionTokenReserve - estimateTokens(request)
+	if available < budget {
+		budget = available
+	}
+	if budget < minPromptTokenBudget {
+		budget = minPromptTokenBudget
+	}
+	return budget
+}
+
+const (
+	// filesHeading introduces the file list in the system prompt
+	filesHeading = "Files in current directory:\n"
+
+	// fileSummaryReserve is kept for the line summarizing files left out of the prompt
+	fileSummaryReserve = 24
+
+	// conversationHeading introduces earlier turns of the session in the system prompt
+	conversationHeading = "Earlier in this session, oldest first (the request may refer to these):\n"
+)
+
+// promptStopWords are request words too common to rank files by
+var promptStopWords = map[string]bool{
+	"a": true, "all": true, "an": true, "and": true, "any": true, "by": true,
+	"dir": true, "directory": true, "do": true, "every": true, "file": true,
+	"files": true, "find": true, "folder": true, "for": true, "from": true,
+	"get": true, "in": true, "into": true, "is": true, "it": true, "list": true,
+	"me": true, "my": true, "of": true, "on": true, "show": true, "that": true,
+	"the": true, "them": true, "then": true, "this": true, "to": true, "with": true,
+}
+
+// rankedFiles splits the file list into files named after request keywords,
+// best matches first, and the other files in their original order
+type rankedFiles struct {
+	relevant []types.FileInfo
+	other    []types.FileInfo
+}
+
+// rankFiles ranks files by how many keywords their names match
+func rankFiles(files []types.FileInfo, keywords []string) rankedFiles {
+	var ranked rankedFiles
+	scores := make(map[int]int)
+	var relevant []int
+	for i, file := range files {
+		score := 0
+		for _, keyword := range keywords {
+			if fileMatchesKeyword(file, keyword) {
+				score++
+			}
+		}
+		if score > 0 {
+			scores[i] = score
+			relevant = append(relevant, i)
+		} else {
+			ranked.other = append(ranked.other, file)
+		}
+	}
+
+	sort.SliceStable(relevant, func(a, b int) bool {
+		return scores[relevant[a]] > scores[relevant[b]]
+	})
+	for _, i := range relevant {
+		ranked.relevant = append(ranked.relevant, files[i])
+	}
+	return ranked
+}
+
+// fileMatchesKeyword reports whether a file's name contains keyword. Short
+// keywords such as "go" or "js" only match an extension or a name prefix.
+func fileMatchesKeyword(file types.FileInfo, keyword string) bool {
+	name := strings.ToLower(file.Name)
+	if len(keyword) >= 3 {
+		return strings.Contains(name, keyword)
+	}
+	return strings.TrimPrefix(filepath.Ext(name), ".") == keyword || strings.HasPrefix(name, keyword)
+}
+
+// requestKeywords returns the lowercase words of request worth matching file names against
+func requestKeywords(request string) []string {
+	words := strings.FieldsFunc(strings.ToLower(request), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '.' && r != '_' && r != '-'
+	})
+
+	var keywords []string
+	seen := make(map[string]bool)
+	for _, word := range words {
+		word = strings.Trim(word, ".-_")
+		if len(word) < 2 || promptStopWords[word] || seen[word] {
+			continue
+		}
+		seen[word] = true
+		keywords = append(keywords, word)
+	}
+	return keywords
+}
+
+// fitFiles renders file lines while spend accepts them, returning the lines
+// and the files left out
+func fitFiles(files []types.FileInfo, spend func(string) string) ([]string, []types.FileInfo) {
+	var lines []string
+	for i, file := range files {
+		line := spend(formatFileLine(file))
+		if line == "" {
+			return lines, files[i:]
+		}
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
+
+// formatFileLine renders one entry of the file list
+func formatFileLine(file types.FileInfo) string {
+	if file.IsDir {
+		return fmt.Sprintf("  %s/ (directory)\n", file.Name)
+	}
+	return fmt.Sprintf("  %s (file, %d bytes)\n", file.Name, file.Size)
+}
+
+// summarizeFiles describes files left out of the prompt by kind, so the model
+// still knows they exist
+func summarizeFiles(files []types.FileInfo) string {
+	directories := 0
+	extensions := make(map[string]int)
+	for _, file := range files {
+		if file.IsDir {
+			directories++
+			continue
+		}
+		ext := filepath.Ext(file.Name)
+		if ext == "" {
+			ext = "no extension"
+		}
+		extensions[ext]++
+	}
+
+	kinds := make([]string, 0, len(extensions))
+	for ext := range extensions {
+		kinds = append(kinds, ext)
+	}
+	sort.Slice(kinds, func(a, b int) bool {
+		if extensions[kinds[a]] != extensions[kinds[b]] {
+			return extensions[kinds[a]] > extensions[kinds[b]]
+		}
+		return kinds[a] < kinds[b]
+	})
+
+	var parts []string
+	switch {
+	case directories == 1:
+		parts = append(parts, "1 directory")
+	case directories > 1:
+		parts = append(parts, fmt.Sprintf("%d directories", directories))
+	}
+	other := 0
+	for i, ext := range kinds {
+		if i < 3 {
+			parts = append(parts, fmt.Sprintf("%d %s", extensions[ext], ext))
+		} else {
+			other += extensions[ext]
+		}
+	}
+	if other > 0 {
+		parts = append(parts, fmt.Sprintf("%d other", other))
+	}
+
+	return fmt.Sprintf("  ... and %d more (%s)\n", len(files), strings.Join(parts, ", "))
+}
+
+// fitConversation renders the newest turns that spend accepts, oldest first.
+// A turn whose output does not fit is rendered without it.
+func fitConversation(turns []types.ConversationTurn, spend func(string) string) string {
+	if len(turns) == 0 || spend(conversationHeading) == "" {
+		return ""
+	}
+
+	var rendered []string
+	for i := len(turns) - 1; i >= 0; i-- {
+		text := spend(formatTurn(turns[i], true))
+		if text == "" && turns[i].Output != "" {
+			text = spend(formatTurn(turns[i], false))
+		}
+		if text == "" {
+			break
+		}
+		rendered = append([]string{text}, rendered...)
+	}
+	if len(rendered) == 0 {
+		return ""
+	}
+	return conversationHeading + strings.Join(rendered, "")
+}
+
+// formatTurn renders one earlier turn of the session
+func formatTurn(turn types.ConversationTurn, withOutput bool) string {
+	var text strings.Builder
+	fmt.Fprintf(&text, "  - Request: %s\n", turn.Input)
+	if turn.Command != "" {
+		fmt.Fprintf(&text, "    Command: %s\n", turn.Command)
+	}
+	if turn.ExitCode == nil {
+		text.WriteString("    (not run)\n")
+		return text.String()
+	}
+	fmt.Fprintf(&text, "    Exit code: %d\n", *turn.ExitCode)
+	if withOutput && turn.Output != "" {
+		text.WriteString("    Output:\n")
+		for _, line := range strings.Split(turn.Output, "\n") {
+			fmt.Fprintf(&text, "      %s\n", line)
+		}
+	}
+	return text.String()
+}
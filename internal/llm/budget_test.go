@@ -0,0 +1,166 @@
+package llm
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+func TestContextWindow(t *testing.T) {
+	tests := []struct {
+		provider string
+		model    string
+		expected int
+	}{
+		{"openai", "gpt-4", 8192},
+		{"openai", "gpt-4-turbo", 128000},
+		{"openai", "gpt-4o-mini", 128000},
+		{"openai", "gpt-3.5-turbo", 16385},
+		{"anthropic", "claude-3-haiku-20240307", 200000},
+		{"gemini", "gemini-pro", 32760},
+		{"openrouter", "anthropic/claude-3-opus", 200000},
+		{"ollama", "llama3.2", ollamaContextWindow},
+		{"openai", "unknown-model", defaultContextWindow},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.provider+"/"+tt.model, func(t *testing.T) {
+			if got := ContextWindow(tt.provider, tt.model); got != tt.expected {
+				t.Errorf("ContextWindow(%q, %q) = %d, want %d", tt.provider, tt.model, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPromptBudget(t *testing.T) {
+	if got := PromptBudget("openai", "gpt-4o", 0, "list files"); got != DefaultPromptTokenBudget {
+		t.Errorf("expected the default budget, got %d", got)
+	}
+	if got := PromptBudget("openai", "gpt-4o", 5000, "list files"); got != 5000 {
+		t.Errorf("expected the configured budget, got %d", got)
+	}
+
+	// The budget never exceeds what the context window leaves for the system prompt
+	request := "list files"
+	want := ollamaContextWindow - completionTokenReserve - estimateTokens(request)
+	if got := PromptBudget("ollama", "llama3.2", 5000, request); got != want {
+		t.Errorf("expected the budget to be limited by the context window to %d, got %d", want, got)
+	}
+
+	long := strings.Repeat("x", 4*ollamaContextWindow)
+	if got := PromptBudget("ollama", "llama3.2", 0, long); got != minPromptTokenBudget {
+		t.Errorf("expected the minimum budget for a request filling the window, got %d", got)
+	}
+}
+
+func TestRequestKeywords(t *testing.T) {
+	got := requestKeywords("Show all the Go files in the Docker folder, then config.yaml.")
+	want := []string{"go", "docker", "config.yaml"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("requestKeywords() = %v, want %v", got, want)
+	}
+}
+
+func TestPromptBuilder_BuildBudgetedSystemPrompt(t *testing.T) {
+	builder := NewPromptBuilder()
+
+	var files []types.FileInfo
+	for i := 0; i < 200; i++ {
+		files = append(files, types.FileInfo{Name: fmt.Sprintf("module%03d.py", i), Size: 100})
+	}
+	files = append(files,
+		types.FileInfo{Name: "docs", IsDir: true},
+		types.FileInfo{Name: "Dockerfile", Size: 512},
+	)
+
+	context := &types.Context{
+		WorkingDirectory: "/home/user/project",
+		Files:            files,
+		GitInfo:          &types.GitContext{IsRepository: true, CurrentBranch: "main"},
+		Conversation:     []types.ConversationTurn{{Input: "build the image", Command: "docker build ."}},
+	}
+
+	const budget = 400
+	prompt := builder.BuildBudgetedSystemPrompt(context, "run the dockerfile", budget)
+
+	if tokens := estimateTokens(prompt); tokens > budget {
+		t.Errorf("prompt exceeds its budget: %d tokens", tokens)
+	}
+	for _, want := range []string{
+		"Dockerfile (file, 512 bytes)",
+		"branch 'main'",
+		"build the image",
+		"more (",
+		"JSON object",
+	} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt should contain %q, got:\n%s", want, prompt)
+		}
+	}
+
+	// Files matching the request are listed before the others
+	if strings.Index(prompt, "Dockerfile") > strings.Index(prompt, "module000.py") {
+		t.Error("relevant files should come first")
+	}
+	if strings.Contains(prompt, "module199.py") {
+		t.Error("files beyond the budget should be summarized")
+	}
+
+	// Without a budget every file is listed
+	unlimited := builder.BuildBudgetedSystemPrompt(context, "run the dockerfile", 0)
+	if !strings.Contains(unlimited, "module199.py") || strings.Contains(unlimited, "more (") {
+		t.Error("unlimited prompt should list every file")
+	}
+}
+
+func TestSummarizeFiles(t *testing.T) {
+	summary := summarizeFiles([]types.FileInfo{
+		{Name: "a.go"}, {Name: "b.go"}, {Name: "c.md"}, {Name: "d.txt"}, {Name: "e.sh"},
+		{Name: "Makefile"}, {Name: "vendor", IsDir: true},
+	})
+
+	want := "  ... and 7 more (1 directory, 2 .go, 1 .md, 1 .sh, 2 other)\n"
+	if summary != want {
+		t.Errorf("summarizeFiles() = %q, want %q", summary, want)
+	}
+}
+
+func TestFitConversation(t *testing.T) {
+	exitCode := 1
+	turns := []types.ConversationTurn{
+		{Input: "list the logs", Command: "ls *.log"},
+		{Input: "count errors in them", Command: "grep -c ERROR *.log", Output: "app.log:3\nworker.log:0", ExitCode: &exitCode},
+	}
+
+	unlimited := func(s string) string { return s }
+	got := fitConversation(turns, unlimited)
+	for _, want := range []string{
+		conversationHeading,
+		"  - Request: list the logs\n    Command: ls *.log\n    (not run)\n",
+		"    Exit code: 1\n    Output:\n      app.log:3\n      worker.log:0\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("fitConversation() should contain %q, got:\n%s", want, got)
+		}
+	}
+	if strings.Index(got, "list the logs") > strings.Index(got, "count errors") {
+		t.Error("turns should be rendered oldest first")
+	}
+
+	// When space runs out the newest turn is kept, without its output if need be
+	remaining := estimateTokens(conversationHeading) + estimateTokens(formatTurn(turns[1], false))
+	limited := func(s string) string {
+		tokens := estimateTokens(s)
+		if tokens > remaining {
+			return ""
+		}
+		remaining -= tokens
+		return s
+	}
+	got = fitConversation(turns, limited)
+	if !strings.Contains(got, "count errors") || strings.Contains(got, "list the logs") || strings.Contains(got, "app.log:3") {
+		t.Errorf("fitConversation() should keep only the newest turn without output, got:\n%s", got)
+	}
+}
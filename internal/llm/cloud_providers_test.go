@@ -167,6 +167,34 @@ func TestOpenRouterProvider_ValidateResult(t *testing.T) {
 	}
 }
 
+func TestOpenRouterProvider_ResponseFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   *types.ProviderConfig
+		expected string
+	}{
+		{"default model", &types.ProviderConfig{}, "json_object"},
+		{"structured outputs", &types.ProviderConfig{DefaultModel: "openai/gpt-4o:free"}, "json_schema"},
+		{"legacy OpenAI model", &types.ProviderConfig{DefaultModel: "openai/gpt-4"}, ""},
+		{"other vendor", &types.ProviderConfig{DefaultModel: "meta-llama/llama-2-70b-chat"}, ""},
+		{"configured capability", &types.ProviderConfig{DefaultModel: "mistralai/mistral-large", Capabilities: types.ProviderCapabilities{JSONMode: true}}, "json_object"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := NewOpenRouterProvider(tt.config, DefaultRetryConfig()).(*OpenRouterProvider)
+			request := provider.commandRequest("system", "list files", false)
+			formatType := ""
+			if request.ResponseFormat != nil {
+				formatType = request.ResponseFormat.Type
+			}
+			if formatType != tt.expected {
+				t.Errorf("Expected response format %q for %s, got %+v", tt.expected, request.Model, request.ResponseFormat)
+			}
+		})
+	}
+}
+
 func TestOpenRouterProvider_GetModel(t *testing.T) {
 	// Test with custom model
 	config := &types.ProviderConfig{
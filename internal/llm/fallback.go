@@ -0,0 +1,192 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/errors"
+	"github.com/kanishka-sahoo/nl-to-shell/internal/interfaces"
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+// FallbackProvider implements LLMProvider over an ordered list of providers.
+// Each request goes to the first provider; when it is unreachable, rejects its
+// credentials, is rate-limited or fails with a server error, the request moves
+// on to the next provider. Responses name the provider that answered.
+type FallbackProvider struct {
+	providers []interfaces.LLMProvider
+	logger    errors.Logger
+}
+
+// NewFallbackProvider creates a provider that tries providers in order
+func NewFallbackProvider(providers ...interfaces.LLMProvider) *FallbackProvider {
+	return &FallbackProvider{
+		providers: providers,
+		logger:    errors.GetGlobalLogger(),
+	}
+}
+
+// WithLogger sets the logger fallbacks are reported to
+func (p *FallbackProvider) WithLogger(logger errors.Logger) *FallbackProvider {
+	p.logger = logger
+	return p
+}
+
+// GenerateCommand generates a command with the first provider that answers
+func (p *FallbackProvider) GenerateCommand(ctx context.Context, prompt string, context *types.Context) (*types.CommandResponse, error) {
+	var response *types.CommandResponse
+	answered, err := p.try(ctx, func(provider interfaces.LLMProvider) error {
+		var err error
+		response, err = provider.GenerateCommand(ctx, prompt, context)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if response.Provider == "" {
+		response.Provider = answered.GetProviderInfo().Name
+	}
+	return response, nil
+}
+
+// GenerateCommandStream generates a command with the first provider that
+// answers, streaming the response from providers that support it
+func (p *FallbackProvider) GenerateCommandStream(ctx context.Context, prompt string, context *types.Context, onText func(string)) (*types.CommandResponse, error) {
+	var response *types.CommandResponse
+	answered, err := p.try(ctx, func(provider interfaces.LLMProvider) error {
+		var err error
+		if streaming, ok := provider.(interfaces.StreamingLLMProvider); ok {
+			response, err = streaming.GenerateCommandStream(ctx, prompt, context, onText)
+		} else {
+			response, err = provider.GenerateCommand(ctx, prompt, context)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if response.Provider == "" {
+		response.Provider = answered.GetProviderInfo().Name
+	}
+	return response, nil
+}
+
+// ValidateResult validates a result with the first provider that answers
+func (p *FallbackProvider) ValidateResult(ctx context.Context, command, output, intent string) (*types.ValidationResponse, error) {
+	var response *types.ValidationResponse
+	_, err := p.try(ctx, func(provider interfaces.LLMProvider) error {
+		var err error
+		response, err = provider.ValidateResult(ctx, command, output, intent)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// GetProviderInfo returns the information of the first provider
+func (p *FallbackProvider) GetProviderInfo() types.ProviderInfo {
+	if len(p.providers) == 0 {
+		return types.ProviderInfo{Name: "fallback"}
+	}
+	return p.providers[0].GetProviderInfo()
+}
+
+// Providers returns the providers in the order they are tried
+func (p *FallbackProvider) Providers() []interfaces.LLMProvider {
+	return p.providers
+}
+
+// try calls operation with each provider in turn until one succeeds, using a
+// RecoveryManager with a ProviderFallbackStrategy to switch providers. Errors
+// the next provider would also hit, such as an unparseable request, are returned
+// without falling back.
+func (p *FallbackProvider) try(ctx context.Context, operation func(interfaces.LLMProvider) error) (interfaces.LLMProvider, error) {
+	if len(p.providers) == 0 {
+		return nil, &types.NLShellError{
+			Type:    types.ErrTypeConfiguration,
+			Message: "no providers configured",
+		}
+	}
+
+	current := 0
+	tried := make(map[int]bool)
+	names := make([]string, len(p.providers))
+	for i, provider := range p.providers {
+		names[i] = provider.GetProviderInfo().Name
+	}
+
+	recovery := errors.NewRecoveryManager().WithLogger(p.logger)
+	recovery.RegisterStrategy(types.ErrTypeProvider, errors.NewProviderFallbackStrategy(names[1:],
+		func(ctx context.Context, name string) error {
+			for i := range p.providers {
+				if names[i] == name && !tried[i] {
+					current = i
+					return nil
+				}
+			}
+			return fmt.Errorf("provider %s was already tried", name)
+		}))
+
+	var failures []string
+	for {
+		tried[current] = true
+		err := operation(p.providers[current])
+		if err == nil {
+			return p.providers[current], nil
+		}
+
+		reason := fallbackReason(err)
+		if reason == "" || ctx.Err() != nil {
+			return nil, err
+		}
+		failures = append(failures, fmt.Sprintf("%s: %s", names[current], reason))
+
+		fallbackErr := errors.NewProviderError(fmt.Sprintf("provider %s failed: %s", names[current], reason), err).
+			WithContext("provider", names[current])
+		if recovery.TryRecover(ctx, fallbackErr) != nil {
+			if len(p.providers) == 1 {
+				return nil, err
+			}
+			return nil, &types.NLShellError{
+				Type:    types.ErrTypeProvider,
+				Message: "all providers failed (" + strings.Join(failures, "; ") + ")",
+				Cause:   err,
+				Context: map[string]interface{}{
+					"providers": names,
+				},
+			}
+		}
+	}
+}
+
+// fallbackReason returns why err should move a request on to the next
+// provider, or "" when the next provider would fail the same way
+func fallbackReason(err error) string {
+	var nlErr *types.NLShellError
+	for cause := err; errors.AsNLShellError(cause, &nlErr); cause = nlErr.Cause {
+		switch nlErr.Type {
+		case types.ErrTypeNetwork, types.ErrTypeTimeout:
+			return "unreachable"
+		case types.ErrTypeAuth:
+			return "authentication failed"
+		}
+
+		if status, ok := nlErr.Context["http_status"].(int); ok {
+			switch {
+			case status == http.StatusUnauthorized || status == http.StatusForbidden:
+				return "authentication failed"
+			case status == http.StatusTooManyRequests:
+				return "rate limited"
+			case status >= 500:
+				return fmt.Sprintf("server error (status %d)", status)
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,183 @@
+package llm
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/errors"
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+// recordingLogger records logged errors instead of writing them
+type recordingLogger struct {
+	errors []*types.NLShellError
+}
+
+func (l *recordingLogger) LogError(err *types.NLShellError) {
+	l.errors = append(l.errors, err)
+}
+
+func (l *recordingLogger) LogErrorWithContext(ctx context.Context, err *types.NLShellError) {
+	l.errors = append(l.errors, err)
+}
+
+func (l *recordingLogger) SetLevel(level errors.LogLevel) {}
+
+func (l *recordingLogger) Close() error { return nil }
+
+// namedProvider returns a mock provider called name that fails with err, or
+// answers with command when err is nil, counting its calls
+func namedProvider(name, command string, err error, calls *int) *MockProvider {
+	return &MockProvider{
+		generateCommandFunc: func(ctx context.Context, prompt string, context *types.Context) (*types.CommandResponse, error) {
+			*calls++
+			if err != nil {
+				return nil, err
+			}
+			return &types.CommandResponse{Command: command}, nil
+		},
+		validateResultFunc: func(ctx context.Context, command, output, intent string) (*types.ValidationResponse, error) {
+			*calls++
+			if err != nil {
+				return nil, err
+			}
+			return &types.ValidationResponse{IsCorrect: true, Explanation: name}, nil
+		},
+		getProviderInfoFunc: func() types.ProviderInfo {
+			return types.ProviderInfo{Name: name}
+		},
+	}
+}
+
+// statusError returns a provider error for an HTTP status, wrapped like ExecuteWithRetry does
+func statusError(status int) error {
+	return &types.NLShellError{
+		Type:    types.ErrTypeProvider,
+		Message: "operation failed after 4 attempts",
+		Cause: &types.NLShellError{
+			Type:    types.ErrTypeProvider,
+			Message: "API returned an error status",
+			Context: map[string]interface{}{"http_status": status},
+		},
+	}
+}
+
+func TestFallbackProvider_GenerateCommand(t *testing.T) {
+	tests := []struct {
+		name        string
+		primaryErr  error
+		expectUsed  string
+		expectCalls [3]int
+		expectError bool
+	}{
+		{
+			name:        "primary answers",
+			expectUsed:  "openai",
+			expectCalls: [3]int{1, 0, 0},
+		},
+		{
+			name:        "rate limited",
+			primaryErr:  statusError(http.StatusTooManyRequests),
+			expectUsed:  "anthropic",
+			expectCalls: [3]int{1, 1, 0},
+		},
+		{
+			name:        "server error",
+			primaryErr:  statusError(http.StatusBadGateway),
+			expectUsed:  "anthropic",
+			expectCalls: [3]int{1, 1, 0},
+		},
+		{
+			name:        "bad credentials",
+			primaryErr:  statusError(http.StatusUnauthorized),
+			expectUsed:  "anthropic",
+			expectCalls: [3]int{1, 1, 0},
+		},
+		{
+			name:        "unreachable",
+			primaryErr:  &types.NLShellError{Type: types.ErrTypeNetwork, Message: "failed to make HTTP request"},
+			expectUsed:  "anthropic",
+			expectCalls: [3]int{1, 1, 0},
+		},
+		{
+			name:        "bad request is not retried elsewhere",
+			primaryErr:  statusError(http.StatusBadRequest),
+			expectCalls: [3]int{1, 0, 0},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls [3]int
+			logger := &recordingLogger{}
+			provider := NewFallbackProvider(
+				namedProvider("openai", "ls -la", tt.primaryErr, &calls[0]),
+				namedProvider("anthropic", "ls -l", nil, &calls[1]),
+				namedProvider("ollama", "ls", nil, &calls[2]),
+			).WithLogger(logger)
+
+			response, err := provider.GenerateCommand(context.Background(), "list files", &types.Context{})
+			if calls != tt.expectCalls {
+				t.Errorf("expected calls %v, got %v", tt.expectCalls, calls)
+			}
+			if tt.expectError {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if response.Provider != tt.expectUsed {
+				t.Errorf("expected the response from %s, got %s", tt.expectUsed, response.Provider)
+			}
+			if tt.primaryErr != nil && len(logger.errors) == 0 {
+				t.Error("expected the fallback to be logged")
+			}
+		})
+	}
+}
+
+func TestFallbackProvider_AllProvidersFail(t *testing.T) {
+	var calls [2]int
+	provider := NewFallbackProvider(
+		namedProvider("openai", "", statusError(http.StatusServiceUnavailable), &calls[0]),
+		namedProvider("ollama", "", &types.NLShellError{Type: types.ErrTypeNetwork, Message: "connection refused"}, &calls[1]),
+	).WithLogger(&recordingLogger{})
+
+	_, err := provider.ValidateResult(context.Background(), "ls", "", "list files")
+	if err == nil {
+		t.Fatal("expected an error when every provider fails")
+	}
+	if calls != [2]int{1, 1} {
+		t.Errorf("expected each provider to be tried once, got %v", calls)
+	}
+	for _, want := range []string{"openai: server error (status 503)", "ollama: unreachable"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error to contain %q, got %v", want, err)
+		}
+	}
+}
+
+func TestFallbackProvider_ValidateResult(t *testing.T) {
+	var calls [2]int
+	provider := NewFallbackProvider(
+		namedProvider("openai", "", statusError(http.StatusInternalServerError), &calls[0]),
+		namedProvider("anthropic", "", nil, &calls[1]),
+	).WithLogger(&recordingLogger{})
+
+	response, err := provider.ValidateResult(context.Background(), "ls", "a.txt", "list files")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if response.Explanation != "anthropic" {
+		t.Errorf("expected validation by the fallback provider, got %q", response.Explanation)
+	}
+	if info := provider.GetProviderInfo(); info.Name != "openai" {
+		t.Errorf("expected the first provider's info, got %s", info.Name)
+	}
+}
@@ -31,8 +31,10 @@ type GeminiPart struct {
 
 // GeminiGenerationConfig represents generation configuration
 type GeminiGenerationConfig struct {
-	Temperature     float64 `json:"temperature,omitempty"`
-	MaxOutputTokens int     `json:"maxOutputTokens,omitempty"`
+	Temperature      float64     `json:"temperature,omitempty"`
+	MaxOutputTokens  int         `json:"maxOutputTokens,omitempty"`
+	ResponseMimeType string      `json:"responseMimeType,omitempty"`
+	ResponseSchema   *jsonSchema `json:"responseSchema,omitempty"`
 }
 
 // GeminiResponse represents the response structure from Gemini API
@@ -56,6 +58,14 @@ type GeminiUsage struct {
 	TotalTokenCount      int `json:"totalTokenCount"`
 }
 
+// tokenUsage returns the usage as counted across providers
+func (u *GeminiUsage) tokenUsage() types.TokenUsage {
+	if u == nil {
+		return types.TokenUsage{}
+	}
+	return types.TokenUsage{InputTokens: u.PromptTokenCount, OutputTokens: u.CandidatesTokenCount}
+}
+
 // GeminiError represents an error from the Gemini API
 type GeminiError struct {
 	Code    int    `json:"code"`
@@ -65,11 +75,63 @@ type GeminiError struct {
 
 // generateCommandInternal implements the actual Gemini API call for command generation
 func (p *GeminiProvider) generateCommandInternal(ctx context.Context, prompt string, context *types.Context) (*types.CommandResponse, error) {
-	// Build the system prompt
-	systemPrompt := p.promptBuilder.BuildSystemPrompt(context)
+	// Build the system prompt within the model's prompt token budget
+	systemPrompt, promptTokens := p.buildSystemPrompt(context, prompt, p.GetProviderInfo().Name, p.getModel())
 
-	// Create the request
-	request := GeminiRequest{
+	// Make the API call
+	request := p.commandRequest(systemPrompt, prompt)
+	response, err := p.makeAPICall(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	usage := response.UsageMetadata.tokenUsage()
+
+	// Parse the response, asking the model to correct it when it does not match the schema
+	content, err := p.responseContent(response)
+	if err != nil {
+		return nil, err
+	}
+	result, err := decodeWithRepair(content, decodeCommandResponse, p.reask(ctx, request, &usage))
+	if err != nil {
+		return nil, err
+	}
+	result.PromptTokens = promptTokens
+	result.Provider = p.GetProviderInfo().Name
+	result.Model = p.getModel()
+	result.Usage = usage
+	return result, nil
+}
+
+// generateCommandStreamInternal implements the streamed Gemini API call for command generation
+func (p *GeminiProvider) generateCommandStreamInternal(ctx context.Context, prompt string, context *types.Context, onText func(string)) (*types.CommandResponse, error) {
+	// Build the system prompt within the model's prompt token budget
+	systemPrompt, promptTokens := p.buildSystemPrompt(context, prompt, p.GetProviderInfo().Name, p.getModel())
+
+	request := p.commandRequest(systemPrompt, prompt)
+	httpReq, err := p.newHTTPRequest(ctx, request, true)
+	if err != nil {
+		return nil, err
+	}
+	content, usage, err := p.streamResponse(httpReq, "Gemini", streamSSE, decodeGeminiStreamChunk, onText)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse the complete response, asking the model to correct it when it does not match the schema
+	result, err := decodeWithRepair(content, decodeCommandResponse, p.reask(ctx, request, &usage))
+	if err != nil {
+		return nil, err
+	}
+	result.PromptTokens = promptTokens
+	result.Provider = p.GetProviderInfo().Name
+	result.Model = p.getModel()
+	result.Usage = usage
+	return result, nil
+}
+
+// commandRequest creates the request for command generation
+func (p *GeminiProvider) commandRequest(systemPrompt, prompt string) GeminiRequest {
+	return GeminiRequest{
 		Contents: []GeminiContent{
 			{
 				Parts: []GeminiPart{
@@ -79,19 +141,59 @@ func (p *GeminiProvider) generateCommandInternal(ctx context.Context, prompt str
 			},
 		},
 		GenerationConfig: &GeminiGenerationConfig{
-			Temperature:     0.1, // Low temperature for more deterministic responses
-			MaxOutputTokens: 500, // Reasonable limit for shell commands
+			Temperature:      0.1, // Low temperature for more deterministic responses
+			MaxOutputTokens:  500, // Reasonable limit for shell commands
+			ResponseMimeType: "application/json",
+			ResponseSchema:   commandResponseSchema.forGemini(),
 		},
 	}
+}
 
-	// Make the API call
-	response, err := p.makeAPICall(ctx, request)
-	if err != nil {
-		return nil, err
+// reask returns a reaskFunc continuing the conversation of request
+func (p *GeminiProvider) reask(ctx context.Context, request GeminiRequest, usage *types.TokenUsage) reaskFunc {
+	return func(previous, repairPrompt string) (string, error) {
+		request.Contents = append(request.Contents[:len(request.Contents):len(request.Contents)],
+			GeminiContent{Parts: []GeminiPart{{Text: previous}}, Role: "model"},
+			GeminiContent{Parts: []GeminiPart{{Text: repairPrompt}}, Role: "user"},
+		)
+		response, err := p.makeAPICall(ctx, request)
+		if err != nil {
+			return "", err
+		}
+		usage.Add(response.UsageMetadata.tokenUsage())
+		return p.responseContent(response)
 	}
+}
 
-	// Parse the response
-	return p.parseCommandResponse(response)
+// decodeGeminiStreamChunk returns the text of one chunk of a streamed response
+func decodeGeminiStreamChunk(payload []byte, usage *types.TokenUsage) (string, error) {
+	var chunk GeminiResponse
+	if err := decodeStreamPayload(payload, &chunk); err != nil {
+		return "", err
+	}
+	if chunk.Error != nil {
+		return "", &types.NLShellError{
+			Type:    types.ErrTypeProvider,
+			Message: fmt.Sprintf("Gemini API error: %s", chunk.Error.Message),
+			Context: map[string]interface{}{
+				"error_code":   chunk.Error.Code,
+				"error_status": chunk.Error.Status,
+			},
+		}
+	}
+	// Each chunk reports the usage of the response so far
+	if chunk.UsageMetadata != nil {
+		*usage = chunk.UsageMetadata.tokenUsage()
+	}
+	if len(chunk.Candidates) == 0 {
+		return "", nil
+	}
+
+	var text strings.Builder
+	for _, part := range chunk.Candidates[0].Content.Parts {
+		text.WriteString(part.Text)
+	}
+	return text.String(), nil
 }
 
 // validateResultInternal implements the actual Gemini API call for result validation
@@ -110,8 +212,10 @@ func (p *GeminiProvider) validateResultInternal(ctx context.Context, command, ou
 			},
 		},
 		GenerationConfig: &GeminiGenerationConfig{
-			Temperature:     0.1,
-			MaxOutputTokens: 300,
+			Temperature:      0.1,
+			MaxOutputTokens:  300,
+			ResponseMimeType: "application/json",
+			ResponseSchema:   validationResponseSchema.forGemini(),
 		},
 	}
 
@@ -120,9 +224,19 @@ func (p *GeminiProvider) validateResultInternal(ctx context.Context, command, ou
 	if err != nil {
 		return nil, err
 	}
+	usage := response.UsageMetadata.tokenUsage()
 
-	// Parse the validation response
-	return p.parseValidationResponse(response)
+	// Parse the validation response, asking the model to correct it when it does not match the schema
+	content, err := p.responseContent(response)
+	if err != nil {
+		return nil, err
+	}
+	result, err := decodeWithRepair(content, decodeValidationResponse, p.reask(ctx, request, &usage))
+	if err != nil {
+		return nil, err
+	}
+	result.Usage = usage
+	return result, nil
 }
 
 // getModel returns the model to use for this provider
@@ -141,8 +255,9 @@ func (p *GeminiProvider) getBaseURL() string {
 	return "https://generativelanguage.googleapis.com/v1beta" // Default Gemini API URL
 }
 
-// makeAPICall makes an HTTP request to the Gemini API
-func (p *GeminiProvider) makeAPICall(ctx context.Context, request GeminiRequest) (*GeminiResponse, error) {
+// newHTTPRequest creates the HTTP request for a Gemini API call, streaming
+// the response as server-sent events when stream is set
+func (p *GeminiProvider) newHTTPRequest(ctx context.Context, request GeminiRequest, stream bool) (*http.Request, error) {
 	// Serialize the request
 	requestBody, err := json.Marshal(request)
 	if err != nil {
@@ -155,8 +270,13 @@ func (p *GeminiProvider) makeAPICall(ctx context.Context, request GeminiRequest)
 
 	// Create HTTP request
 	url := fmt.Sprintf("%s/models/%s:generateContent", p.getBaseURL(), p.getModel())
+	separator := "?"
+	if stream {
+		url = fmt.Sprintf("%s/models/%s:streamGenerateContent?alt=sse", p.getBaseURL(), p.getModel())
+		separator = "&"
+	}
 	if p.config != nil && p.config.APIKey != "" {
-		url += "?key=" + p.config.APIKey
+		url += separator + "key=" + p.config.APIKey
 	}
 
 	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(requestBody))
@@ -170,6 +290,15 @@ func (p *GeminiProvider) makeAPICall(ctx context.Context, request GeminiRequest)
 
 	// Set headers
 	httpReq.Header.Set("Content-Type", "application/json")
+	return httpReq, nil
+}
+
+// makeAPICall makes an HTTP request to the Gemini API
+func (p *GeminiProvider) makeAPICall(ctx context.Context, request GeminiRequest) (*GeminiResponse, error) {
+	httpReq, err := p.newHTTPRequest(ctx, request, false)
+	if err != nil {
+		return nil, err
+	}
 
 	// Make the request
 	httpResp, err := p.httpClient.Do(httpReq)
@@ -232,54 +361,10 @@ func (p *GeminiProvider) makeAPICall(ctx context.Context, request GeminiRequest)
 	return &response, nil
 }
 
-// parseCommandResponse parses the Gemini response for command generation
-func (p *GeminiProvider) parseCommandResponse(response *GeminiResponse) (*types.CommandResponse, error) {
-	if len(response.Candidates) == 0 {
-		return nil, &types.NLShellError{
-			Type:    types.ErrTypeProvider,
-			Message: "no candidates in Gemini response",
-		}
-	}
-
-	candidate := response.Candidates[0]
-	if len(candidate.Content.Parts) == 0 {
-		return nil, &types.NLShellError{
-			Type:    types.ErrTypeProvider,
-			Message: "no parts in Gemini candidate",
-		}
-	}
-
-	content := candidate.Content.Parts[0].Text
-
-	// Try to parse as JSON first
-	var jsonResponse struct {
-		Command      string   `json:"command"`
-		Explanation  string   `json:"explanation"`
-		Confidence   float64  `json:"confidence"`
-		Alternatives []string `json:"alternatives"`
-	}
-
-	if err := json.Unmarshal([]byte(content), &jsonResponse); err == nil {
-		return &types.CommandResponse{
-			Command:      jsonResponse.Command,
-			Explanation:  jsonResponse.Explanation,
-			Confidence:   jsonResponse.Confidence,
-			Alternatives: jsonResponse.Alternatives,
-		}, nil
-	}
-
-	// Fallback: treat the entire content as the command
-	return &types.CommandResponse{
-		Command:     strings.TrimSpace(content),
-		Explanation: "Generated by Google Gemini",
-		Confidence:  0.8, // Default confidence when we can't parse JSON
-	}, nil
-}
-
-// parseValidationResponse parses the Gemini response for result validation
-func (p *GeminiProvider) parseValidationResponse(response *GeminiResponse) (*types.ValidationResponse, error) {
+// responseContent returns the text of the first candidate of a response
+func (p *GeminiProvider) responseContent(response *GeminiResponse) (string, error) {
 	if len(response.Candidates) == 0 {
-		return nil, &types.NLShellError{
+		return "", &types.NLShellError{
 			Type:    types.ErrTypeProvider,
 			Message: "no candidates in Gemini response",
 		}
@@ -287,34 +372,15 @@ func (p *GeminiProvider) parseValidationResponse(response *GeminiResponse) (*typ
 
 	candidate := response.Candidates[0]
 	if len(candidate.Content.Parts) == 0 {
-		return nil, &types.NLShellError{
+		return "", &types.NLShellError{
 			Type:    types.ErrTypeProvider,
 			Message: "no parts in Gemini candidate",
 		}
 	}
 
-	content := candidate.Content.Parts[0].Text
-
-	// Try to parse as JSON
-	var jsonResponse struct {
-		IsCorrect   bool     `json:"is_correct"`
-		Explanation string   `json:"explanation"`
-		Suggestions []string `json:"suggestions"`
-		Correction  string   `json:"correction"`
+	var text strings.Builder
+	for _, part := range candidate.Content.Parts {
+		text.WriteString(part.Text)
 	}
-
-	if err := json.Unmarshal([]byte(content), &jsonResponse); err == nil {
-		return &types.ValidationResponse{
-			IsCorrect:   jsonResponse.IsCorrect,
-			Explanation: jsonResponse.Explanation,
-			Suggestions: jsonResponse.Suggestions,
-			Correction:  jsonResponse.Correction,
-		}, nil
-	}
-
-	// Fallback: treat the content as explanation
-	return &types.ValidationResponse{
-		IsCorrect:   false, // Conservative default
-		Explanation: strings.TrimSpace(content),
-	}, nil
+	return text.String(), nil
 }
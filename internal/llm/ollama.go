@@ -17,6 +17,7 @@ type OllamaRequest struct {
 	Model   string         `json:"model"`
 	Prompt  string         `json:"prompt"`
 	Stream  bool           `json:"stream"`
+	Format  *jsonSchema    `json:"format,omitempty"` // JSON schema the response must match
 	Options *OllamaOptions `json:"options,omitempty"`
 }
 
@@ -42,6 +43,11 @@ type OllamaResponse struct {
 	Error              string `json:"error,omitempty"`
 }
 
+// tokenUsage returns the usage as counted across providers
+func (r *OllamaResponse) tokenUsage() types.TokenUsage {
+	return types.TokenUsage{InputTokens: r.PromptEvalCount, OutputTokens: r.EvalCount}
+}
+
 // OllamaModelInfo represents information about an Ollama model
 type OllamaModelInfo struct {
 	Name       string `json:"name"`
@@ -57,28 +63,103 @@ type OllamaModelsResponse struct {
 
 // generateCommandInternal implements the actual Ollama API call for command generation
 func (p *OllamaProvider) generateCommandInternal(ctx context.Context, prompt string, context *types.Context) (*types.CommandResponse, error) {
-	// Build the system prompt
-	systemPrompt := p.promptBuilder.BuildSystemPrompt(context)
+	// Build the system prompt within the model's prompt token budget
+	systemPrompt, promptTokens := p.buildSystemPrompt(context, prompt, p.GetProviderInfo().Name, p.getModel())
 
-	// Create the request
-	request := OllamaRequest{
+	// Make the API call
+	request := p.commandRequest(systemPrompt, prompt, false)
+	response, err := p.makeAPICall(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	usage := response.tokenUsage()
+
+	// Parse the response, asking the model to correct it when it does not match the schema
+	result, err := decodeWithRepair(response.Response, decodeCommandResponse, p.reask(ctx, request, &usage))
+	if err != nil {
+		return nil, err
+	}
+	result.PromptTokens = promptTokens
+	result.Provider = p.GetProviderInfo().Name
+	result.Model = p.getModel()
+	result.Usage = usage
+	return result, nil
+}
+
+// generateCommandStreamInternal implements the streamed Ollama API call for
+// command generation; Ollama streams one JSON object per line
+func (p *OllamaProvider) generateCommandStreamInternal(ctx context.Context, prompt string, context *types.Context, onText func(string)) (*types.CommandResponse, error) {
+	// Build the system prompt within the model's prompt token budget
+	systemPrompt, promptTokens := p.buildSystemPrompt(context, prompt, p.GetProviderInfo().Name, p.getModel())
+
+	request := p.commandRequest(systemPrompt, prompt, true)
+	httpReq, err := p.newHTTPRequest(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	content, usage, err := p.streamResponse(httpReq, "Ollama", streamNDJSON, decodeOllamaStreamChunk, onText)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse the complete response, asking the model to correct it when it does not match the schema
+	result, err := decodeWithRepair(content, decodeCommandResponse, p.reask(ctx, request, &usage))
+	if err != nil {
+		return nil, err
+	}
+	result.PromptTokens = promptTokens
+	result.Provider = p.GetProviderInfo().Name
+	result.Model = p.getModel()
+	result.Usage = usage
+	return result, nil
+}
+
+// commandRequest creates the request for command generation
+func (p *OllamaProvider) commandRequest(systemPrompt, prompt string, stream bool) OllamaRequest {
+	return OllamaRequest{
 		Model:  p.getModel(),
 		Prompt: systemPrompt + "\n\nUser request: " + prompt,
-		Stream: false,
+		Stream: stream,
+		Format: commandResponseSchema,
 		Options: &OllamaOptions{
 			Temperature: 0.1, // Low temperature for more deterministic responses
 			NumPredict:  500, // Reasonable limit for shell commands
 		},
 	}
+}
 
-	// Make the API call
-	response, err := p.makeAPICall(ctx, request)
-	if err != nil {
-		return nil, err
+// reask returns a reaskFunc continuing request. The generate API takes a
+// single prompt, so the previous response and the correction are appended to it.
+func (p *OllamaProvider) reask(ctx context.Context, request OllamaRequest, usage *types.TokenUsage) reaskFunc {
+	return func(previous, repairPrompt string) (string, error) {
+		request.Stream = false
+		request.Prompt += "\n\nYour previous response:\n" + previous + "\n\n" + repairPrompt
+		response, err := p.makeAPICall(ctx, request)
+		if err != nil {
+			return "", err
+		}
+		usage.Add(response.tokenUsage())
+		return response.Response, nil
 	}
+}
 
-	// Parse the response
-	return p.parseCommandResponse(response)
+// decodeOllamaStreamChunk returns the text of one line of a streamed response
+func decodeOllamaStreamChunk(payload []byte, usage *types.TokenUsage) (string, error) {
+	var chunk OllamaResponse
+	if err := decodeStreamPayload(payload, &chunk); err != nil {
+		return "", err
+	}
+	if chunk.Error != "" {
+		return "", &types.NLShellError{
+			Type:    types.ErrTypeProvider,
+			Message: fmt.Sprintf("Ollama API error: %s", chunk.Error),
+		}
+	}
+	// The final line reports the token counts
+	if chunk.Done {
+		*usage = chunk.tokenUsage()
+	}
+	return chunk.Response, nil
 }
 
 // validateResultInternal implements the actual Ollama API call for result validation
@@ -91,6 +172,7 @@ func (p *OllamaProvider) validateResultInternal(ctx context.Context, command, ou
 		Model:  p.getModel(),
 		Prompt: p.promptBuilder.BuildValidationSystemPrompt() + "\n\n" + validationPrompt,
 		Stream: false,
+		Format: validationResponseSchema,
 		Options: &OllamaOptions{
 			Temperature: 0.1,
 			NumPredict:  300,
@@ -102,9 +184,15 @@ func (p *OllamaProvider) validateResultInternal(ctx context.Context, command, ou
 	if err != nil {
 		return nil, err
 	}
+	usage := response.tokenUsage()
 
-	// Parse the validation response
-	return p.parseValidationResponse(response)
+	// Parse the validation response, asking the model to correct it when it does not match the schema
+	result, err := decodeWithRepair(response.Response, decodeValidationResponse, p.reask(ctx, request, &usage))
+	if err != nil {
+		return nil, err
+	}
+	result.Usage = usage
+	return result, nil
 }
 
 // getModel returns the model to use for this provider
@@ -198,8 +286,8 @@ func (p *OllamaProvider) CheckModelAvailability(ctx context.Context) error {
 	}
 }
 
-// makeAPICall makes an HTTP request to the Ollama API
-func (p *OllamaProvider) makeAPICall(ctx context.Context, request OllamaRequest) (*OllamaResponse, error) {
+// newHTTPRequest creates the HTTP request for an Ollama API call
+func (p *OllamaProvider) newHTTPRequest(ctx context.Context, request OllamaRequest) (*http.Request, error) {
 	// Serialize the request
 	requestBody, err := json.Marshal(request)
 	if err != nil {
@@ -223,6 +311,15 @@ func (p *OllamaProvider) makeAPICall(ctx context.Context, request OllamaRequest)
 
 	// Set headers (Ollama doesn't require authentication by default)
 	httpReq.Header.Set("Content-Type", "application/json")
+	return httpReq, nil
+}
+
+// makeAPICall makes an HTTP request to the Ollama API
+func (p *OllamaProvider) makeAPICall(ctx context.Context, request OllamaRequest) (*OllamaResponse, error) {
+	httpReq, err := p.newHTTPRequest(ctx, request)
+	if err != nil {
+		return nil, err
+	}
 
 	// Make the request
 	httpResp, err := p.httpClient.Do(httpReq)
@@ -280,60 +377,3 @@ func (p *OllamaProvider) makeAPICall(ctx context.Context, request OllamaRequest)
 
 	return &response, nil
 }
-
-// parseCommandResponse parses the Ollama response for command generation
-func (p *OllamaProvider) parseCommandResponse(response *OllamaResponse) (*types.CommandResponse, error) {
-	content := response.Response
-
-	// Try to parse as JSON first
-	var jsonResponse struct {
-		Command      string   `json:"command"`
-		Explanation  string   `json:"explanation"`
-		Confidence   float64  `json:"confidence"`
-		Alternatives []string `json:"alternatives"`
-	}
-
-	if err := json.Unmarshal([]byte(content), &jsonResponse); err == nil {
-		return &types.CommandResponse{
-			Command:      jsonResponse.Command,
-			Explanation:  jsonResponse.Explanation,
-			Confidence:   jsonResponse.Confidence,
-			Alternatives: jsonResponse.Alternatives,
-		}, nil
-	}
-
-	// Fallback: treat the entire content as the command
-	return &types.CommandResponse{
-		Command:     strings.TrimSpace(content),
-		Explanation: "Generated by Ollama",
-		Confidence:  0.8, // Default confidence when we can't parse JSON
-	}, nil
-}
-
-// parseValidationResponse parses the Ollama response for result validation
-func (p *OllamaProvider) parseValidationResponse(response *OllamaResponse) (*types.ValidationResponse, error) {
-	content := response.Response
-
-	// Try to parse as JSON
-	var jsonResponse struct {
-		IsCorrect   bool     `json:"is_correct"`
-		Explanation string   `json:"explanation"`
-		Suggestions []string `json:"suggestions"`
-		Correction  string   `json:"correction"`
-	}
-
-	if err := json.Unmarshal([]byte(content), &jsonResponse); err == nil {
-		return &types.ValidationResponse{
-			IsCorrect:   jsonResponse.IsCorrect,
-			Explanation: jsonResponse.Explanation,
-			Suggestions: jsonResponse.Suggestions,
-			Correction:  jsonResponse.Correction,
-		}, nil
-	}
-
-	// Fallback: treat the content as explanation
-	return &types.ValidationResponse{
-		IsCorrect:   false, // Conservative default
-		Explanation: strings.TrimSpace(content),
-	}, nil
-}
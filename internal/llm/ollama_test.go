@@ -212,47 +212,40 @@ func TestOllamaProvider_NetworkError(t *testing.T) {
 	}
 }
 
-func TestOllamaProvider_FallbackParsing(t *testing.T) {
-	// Mock server that returns non-JSON response
+func TestOllamaProvider_RepairsResponse(t *testing.T) {
+	// Mock server that answers with plain text, then with JSON when asked to correct it
+	var requests []map[string]interface{}
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		response := OllamaResponse{
-			Model:     "llama3.2",
-			CreatedAt: "2024-01-01T12:00:00Z",
-			Response:  "ls -la", // Plain text, not JSON
-			Done:      true,
-		}
+		var request map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&request)
+		requests = append(requests, request)
 
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(response)
+		content := "ls -la" // Plain text, not JSON
+		if len(requests) > 1 {
+			content = `{"command": "ls -la", "explanation": "List files", "confidence": 0.9}`
+		}
+		json.NewEncoder(w).Encode(OllamaResponse{Model: "llama3.2", Response: content, Done: true})
 	}))
 	defer server.Close()
 
-	config := &types.ProviderConfig{
-		BaseURL:      server.URL,
-		DefaultModel: "llama3.2",
-		Timeout:      30 * time.Second,
-	}
-
-	retryConfig := DefaultRetryConfig()
-	provider := NewOllamaProvider(config, retryConfig)
-
-	ctx := context.Background()
-
-	response, err := provider.GenerateCommand(ctx, "list files", &types.Context{})
+	provider := NewOllamaProvider(&types.ProviderConfig{BaseURL: server.URL, DefaultModel: "llama3.2"}, DefaultRetryConfig())
+	response, err := provider.GenerateCommand(context.Background(), "list files", &types.Context{})
 	if err != nil {
 		t.Fatalf("GenerateCommand failed: %v", err)
 	}
 
-	if response.Command != "ls -la" {
-		t.Errorf("Expected 'ls -la', got '%s'", response.Command)
+	if response.Command != "ls -la" || response.Confidence != 0.9 {
+		t.Errorf("Expected the corrected response, got %+v", response)
 	}
-
-	if response.Confidence != 0.8 {
-		t.Errorf("Expected default confidence 0.8, got %f", response.Confidence)
+	if len(requests) != 2 {
+		t.Fatalf("Expected the model to be asked once to correct its response, got %d requests", len(requests))
 	}
-
-	if response.Explanation != "Generated by Ollama" {
-		t.Errorf("Expected default explanation, got '%s'", response.Explanation)
+	if format, ok := requests[0]["format"].(map[string]interface{}); !ok || format["type"] != "object" {
+		t.Errorf("Expected the response schema as the format, got %v", requests[0]["format"])
+	}
+	prompt, _ := requests[1]["prompt"].(string)
+	if !strings.Contains(prompt, "Your previous response:\nls -la") || !strings.Contains(prompt, "expected a JSON object") {
+		t.Errorf("Expected the previous response and the problem in the repair prompt, got:\n%s", prompt)
 	}
 }
 
@@ -7,18 +7,37 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"strings"
 
 	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
 )
 
 // OpenAIRequest represents the request structure for OpenAI API
 type OpenAIRequest struct {
-	Model       string          `json:"model"`
-	Messages    []OpenAIMessage `json:"messages"`
-	Temperature float64         `json:"temperature,omitempty"`
-	MaxTokens   int             `json:"max_tokens,omitempty"`
-	Stream      bool            `json:"stream"`
+	Model          string                `json:"model"`
+	Messages       []OpenAIMessage       `json:"messages"`
+	Temperature    float64               `json:"temperature,omitempty"`
+	MaxTokens      int                   `json:"max_tokens,omitempty"`
+	Stream         bool                  `json:"stream"`
+	StreamOptions  *OpenAIStreamOptions  `json:"stream_options,omitempty"`
+	ResponseFormat *OpenAIResponseFormat `json:"response_format,omitempty"`
+}
+
+// OpenAIStreamOptions controls what a streamed response includes
+type OpenAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"` // Send the token usage in a final event
+}
+
+// OpenAIResponseFormat constrains the format of a chat completions response
+type OpenAIResponseFormat struct {
+	Type       string            `json:"type"`
+	JSONSchema *OpenAIJSONSchema `json:"json_schema,omitempty"`
+}
+
+// OpenAIJSONSchema names the JSON schema a response must match
+type OpenAIJSONSchema struct {
+	Name   string      `json:"name"`
+	Strict bool        `json:"strict"`
+	Schema *jsonSchema `json:"schema"`
 }
 
 // OpenAIMessage represents a message in the OpenAI chat format
@@ -52,6 +71,25 @@ type OpenAIUsage struct {
 	TotalTokens      int `json:"total_tokens"`
 }
 
+// tokenUsage returns the usage as counted across providers
+func (u OpenAIUsage) tokenUsage() types.TokenUsage {
+	return types.TokenUsage{InputTokens: u.PromptTokens, OutputTokens: u.CompletionTokens}
+}
+
+// OpenAIStreamChunk represents one event of a streamed OpenAI response
+type OpenAIStreamChunk struct {
+	Choices []OpenAIStreamChoice `json:"choices"`
+	Usage   *OpenAIUsage         `json:"usage,omitempty"` // Set on the final event when usage was requested
+	Error   *OpenAIError         `json:"error,omitempty"`
+}
+
+// OpenAIStreamChoice represents the new content of a choice in a streamed response
+type OpenAIStreamChoice struct {
+	Index        int           `json:"index"`
+	Delta        OpenAIMessage `json:"delta"`
+	FinishReason string        `json:"finish_reason"`
+}
+
 // OpenAIError represents an error from the OpenAI API
 type OpenAIError struct {
 	Message string `json:"message"`
@@ -61,10 +99,62 @@ type OpenAIError struct {
 
 // generateCommandInternal implements the actual OpenAI API call for command generation
 func (p *OpenAIProvider) generateCommandInternal(ctx context.Context, prompt string, context *types.Context) (*types.CommandResponse, error) {
-	// Build the system prompt
-	systemPrompt := p.promptBuilder.BuildSystemPrompt(context)
+	// Build the system prompt within the model's prompt token budget
+	systemPrompt, promptTokens := p.buildSystemPrompt(context, prompt, p.GetProviderInfo().Name, p.getModel())
 
-	// Create the request
+	// Make the API call
+	request := p.commandRequest(systemPrompt, prompt, false)
+	response, err := p.makeAPICall(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	usage := response.Usage.tokenUsage()
+
+	// Parse the response, asking the model to correct it when it does not match the schema
+	content, err := p.responseContent(response)
+	if err != nil {
+		return nil, err
+	}
+	result, err := decodeWithRepair(content, decodeCommandResponse, p.reask(ctx, request, &usage))
+	if err != nil {
+		return nil, err
+	}
+	result.PromptTokens = promptTokens
+	result.Provider = p.GetProviderInfo().Name
+	result.Model = p.getModel()
+	result.Usage = usage
+	return result, nil
+}
+
+// generateCommandStreamInternal implements the streamed OpenAI API call for command generation
+func (p *OpenAIProvider) generateCommandStreamInternal(ctx context.Context, prompt string, context *types.Context, onText func(string)) (*types.CommandResponse, error) {
+	// Build the system prompt within the model's prompt token budget
+	systemPrompt, promptTokens := p.buildSystemPrompt(context, prompt, p.GetProviderInfo().Name, p.getModel())
+
+	request := p.commandRequest(systemPrompt, prompt, true)
+	httpReq, err := p.newHTTPRequest(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	content, usage, err := p.streamResponse(httpReq, "OpenAI", streamSSE, decodeOpenAIStreamChunk, onText)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse the complete response, asking the model to correct it when it does not match the schema
+	result, err := decodeWithRepair(content, decodeCommandResponse, p.reask(ctx, request, &usage))
+	if err != nil {
+		return nil, err
+	}
+	result.PromptTokens = promptTokens
+	result.Provider = p.GetProviderInfo().Name
+	result.Model = p.getModel()
+	result.Usage = usage
+	return result, nil
+}
+
+// commandRequest creates the request for command generation
+func (p *OpenAIProvider) commandRequest(systemPrompt, prompt string, stream bool) OpenAIRequest {
 	request := OpenAIRequest{
 		Model: p.getModel(),
 		Messages: []OpenAIMessage{
@@ -77,19 +167,79 @@ func (p *OpenAIProvider) generateCommandInternal(ctx context.Context, prompt str
 				Content: prompt,
 			},
 		},
-		Temperature: 0.1, // Low temperature for more deterministic responses
-		MaxTokens:   500, // Reasonable limit for shell commands
-		Stream:      false,
+		Temperature:    0.1, // Low temperature for more deterministic responses
+		MaxTokens:      500, // Reasonable limit for shell commands
+		Stream:         stream,
+		ResponseFormat: p.responseFormat("command_response", commandResponseSchema),
+	}
+	if stream {
+		request.StreamOptions = &OpenAIStreamOptions{IncludeUsage: true}
 	}
+	return request
+}
 
-	// Make the API call
-	response, err := p.makeAPICall(ctx, request)
-	if err != nil {
-		return nil, err
+// openAISchemaFormat returns the response format constraining responses to schema
+func openAISchemaFormat(name string, schema *jsonSchema) *OpenAIResponseFormat {
+	return &OpenAIResponseFormat{
+		Type:       "json_schema",
+		JSONSchema: &OpenAIJSONSchema{Name: name, Strict: true, Schema: schema},
+	}
+}
+
+// responseFormat constrains a response to schema, or to any JSON object, as
+// far as the model supports. Older models reject response_format altogether.
+func (p *OpenAIProvider) responseFormat(name string, schema *jsonSchema) *OpenAIResponseFormat {
+	switch openAIResponseFormat(p.config, p.getModel()) {
+	case jsonSchemaFormat:
+		return openAISchemaFormat(name, schema)
+	case jsonObjectFormat:
+		return &OpenAIResponseFormat{Type: "json_object"}
+	default:
+		return nil
 	}
+}
 
-	// Parse the response
-	return p.parseCommandResponse(response)
+// reask returns a reaskFunc continuing the conversation of request
+func (p *OpenAIProvider) reask(ctx context.Context, request OpenAIRequest, usage *types.TokenUsage) reaskFunc {
+	return func(previous, repairPrompt string) (string, error) {
+		request.Stream = false
+		request.StreamOptions = nil
+		request.Messages = append(request.Messages[:len(request.Messages):len(request.Messages)],
+			OpenAIMessage{Role: "assistant", Content: previous},
+			OpenAIMessage{Role: "user", Content: repairPrompt},
+		)
+		response, err := p.makeAPICall(ctx, request)
+		if err != nil {
+			return "", err
+		}
+		usage.Add(response.Usage.tokenUsage())
+		return p.responseContent(response)
+	}
+}
+
+// decodeOpenAIStreamChunk returns the text of one event of a streamed response
+func decodeOpenAIStreamChunk(payload []byte, usage *types.TokenUsage) (string, error) {
+	var chunk OpenAIStreamChunk
+	if err := decodeStreamPayload(payload, &chunk); err != nil {
+		return "", err
+	}
+	if chunk.Error != nil {
+		return "", &types.NLShellError{
+			Type:    types.ErrTypeProvider,
+			Message: fmt.Sprintf("OpenAI API error: %s", chunk.Error.Message),
+			Context: map[string]interface{}{
+				"error_type": chunk.Error.Type,
+				"error_code": chunk.Error.Code,
+			},
+		}
+	}
+	if chunk.Usage != nil {
+		*usage = chunk.Usage.tokenUsage()
+	}
+	if len(chunk.Choices) == 0 {
+		return "", nil
+	}
+	return chunk.Choices[0].Delta.Content, nil
 }
 
 // validateResultInternal implements the actual OpenAI API call for result validation
@@ -110,9 +260,10 @@ func (p *OpenAIProvider) validateResultInternal(ctx context.Context, command, ou
 				Content: validationPrompt,
 			},
 		},
-		Temperature: 0.1,
-		MaxTokens:   300,
-		Stream:      false,
+		Temperature:    0.1,
+		MaxTokens:      300,
+		Stream:         false,
+		ResponseFormat: p.responseFormat("validation_response", validationResponseSchema),
 	}
 
 	// Make the API call
@@ -120,9 +271,19 @@ func (p *OpenAIProvider) validateResultInternal(ctx context.Context, command, ou
 	if err != nil {
 		return nil, err
 	}
+	usage := response.Usage.tokenUsage()
 
-	// Parse the validation response
-	return p.parseValidationResponse(response)
+	// Parse the validation response, asking the model to correct it when it does not match the schema
+	content, err := p.responseContent(response)
+	if err != nil {
+		return nil, err
+	}
+	result, err := decodeWithRepair(content, decodeValidationResponse, p.reask(ctx, request, &usage))
+	if err != nil {
+		return nil, err
+	}
+	result.Usage = usage
+	return result, nil
 }
 
 // getModel returns the model to use for this provider
@@ -141,8 +302,8 @@ func (p *OpenAIProvider) getBaseURL() string {
 	return "https://api.openai.com/v1" // Default OpenAI API URL
 }
 
-// makeAPICall makes an HTTP request to the OpenAI API
-func (p *OpenAIProvider) makeAPICall(ctx context.Context, request OpenAIRequest) (*OpenAIResponse, error) {
+// newHTTPRequest creates the HTTP request for an OpenAI API call
+func (p *OpenAIProvider) newHTTPRequest(ctx context.Context, request OpenAIRequest) (*http.Request, error) {
 	// Serialize the request
 	requestBody, err := json.Marshal(request)
 	if err != nil {
@@ -169,6 +330,15 @@ func (p *OpenAIProvider) makeAPICall(ctx context.Context, request OpenAIRequest)
 	if p.config != nil && p.config.APIKey != "" {
 		httpReq.Header.Set("Authorization", "Bearer "+p.config.APIKey)
 	}
+	return httpReq, nil
+}
+
+// makeAPICall makes an HTTP request to the OpenAI API
+func (p *OpenAIProvider) makeAPICall(ctx context.Context, request OpenAIRequest) (*OpenAIResponse, error) {
+	httpReq, err := p.newHTTPRequest(ctx, request)
+	if err != nil {
+		return nil, err
+	}
 
 	// Make the request
 	httpResp, err := p.httpClient.Do(httpReq)
@@ -231,73 +401,13 @@ func (p *OpenAIProvider) makeAPICall(ctx context.Context, request OpenAIRequest)
 	return &response, nil
 }
 
-// parseCommandResponse parses the OpenAI response for command generation
-func (p *OpenAIProvider) parseCommandResponse(response *OpenAIResponse) (*types.CommandResponse, error) {
+// responseContent returns the text of the first choice of a response
+func (p *OpenAIProvider) responseContent(response *OpenAIResponse) (string, error) {
 	if len(response.Choices) == 0 {
-		return nil, &types.NLShellError{
+		return "", &types.NLShellError{
 			Type:    types.ErrTypeProvider,
 			Message: "no choices in OpenAI response",
 		}
 	}
-
-	content := response.Choices[0].Message.Content
-
-	// Try to parse as JSON first
-	var jsonResponse struct {
-		Command      string   `json:"command"`
-		Explanation  string   `json:"explanation"`
-		Confidence   float64  `json:"confidence"`
-		Alternatives []string `json:"alternatives"`
-	}
-
-	if err := json.Unmarshal([]byte(content), &jsonResponse); err == nil {
-		return &types.CommandResponse{
-			Command:      jsonResponse.Command,
-			Explanation:  jsonResponse.Explanation,
-			Confidence:   jsonResponse.Confidence,
-			Alternatives: jsonResponse.Alternatives,
-		}, nil
-	}
-
-	// Fallback: treat the entire content as the command
-	return &types.CommandResponse{
-		Command:     strings.TrimSpace(content),
-		Explanation: "Generated by OpenAI",
-		Confidence:  0.8, // Default confidence when we can't parse JSON
-	}, nil
-}
-
-// parseValidationResponse parses the OpenAI response for result validation
-func (p *OpenAIProvider) parseValidationResponse(response *OpenAIResponse) (*types.ValidationResponse, error) {
-	if len(response.Choices) == 0 {
-		return nil, &types.NLShellError{
-			Type:    types.ErrTypeProvider,
-			Message: "no choices in OpenAI response",
-		}
-	}
-
-	content := response.Choices[0].Message.Content
-
-	// Try to parse as JSON
-	var jsonResponse struct {
-		IsCorrect   bool     `json:"is_correct"`
-		Explanation string   `json:"explanation"`
-		Suggestions []string `json:"suggestions"`
-		Correction  string   `json:"correction"`
-	}
-
-	if err := json.Unmarshal([]byte(content), &jsonResponse); err == nil {
-		return &types.ValidationResponse{
-			IsCorrect:   jsonResponse.IsCorrect,
-			Explanation: jsonResponse.Explanation,
-			Suggestions: jsonResponse.Suggestions,
-			Correction:  jsonResponse.Correction,
-		}, nil
-	}
-
-	// Fallback: treat the content as explanation
-	return &types.ValidationResponse{
-		IsCorrect:   false, // Conservative default
-		Explanation: strings.TrimSpace(content),
-	}, nil
+	return response.Choices[0].Message.Content, nil
 }
@@ -0,0 +1,283 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+// generateCommandInternal implements the actual API call for command generation
+func (p *OpenAICompatibleProvider) generateCommandInternal(ctx context.Context, prompt string, context *types.Context) (*types.CommandResponse, error) {
+	// Build the system prompt within the model's prompt token budget
+	systemPrompt, promptTokens := p.buildSystemPrompt(context, prompt, p.name, p.getModel())
+
+	// Make the API call
+	request := p.commandRequest(systemPrompt, prompt, false)
+	response, err := p.makeAPICall(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	usage := response.Usage.tokenUsage()
+
+	// Parse the response, asking the model to correct it when it does not match the schema
+	content, err := p.responseContent(response)
+	if err != nil {
+		return nil, err
+	}
+	result, err := decodeWithRepair(content, decodeCommandResponse, p.reask(ctx, request, &usage))
+	if err != nil {
+		return nil, err
+	}
+	result.PromptTokens = promptTokens
+	result.Provider = p.name
+	result.Model = p.getModel()
+	result.Usage = usage
+	return result, nil
+}
+
+// generateCommandStreamInternal implements the streamed API call for command generation
+func (p *OpenAICompatibleProvider) generateCommandStreamInternal(ctx context.Context, prompt string, context *types.Context, onText func(string)) (*types.CommandResponse, error) {
+	// Build the system prompt within the model's prompt token budget
+	systemPrompt, promptTokens := p.buildSystemPrompt(context, prompt, p.name, p.getModel())
+
+	request := p.commandRequest(systemPrompt, prompt, true)
+	httpReq, err := p.newHTTPRequest(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	content, usage, err := p.streamResponse(httpReq, p.name, streamSSE, decodeOpenAIStreamChunk, onText)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse the complete response, asking the model to correct it when it does not match the schema
+	result, err := decodeWithRepair(content, decodeCommandResponse, p.reask(ctx, request, &usage))
+	if err != nil {
+		return nil, err
+	}
+	result.PromptTokens = promptTokens
+	result.Provider = p.name
+	result.Model = p.getModel()
+	result.Usage = usage
+	return result, nil
+}
+
+// commandRequest creates the request for command generation
+func (p *OpenAICompatibleProvider) commandRequest(systemPrompt, prompt string, stream bool) OpenAIRequest {
+	return p.withResponseFormat(OpenAIRequest{
+		Model: p.getModel(),
+		Messages: []OpenAIMessage{
+			{
+				Role:    "system",
+				Content: systemPrompt,
+			},
+			{
+				Role:    "user",
+				Content: prompt,
+			},
+		},
+		Temperature: 0.1, // Low temperature for more deterministic responses
+		MaxTokens:   500, // Reasonable limit for shell commands
+		Stream:      stream,
+	}, "command_response", commandResponseSchema)
+}
+
+// reask returns a reaskFunc continuing the conversation of request
+func (p *OpenAICompatibleProvider) reask(ctx context.Context, request OpenAIRequest, usage *types.TokenUsage) reaskFunc {
+	return func(previous, repairPrompt string) (string, error) {
+		request.Stream = false
+		request.Messages = append(request.Messages[:len(request.Messages):len(request.Messages)],
+			OpenAIMessage{Role: "assistant", Content: previous},
+			OpenAIMessage{Role: "user", Content: repairPrompt},
+		)
+		response, err := p.makeAPICall(ctx, request)
+		if err != nil {
+			return "", err
+		}
+		usage.Add(response.Usage.tokenUsage())
+		return p.responseContent(response)
+	}
+}
+
+// validateResultInternal implements the actual API call for result validation
+func (p *OpenAICompatibleProvider) validateResultInternal(ctx context.Context, command, output, intent string) (*types.ValidationResponse, error) {
+	// Build the validation prompt
+	validationPrompt := p.promptBuilder.BuildValidationPrompt(command, output, intent)
+
+	// Create the request
+	request := p.withResponseFormat(OpenAIRequest{
+		Model: p.getModel(),
+		Messages: []OpenAIMessage{
+			{
+				Role:    "system",
+				Content: p.promptBuilder.BuildValidationSystemPrompt(),
+			},
+			{
+				Role:    "user",
+				Content: validationPrompt,
+			},
+		},
+		Temperature: 0.1,
+		MaxTokens:   300,
+		Stream:      false,
+	}, "validation_response", validationResponseSchema)
+
+	// Make the API call
+	response, err := p.makeAPICall(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	usage := response.Usage.tokenUsage()
+
+	// Parse the validation response, asking the model to correct it when it does not match the schema
+	content, err := p.responseContent(response)
+	if err != nil {
+		return nil, err
+	}
+	result, err := decodeWithRepair(content, decodeValidationResponse, p.reask(ctx, request, &usage))
+	if err != nil {
+		return nil, err
+	}
+	result.Usage = usage
+	return result, nil
+}
+
+// withResponseFormat constrains the response to request to schema, or to any
+// JSON object, as far as the server supports
+func (p *OpenAICompatibleProvider) withResponseFormat(request OpenAIRequest, name string, schema *jsonSchema) OpenAIRequest {
+	switch {
+	case p.config == nil:
+	case p.config.Capabilities.JSONSchema:
+		request.ResponseFormat = openAISchemaFormat(name, schema)
+	case p.config.Capabilities.JSONMode:
+		request.ResponseFormat = &OpenAIResponseFormat{Type: "json_object"}
+	}
+	return request
+}
+
+// getBaseURL returns the base URL of the server's API
+func (p *OpenAICompatibleProvider) getBaseURL() string {
+	if p.config == nil {
+		return ""
+	}
+	return strings.TrimSuffix(p.config.BaseURL, "/")
+}
+
+// newHTTPRequest creates the HTTP request for an API call
+func (p *OpenAICompatibleProvider) newHTTPRequest(ctx context.Context, request OpenAIRequest) (*http.Request, error) {
+	// Serialize the request
+	requestBody, err := json.Marshal(request)
+	if err != nil {
+		return nil, &types.NLShellError{
+			Type:    types.ErrTypeProvider,
+			Message: "failed to serialize request",
+			Cause:   err,
+		}
+	}
+
+	// Create HTTP request
+	url := p.getBaseURL() + "/chat/completions"
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(requestBody))
+	if err != nil {
+		return nil, &types.NLShellError{
+			Type:    types.ErrTypeProvider,
+			Message: "failed to create HTTP request",
+			Cause:   err,
+		}
+	}
+
+	// Set headers; servers without authentication need no API key
+	httpReq.Header.Set("Content-Type", "application/json")
+	if p.config.APIKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+p.config.APIKey)
+	}
+	for name, value := range p.config.Headers {
+		httpReq.Header.Set(name, value)
+	}
+	return httpReq, nil
+}
+
+// makeAPICall makes an HTTP request to the server's API
+func (p *OpenAICompatibleProvider) makeAPICall(ctx context.Context, request OpenAIRequest) (*OpenAIResponse, error) {
+	httpReq, err := p.newHTTPRequest(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	// Make the request
+	httpResp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, &types.NLShellError{
+			Type:    types.ErrTypeNetwork,
+			Message: "failed to make HTTP request",
+			Cause:   err,
+		}
+	}
+	defer httpResp.Body.Close()
+
+	// Read response body
+	responseBody, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, &types.NLShellError{
+			Type:    types.ErrTypeProvider,
+			Message: "failed to read response body",
+			Cause:   err,
+		}
+	}
+
+	// Check for HTTP errors
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, &types.NLShellError{
+			Type:    types.ErrTypeProvider,
+			Message: fmt.Sprintf("%s API returned status %d", p.name, httpResp.StatusCode),
+			Context: map[string]interface{}{
+				"http_status":   httpResp.StatusCode,
+				"response_body": string(responseBody),
+			},
+		}
+	}
+
+	// Parse response
+	var response OpenAIResponse
+	if err := json.Unmarshal(responseBody, &response); err != nil {
+		return nil, &types.NLShellError{
+			Type:    types.ErrTypeProvider,
+			Message: "failed to parse response",
+			Cause:   err,
+			Context: map[string]interface{}{
+				"response_body": string(responseBody),
+			},
+		}
+	}
+
+	// Check for API errors
+	if response.Error != nil {
+		return nil, &types.NLShellError{
+			Type:    types.ErrTypeProvider,
+			Message: fmt.Sprintf("%s API error: %s", p.name, response.Error.Message),
+			Context: map[string]interface{}{
+				"error_type": response.Error.Type,
+				"error_code": response.Error.Code,
+			},
+		}
+	}
+
+	return &response, nil
+}
+
+// responseContent returns the text of the first choice of a response
+func (p *OpenAICompatibleProvider) responseContent(response *OpenAIResponse) (string, error) {
+	if len(response.Choices) == 0 {
+		return "", &types.NLShellError{
+			Type:    types.ErrTypeProvider,
+			Message: fmt.Sprintf("no choices in %s response", p.name),
+		}
+	}
+	return response.Choices[0].Message.Content, nil
+}
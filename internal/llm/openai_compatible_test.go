@@ -0,0 +1,138 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+func TestOpenAICompatibleProvider_GenerateCommand(t *testing.T) {
+	tests := []struct {
+		name         string
+		config       types.ProviderConfig
+		expectAuth   string
+		expectFormat bool
+	}{
+		{
+			name:   "no authentication",
+			config: types.ProviderConfig{DefaultModel: "local-model"},
+		},
+		{
+			name: "api key, headers and json mode",
+			config: types.ProviderConfig{
+				APIKey:       "secret",
+				DefaultModel: "local-model",
+				Headers:      map[string]string{"X-Team": "infra"},
+				Capabilities: types.ProviderCapabilities{JSONMode: true},
+			},
+			expectAuth:   "Bearer secret",
+			expectFormat: true,
+		},
+		{
+			name: "json schema",
+			config: types.ProviderConfig{
+				DefaultModel: "local-model",
+				Capabilities: types.ProviderCapabilities{JSONSchema: true},
+			},
+			expectFormat: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/v1/chat/completions" {
+					t.Errorf("Expected request to /v1/chat/completions, got %s", r.URL.Path)
+				}
+				if auth := r.Header.Get("Authorization"); auth != tt.expectAuth {
+					t.Errorf("Expected Authorization %q, got %q", tt.expectAuth, auth)
+				}
+				for name, value := range tt.config.Headers {
+					if r.Header.Get(name) != value {
+						t.Errorf("Expected header %s: %s, got %q", name, value, r.Header.Get(name))
+					}
+				}
+
+				var request map[string]interface{}
+				if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+					t.Fatalf("Failed to decode request: %v", err)
+				}
+				if request["model"] != "local-model" {
+					t.Errorf("Expected model local-model, got %v", request["model"])
+				}
+				if _, ok := request["response_format"]; ok != tt.expectFormat {
+					t.Errorf("Expected response_format sent: %v, got %v", tt.expectFormat, request["response_format"])
+				}
+
+				json.NewEncoder(w).Encode(OpenAIResponse{
+					Choices: []OpenAIChoice{{Message: OpenAIMessage{
+						Role:    "assistant",
+						Content: "```json\n{\"command\": \"df -h\", \"explanation\": \"Show disk usage\", \"confidence\": 0.9}\n```",
+					}}},
+				})
+			}))
+			defer server.Close()
+
+			config := tt.config
+			config.BaseURL = server.URL + "/v1/"
+			config.Timeout = 5 * time.Second
+			provider := NewOpenAICompatibleProvider("lmstudio", &config, DefaultRetryConfig())
+
+			response, err := provider.GenerateCommand(context.Background(), "show disk usage", &types.Context{})
+			if err != nil {
+				t.Fatalf("GenerateCommand failed: %v", err)
+			}
+			if response.Command != "df -h" || response.Explanation != "Show disk usage" {
+				t.Errorf("Unexpected response: %+v", response)
+			}
+			if response.Provider != "lmstudio" || response.Model != "local-model" {
+				t.Errorf("Expected the response from lmstudio/local-model, got %s/%s", response.Provider, response.Model)
+			}
+		})
+	}
+}
+
+func TestOpenAICompatibleProvider_RequiresBaseURL(t *testing.T) {
+	provider := NewOpenAICompatibleProvider("vllm", &types.ProviderConfig{}, DefaultRetryConfig())
+
+	_, err := provider.GenerateCommand(context.Background(), "list files", &types.Context{})
+	nlErr, ok := err.(*types.NLShellError)
+	if !ok || nlErr.Type != types.ErrTypeConfiguration {
+		t.Errorf("Expected a configuration error, got %v", err)
+	}
+}
+
+func TestOpenAICompatibleProvider_NoStreaming(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&request)
+		if request["stream"] == true {
+			t.Error("Expected a single response from a server without streaming")
+		}
+		json.NewEncoder(w).Encode(OpenAIResponse{
+			Choices: []OpenAIChoice{{Message: OpenAIMessage{Role: "assistant", Content: `{"command": "ls"}`}}},
+		})
+	}))
+	defer server.Close()
+
+	provider := NewOpenAICompatibleProvider("llamacpp", &types.ProviderConfig{
+		BaseURL:      server.URL,
+		Capabilities: types.ProviderCapabilities{NoStreaming: true},
+	}, DefaultRetryConfig())
+
+	streamed := false
+	response, err := provider.(*OpenAICompatibleProvider).GenerateCommandStream(context.Background(), "list files", &types.Context{}, func(string) {
+		streamed = true
+	})
+	if err != nil {
+		t.Fatalf("GenerateCommandStream failed: %v", err)
+	}
+	if streamed || response.Command != "ls" {
+		t.Errorf("Expected a single response with command ls, got %+v (streamed: %v)", response, streamed)
+	}
+}
@@ -314,58 +314,75 @@ func TestOpenAIProvider_RateLimitRetry(t *testing.T) {
 	}
 }
 
-func TestOpenAIProvider_FallbackParsing(t *testing.T) {
-	// Mock server that returns non-JSON response
+func TestOpenAIProvider_RepairsResponse(t *testing.T) {
+	// Mock server that answers with plain text, then with JSON when asked to correct it
+	var requests []OpenAIRequest
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		response := OpenAIResponse{
-			ID:      "test-id",
-			Object:  "chat.completion",
-			Created: time.Now().Unix(),
-			Model:   "gpt-3.5-turbo",
-			Choices: []OpenAIChoice{
-				{
-					Index: 0,
-					Message: OpenAIMessage{
-						Role:    "assistant",
-						Content: "ls -la", // Plain text, not JSON
-					},
-					FinishReason: "stop",
-				},
-			},
-		}
+		var request OpenAIRequest
+		json.NewDecoder(r.Body).Decode(&request)
+		requests = append(requests, request)
 
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(response)
+		content := "ls -la" // Plain text, not JSON
+		if len(requests) > 1 {
+			content = `{"command": "ls -la", "explanation": "List files", "confidence": 0.9, "alternatives": [], "interactive": false, "steps": []}`
+		}
+		json.NewEncoder(w).Encode(OpenAIResponse{
+			Choices: []OpenAIChoice{{Message: OpenAIMessage{Role: "assistant", Content: content}}},
+			Usage:   OpenAIUsage{PromptTokens: 100 * len(requests), CompletionTokens: 10 * len(requests)},
+		})
 	}))
 	defer server.Close()
 
-	config := &types.ProviderConfig{
-		APIKey:       "test-api-key",
-		BaseURL:      server.URL,
-		DefaultModel: "gpt-3.5-turbo",
-		Timeout:      30 * time.Second,
+	provider := NewOpenAIProvider(&types.ProviderConfig{APIKey: "test-api-key", BaseURL: server.URL, DefaultModel: "gpt-4o-mini"}, DefaultRetryConfig())
+	response, err := provider.GenerateCommand(context.Background(), "list files", &types.Context{})
+	if err != nil {
+		t.Fatalf("GenerateCommand failed: %v", err)
 	}
 
-	retryConfig := DefaultRetryConfig()
-	provider := NewOpenAIProvider(config, retryConfig)
+	if response.Command != "ls -la" || response.Confidence != 0.9 {
+		t.Errorf("Expected the corrected response, got %+v", response)
+	}
+	if len(requests) != 2 {
+		t.Fatalf("Expected the model to be asked once to correct its response, got %d requests", len(requests))
+	}
+	if response.Usage != (types.TokenUsage{InputTokens: 300, OutputTokens: 30}) {
+		t.Errorf("Expected the usage of both requests, got %+v", response.Usage)
+	}
+	format := requests[0].ResponseFormat
+	if format == nil || format.Type != "json_schema" || format.JSONSchema.Name != "command_response" || !format.JSONSchema.Strict {
+		t.Errorf("Expected the command response schema in the request, got %+v", format)
+	}
+	messages := requests[1].Messages
+	if len(messages) != 4 || messages[2].Role != "assistant" || messages[2].Content != "ls -la" || !strings.Contains(messages[3].Content, "expected a JSON object") {
+		t.Errorf("Expected the previous response and the problem in the repair request, got %+v", messages)
+	}
+}
 
-	ctx := context.Background()
+func TestOpenAIProvider_GeneratePlan(t *testing.T) {
+	var systemPrompt string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request OpenAIRequest
+		json.NewDecoder(r.Body).Decode(&request)
+		systemPrompt = request.Messages[0].Content
+
+		content := `{"steps":[{"command":"python3 -m venv .venv","explanation":"Create the environment"},{"command":" ","explanation":"empty"},{"command":".venv/bin/pytest","explanation":"Run the tests"}],"explanation":"Set up and test","confidence":0.8}`
+		json.NewEncoder(w).Encode(OpenAIResponse{
+			Choices: []OpenAIChoice{{Message: OpenAIMessage{Role: "assistant", Content: content}}},
+		})
+	}))
+	defer server.Close()
 
-	response, err := provider.GenerateCommand(ctx, "list files", &types.Context{})
+	provider := NewOpenAIProvider(&types.ProviderConfig{APIKey: "test-api-key", BaseURL: server.URL}, DefaultRetryConfig())
+	response, err := provider.GenerateCommand(context.Background(), "create a venv and run the tests", &types.Context{PlanMode: true})
 	if err != nil {
 		t.Fatalf("GenerateCommand failed: %v", err)
 	}
 
-	if response.Command != "ls -la" {
-		t.Errorf("Expected 'ls -la', got '%s'", response.Command)
-	}
-
-	if response.Confidence != 0.8 {
-		t.Errorf("Expected default confidence 0.8, got %f", response.Confidence)
+	if !strings.Contains(systemPrompt, "'steps'") {
+		t.Errorf("expected the system prompt to ask for steps, got:\n%s", systemPrompt)
 	}
-
-	if response.Explanation != "Generated by OpenAI" {
-		t.Errorf("Expected default explanation, got '%s'", response.Explanation)
+	if len(response.Steps) != 2 || response.Steps[1].Command != ".venv/bin/pytest" || response.Steps[0].Explanation != "Create the environment" {
+		t.Errorf("unexpected steps: %+v", response.Steps)
 	}
 }
 
@@ -419,6 +436,43 @@ func TestOpenAIProvider_BuildSystemPrompt(t *testing.T) {
 	}
 }
 
+func TestOpenAIProvider_ResponseFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   *types.ProviderConfig
+		expected string
+	}{
+		{"default model", &types.ProviderConfig{}, "json_object"},
+		{"structured outputs", &types.ProviderConfig{DefaultModel: "gpt-4o-mini"}, "json_schema"},
+		{"JSON mode", &types.ProviderConfig{DefaultModel: "gpt-4-turbo"}, "json_object"},
+		{"early gpt-4o snapshot", &types.ProviderConfig{DefaultModel: "gpt-4o-2024-05-13"}, "json_object"},
+		{"legacy model", &types.ProviderConfig{DefaultModel: "gpt-4"}, ""},
+		{"configured capability", &types.ProviderConfig{DefaultModel: "gpt-4", Capabilities: types.ProviderCapabilities{JSONSchema: true}}, "json_schema"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := NewOpenAIProvider(tt.config, DefaultRetryConfig()).(*OpenAIProvider)
+			for _, request := range []OpenAIRequest{provider.commandRequest("system", "list files", false), provider.commandRequest("system", "list files", true)} {
+				formatType := ""
+				if request.ResponseFormat != nil {
+					formatType = request.ResponseFormat.Type
+				}
+				if formatType != tt.expected {
+					t.Errorf("Expected response format %q for %s, got %+v", tt.expected, request.Model, request.ResponseFormat)
+				}
+			}
+		})
+	}
+
+	// Legacy models reject the response_format parameter, so it must be left out entirely
+	provider := NewOpenAIProvider(&types.ProviderConfig{DefaultModel: "gpt-4"}, DefaultRetryConfig()).(*OpenAIProvider)
+	body, _ := json.Marshal(provider.commandRequest("system", "list files", false))
+	if strings.Contains(string(body), "response_format") {
+		t.Errorf("Expected no response_format for gpt-4, got %s", body)
+	}
+}
+
 func TestOpenAIProvider_GetModel(t *testing.T) {
 	// Test with custom model
 	config := &types.ProviderConfig{
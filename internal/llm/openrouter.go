@@ -7,18 +7,37 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"strings"
 
 	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
 )
 
 // OpenRouterRequest represents the request structure for OpenRouter API
 type OpenRouterRequest struct {
-	Model       string              `json:"model"`
-	Messages    []OpenRouterMessage `json:"messages"`
-	Temperature float64             `json:"temperature,omitempty"`
-	MaxTokens   int                 `json:"max_tokens,omitempty"`
-	Stream      bool                `json:"stream"`
+	Model          string                    `json:"model"`
+	Messages       []OpenRouterMessage       `json:"messages"`
+	Temperature    float64                   `json:"temperature,omitempty"`
+	MaxTokens      int                       `json:"max_tokens,omitempty"`
+	Stream         bool                      `json:"stream"`
+	StreamOptions  *OpenRouterStreamOptions  `json:"stream_options,omitempty"`
+	ResponseFormat *OpenRouterResponseFormat `json:"response_format,omitempty"`
+}
+
+// OpenRouterStreamOptions controls what a streamed response includes
+type OpenRouterStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"` // Send the token usage in a final event
+}
+
+// OpenRouterResponseFormat constrains the format of a chat completions response
+type OpenRouterResponseFormat struct {
+	Type       string                `json:"type"`
+	JSONSchema *OpenRouterJSONSchema `json:"json_schema,omitempty"`
+}
+
+// OpenRouterJSONSchema names the JSON schema a response must match
+type OpenRouterJSONSchema struct {
+	Name   string      `json:"name"`
+	Strict bool        `json:"strict"`
+	Schema *jsonSchema `json:"schema"`
 }
 
 // OpenRouterMessage represents a message in the OpenRouter chat format
@@ -52,6 +71,25 @@ type OpenRouterUsage struct {
 	TotalTokens      int `json:"total_tokens"`
 }
 
+// tokenUsage returns the usage as counted across providers
+func (u OpenRouterUsage) tokenUsage() types.TokenUsage {
+	return types.TokenUsage{InputTokens: u.PromptTokens, OutputTokens: u.CompletionTokens}
+}
+
+// OpenRouterStreamChunk represents one event of a streamed OpenRouter response
+type OpenRouterStreamChunk struct {
+	Choices []OpenRouterStreamChoice `json:"choices"`
+	Usage   *OpenRouterUsage         `json:"usage,omitempty"` // Set on the final event when usage was requested
+	Error   *OpenRouterError         `json:"error,omitempty"`
+}
+
+// OpenRouterStreamChoice represents the new content of a choice in a streamed response
+type OpenRouterStreamChoice struct {
+	Index        int               `json:"index"`
+	Delta        OpenRouterMessage `json:"delta"`
+	FinishReason string            `json:"finish_reason"`
+}
+
 // OpenRouterError represents an error from the OpenRouter API
 type OpenRouterError struct {
 	Message string `json:"message"`
@@ -61,10 +99,62 @@ type OpenRouterError struct {
 
 // generateCommandInternal implements the actual OpenRouter API call for command generation
 func (p *OpenRouterProvider) generateCommandInternal(ctx context.Context, prompt string, context *types.Context) (*types.CommandResponse, error) {
-	// Build the system prompt
-	systemPrompt := p.promptBuilder.BuildSystemPrompt(context)
+	// Build the system prompt within the model's prompt token budget
+	systemPrompt, promptTokens := p.buildSystemPrompt(context, prompt, p.GetProviderInfo().Name, p.getModel())
 
-	// Create the request
+	// Make the API call
+	request := p.commandRequest(systemPrompt, prompt, false)
+	response, err := p.makeAPICall(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	usage := response.Usage.tokenUsage()
+
+	// Parse the response, asking the model to correct it when it does not match the schema
+	content, err := p.responseContent(response)
+	if err != nil {
+		return nil, err
+	}
+	result, err := decodeWithRepair(content, decodeCommandResponse, p.reask(ctx, request, &usage))
+	if err != nil {
+		return nil, err
+	}
+	result.PromptTokens = promptTokens
+	result.Provider = p.GetProviderInfo().Name
+	result.Model = p.getModel()
+	result.Usage = usage
+	return result, nil
+}
+
+// generateCommandStreamInternal implements the streamed OpenRouter API call for command generation
+func (p *OpenRouterProvider) generateCommandStreamInternal(ctx context.Context, prompt string, context *types.Context, onText func(string)) (*types.CommandResponse, error) {
+	// Build the system prompt within the model's prompt token budget
+	systemPrompt, promptTokens := p.buildSystemPrompt(context, prompt, p.GetProviderInfo().Name, p.getModel())
+
+	request := p.commandRequest(systemPrompt, prompt, true)
+	httpReq, err := p.newHTTPRequest(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	content, usage, err := p.streamResponse(httpReq, "OpenRouter", streamSSE, decodeOpenRouterStreamChunk, onText)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse the complete response, asking the model to correct it when it does not match the schema
+	result, err := decodeWithRepair(content, decodeCommandResponse, p.reask(ctx, request, &usage))
+	if err != nil {
+		return nil, err
+	}
+	result.PromptTokens = promptTokens
+	result.Provider = p.GetProviderInfo().Name
+	result.Model = p.getModel()
+	result.Usage = usage
+	return result, nil
+}
+
+// commandRequest creates the request for command generation
+func (p *OpenRouterProvider) commandRequest(systemPrompt, prompt string, stream bool) OpenRouterRequest {
 	request := OpenRouterRequest{
 		Model: p.getModel(),
 		Messages: []OpenRouterMessage{
@@ -77,19 +167,79 @@ func (p *OpenRouterProvider) generateCommandInternal(ctx context.Context, prompt
 				Content: prompt,
 			},
 		},
-		Temperature: 0.1, // Low temperature for more deterministic responses
-		MaxTokens:   500, // Reasonable limit for shell commands
-		Stream:      false,
+		Temperature:    0.1, // Low temperature for more deterministic responses
+		MaxTokens:      500, // Reasonable limit for shell commands
+		Stream:         stream,
+		ResponseFormat: p.responseFormat("command_response", commandResponseSchema),
+	}
+	if stream {
+		request.StreamOptions = &OpenRouterStreamOptions{IncludeUsage: true}
 	}
+	return request
+}
 
-	// Make the API call
-	response, err := p.makeAPICall(ctx, request)
-	if err != nil {
-		return nil, err
+// openRouterSchemaFormat returns the response format constraining responses to schema
+func openRouterSchemaFormat(name string, schema *jsonSchema) *OpenRouterResponseFormat {
+	return &OpenRouterResponseFormat{
+		Type:       "json_schema",
+		JSONSchema: &OpenRouterJSONSchema{Name: name, Strict: true, Schema: schema},
+	}
+}
+
+// responseFormat constrains a response to schema, or to any JSON object, as
+// far as the routed model supports
+func (p *OpenRouterProvider) responseFormat(name string, schema *jsonSchema) *OpenRouterResponseFormat {
+	switch openRouterResponseFormat(p.config, p.getModel()) {
+	case jsonSchemaFormat:
+		return openRouterSchemaFormat(name, schema)
+	case jsonObjectFormat:
+		return &OpenRouterResponseFormat{Type: "json_object"}
+	default:
+		return nil
 	}
+}
 
-	// Parse the response
-	return p.parseCommandResponse(response)
+// reask returns a reaskFunc continuing the conversation of request
+func (p *OpenRouterProvider) reask(ctx context.Context, request OpenRouterRequest, usage *types.TokenUsage) reaskFunc {
+	return func(previous, repairPrompt string) (string, error) {
+		request.Stream = false
+		request.StreamOptions = nil
+		request.Messages = append(request.Messages[:len(request.Messages):len(request.Messages)],
+			OpenRouterMessage{Role: "assistant", Content: previous},
+			OpenRouterMessage{Role: "user", Content: repairPrompt},
+		)
+		response, err := p.makeAPICall(ctx, request)
+		if err != nil {
+			return "", err
+		}
+		usage.Add(response.Usage.tokenUsage())
+		return p.responseContent(response)
+	}
+}
+
+// decodeOpenRouterStreamChunk returns the text of one event of a streamed response
+func decodeOpenRouterStreamChunk(payload []byte, usage *types.TokenUsage) (string, error) {
+	var chunk OpenRouterStreamChunk
+	if err := decodeStreamPayload(payload, &chunk); err != nil {
+		return "", err
+	}
+	if chunk.Error != nil {
+		return "", &types.NLShellError{
+			Type:    types.ErrTypeProvider,
+			Message: fmt.Sprintf("OpenRouter API error: %s", chunk.Error.Message),
+			Context: map[string]interface{}{
+				"error_type": chunk.Error.Type,
+				"error_code": chunk.Error.Code,
+			},
+		}
+	}
+	if chunk.Usage != nil {
+		*usage = chunk.Usage.tokenUsage()
+	}
+	if len(chunk.Choices) == 0 {
+		return "", nil
+	}
+	return chunk.Choices[0].Delta.Content, nil
 }
 
 // validateResultInternal implements the actual OpenRouter API call for result validation
@@ -110,9 +260,10 @@ func (p *OpenRouterProvider) validateResultInternal(ctx context.Context, command
 				Content: validationPrompt,
 			},
 		},
-		Temperature: 0.1,
-		MaxTokens:   300,
-		Stream:      false,
+		Temperature:    0.1,
+		MaxTokens:      300,
+		Stream:         false,
+		ResponseFormat: p.responseFormat("validation_response", validationResponseSchema),
 	}
 
 	// Make the API call
@@ -120,9 +271,19 @@ func (p *OpenRouterProvider) validateResultInternal(ctx context.Context, command
 	if err != nil {
 		return nil, err
 	}
+	usage := response.Usage.tokenUsage()
 
-	// Parse the validation response
-	return p.parseValidationResponse(response)
+	// Parse the validation response, asking the model to correct it when it does not match the schema
+	content, err := p.responseContent(response)
+	if err != nil {
+		return nil, err
+	}
+	result, err := decodeWithRepair(content, decodeValidationResponse, p.reask(ctx, request, &usage))
+	if err != nil {
+		return nil, err
+	}
+	result.Usage = usage
+	return result, nil
 }
 
 // getModel returns the model to use for this provider
@@ -141,8 +302,8 @@ func (p *OpenRouterProvider) getBaseURL() string {
 	return "https://openrouter.ai/api/v1" // Default OpenRouter API URL
 }
 
-// makeAPICall makes an HTTP request to the OpenRouter API
-func (p *OpenRouterProvider) makeAPICall(ctx context.Context, request OpenRouterRequest) (*OpenRouterResponse, error) {
+// newHTTPRequest creates the HTTP request for an OpenRouter API call
+func (p *OpenRouterProvider) newHTTPRequest(ctx context.Context, request OpenRouterRequest) (*http.Request, error) {
 	// Serialize the request
 	requestBody, err := json.Marshal(request)
 	if err != nil {
@@ -169,6 +330,15 @@ func (p *OpenRouterProvider) makeAPICall(ctx context.Context, request OpenRouter
 	if p.config != nil && p.config.APIKey != "" {
 		httpReq.Header.Set("Authorization", "Bearer "+p.config.APIKey)
 	}
+	return httpReq, nil
+}
+
+// makeAPICall makes an HTTP request to the OpenRouter API
+func (p *OpenRouterProvider) makeAPICall(ctx context.Context, request OpenRouterRequest) (*OpenRouterResponse, error) {
+	httpReq, err := p.newHTTPRequest(ctx, request)
+	if err != nil {
+		return nil, err
+	}
 
 	// Make the request
 	httpResp, err := p.httpClient.Do(httpReq)
@@ -231,73 +401,13 @@ func (p *OpenRouterProvider) makeAPICall(ctx context.Context, request OpenRouter
 	return &response, nil
 }
 
-// parseCommandResponse parses the OpenRouter response for command generation
-func (p *OpenRouterProvider) parseCommandResponse(response *OpenRouterResponse) (*types.CommandResponse, error) {
+// responseContent returns the text of the first choice of a response
+func (p *OpenRouterProvider) responseContent(response *OpenRouterResponse) (string, error) {
 	if len(response.Choices) == 0 {
-		return nil, &types.NLShellError{
+		return "", &types.NLShellError{
 			Type:    types.ErrTypeProvider,
 			Message: "no choices in OpenRouter response",
 		}
 	}
-
-	content := response.Choices[0].Message.Content
-
-	// Try to parse as JSON first
-	var jsonResponse struct {
-		Command      string   `json:"command"`
-		Explanation  string   `json:"explanation"`
-		Confidence   float64  `json:"confidence"`
-		Alternatives []string `json:"alternatives"`
-	}
-
-	if err := json.Unmarshal([]byte(content), &jsonResponse); err == nil {
-		return &types.CommandResponse{
-			Command:      jsonResponse.Command,
-			Explanation:  jsonResponse.Explanation,
-			Confidence:   jsonResponse.Confidence,
-			Alternatives: jsonResponse.Alternatives,
-		}, nil
-	}
-
-	// Fallback: treat the entire content as the command
-	return &types.CommandResponse{
-		Command:     strings.TrimSpace(content),
-		Explanation: "Generated by OpenRouter",
-		Confidence:  0.8, // Default confidence when we can't parse JSON
-	}, nil
-}
-
-// parseValidationResponse parses the OpenRouter response for result validation
-func (p *OpenRouterProvider) parseValidationResponse(response *OpenRouterResponse) (*types.ValidationResponse, error) {
-	if len(response.Choices) == 0 {
-		return nil, &types.NLShellError{
-			Type:    types.ErrTypeProvider,
-			Message: "no choices in OpenRouter response",
-		}
-	}
-
-	content := response.Choices[0].Message.Content
-
-	// Try to parse as JSON
-	var jsonResponse struct {
-		IsCorrect   bool     `json:"is_correct"`
-		Explanation string   `json:"explanation"`
-		Suggestions []string `json:"suggestions"`
-		Correction  string   `json:"correction"`
-	}
-
-	if err := json.Unmarshal([]byte(content), &jsonResponse); err == nil {
-		return &types.ValidationResponse{
-			IsCorrect:   jsonResponse.IsCorrect,
-			Explanation: jsonResponse.Explanation,
-			Suggestions: jsonResponse.Suggestions,
-			Correction:  jsonResponse.Correction,
-		}, nil
-	}
-
-	// Fallback: treat the content as explanation
-	return &types.ValidationResponse{
-		IsCorrect:   false, // Conservative default
-		Explanation: strings.TrimSpace(content),
-	}, nil
+	return response.Choices[0].Message.Content, nil
 }
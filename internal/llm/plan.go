@@ -0,0 +1,35 @@
+package llm
+
+import (
+	"strings"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+// planResponseFormat replaces the single-command response format when a plan is requested
+const planResponseFormat = `
+The request needs several commands. Respond with a JSON object containing:
+- 'steps': array of objects with 'command' (one shell command) and 'explanation', in the order they must run
+- 'explanation': brief explanation of the plan as a whole
+- 'confidence': confidence level (0.0-1.0)
+Each step runs in a new shell in the current directory: do not rely on cd, exported variables or activated environments from earlier steps, and use explicit paths instead.
+`
+
+// planStepJSON is a plan step as returned by a provider
+type planStepJSON struct {
+	Command     string `json:"command"`
+	Explanation string `json:"explanation"`
+}
+
+// planSteps converts the steps returned by a provider, dropping empty commands
+func planSteps(steps []planStepJSON) []types.PlanStep {
+	var result []types.PlanStep
+	for _, step := range steps {
+		command := strings.TrimSpace(step.Command)
+		if command == "" {
+			continue
+		}
+		result = append(result, types.PlanStep{Command: command, Explanation: step.Explanation})
+	}
+	return result
+}
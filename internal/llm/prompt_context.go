@@ -0,0 +1,239 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+// environmentTokenBudget caps the tokens the environment section adds to the system prompt
+const environmentTokenBudget = 200
+
+// environmentHint follows the environment section in the system prompt
+const environmentHint = "Prefer the listed tools and package managers, and flags supported by this OS and userland.\n"
+
+// promptEnvironmentVars are the environment variables worth showing the model.
+// Paths such as PATH and HOME cost tokens without changing which command fits.
+var promptEnvironmentVars = []string{
+	"EDITOR", "PAGER", "NODE_ENV", "VIRTUAL_ENV", "CONDA_DEFAULT_ENV",
+	"DOCKER_HOST", "KUBERNETES_SERVICE_HOST",
+	"AWS_REGION", "AWS_DEFAULT_REGION", "GOOGLE_CLOUD_PROJECT",
+}
+
+// userlandDescriptions explain the flag dialect of the detected core utilities
+var userlandDescriptions = map[string]string{
+	"gnu":     "GNU userland",
+	"bsd":     "BSD userland, avoid GNU-only flags",
+	"busybox": "BusyBox userland, limited flags",
+}
+
+// runtimeNames label runtimes reported by the devtools plugin
+var runtimeNames = map[string]string{
+	"nodejs": "Node.js",
+	"python": "Python",
+	"java":   "Java",
+	"go":     "Go",
+}
+
+// promptLine is one line of the environment section: a label and the items
+// listed after it, trailing items being dropped first when over budget
+type promptLine struct {
+	label string
+	items []string
+}
+
+// buildEnvironmentSection renders plugin data and environment variables as a
+// compact section of at most budget tokens, or "" when there is nothing to show
+func buildEnvironmentSection(ctx *types.Context, budget int) string {
+	if ctx == nil {
+		return ""
+	}
+
+	env := pluginMap(ctx.PluginData, "environment")
+	devtools := pluginMap(ctx.PluginData, "devtools")
+	project := pluginMap(ctx.PluginData, "project")
+
+	// Lines are in priority order; the tool list is long and goes last
+	lines := []promptLine{
+		{"OS", systemItems(mapValue(env, "system"))},
+		{"Shell", shellItems(mapValue(env, "shell"), ctx.Environment)},
+		{"Project", projectItems(mapValue(project, "primary_type"))},
+		{"Package managers", packageManagerItems(mapValue(devtools, "runtimes"))},
+		{"Containers", containerItems(mapValue(devtools, "containers"))},
+		{"Environment variables", environmentItems(ctx.Environment)},
+		{"Available tools", toolItems(mapValue(devtools, "tools"))},
+	}
+
+	var section strings.Builder
+	remaining := budget - estimateTokens("Environment:\n")
+	for _, line := range lines {
+		rendered := fitPromptLine(line, remaining)
+		if rendered == "" {
+			continue
+		}
+		section.WriteString(rendered)
+		remaining -= estimateTokens(rendered)
+	}
+
+	if section.Len() == 0 {
+		return ""
+	}
+	return "Environment:\n" + section.String()
+}
+
+// fitPromptLine renders line in at most budget tokens, dropping trailing items
+// that do not fit. It returns "" when the line is empty or nothing fits.
+func fitPromptLine(line promptLine, budget int) string {
+	for n := len(line.items); n > 0; n-- {
+		items := line.items[:n]
+		if n < len(line.items) {
+			items = append(items[:n:n], fmt.Sprintf("+%d more", len(line.items)-n))
+		}
+		rendered := fmt.Sprintf("  %s: %s\n", line.label, strings.Join(items, ", "))
+		if estimateTokens(rendered) <= budget {
+			return rendered
+		}
+	}
+	return ""
+}
+
+// estimateTokens approximates the token count of s at four characters per token
+func estimateTokens(s string) int {
+	return (len(s) + 3) / 4
+}
+
+// pluginMap returns a plugin's data as generic JSON values, so typed plugin
+// results and data from external plugins are read the same way
+func pluginMap(pluginData map[string]interface{}, name string) map[string]interface{} {
+	data, ok := pluginData[name]
+	if !ok || data == nil {
+		return nil
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil
+	}
+	var generic map[string]interface{}
+	if err := json.Unmarshal(raw, &generic); err != nil {
+		return nil
+	}
+	return generic
+}
+
+// mapValue returns m[key] when it is an object
+func mapValue(m map[string]interface{}, key string) map[string]interface{} {
+	value, _ := m[key].(map[string]interface{})
+	return value
+}
+
+// stringValue returns m[key] when it is a non-empty string
+func stringValue(m map[string]interface{}, key string) string {
+	value, _ := m[key].(string)
+	return value
+}
+
+func systemItems(system map[string]interface{}) []string {
+	osName := stringValue(system, "os")
+	if osName == "" {
+		return nil
+	}
+	if arch := stringValue(system, "arch"); arch != "" {
+		osName += "/" + arch
+	}
+	items := []string{osName}
+	if description, ok := userlandDescriptions[stringValue(system, "userland")]; ok {
+		items = append(items, description)
+	}
+	return items
+}
+
+func shellItems(shell map[string]interface{}, environment map[string]string) []string {
+	if name := stringValue(shell, "name"); name != "" {
+		return []string{name}
+	}
+	if path := environment["SHELL"]; path != "" {
+		return []string{filepath.Base(path)}
+	}
+	return nil
+}
+
+func projectItems(primary map[string]interface{}) []string {
+	projectType := stringValue(primary, "type")
+	if projectType == "" {
+		return nil
+	}
+	var details []string
+	for _, key := range []string{"language", "framework"} {
+		if value := stringValue(primary, key); value != "" && value != "Unknown" {
+			details = append(details, value)
+		}
+	}
+	if len(details) > 0 {
+		projectType += " (" + strings.Join(details, ", ") + ")"
+	}
+	return []string{projectType}
+}
+
+func packageManagerItems(runtimes map[string]interface{}) []string {
+	var items []string
+	for _, key := range sortedKeys(runtimes) {
+		manager := stringValue(mapValue(runtimes, key), "package_manager")
+		if manager == "" {
+			continue
+		}
+		if name, ok := runtimeNames[key]; ok {
+			manager += " (" + name + ")"
+		}
+		items = append(items, manager)
+	}
+	return items
+}
+
+func containerItems(containers map[string]interface{}) []string {
+	var items []string
+	if docker := mapValue(containers, "docker"); docker != nil {
+		if docker["has_dockerfile"] == true {
+			items = append(items, "Dockerfile")
+		}
+		if docker["has_compose"] == true {
+			items = append(items, "compose file")
+		}
+	}
+	if kubernetes := mapValue(containers, "kubernetes"); kubernetes != nil {
+		items = append(items, "Kubernetes config")
+	}
+	return items
+}
+
+func environmentItems(environment map[string]string) []string {
+	var items []string
+	for _, name := range promptEnvironmentVars {
+		if value := environment[name]; value != "" {
+			items = append(items, name+"="+value)
+		}
+	}
+	return items
+}
+
+func toolItems(tools map[string]interface{}) []string {
+	var items []string
+	for _, name := range sortedKeys(tools) {
+		if available, _ := mapValue(tools, name)["available"].(bool); available {
+			items = append(items, name)
+		}
+	}
+	return items
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
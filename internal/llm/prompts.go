@@ -2,6 +2,8 @@ package llm
 
 import (
 	"fmt"
+	"math"
+	"sort"
 	"strings"
 
 	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
@@ -15,51 +17,140 @@ func NewPromptBuilder() *PromptBuilder {
 	return &PromptBuilder{}
 }
 
-// BuildSystemPrompt creates the system prompt for command generation
+// BuildSystemPrompt creates the system prompt for command generation with all gathered context
 func (pb *PromptBuilder) BuildSystemPrompt(context *types.Context) string {
-	var prompt strings.Builder
+	return pb.BuildBudgetedSystemPrompt(context, "", 0)
+}
 
-	prompt.WriteString("You are an expert shell command generator. Convert natural language requests into safe, accurate shell commands.\n\n")
-	prompt.WriteString("Rules:\n")
-	prompt.WriteString("1. Generate only the shell command, no explanations unless asked\n")
-	prompt.WriteString("2. Prefer safe, non-destructive commands\n")
-	prompt.WriteString("3. Use standard Unix/Linux commands when possible\n")
-	prompt.WriteString("4. Consider the current context when generating commands\n\n")
+// BuildBudgetedSystemPrompt creates the system prompt for command generation
+// within budget tokens. Context is ranked: files named after the request's
+// keywords come first, then git state, earlier turns of the session and plugin data, and
+// the other files fill what is left and are summarized when they do not fit.
+// A budget of 0 or less includes all context.
+func (pb *PromptBuilder) BuildBudgetedSystemPrompt(context *types.Context, request string, budget int) string {
+	var header strings.Builder
+	header.WriteString("You are an expert shell command generator. Convert natural language requests into safe, accurate shell commands.\n\n")
+	header.WriteString("Rules:\n")
+	header.WriteString("1. Generate only the shell command, no explanations unless asked\n")
+	header.WriteString("2. Prefer safe, non-destructive commands\n")
+	header.WriteString("3. Use standard Unix/Linux commands when possible\n")
+	header.WriteString("4. Consider the current context when generating commands\n\n")
+
+	var footer strings.Builder
+	if context != nil && context.PlanMode {
+		footer.WriteString(planResponseFormat)
+	} else {
+		footer.WriteString("\nRespond with a JSON object containing:\n")
+		footer.WriteString("- 'command': the shell command\n")
+		footer.WriteString("- 'explanation': brief explanation of what the command does\n")
+		footer.WriteString("- 'confidence': confidence level (0.0-1.0)\n")
+		footer.WriteString("- 'alternatives': array of alternative commands (optional)\n")
+		footer.WriteString("- 'interactive': true if the command needs a terminal for user input (editor, pager, password prompt)\n")
+	}
 
-	if context != nil {
-		if context.WorkingDirectory != "" {
-			prompt.WriteString(fmt.Sprintf("Current directory: %s\n", context.WorkingDirectory))
-		}
+	if context == nil {
+		return header.String() + footer.String()
+	}
 
-		if len(context.Files) > 0 {
-			prompt.WriteString("Files in current directory:\n")
-			for _, file := range context.Files {
-				if file.IsDir {
-					prompt.WriteString(fmt.Sprintf("  %s/ (directory)\n", file.Name))
-				} else {
-					prompt.WriteString(fmt.Sprintf("  %s (file, %d bytes)\n", file.Name, file.Size))
-				}
-			}
+	remaining := math.MaxInt32
+	if budget > 0 {
+		remaining = budget - estimateTokens(header.String()) - estimateTokens(footer.String())
+	}
+	// spend returns s if it fits in the remaining budget, and "" otherwise
+	spend := func(s string) string {
+		tokens := estimateTokens(s)
+		if s == "" || tokens > remaining {
+			return ""
 		}
+		remaining -= tokens
+		return s
+	}
 
-		if context.GitInfo != nil && context.GitInfo.IsRepository {
-			prompt.WriteString(fmt.Sprintf("Git repository: branch '%s'", context.GitInfo.CurrentBranch))
-			if context.GitInfo.HasUncommittedChanges {
-				prompt.WriteString(" (has uncommitted changes)")
-			}
-			prompt.WriteString("\n")
+	// Sections are filled in priority order and written in prompt order
+	var directory string
+	if context.WorkingDirectory != "" {
+		directory = spend(fmt.Sprintf("Current directory: %s\n", context.WorkingDirectory))
+	}
+	session := spend(formatShellState(context.Shell))
+
+	files := rankFiles(context.Files, requestKeywords(request))
+	var fileLines []string
+	var omitted []types.FileInfo
+	if len(context.Files) > 0 {
+		// The heading and a summary of omitted files are always reserved
+		remaining -= estimateTokens(filesHeading) + fileSummaryReserve
+		fileLines, omitted = fitFiles(files.relevant, spend)
+	}
+
+	var git string
+	if context.GitInfo != nil && context.GitInfo.IsRepository {
+		line := fmt.Sprintf("Git repository: branch '%s'", context.GitInfo.CurrentBranch)
+		if context.GitInfo.HasUncommittedChanges {
+			line += " (has uncommitted changes)"
 		}
+		git = spend(line + "\n")
 	}
 
-	prompt.WriteString("\nRespond with a JSON object containing:\n")
-	prompt.WriteString("- 'command': the shell command\n")
-	prompt.WriteString("- 'explanation': brief explanation of what the command does\n")
-	prompt.WriteString("- 'confidence': confidence level (0.0-1.0)\n")
-	prompt.WriteString("- 'alternatives': array of alternative commands (optional)\n")
+	conversation := fitConversation(context.Conversation, spend)
+
+	environmentBudget := environmentTokenBudget
+	if remaining-estimateTokens(environmentHint) < environmentBudget {
+		environmentBudget = remaining - estimateTokens(environmentHint)
+	}
+	environment := buildEnvironmentSection(context, environmentBudget)
+	if environment != "" {
+		environment = spend(environment + environmentHint)
+	}
+
+	if len(context.Files) > 0 {
+		otherLines, otherOmitted := fitFiles(files.other, spend)
+		fileLines = append(fileLines, otherLines...)
+		omitted = append(omitted, otherOmitted...)
+	}
+
+	var prompt strings.Builder
+	prompt.WriteString(header.String())
+	prompt.WriteString(directory)
+	prompt.WriteString(session)
+	if len(fileLines) > 0 || len(omitted) > 0 {
+		prompt.WriteString(filesHeading)
+		for _, line := range fileLines {
+			prompt.WriteString(line)
+		}
+		if len(omitted) > 0 {
+			prompt.WriteString(summarizeFiles(omitted))
+		}
+	}
+	prompt.WriteString(git)
+	prompt.WriteString(environment)
+	prompt.WriteString(conversation)
+	prompt.WriteString(footer.String())
 
 	return prompt.String()
 }
 
+// formatShellState names the variables earlier commands of the session
+// exported or unset. Values are left out, as they may be secrets.
+func formatShellState(state *types.ShellState) string {
+	if state == nil {
+		return ""
+	}
+
+	var text strings.Builder
+	if len(state.Environment) > 0 {
+		names := make([]string, 0, len(state.Environment))
+		for name := range state.Environment {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Fprintf(&text, "Exported earlier in this session: %s\n", strings.Join(names, ", "))
+	}
+	if len(state.Unset) > 0 {
+		fmt.Fprintf(&text, "Unset earlier in this session: %s\n", strings.Join(state.Unset, ", "))
+	}
+	return text.String()
+}
+
 // BuildValidationPrompt creates the prompt for result validation
 func (pb *PromptBuilder) BuildValidationPrompt(command, output, intent string) string {
 	return fmt.Sprintf(`Analyze this command execution:
@@ -1,6 +1,7 @@
 package llm
 
 import (
+	"fmt"
 	"strings"
 	"testing"
 
@@ -71,6 +72,114 @@ func TestPromptBuilder_BuildSystemPrompt_NilContext(t *testing.T) {
 	}
 }
 
+func TestPromptBuilder_BuildSystemPrompt_ShellState(t *testing.T) {
+	builder := NewPromptBuilder()
+
+	prompt := builder.BuildSystemPrompt(&types.Context{
+		WorkingDirectory: "/work/src",
+		Shell: &types.ShellState{
+			WorkingDirectory: "/work/src",
+			Environment:      map[string]string{"GOFLAGS": "-mod=mod", "API_TOKEN": "secret"},
+			Unset:            []string{"DEBUG"},
+		},
+	})
+
+	for _, want := range []string{"Current directory: /work/src", "Exported earlier in this session: API_TOKEN, GOFLAGS", "Unset earlier in this session: DEBUG"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("expected %q in the prompt, got:\n%s", want, prompt)
+		}
+	}
+	if strings.Contains(prompt, "secret") {
+		t.Error("expected values of exported variables to stay out of the prompt")
+	}
+}
+
+func TestPromptBuilder_BuildSystemPrompt_PluginData(t *testing.T) {
+	builder := NewPromptBuilder()
+
+	type toolInfo struct {
+		Name      string `json:"name"`
+		Available bool   `json:"available"`
+	}
+
+	context := &types.Context{
+		WorkingDirectory: "/home/user/project",
+		Environment: map[string]string{
+			"SHELL":    "/bin/zsh",
+			"NODE_ENV": "development",
+			"PATH":     "/usr/bin:/bin",
+		},
+		PluginData: map[string]interface{}{
+			"environment": map[string]interface{}{
+				"system": map[string]interface{}{"os": "darwin", "arch": "arm64", "userland": "bsd"},
+			},
+			"devtools": map[string]interface{}{
+				"tools": map[string]toolInfo{
+					"podman": {Name: "podman", Available: true},
+					"docker": {Name: "docker", Available: false},
+					"git":    {Name: "git", Available: true},
+				},
+				"runtimes": map[string]interface{}{
+					"nodejs": map[string]interface{}{"package_manager": "pnpm"},
+				},
+			},
+			"project": map[string]interface{}{
+				"primary_type": map[string]interface{}{"type": "web", "language": "TypeScript", "framework": "Next.js"},
+			},
+		},
+	}
+
+	prompt := builder.BuildSystemPrompt(context)
+
+	for _, want := range []string{
+		"OS: darwin/arm64, BSD userland",
+		"Shell: zsh",
+		"Project: web (TypeScript, Next.js)",
+		"Package managers: pnpm (Node.js)",
+		"Environment variables: NODE_ENV=development",
+		"Available tools: git, podman",
+	} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("Prompt should contain %q, got:\n%s", want, prompt)
+		}
+	}
+
+	if strings.Contains(prompt, "docker") || strings.Contains(prompt, "PATH=") {
+		t.Error("Prompt should not list unavailable tools or path variables")
+	}
+}
+
+func TestBuildEnvironmentSection_Budget(t *testing.T) {
+	tools := make(map[string]interface{})
+	for i := 0; i < 100; i++ {
+		name := fmt.Sprintf("tool%03d", i)
+		tools[name] = map[string]interface{}{"name": name, "available": true}
+	}
+	context := &types.Context{
+		PluginData: map[string]interface{}{
+			"environment": map[string]interface{}{
+				"system": map[string]interface{}{"os": "linux", "arch": "amd64", "userland": "gnu"},
+			},
+			"devtools": map[string]interface{}{"tools": tools},
+		},
+	}
+
+	section := buildEnvironmentSection(context, 60)
+	if estimateTokens(section) > 60 {
+		t.Errorf("Section exceeds its budget: %d tokens", estimateTokens(section))
+	}
+	if !strings.Contains(section, "OS: linux/amd64, GNU userland") {
+		t.Errorf("Higher priority lines should be kept, got:\n%s", section)
+	}
+	if !strings.Contains(section, "tool000") || !strings.Contains(section, "more") {
+		t.Errorf("Tool list should be truncated, got:\n%s", section)
+	}
+
+	if section := buildEnvironmentSection(&types.Context{}, 60); section != "" {
+		t.Errorf("Expected no section without plugin data, got %q", section)
+	}
+}
+
 func TestPromptBuilder_BuildValidationPrompt(t *testing.T) {
 	builder := NewPromptBuilder()
 
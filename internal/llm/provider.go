@@ -126,7 +126,8 @@ func ExecuteWithRetry(ctx context.Context, config *RetryConfig, operation Retrya
 
 // ProviderFactory creates LLM provider instances
 type ProviderFactory struct {
-	retryConfig *RetryConfig
+	retryConfig     *RetryConfig
+	persistentCache *cache.PersistentStore
 }
 
 // NewProviderFactory creates a new provider factory
@@ -143,9 +144,38 @@ func NewProviderFactoryWithRetry(retryConfig *RetryConfig) *ProviderFactory {
 	}
 }
 
-// CreateProvider creates a provider instance for the given provider name
+// SetPersistentCache makes the providers created from now on keep command
+// responses in store between runs
+func (f *ProviderFactory) SetPersistentCache(store *cache.PersistentStore) {
+	f.persistentCache = store
+}
+
+// CreateProvider creates a provider instance for the given provider name. The
+// configuration's Type selects the kind of provider when it is set, so several
+// named instances can share a type.
 func (f *ProviderFactory) CreateProvider(providerName string, config *types.ProviderConfig) (interfaces.LLMProvider, error) {
-	switch providerName {
+	provider, err := f.createProvider(providerName, config)
+	if err != nil {
+		return nil, err
+	}
+	if f.persistentCache != nil {
+		if cached, ok := provider.(interface {
+			SetPersistentCache(*cache.PersistentStore)
+		}); ok {
+			cached.SetPersistentCache(f.persistentCache)
+		}
+	}
+	return provider, nil
+}
+
+// createProvider creates the provider of the type the configuration selects
+func (f *ProviderFactory) createProvider(providerName string, config *types.ProviderConfig) (interfaces.LLMProvider, error) {
+	providerType := providerName
+	if config != nil && config.Type != "" {
+		providerType = config.Type
+	}
+
+	switch providerType {
 	case "openai":
 		return NewOpenAIProvider(config, f.retryConfig), nil
 	case "anthropic":
@@ -156,13 +186,15 @@ func (f *ProviderFactory) CreateProvider(providerName string, config *types.Prov
 		return NewOpenRouterProvider(config, f.retryConfig), nil
 	case "ollama":
 		return NewOllamaProvider(config, f.retryConfig), nil
+	case "openai-compatible":
+		return NewOpenAICompatibleProvider(providerName, config, f.retryConfig), nil
 	default:
 		return nil, &types.NLShellError{
 			Type:    types.ErrTypeConfiguration,
-			Message: "unsupported provider: " + providerName,
+			Message: "unsupported provider: " + providerType,
 			Context: map[string]interface{}{
 				"provider":            providerName,
-				"supported_providers": []string{"openai", "anthropic", "gemini", "openrouter", "ollama"},
+				"supported_providers": []string{"openai", "anthropic", "gemini", "openrouter", "ollama", "openai-compatible"},
 			},
 		}
 	}
@@ -197,6 +229,12 @@ func NewBaseProvider(config *types.ProviderConfig, retryConfig *RetryConfig) *Ba
 	}
 }
 
+// SetPersistentCache makes the provider keep command responses in store
+// between runs
+func (bp *BaseProvider) SetPersistentCache(store *cache.PersistentStore) {
+	bp.providerCache.SetPersistentStore(store)
+}
+
 // executeWithRetry wraps an operation with retry logic
 func (bp *BaseProvider) executeWithRetry(ctx context.Context, operation RetryableOperation) error {
 	return ExecuteWithRetry(ctx, bp.retryConfig, operation)
@@ -213,6 +251,19 @@ func (bp *BaseProvider) validateConfig() error {
 	return nil
 }
 
+// buildSystemPrompt builds the system prompt for request within the prompt
+// token budget of the provider's model, returning it with the estimated
+// tokens of the whole prompt
+func (bp *BaseProvider) buildSystemPrompt(context *types.Context, request, provider, model string) (string, int) {
+	configured := 0
+	if bp.config != nil {
+		configured = bp.config.PromptTokenBudget
+	}
+	budget := PromptBudget(provider, model, configured, request)
+	systemPrompt := bp.promptBuilder.BuildBudgetedSystemPrompt(context, request, budget)
+	return systemPrompt, estimateTokens(systemPrompt) + estimateTokens(request)
+}
+
 // getModel returns the model to use for this provider (to be implemented by concrete providers)
 func (bp *BaseProvider) getModel() string {
 	if bp.config != nil && bp.config.DefaultModel != "" {
@@ -312,6 +363,15 @@ func (p *OpenAIProvider) ValidateResult(ctx context.Context, command, output, in
 	return response, nil
 }
 
+// GenerateCommandStream generates a command like GenerateCommand, passing the
+// response text to onText as it arrives
+func (p *OpenAIProvider) GenerateCommandStream(ctx context.Context, prompt string, context *types.Context, onText func(string)) (*types.CommandResponse, error) {
+	return p.generateCommandStream(ctx, prompt, context, p.GetProviderInfo().Name, p.getModel(), onText,
+		func(onText func(string)) (*types.CommandResponse, error) {
+			return p.generateCommandStreamInternal(ctx, prompt, context, onText)
+		})
+}
+
 func (p *OpenAIProvider) GetProviderInfo() types.ProviderInfo {
 	return types.ProviderInfo{
 		Name:            "openai",
@@ -411,6 +471,15 @@ func (p *AnthropicProvider) ValidateResult(ctx context.Context, command, output,
 	return response, nil
 }
 
+// GenerateCommandStream generates a command like GenerateCommand, passing the
+// response text to onText as it arrives
+func (p *AnthropicProvider) GenerateCommandStream(ctx context.Context, prompt string, context *types.Context, onText func(string)) (*types.CommandResponse, error) {
+	return p.generateCommandStream(ctx, prompt, context, p.GetProviderInfo().Name, p.getModel(), onText,
+		func(onText func(string)) (*types.CommandResponse, error) {
+			return p.generateCommandStreamInternal(ctx, prompt, context, onText)
+		})
+}
+
 func (p *AnthropicProvider) GetProviderInfo() types.ProviderInfo {
 	return types.ProviderInfo{
 		Name:            "anthropic",
@@ -510,6 +579,15 @@ func (p *GeminiProvider) ValidateResult(ctx context.Context, command, output, in
 	return response, nil
 }
 
+// GenerateCommandStream generates a command like GenerateCommand, passing the
+// response text to onText as it arrives
+func (p *GeminiProvider) GenerateCommandStream(ctx context.Context, prompt string, context *types.Context, onText func(string)) (*types.CommandResponse, error) {
+	return p.generateCommandStream(ctx, prompt, context, p.GetProviderInfo().Name, p.getModel(), onText,
+		func(onText func(string)) (*types.CommandResponse, error) {
+			return p.generateCommandStreamInternal(ctx, prompt, context, onText)
+		})
+}
+
 func (p *GeminiProvider) GetProviderInfo() types.ProviderInfo {
 	return types.ProviderInfo{
 		Name:            "gemini",
@@ -609,6 +687,15 @@ func (p *OpenRouterProvider) ValidateResult(ctx context.Context, command, output
 	return response, nil
 }
 
+// GenerateCommandStream generates a command like GenerateCommand, passing the
+// response text to onText as it arrives
+func (p *OpenRouterProvider) GenerateCommandStream(ctx context.Context, prompt string, context *types.Context, onText func(string)) (*types.CommandResponse, error) {
+	return p.generateCommandStream(ctx, prompt, context, p.GetProviderInfo().Name, p.getModel(), onText,
+		func(onText func(string)) (*types.CommandResponse, error) {
+			return p.generateCommandStreamInternal(ctx, prompt, context, onText)
+		})
+}
+
 func (p *OpenRouterProvider) GetProviderInfo() types.ProviderInfo {
 	return types.ProviderInfo{
 		Name:            "openrouter",
@@ -708,6 +795,15 @@ func (p *OllamaProvider) ValidateResult(ctx context.Context, command, output, in
 	return response, nil
 }
 
+// GenerateCommandStream generates a command like GenerateCommand, passing the
+// response text to onText as it arrives
+func (p *OllamaProvider) GenerateCommandStream(ctx context.Context, prompt string, context *types.Context, onText func(string)) (*types.CommandResponse, error) {
+	return p.generateCommandStream(ctx, prompt, context, p.GetProviderInfo().Name, p.getModel(), onText,
+		func(onText func(string)) (*types.CommandResponse, error) {
+			return p.generateCommandStreamInternal(ctx, prompt, context, onText)
+		})
+}
+
 func (p *OllamaProvider) GetProviderInfo() types.ProviderInfo {
 	return types.ProviderInfo{
 		Name:            "ollama",
@@ -715,3 +811,147 @@ func (p *OllamaProvider) GetProviderInfo() types.ProviderInfo {
 		SupportedModels: []string{"llama2", "codellama", "mistral"},
 	}
 }
+
+// OpenAICompatibleProvider implements LLMProvider for self-hosted servers
+// implementing the OpenAI chat completions API, such as vLLM, LM Studio and
+// llama.cpp. Each configured instance is named after its configuration entry.
+type OpenAICompatibleProvider struct {
+	*BaseProvider
+	name string
+}
+
+func NewOpenAICompatibleProvider(name string, config *types.ProviderConfig, retryConfig *RetryConfig) interfaces.LLMProvider {
+	return &OpenAICompatibleProvider{
+		BaseProvider: NewBaseProvider(config, retryConfig),
+		name:         name,
+	}
+}
+
+func (p *OpenAICompatibleProvider) GenerateCommand(ctx context.Context, prompt string, context *types.Context) (*types.CommandResponse, error) {
+	if err := p.validateConfig(); err != nil {
+		return nil, err
+	}
+
+	providerName := p.GetProviderInfo().Name
+	model := p.getModel()
+
+	// Try cache first
+	if cachedResponse, exists := p.providerCache.GetCommandResponse(prompt, context, providerName, model); exists {
+		p.metricsTracker.RecordCommandHit()
+		return cachedResponse, nil
+	}
+
+	p.metricsTracker.RecordCommandMiss()
+
+	var response *types.CommandResponse
+	var err error
+	startTime := time.Now()
+
+	operation := func() error {
+		response, err = p.generateCommandInternal(ctx, prompt, context)
+		return err
+	}
+
+	if retryErr := p.executeWithRetry(ctx, operation); retryErr != nil {
+		return nil, retryErr
+	}
+
+	// Record response time
+	p.metricsTracker.RecordResponseTime(time.Since(startTime))
+
+	// Cache the response
+	if cacheErr := p.providerCache.SetCommandResponse(prompt, context, providerName, model, response); cacheErr != nil {
+		// Log cache error but don't fail the operation
+	}
+
+	return response, nil
+}
+
+func (p *OpenAICompatibleProvider) ValidateResult(ctx context.Context, command, output, intent string) (*types.ValidationResponse, error) {
+	if err := p.validateConfig(); err != nil {
+		return nil, err
+	}
+
+	providerName := p.GetProviderInfo().Name
+	model := p.getModel()
+
+	// Try cache first
+	if cachedResponse, exists := p.providerCache.GetValidationResponse(command, output, intent, providerName, model); exists {
+		p.metricsTracker.RecordValidationHit()
+		return cachedResponse, nil
+	}
+
+	p.metricsTracker.RecordValidationMiss()
+
+	var response *types.ValidationResponse
+	var err error
+	startTime := time.Now()
+
+	operation := func() error {
+		response, err = p.validateResultInternal(ctx, command, output, intent)
+		return err
+	}
+
+	if retryErr := p.executeWithRetry(ctx, operation); retryErr != nil {
+		return nil, retryErr
+	}
+
+	// Record response time
+	p.metricsTracker.RecordResponseTime(time.Since(startTime))
+
+	// Cache the response
+	if cacheErr := p.providerCache.SetValidationResponse(command, output, intent, providerName, model, response); cacheErr != nil {
+		// Log cache error but don't fail the operation
+	}
+
+	return response, nil
+}
+
+// GenerateCommandStream generates a command like GenerateCommand, passing the
+// response text to onText as it arrives. Servers configured without streaming
+// answer with a single response instead.
+func (p *OpenAICompatibleProvider) GenerateCommandStream(ctx context.Context, prompt string, context *types.Context, onText func(string)) (*types.CommandResponse, error) {
+	if err := p.validateConfig(); err != nil {
+		return nil, err
+	}
+	if p.config.Capabilities.NoStreaming {
+		return p.GenerateCommand(ctx, prompt, context)
+	}
+	return p.generateCommandStream(ctx, prompt, context, p.GetProviderInfo().Name, p.getModel(), onText,
+		func(onText func(string)) (*types.CommandResponse, error) {
+			return p.generateCommandStreamInternal(ctx, prompt, context, onText)
+		})
+}
+
+func (p *OpenAICompatibleProvider) GetProviderInfo() types.ProviderInfo {
+	requiresAuth := false
+	var models []string
+	if p.config != nil {
+		requiresAuth = p.config.APIKey != ""
+		if p.config.DefaultModel != "" {
+			models = []string{p.config.DefaultModel}
+		}
+	}
+	return types.ProviderInfo{
+		Name:            p.name,
+		RequiresAuth:    requiresAuth,
+		SupportedModels: models,
+	}
+}
+
+// validateConfig validates the provider configuration, which must name the server
+func (p *OpenAICompatibleProvider) validateConfig() error {
+	if err := p.BaseProvider.validateConfig(); err != nil {
+		return err
+	}
+	if p.config.BaseURL == "" {
+		return &types.NLShellError{
+			Type:    types.ErrTypeConfiguration,
+			Message: fmt.Sprintf("provider %s needs a BaseURL for its OpenAI-compatible server", p.name),
+			Context: map[string]interface{}{
+				"provider": p.name,
+			},
+		}
+	}
+	return nil
+}
@@ -327,6 +327,21 @@ func TestProviderFactory(t *testing.T) {
 		}
 	})
 
+	t.Run("named instance of a provider type", func(t *testing.T) {
+		instance := *config
+		instance.Type = "openai-compatible"
+		provider, err := factory.CreateProvider("vllm", &instance)
+		if err != nil {
+			t.Fatalf("Failed to create vllm provider: %v", err)
+		}
+		if _, ok := provider.(*OpenAICompatibleProvider); !ok {
+			t.Errorf("Expected an OpenAI-compatible provider, got %T", provider)
+		}
+		if name := provider.GetProviderInfo().Name; name != "vllm" {
+			t.Errorf("Expected the provider to be named vllm, got %s", name)
+		}
+	})
+
 	t.Run("unsupported provider", func(t *testing.T) {
 		provider, err := factory.CreateProvider("unsupported", config)
 		if err == nil {
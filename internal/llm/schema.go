@@ -0,0 +1,292 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+// maxRepairAttempts is how many times a response that does not match its
+// schema is sent back to the model to be corrected
+const maxRepairAttempts = 1
+
+// jsonSchema is a JSON schema for a structured response, limited to the subset
+// every provider's structured output feature accepts
+type jsonSchema struct {
+	Type                 string                 `json:"type"`
+	Description          string                 `json:"description,omitempty"`
+	Properties           map[string]*jsonSchema `json:"properties,omitempty"`
+	Items                *jsonSchema            `json:"items,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	AdditionalProperties *bool                  `json:"additionalProperties,omitempty"`
+}
+
+// objectSchema returns the schema of an object requiring all of its
+// properties, as OpenAI's strict mode does
+func objectSchema(properties map[string]*jsonSchema, order ...string) *jsonSchema {
+	noAdditional := false
+	return &jsonSchema{
+		Type:                 "object",
+		Properties:           properties,
+		Required:             order,
+		AdditionalProperties: &noAdditional,
+	}
+}
+
+// commandResponseSchema is the schema of a command generation response
+var commandResponseSchema = objectSchema(map[string]*jsonSchema{
+	"command":      {Type: "string", Description: "The shell command, or an empty string when steps are given"},
+	"explanation":  {Type: "string", Description: "Brief explanation of what the command does"},
+	"confidence":   {Type: "number", Description: "Confidence level from 0.0 to 1.0"},
+	"alternatives": {Type: "array", Items: &jsonSchema{Type: "string"}, Description: "Alternative commands"},
+	"interactive":  {Type: "boolean", Description: "Whether the command needs a terminal for user input"},
+	"steps": {Type: "array", Description: "Commands of a multi-step plan in the order they must run, or empty", Items: objectSchema(map[string]*jsonSchema{
+		"command":     {Type: "string"},
+		"explanation": {Type: "string"},
+	}, "command", "explanation")},
+}, "command", "explanation", "confidence", "alternatives", "interactive", "steps")
+
+// validationResponseSchema is the schema of a result validation response
+var validationResponseSchema = objectSchema(map[string]*jsonSchema{
+	"is_correct":  {Type: "boolean", Description: "Whether the result matches the intent"},
+	"explanation": {Type: "string", Description: "Explanation of the assessment"},
+	"suggestions": {Type: "array", Items: &jsonSchema{Type: "string"}, Description: "Suggestions for improvement"},
+	"correction":  {Type: "string", Description: "Corrected command, or an empty string"},
+}, "is_correct", "explanation", "suggestions", "correction")
+
+// forGemini returns the schema in the OpenAPI dialect of Gemini's
+// responseSchema, which names types in upper case and has no additionalProperties
+func (s *jsonSchema) forGemini() *jsonSchema {
+	if s == nil {
+		return nil
+	}
+	converted := &jsonSchema{
+		Type:        strings.ToUpper(s.Type),
+		Description: s.Description,
+		Items:       s.Items.forGemini(),
+		Required:    s.Required,
+	}
+	if s.Properties != nil {
+		converted.Properties = make(map[string]*jsonSchema, len(s.Properties))
+		for name, property := range s.Properties {
+			converted.Properties[name] = property.forGemini()
+		}
+	}
+	return converted
+}
+
+// responseFormat is how far a model's API constrains responses to JSON
+type responseFormat int
+
+const (
+	noResponseFormat responseFormat = iota // response_format is rejected; the prompt asks for JSON
+	jsonObjectFormat                       // "json_object": any JSON object
+	jsonSchemaFormat                       // "json_schema": structured outputs matching a schema
+)
+
+// OpenAI models with structured outputs, and with only JSON mode. Later
+// versions of a model share its prefix; earlier snapshots without the feature
+// are listed as exceptions.
+var (
+	openAISchemaModels     = []string{"gpt-4o", "gpt-4.1", "gpt-5", "o1", "o3", "o4"}
+	openAINoSchemaModels   = []string{"gpt-4o-2024-05-13", "o1-preview", "o1-mini"}
+	openAIJSONObjectModels = []string{"gpt-3.5-turbo-1106", "gpt-3.5-turbo-0125", "gpt-4-turbo", "gpt-4-1106", "gpt-4-0125", "gpt-4o-2024-05-13"}
+)
+
+// openAIResponseFormat returns the response format an OpenAI model supports.
+// Capabilities set in the provider's configuration take precedence, for
+// models this does not know.
+func openAIResponseFormat(config *types.ProviderConfig, model string) responseFormat {
+	if config != nil {
+		switch {
+		case config.Capabilities.JSONSchema:
+			return jsonSchemaFormat
+		case config.Capabilities.JSONMode:
+			return jsonObjectFormat
+		}
+	}
+
+	model = strings.ToLower(model)
+	hasPrefix := func(prefixes []string) bool {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(model, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+	switch {
+	case hasPrefix(openAISchemaModels) && !hasPrefix(openAINoSchemaModels):
+		return jsonSchemaFormat
+	case model == "gpt-3.5-turbo" || hasPrefix(openAIJSONObjectModels):
+		return jsonObjectFormat
+	default:
+		return noResponseFormat
+	}
+}
+
+// openRouterResponseFormat returns the response format a model routed by
+// OpenRouter supports. Only OpenAI's models are known to honor response_format;
+// the others are asked for JSON by the prompt alone.
+func openRouterResponseFormat(config *types.ProviderConfig, model string) responseFormat {
+	name, ok := strings.CutPrefix(strings.ToLower(model), "openai/")
+	if !ok && (config == nil || config.Capabilities == (types.ProviderCapabilities{})) {
+		return noResponseFormat
+	}
+	name, _, _ = strings.Cut(name, ":") // Variants such as :free
+	return openAIResponseFormat(config, name)
+}
+
+// schemaError reports a response that does not match its schema
+type schemaError struct {
+	problem string
+}
+
+func (e *schemaError) Error() string {
+	return "response does not match the schema: " + e.problem
+}
+
+// newSchemaError wraps a schema mismatch as a provider error
+func newSchemaError(content, format string, args ...interface{}) error {
+	return &types.NLShellError{
+		Type:    types.ErrTypeProvider,
+		Message: "provider response does not match the expected format",
+		Cause:   &schemaError{problem: fmt.Sprintf(format, args...)},
+		Context: map[string]interface{}{
+			"response": content,
+		},
+	}
+}
+
+// asSchemaError returns the schema mismatch err reports, if any
+func asSchemaError(err error) (*schemaError, bool) {
+	if nlErr, ok := err.(*types.NLShellError); ok {
+		schemaErr, ok := nlErr.Cause.(*schemaError)
+		return schemaErr, ok
+	}
+	return nil, false
+}
+
+// commandResponseJSON is a command generation response as described by commandResponseSchema
+type commandResponseJSON struct {
+	Command      *string        `json:"command"`
+	Explanation  string         `json:"explanation"`
+	Confidence   *float64       `json:"confidence"`
+	Alternatives []string       `json:"alternatives"`
+	Interactive  bool           `json:"interactive"`
+	Steps        []planStepJSON `json:"steps"`
+}
+
+// validationResponseJSON is a result validation response as described by validationResponseSchema
+type validationResponseJSON struct {
+	IsCorrect   *bool    `json:"is_correct"`
+	Explanation string   `json:"explanation"`
+	Suggestions []string `json:"suggestions"`
+	Correction  string   `json:"correction"`
+}
+
+// decodeCommandResponse decodes a command generation response, returning a
+// schema error when it is not a JSON object with a command or steps
+func decodeCommandResponse(content string) (*types.CommandResponse, error) {
+	var decoded commandResponseJSON
+	if err := decodeStructured(content, &decoded); err != nil {
+		return nil, err
+	}
+
+	steps := planSteps(decoded.Steps)
+	switch {
+	case decoded.Command == nil && decoded.Steps == nil:
+		return nil, newSchemaError(content, "missing required field 'command'")
+	case strings.TrimSpace(derefString(decoded.Command)) == "" && len(steps) == 0:
+		return nil, newSchemaError(content, "'command' is empty and there are no 'steps'")
+	case decoded.Confidence != nil && (*decoded.Confidence < 0 || *decoded.Confidence > 1):
+		return nil, newSchemaError(content, "'confidence' must be between 0.0 and 1.0, got %g", *decoded.Confidence)
+	}
+
+	response := &types.CommandResponse{
+		Command:      strings.TrimSpace(derefString(decoded.Command)),
+		Explanation:  decoded.Explanation,
+		Alternatives: decoded.Alternatives,
+		Interactive:  decoded.Interactive,
+		Steps:        steps,
+	}
+	if decoded.Confidence != nil {
+		response.Confidence = *decoded.Confidence
+	}
+	return response, nil
+}
+
+// decodeValidationResponse decodes a result validation response, returning a
+// schema error when it is not a JSON object with is_correct
+func decodeValidationResponse(content string) (*types.ValidationResponse, error) {
+	var decoded validationResponseJSON
+	if err := decodeStructured(content, &decoded); err != nil {
+		return nil, err
+	}
+	if decoded.IsCorrect == nil {
+		return nil, newSchemaError(content, "missing required field 'is_correct'")
+	}
+
+	return &types.ValidationResponse{
+		IsCorrect:   *decoded.IsCorrect,
+		Explanation: decoded.Explanation,
+		Suggestions: decoded.Suggestions,
+		Correction:  decoded.Correction,
+	}, nil
+}
+
+// decodeStructured unmarshals a JSON object response into v. A markdown code
+// block around the object, which models without structured output often add,
+// is removed first.
+func decodeStructured(content string, v interface{}) error {
+	text := strings.TrimSpace(content)
+	if strings.HasPrefix(text, "```") {
+		text = strings.TrimPrefix(strings.TrimPrefix(text, "```json"), "```")
+		text = strings.TrimSpace(strings.TrimSuffix(text, "```"))
+	}
+	if !strings.HasPrefix(text, "{") {
+		return newSchemaError(content, "expected a JSON object")
+	}
+	if err := json.Unmarshal([]byte(text), v); err != nil {
+		return newSchemaError(content, "invalid JSON: %v", err)
+	}
+	return nil
+}
+
+// reaskFunc sends the model its previous response followed by a request to
+// correct it, returning the new response
+type reaskFunc func(previous, repairPrompt string) (string, error)
+
+// decodeWithRepair decodes content, asking the model through reask to correct
+// it when it does not match the schema
+func decodeWithRepair[T any](content string, decode func(string) (T, error), reask reaskFunc) (T, error) {
+	result, err := decode(content)
+	for attempt := 0; attempt < maxRepairAttempts; attempt++ {
+		schemaErr, ok := asSchemaError(err)
+		if !ok {
+			break
+		}
+		content, err = reask(content, repairPrompt(schemaErr))
+		if err != nil {
+			var zero T
+			return zero, err
+		}
+		result, err = decode(content)
+	}
+	return result, err
+}
+
+// repairPrompt asks the model to correct a response that does not match its schema
+func repairPrompt(problem *schemaError) string {
+	return fmt.Sprintf("Your previous response could not be used because it does not match the required format: %s. Respond again with only the JSON object described in the instructions, with no other text.", problem.problem)
+}
+
+// derefString returns the value of s, or "" when it is nil
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
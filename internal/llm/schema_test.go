@@ -0,0 +1,113 @@
+package llm
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestDecodeCommandResponse(t *testing.T) {
+	tests := []struct {
+		name          string
+		content       string
+		expectCommand string
+		expectSteps   int
+		expectProblem string
+	}{
+		{
+			name:          "command",
+			content:       `{"command": "ls -la", "explanation": "List files", "confidence": 0.9, "alternatives": [], "interactive": false, "steps": []}`,
+			expectCommand: "ls -la",
+		},
+		{
+			name:          "code block",
+			content:       "```json\n{\"command\": \"df -h\", \"explanation\": \"Disk usage\"}\n```",
+			expectCommand: "df -h",
+		},
+		{
+			name:        "plan",
+			content:     `{"command": "", "steps": [{"command": "make", "explanation": "Build"}, {"command": "make test", "explanation": "Test"}], "explanation": "Build and test"}`,
+			expectSteps: 2,
+		},
+		{name: "plain text", content: "ls -la", expectProblem: "expected a JSON object"},
+		{name: "truncated", content: `{"command": "ls`, expectProblem: "invalid JSON"},
+		{name: "missing command", content: `{"explanation": "List files"}`, expectProblem: "missing required field 'command'"},
+		{name: "empty command", content: `{"command": " ", "steps": []}`, expectProblem: "'command' is empty"},
+		{name: "confidence out of range", content: `{"command": "ls", "confidence": 90}`, expectProblem: "'confidence' must be between"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := decodeCommandResponse(tt.content)
+			if tt.expectProblem != "" {
+				schemaErr, ok := asSchemaError(err)
+				if !ok || !strings.Contains(schemaErr.problem, tt.expectProblem) {
+					t.Errorf("expected a schema error containing %q, got %v", tt.expectProblem, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if response.Command != tt.expectCommand || len(response.Steps) != tt.expectSteps {
+				t.Errorf("unexpected response: %+v", response)
+			}
+		})
+	}
+}
+
+func TestDecodeValidationResponse(t *testing.T) {
+	response, err := decodeValidationResponse(`{"is_correct": false, "explanation": "Wrong directory", "suggestions": [], "correction": "ls /tmp"}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if response.IsCorrect || response.Correction != "ls /tmp" {
+		t.Errorf("unexpected response: %+v", response)
+	}
+
+	// A missing verdict is not read as incorrect
+	if _, err := decodeValidationResponse(`{"explanation": "Looks fine"}`); err == nil {
+		t.Error("expected a schema error for a response without is_correct")
+	}
+}
+
+func TestDecodeWithRepair(t *testing.T) {
+	var prompts []string
+	reask := func(previous, repairPrompt string) (string, error) {
+		prompts = append(prompts, repairPrompt)
+		return "still not JSON", nil
+	}
+
+	// The model is asked to correct its response, and the problem is reported when it does not
+	_, err := decodeWithRepair("ls -la", decodeCommandResponse, reask)
+	if _, ok := asSchemaError(err); !ok {
+		t.Errorf("expected a schema error, got %v", err)
+	}
+	if len(prompts) != maxRepairAttempts {
+		t.Errorf("expected %d repair attempts, got %d", maxRepairAttempts, len(prompts))
+	}
+
+	// A valid response is used without asking again
+	prompts = nil
+	response, err := decodeWithRepair(`{"command": "ls"}`, decodeCommandResponse, reask)
+	if err != nil || response.Command != "ls" || len(prompts) != 0 {
+		t.Errorf("expected the response to be used as is, got %+v, %v after %d repairs", response, err, len(prompts))
+	}
+}
+
+func TestJSONSchema_ForGemini(t *testing.T) {
+	data, err := json.Marshal(commandResponseSchema.forGemini())
+	if err != nil {
+		t.Fatalf("failed to marshal schema: %v", err)
+	}
+	schema := string(data)
+
+	for _, want := range []string{`"type":"OBJECT"`, `"command":{"type":"STRING"`, `"items":{"type":"OBJECT"`} {
+		if !strings.Contains(schema, want) {
+			t.Errorf("expected %s in the Gemini schema, got %s", want, schema)
+		}
+	}
+	if strings.Contains(schema, "additionalProperties") {
+		t.Errorf("expected no additionalProperties in the Gemini schema, got %s", schema)
+	}
+}
@@ -0,0 +1,338 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+// maxStreamLineSize bounds one line of a streamed response
+const maxStreamLineSize = 1024 * 1024
+
+// streamFormat is how an API frames the pieces of a streamed response
+type streamFormat int
+
+const (
+	streamSSE    streamFormat = iota // Server-sent events with a JSON payload per data line
+	streamNDJSON                     // One JSON object per line
+)
+
+// streamDecoder returns the response text carried by one payload of a
+// stream, recording in usage the token counts the payload reports
+type streamDecoder func(payload []byte, usage *types.TokenUsage) (string, error)
+
+// generateCommandStream wraps a streaming operation with the cache, retry and
+// metrics handling of GenerateCommand. A cached response is returned without
+// calling onText. Once text has been passed to onText the operation is not
+// retried, so the caller never sees a response twice.
+func (bp *BaseProvider) generateCommandStream(ctx context.Context, prompt string, context *types.Context, providerName, model string, onText func(string), operation func(onText func(string)) (*types.CommandResponse, error)) (*types.CommandResponse, error) {
+	if err := bp.validateConfig(); err != nil {
+		return nil, err
+	}
+
+	// Try cache first
+	if cachedResponse, exists := bp.providerCache.GetCommandResponse(prompt, context, providerName, model); exists {
+		bp.metricsTracker.RecordCommandHit()
+		return cachedResponse, nil
+	}
+
+	bp.metricsTracker.RecordCommandMiss()
+
+	var response *types.CommandResponse
+	streamed := false
+	startTime := time.Now()
+
+	err := bp.executeWithRetry(ctx, func() error {
+		var err error
+		response, err = operation(func(text string) {
+			streamed = true
+			if onText != nil {
+				onText(text)
+			}
+		})
+		if err != nil && streamed {
+			return &types.NLShellError{
+				Type:    types.ErrTypeProvider,
+				Message: "response stream was interrupted",
+				Cause:   err,
+			}
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Record response time
+	bp.metricsTracker.RecordResponseTime(time.Since(startTime))
+
+	// Cache the response
+	if cacheErr := bp.providerCache.SetCommandResponse(prompt, context, providerName, model, response); cacheErr != nil {
+		// Log cache error but don't fail the operation
+	}
+
+	return response, nil
+}
+
+// streamResponse sends httpReq and reads its streamed response, passing the
+// text decoded from each payload to onText, and returns the whole text and
+// the token usage the stream reported
+func (bp *BaseProvider) streamResponse(httpReq *http.Request, apiName string, format streamFormat, decode streamDecoder, onText func(string)) (string, types.TokenUsage, error) {
+	var usage types.TokenUsage
+	httpResp, err := bp.httpClient.Do(httpReq)
+	if err != nil {
+		return "", usage, &types.NLShellError{
+			Type:    types.ErrTypeNetwork,
+			Message: "failed to make HTTP request",
+			Cause:   err,
+		}
+	}
+	defer httpResp.Body.Close()
+
+	// Check for HTTP errors
+	if httpResp.StatusCode != http.StatusOK {
+		responseBody, _ := io.ReadAll(httpResp.Body)
+		return "", usage, &types.NLShellError{
+			Type:    types.ErrTypeProvider,
+			Message: fmt.Sprintf("%s API returned status %d", apiName, httpResp.StatusCode),
+			Context: map[string]interface{}{
+				"http_status":   httpResp.StatusCode,
+				"response_body": string(responseBody),
+			},
+		}
+	}
+
+	var text strings.Builder
+	scanner := bufio.NewScanner(httpResp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxStreamLineSize)
+	for scanner.Scan() {
+		payload := bytes.TrimSpace(scanner.Bytes())
+		if format == streamSSE {
+			// Event names, comments and keep-alives carry no text
+			if !bytes.HasPrefix(payload, []byte("data:")) {
+				continue
+			}
+			payload = bytes.TrimSpace(bytes.TrimPrefix(payload, []byte("data:")))
+			if bytes.Equal(payload, []byte("[DONE]")) {
+				break
+			}
+		}
+		if len(payload) == 0 {
+			continue
+		}
+
+		piece, err := decode(payload, &usage)
+		if err != nil {
+			return "", usage, err
+		}
+		if piece != "" {
+			text.WriteString(piece)
+			onText(piece)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", usage, &types.NLShellError{
+			Type:    types.ErrTypeNetwork,
+			Message: "failed to read response stream",
+			Cause:   err,
+		}
+	}
+
+	return text.String(), usage, nil
+}
+
+// decodeStreamPayload unmarshals one payload of a stream into v
+func decodeStreamPayload(payload []byte, v interface{}) error {
+	if err := json.Unmarshal(payload, v); err != nil {
+		return &types.NLShellError{
+			Type:    types.ErrTypeProvider,
+			Message: "failed to parse response stream",
+			Cause:   err,
+			Context: map[string]interface{}{
+				"payload": string(payload),
+			},
+		}
+	}
+	return nil
+}
+
+// ResponsePreview follows a streamed command response, reporting the text of
+// its explanation and command fields as it arrives. Responses are JSON
+// objects, so the fields are read from the partial object received so far.
+type ResponsePreview struct {
+	text     strings.Builder
+	shown    map[string]int
+	onUpdate func(field, text string)
+}
+
+// previewFields are the response fields reported by a ResponsePreview
+var previewFields = map[string]bool{"explanation": true, "command": true}
+
+// NewResponsePreview creates a preview calling onUpdate with each piece of the
+// explanation and command fields, named by field, as they arrive
+func NewResponsePreview(onUpdate func(field, text string)) *ResponsePreview {
+	return &ResponsePreview{
+		shown:    make(map[string]int),
+		onUpdate: onUpdate,
+	}
+}
+
+// Write adds a piece of the streamed response text
+func (p *ResponsePreview) Write(text string) {
+	p.text.WriteString(text)
+
+	fields, order := partialFields(p.text.String())
+	for _, field := range order {
+		if !previewFields[field] {
+			continue
+		}
+		value := fields[field]
+		if len(value) > p.shown[field] {
+			p.onUpdate(field, value[p.shown[field]:])
+			p.shown[field] = len(value)
+		}
+	}
+}
+
+// partialFields returns the string fields of a JSON object that may be cut
+// off, in the order they appear. A string cut off mid-value is returned up to
+// the cut.
+func partialFields(text string) (map[string]string, []string) {
+	fields := make(map[string]string)
+	var order []string
+
+	i := strings.IndexByte(text, '{')
+	if i < 0 {
+		return fields, order
+	}
+	i++
+
+	for {
+		i = skipSpace(text, i)
+		if i >= len(text) || text[i] != '"' {
+			return fields, order
+		}
+		key, next, complete := partialString(text, i)
+		if !complete {
+			return fields, order
+		}
+
+		i = skipSpace(text, next)
+		if i >= len(text) || text[i] != ':' {
+			return fields, order
+		}
+		i = skipSpace(text, i+1)
+		if i >= len(text) {
+			return fields, order
+		}
+
+		if text[i] == '"' {
+			value, next, complete := partialString(text, i)
+			if _, seen := fields[key]; !seen {
+				order = append(order, key)
+			}
+			fields[key] = value
+			if !complete {
+				return fields, order
+			}
+			i = next
+		} else {
+			next, complete := skipValue(text, i)
+			if !complete {
+				return fields, order
+			}
+			i = next
+		}
+
+		i = skipSpace(text, i)
+		if i >= len(text) || text[i] != ',' {
+			return fields, order
+		}
+		i++
+	}
+}
+
+// partialString decodes the JSON string starting at text[start], returning
+// its value, the index after it and whether it was complete
+func partialString(text string, start int) (string, int, bool) {
+	i := start + 1
+	for i < len(text) {
+		switch text[i] {
+		case '"':
+			var value string
+			if err := json.Unmarshal([]byte(text[start:i+1]), &value); err != nil {
+				return "", i + 1, false
+			}
+			return value, i + 1, true
+		case '\\':
+			// Stop before an escape sequence that has not fully arrived
+			length := 2
+			if i+1 < len(text) && text[i+1] == 'u' {
+				length = 6
+			}
+			if i+length > len(text) {
+				return decodeStringPrefix(text[start:i]), i, false
+			}
+			i += length
+		default:
+			i++
+		}
+	}
+	return decodeStringPrefix(text[start:i]), i, false
+}
+
+// decodeStringPrefix decodes the start of a JSON string that has no closing quote
+func decodeStringPrefix(raw string) string {
+	var value string
+	if err := json.Unmarshal([]byte(raw+`"`), &value); err != nil {
+		return ""
+	}
+	return value
+}
+
+// skipValue skips the JSON value that is not a string starting at text[start],
+// returning the index after it and whether it was complete
+func skipValue(text string, start int) (int, bool) {
+	depth := 0
+	for i := start; i < len(text); i++ {
+		switch text[i] {
+		case '"':
+			_, next, complete := partialString(text, i)
+			if !complete {
+				return i, false
+			}
+			i = next - 1
+		case '{', '[':
+			depth++
+		case '}', ']':
+			if depth == 0 {
+				return i, true
+			}
+			depth--
+			if depth == 0 {
+				return i + 1, true
+			}
+		case ',':
+			if depth == 0 {
+				return i, true
+			}
+		}
+	}
+	return len(text), false
+}
+
+// skipSpace returns the index of the first character at or after i that is not whitespace
+func skipSpace(text string, i int) int {
+	for i < len(text) && strings.IndexByte(" \t\r\n", text[i]) >= 0 {
+		i++
+	}
+	return i
+}
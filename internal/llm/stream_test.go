@@ -0,0 +1,229 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/interfaces"
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+// streamedContent is the response text the test servers stream in pieces
+const streamedContent = `{"explanation": "List files in long format", "command": "ls -la", "confidence": 0.9}`
+
+// streamPieces splits streamedContent into small pieces, as providers stream it
+func streamPieces() []string {
+	var pieces []string
+	for i := 0; i < len(streamedContent); i += 7 {
+		end := i + 7
+		if end > len(streamedContent) {
+			end = len(streamedContent)
+		}
+		pieces = append(pieces, streamedContent[i:end])
+	}
+	return pieces
+}
+
+func TestProviders_GenerateCommandStream(t *testing.T) {
+	tests := []struct {
+		name       string
+		path       string
+		newFunc    func(*types.ProviderConfig, *RetryConfig) interfaces.LLMProvider
+		writeEvent func(w http.ResponseWriter, piece string)
+		start      string // Sent before the pieces
+		finish     string // Sent after the pieces, reporting 50 input and 20 output tokens
+	}{
+		{
+			name:    "openai",
+			path:    "/chat/completions",
+			newFunc: NewOpenAIProvider,
+			writeEvent: func(w http.ResponseWriter, piece string) {
+				data, _ := json.Marshal(OpenAIStreamChunk{Choices: []OpenAIStreamChoice{{Delta: OpenAIMessage{Content: piece}}}})
+				fmt.Fprintf(w, "data: %s\n\n", data)
+			},
+			finish: "data: {\"choices\":[],\"usage\":{\"prompt_tokens\":50,\"completion_tokens\":20,\"total_tokens\":70}}\n\ndata: [DONE]\n\n",
+		},
+		{
+			name:    "openrouter",
+			path:    "/chat/completions",
+			newFunc: NewOpenRouterProvider,
+			writeEvent: func(w http.ResponseWriter, piece string) {
+				data, _ := json.Marshal(OpenRouterStreamChunk{Choices: []OpenRouterStreamChoice{{Delta: OpenRouterMessage{Content: piece}}}})
+				fmt.Fprintf(w, "data: %s\n\n", data)
+			},
+			finish: "data: {\"choices\":[],\"usage\":{\"prompt_tokens\":50,\"completion_tokens\":20,\"total_tokens\":70}}\n\ndata: [DONE]\n\n",
+		},
+		{
+			name:    "anthropic",
+			path:    "/v1/messages",
+			newFunc: NewAnthropicProvider,
+			writeEvent: func(w http.ResponseWriter, piece string) {
+				data, _ := json.Marshal(AnthropicStreamEvent{Type: "content_block_delta", Delta: &AnthropicContent{Type: "text_delta", Text: piece}})
+				fmt.Fprintf(w, "event: content_block_delta\ndata: %s\n\n", data)
+			},
+			start:  "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"usage\":{\"input_tokens\":50,\"output_tokens\":1}}}\n\n",
+			finish: "event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"tool_use\"},\"usage\":{\"output_tokens\":20}}\n\nevent: message_stop\ndata: {\"type\":\"message_stop\"}\n\n",
+		},
+		{
+			name:    "anthropic tool input",
+			path:    "/v1/messages",
+			newFunc: NewAnthropicProvider,
+			writeEvent: func(w http.ResponseWriter, piece string) {
+				data, _ := json.Marshal(AnthropicStreamEvent{Type: "content_block_delta", Delta: &AnthropicContent{Type: "input_json_delta", PartialJSON: piece}})
+				fmt.Fprintf(w, "event: content_block_delta\ndata: %s\n\n", data)
+			},
+			start:  "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"usage\":{\"input_tokens\":50,\"output_tokens\":1}}}\n\n",
+			finish: "event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"tool_use\"},\"usage\":{\"output_tokens\":20}}\n\nevent: message_stop\ndata: {\"type\":\"message_stop\"}\n\n",
+		},
+		{
+			name:    "gemini",
+			path:    "/models/gemini-1.5-flash:streamGenerateContent",
+			newFunc: NewGeminiProvider,
+			writeEvent: func(w http.ResponseWriter, piece string) {
+				data, _ := json.Marshal(GeminiResponse{Candidates: []GeminiCandidate{{Content: GeminiContent{Parts: []GeminiPart{{Text: piece}}}}}})
+				fmt.Fprintf(w, "data: %s\r\n\r\n", data)
+			},
+			finish: "data: {\"candidates\":[],\"usageMetadata\":{\"promptTokenCount\":50,\"candidatesTokenCount\":20,\"totalTokenCount\":70}}\r\n\r\n",
+		},
+		{
+			name:    "ollama",
+			path:    "/api/generate",
+			newFunc: NewOllamaProvider,
+			writeEvent: func(w http.ResponseWriter, piece string) {
+				data, _ := json.Marshal(OllamaResponse{Response: piece})
+				fmt.Fprintf(w, "%s\n", data)
+			},
+			finish: "{\"response\":\"\",\"done\":true,\"prompt_eval_count\":50,\"eval_count\":20}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != tt.path {
+					t.Errorf("expected request to %s, got %s", tt.path, r.URL.Path)
+				}
+				var body map[string]interface{}
+				json.NewDecoder(r.Body).Decode(&body)
+				if tt.path != "/models/gemini-1.5-flash:streamGenerateContent" && body["stream"] != true {
+					t.Errorf("expected a streaming request, got %v", body["stream"])
+				}
+				if tt.path == "/models/gemini-1.5-flash:streamGenerateContent" && r.URL.Query().Get("alt") != "sse" {
+					t.Errorf("expected server-sent events, got query %q", r.URL.RawQuery)
+				}
+				if tt.path == "/chat/completions" && fmt.Sprint(body["stream_options"]) != "map[include_usage:true]" {
+					t.Errorf("expected the usage to be requested, got %v", body["stream_options"])
+				}
+
+				fmt.Fprint(w, tt.start)
+				for _, piece := range streamPieces() {
+					tt.writeEvent(w, piece)
+					w.(http.Flusher).Flush()
+				}
+				fmt.Fprint(w, tt.finish)
+			}))
+			defer server.Close()
+
+			provider := tt.newFunc(&types.ProviderConfig{
+				APIKey:  "test-key",
+				BaseURL: server.URL,
+				Timeout: 5 * time.Second,
+			}, DefaultRetryConfig())
+			streaming, ok := provider.(interfaces.StreamingLLMProvider)
+			if !ok {
+				t.Fatal("provider should support streaming")
+			}
+
+			var received []string
+			response, err := streaming.GenerateCommandStream(context.Background(), "list all files", &types.Context{}, func(text string) {
+				received = append(received, text)
+			})
+			if err != nil {
+				t.Fatalf("GenerateCommandStream failed: %v", err)
+			}
+
+			if len(received) != len(streamPieces()) || strings.Join(received, "") != streamedContent {
+				t.Errorf("expected the response in %d pieces, got %q", len(streamPieces()), received)
+			}
+			if response.Command != "ls -la" || response.Explanation != "List files in long format" || response.Confidence != 0.9 {
+				t.Errorf("unexpected parsed response: %+v", response)
+			}
+			if response.Usage != (types.TokenUsage{InputTokens: 50, OutputTokens: 20}) {
+				t.Errorf("expected the reported usage, got %+v", response.Usage)
+			}
+			if response.Provider != provider.GetProviderInfo().Name {
+				t.Errorf("expected provider %s, got %s", provider.GetProviderInfo().Name, response.Provider)
+			}
+		})
+	}
+}
+
+func TestOpenAIProvider_GenerateCommandStream_HTTPError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error": {"message": "Invalid API key"}}`))
+	}))
+	defer server.Close()
+
+	provider := NewOpenAIProvider(&types.ProviderConfig{BaseURL: server.URL}, DefaultRetryConfig())
+	_, err := provider.(interfaces.StreamingLLMProvider).GenerateCommandStream(context.Background(), "list files", &types.Context{}, func(string) {})
+	if err == nil {
+		t.Fatal("expected an error for a rejected request")
+	}
+	if reason := fallbackReason(err); reason != "authentication failed" {
+		t.Errorf("expected the status to be reported, got %v", err)
+	}
+}
+
+func TestResponsePreview(t *testing.T) {
+	var updates []string
+	preview := NewResponsePreview(func(field, text string) {
+		updates = append(updates, field+"="+text)
+	})
+
+	// Pieces split keys, escape sequences and values
+	for _, piece := range []string{
+		"```json\n{\"expl", "anation\": \"Shows \\", "\"hidden\\", "\" files\\u00", "e9 here\", \"confidence\": 0.", "9, \"comm", "and\": \"ls", " -a\"}",
+	} {
+		preview.Write(piece)
+	}
+
+	want := []string{
+		`explanation=Shows `,
+		`explanation="hidden`,
+		`explanation=" files`,
+		`explanation=é here`,
+		`command=ls`,
+		`command= -a`,
+	}
+	if strings.Join(updates, "|") != strings.Join(want, "|") {
+		t.Errorf("unexpected updates:\n got %q\nwant %q", updates, want)
+	}
+}
+
+func TestPartialFields(t *testing.T) {
+	tests := []struct {
+		text  string
+		want  map[string]string
+		order []string
+	}{
+		{`not json`, map[string]string{}, nil},
+		{`{"command": "ls`, map[string]string{"command": "ls"}, []string{"command"}},
+		{`{"alternatives": ["a", "b"], "command": "x"}`, map[string]string{"command": "x"}, []string{"command"}},
+		{`{"steps": [{"command": "nested"}], "explanation": "top`, map[string]string{"explanation": "top"}, []string{"explanation"}},
+		{`{"interactive": true, "command": "vim"}`, map[string]string{"command": "vim"}, []string{"command"}},
+	}
+
+	for _, tt := range tests {
+		fields, order := partialFields(tt.text)
+		if fmt.Sprint(fields) != fmt.Sprint(tt.want) || strings.Join(order, ",") != strings.Join(tt.order, ",") {
+			t.Errorf("partialFields(%q) = %v %v, want %v %v", tt.text, fields, order, tt.want, tt.order)
+		}
+	}
+}
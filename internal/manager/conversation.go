@@ -0,0 +1,110 @@
+package manager
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+const (
+	// maxConversationTurns limits how many earlier turns are passed to the provider
+	maxConversationTurns = 5
+
+	// maxTurnOutput limits how much of a command's output a turn keeps
+	maxTurnOutput = 500
+)
+
+// conversationTurn is a turn of the conversation and the command it last ran
+type conversationTurn struct {
+	commandID string
+	turn      types.ConversationTurn
+}
+
+// ForgetConversation clears the earlier turns passed to the provider, so the
+// next request is generated without them
+func (m *Manager) ForgetConversation() {
+	m.conversationMutex.Lock()
+	defer m.conversationMutex.Unlock()
+
+	m.conversation = nil
+}
+
+// conversationContext returns a copy of the earlier turns, oldest first
+func (m *Manager) conversationContext() []types.ConversationTurn {
+	m.conversationMutex.Lock()
+	defer m.conversationMutex.Unlock()
+
+	var turns []types.ConversationTurn
+	for _, turn := range m.conversation {
+		turns = append(turns, turn.turn)
+	}
+	return turns
+}
+
+// addTurn records a request and the command generated for it
+func (m *Manager) addTurn(input, commandID, command string) {
+	m.conversationMutex.Lock()
+	defer m.conversationMutex.Unlock()
+
+	m.conversation = append(m.conversation, &conversationTurn{
+		commandID: commandID,
+		turn:      types.ConversationTurn{Input: input, Command: command},
+	})
+	if len(m.conversation) > maxConversationTurns {
+		m.conversation = m.conversation[len(m.conversation)-maxConversationTurns:]
+	}
+}
+
+// recordTurnResult records the outcome of an executed command on its turn. A
+// command generated outside a turn, such as a correction or a plan step, updates
+// the latest turn when it was run for the same request.
+func (m *Manager) recordTurnResult(result *types.ExecutionResult) {
+	if result == nil || result.Command == nil {
+		return
+	}
+
+	m.conversationMutex.Lock()
+	defer m.conversationMutex.Unlock()
+
+	var current *conversationTurn
+	for _, turn := range m.conversation {
+		if turn.commandID == result.Command.ID {
+			current = turn
+		}
+	}
+	if current == nil && len(m.conversation) > 0 {
+		if last := m.conversation[len(m.conversation)-1]; last.turn.Input == result.Command.Original {
+			current = last
+			current.commandID = result.Command.ID
+			current.turn.Command = result.Command.Generated
+		}
+	}
+	if current == nil {
+		return
+	}
+
+	exitCode := result.ExitCode
+	current.turn.ExitCode = &exitCode
+	current.turn.Output = truncateTurnOutput(result)
+}
+
+// truncateTurnOutput returns the start of a command's output, within maxTurnOutput bytes
+func truncateTurnOutput(result *types.ExecutionResult) string {
+	output := strings.TrimSpace(result.Stdout)
+	if stderr := strings.TrimSpace(result.Stderr); stderr != "" {
+		if output != "" {
+			output += "\n"
+		}
+		output += stderr
+	}
+
+	if len(output) <= maxTurnOutput {
+		return output
+	}
+	cut := maxTurnOutput
+	for cut > 0 && !utf8.RuneStart(output[cut]) {
+		cut--
+	}
+	return output[:cut] + "\n... (truncated)"
+}
@@ -0,0 +1,111 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/interfaces"
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+// jobOutputLimit is how much of the end of a job's output its result is validated with
+const jobOutputLimit = 16 * 1024
+
+// SetJobManager lets commands start as background jobs managed by jobs
+func (m *Manager) SetJobManager(jobs interfaces.JobManager) {
+	m.jobs = jobs
+}
+
+// backgroundCommand makes cmd start as a background job when options ask for it
+func backgroundCommand(cmd *types.Command, options *types.ExecutionOptions) {
+	if options != nil && options.Background {
+		cmd.Background = true
+	}
+}
+
+// startJob starts cmd as a background job, returning a result for the job
+// rather than for the command
+func (m *Manager) startJob(cmd *types.Command) (*types.ExecutionResult, error) {
+	var reason string
+	switch {
+	case m.jobs == nil:
+		reason = "background jobs are not available"
+	case cmd.Interactive:
+		reason = "interactive commands cannot run in the background"
+	case cmd.Sandbox:
+		reason = "sandboxed commands cannot run in the background"
+	}
+	if reason != "" {
+		return nil, &types.NLShellError{
+			Type:    types.ErrTypeExecution,
+			Message: reason,
+			Context: map[string]interface{}{
+				"command": cmd.Generated,
+			},
+		}
+	}
+
+	// Background jobs run until they finish, so the default timeout does not apply
+	background := *cmd
+	background.Timeout = 0
+
+	job, err := m.jobs.Start(&background)
+	if err != nil {
+		m.recordAudit(cmd, types.AuditActionExecuted, cmd.DangerLevel, fmt.Sprintf("background job failed to start: %v", err), nil)
+		return nil, err
+	}
+	m.recordAudit(cmd, types.AuditActionExecuted, cmd.DangerLevel, fmt.Sprintf("started as background job %d", job.ID), nil)
+
+	return &types.ExecutionResult{
+		Command: cmd,
+		Success: true,
+		Job:     job,
+	}, nil
+}
+
+// ValidateJobs validates the results of background jobs that have finished
+// since they were last checked, recording the verdicts in the jobs. It returns
+// the jobs it validated. Jobs that were killed or lost have no result to validate.
+func (m *Manager) ValidateJobs(ctx context.Context) ([]*types.Job, error) {
+	if m.jobs == nil {
+		return nil, nil
+	}
+	jobs, err := m.jobs.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var validated []*types.Job
+	for _, job := range jobs {
+		if job.Validation != nil || (job.Status != types.JobSucceeded && job.Status != types.JobFailed) {
+			continue
+		}
+
+		output, err := m.jobs.Output(job, jobOutputLimit)
+		if err != nil {
+			return validated, err
+		}
+		result := &types.ExecutionResult{
+			Command:  job.Command,
+			ExitCode: job.ExitCode,
+			Stdout:   output,
+			Duration: job.FinishedAt.Sub(job.StartedAt),
+			Success:  job.Status == types.JobSucceeded,
+		}
+
+		validation, err := m.ValidateResult(ctx, result, job.Command.Original)
+		if err != nil {
+			// Like a command's, the job's result counts as checked even when validation fails
+			validation = &types.ValidationResult{
+				IsCorrect:   false,
+				Explanation: fmt.Sprintf("Validation failed: %v", err),
+			}
+		}
+		job.Validation = validation
+		if err := m.jobs.Update(job); err != nil {
+			return validated, err
+		}
+		validated = append(validated, job)
+	}
+	return validated, nil
+}
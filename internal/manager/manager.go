@@ -3,8 +3,13 @@ package manager
 import (
 	"context"
 	"fmt"
+	"io"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/kanishka-sahoo/nl-to-shell/internal/errors"
+	"github.com/kanishka-sahoo/nl-to-shell/internal/executor"
 	"github.com/kanishka-sahoo/nl-to-shell/internal/interfaces"
 	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
 )
@@ -17,6 +22,26 @@ type Manager struct {
 	executor        interfaces.CommandExecutor
 	resultValidator interfaces.ResultValidator
 	config          *types.Config
+
+	// Audit trail; auditLogger is nil when auditing is disabled
+	auditLogger types.AuditLogger
+	sessionID   string
+	userID      string
+	auditMutex  sync.Mutex
+
+	// Turns of the conversation through this manager, oldest first, passed to the provider as context
+	conversation      []*conversationTurn
+	conversationMutex sync.Mutex
+
+	// Receives responses of streaming providers as they are generated
+	streamHandler types.StreamHandler
+
+	// Directory and environment left by executed commands; nil when not tracked
+	shellState      *types.ShellState
+	shellStateMutex sync.Mutex
+
+	// Starts commands as background jobs; nil when they are not available
+	jobs interfaces.JobManager
 }
 
 // NewManager creates a new command manager with the provided dependencies
@@ -38,10 +63,27 @@ func NewManager(
 	}
 }
 
+// SetAuditLogger records every generated, confirmed, bypassed, blocked and
+// executed command to logger, tagged with the given session and user
+func (m *Manager) SetAuditLogger(logger types.AuditLogger, sessionID, userID string) {
+	m.auditMutex.Lock()
+	defer m.auditMutex.Unlock()
+
+	m.auditLogger = logger
+	m.sessionID = sessionID
+	m.userID = userID
+}
+
+// SetStreamHandler passes command responses to handler as they are generated,
+// when the provider supports streaming
+func (m *Manager) SetStreamHandler(handler types.StreamHandler) {
+	m.streamHandler = handler
+}
+
 // GenerateCommand implements the main command generation pipeline
 func (m *Manager) GenerateCommand(ctx context.Context, input string) (*types.CommandResult, error) {
 	// Step 1: Gather context
-	context, err := m.contextGatherer.GatherContext(ctx)
+	context, err := m.gatherContext(ctx)
 	if err != nil {
 		return nil, &types.NLShellError{
 			Type:    types.ErrTypeValidation,
@@ -53,8 +95,10 @@ func (m *Manager) GenerateCommand(ctx context.Context, input string) (*types.Com
 		}
 	}
 
+	context.Conversation = m.conversationContext()
+
 	// Step 2: Generate command using LLM
-	response, err := m.llmProvider.GenerateCommand(ctx, input, context)
+	response, err := m.generateResponse(ctx, input, context)
 	if err != nil {
 		return nil, &types.NLShellError{
 			Type:    types.ErrTypeProvider,
@@ -67,19 +111,77 @@ func (m *Manager) GenerateCommand(ctx context.Context, input string) (*types.Com
 		}
 	}
 
-	// Step 3: Create command object
+	// Steps 3-5: Create, validate and return the command
+	result, err := m.newCommandResult(input, response.Command, response.Interactive, context, "")
+	if err != nil {
+		return nil, err
+	}
+	result.Confidence = response.Confidence
+	result.Alternatives = response.Alternatives
+	result.PromptTokens = response.PromptTokens
+	result.Provider = response.Provider
+	result.Model = response.Model
+	result.Usage = response.Usage
+	if result.Provider == "" {
+		result.Provider = m.llmProvider.GetProviderInfo().Name
+	}
+	m.addTurn(input, result.Command.ID, result.Command.Generated)
+
+	return result, nil
+}
+
+// generateResponse asks the provider for a command, streaming the response to
+// the stream handler when one is set and the provider supports it
+func (m *Manager) generateResponse(ctx context.Context, input string, context *types.Context) (*types.CommandResponse, error) {
+	streaming, ok := m.llmProvider.(interfaces.StreamingLLMProvider)
+	if !ok || m.streamHandler == nil {
+		return m.llmProvider.GenerateCommand(ctx, input, context)
+	}
+
+	defer m.streamHandler.Done()
+	return streaming.GenerateCommandStream(ctx, input, context, m.streamHandler.Text)
+}
+
+// PrepareCommand builds and safety-checks a known command for input without
+// calling the provider, as when a command is run again from the history
+func (m *Manager) PrepareCommand(ctx context.Context, input, generated string) (*types.CommandResult, error) {
+	context, err := m.gatherContext(ctx)
+	if err != nil {
+		return nil, &types.NLShellError{
+			Type:    types.ErrTypeValidation,
+			Message: "failed to gather context",
+			Cause:   err,
+			Context: map[string]interface{}{
+				"input": input,
+			},
+		}
+	}
+
+	return m.newCommandResult(input, generated, false, context, "rerun from history")
+}
+
+// newCommandResult creates the command for generated, validates its safety in
+// context and audits it as generated
+func (m *Manager) newCommandResult(input, generated string, interactive bool, context *types.Context, reason string) (*types.CommandResult, error) {
 	command := &types.Command{
 		ID:          generateCommandID(),
 		Original:    input,
-		Generated:   response.Command,
+		Generated:   generated,
 		Context:     context,
 		Timestamp:   time.Now(),
 		WorkingDir:  context.WorkingDirectory,
 		Environment: context.Environment,
 		Timeout:     m.getCommandTimeout(),
+		Shell:       m.getCommandShell(context),
+		Limits:      m.getCommandLimits(),
+		Interactive: interactive || executor.IsInteractiveCommand(generated),
+	}
+	if command.Interactive {
+		// Interactive commands wait on the user, so the default timeout does not apply
+		command.Timeout = 0
 	}
+	m.applyShellState(command)
 
-	// Step 4: Validate command safety
 	safetyResult, err := m.safetyValidator.ValidateCommand(command)
 	if err != nil {
 		return nil, &types.NLShellError{
@@ -94,20 +196,53 @@ func (m *Manager) GenerateCommand(ctx context.Context, input string) (*types.Com
 
 	// Mark command as validated
 	command.Validated = safetyResult.IsSafe
+	command.DangerLevel = safetyResult.DangerLevel
+	auditReason := joinWarnings(safetyResult)
+	if reason != "" {
+		auditReason = strings.TrimSuffix(reason+"; "+auditReason, "; ")
+	}
+	m.recordAudit(command, types.AuditActionGenerated, safetyResult.DangerLevel, auditReason, nil)
 
-	// Step 5: Create and return command result
-	result := &types.CommandResult{
-		Command:      command,
-		Safety:       safetyResult,
-		Confidence:   response.Confidence,
-		Alternatives: response.Alternatives,
+	return &types.CommandResult{
+		Command: command,
+		Safety:  safetyResult,
+	}, nil
+}
+
+// DryRun analyzes a command without executing it. A sandboxed command is run
+// in a sandbox first to predict the files it would change.
+func (m *Manager) DryRun(ctx context.Context, cmd *types.Command) (*types.DryRunResult, error) {
+	dryRunResult, err := m.executor.DryRun(cmd)
+	if err != nil {
+		return nil, &types.NLShellError{
+			Type:    types.ErrTypeExecution,
+			Message: "failed to perform dry run",
+			Cause:   err,
+		}
 	}
+	return dryRunResult, nil
+}
 
-	return result, nil
+// previewCommand dry runs a generated command. A command that requires
+// confirmation is run in the sandbox to predict its changes only when the user
+// skipped confirmation; a dry run never asks for it.
+func (m *Manager) previewCommand(ctx context.Context, commandResult *types.CommandResult, options *types.ExecutionOptions) (*types.DryRunResult, error) {
+	if commandResult.Safety.RequiresConfirmation && !commandResult.Command.Validated && options.SkipConfirmation {
+		if err := m.BypassConfirmation(commandResult); err != nil {
+			return nil, err
+		}
+	}
+	return m.DryRun(ctx, commandResult.Command)
 }
 
 // ExecuteCommand executes a validated command
 func (m *Manager) ExecuteCommand(ctx context.Context, cmd *types.Command) (*types.ExecutionResult, error) {
+	return m.ExecuteCommandStreaming(ctx, cmd, nil, nil)
+}
+
+// ExecuteCommandStreaming executes a validated command, writing its output to
+// the given writers while it runs
+func (m *Manager) ExecuteCommandStreaming(ctx context.Context, cmd *types.Command, stdout, stderr io.Writer) (*types.ExecutionResult, error) {
 	if !cmd.Validated {
 		return nil, &types.NLShellError{
 			Type:    types.ErrTypeValidation,
@@ -118,9 +253,14 @@ func (m *Manager) ExecuteCommand(ctx context.Context, cmd *types.Command) (*type
 		}
 	}
 
+	if cmd.Background {
+		return m.startJob(cmd)
+	}
+
 	// Execute the command
-	result, err := m.executor.Execute(ctx, cmd)
+	result, err := m.executor.ExecuteStreaming(ctx, cmd, stdout, stderr)
 	if err != nil {
+		m.recordAudit(cmd, types.AuditActionExecuted, cmd.DangerLevel, fmt.Sprintf("execution failed: %v", err), nil)
 		return nil, &types.NLShellError{
 			Type:    types.ErrTypeExecution,
 			Message: "failed to execute command",
@@ -130,10 +270,56 @@ func (m *Manager) ExecuteCommand(ctx context.Context, cmd *types.Command) (*type
 			},
 		}
 	}
+	exitCode := result.ExitCode
+	m.recordAudit(cmd, types.AuditActionExecuted, cmd.DangerLevel, "", &exitCode)
+	m.recordTurnResult(result)
+	m.updateShellState(result)
 
 	return result, nil
 }
 
+// RecordConfirmation records the user's answer to a confirmation prompt,
+// marking the command as validated when it was confirmed
+func (m *Manager) RecordConfirmation(result *types.CommandResult, confirmed bool) {
+	if confirmed {
+		result.Command.Validated = true
+		m.recordAudit(result.Command, types.AuditActionConfirmed, result.Safety.DangerLevel, "confirmed by user", nil)
+		return
+	}
+	m.recordAudit(result.Command, types.AuditActionBlocked, result.Safety.DangerLevel, "cancelled by user", nil)
+}
+
+// BypassConfirmation skips the confirmation a command requires, revalidating
+// it with bypass options so the bypass is audited
+func (m *Manager) BypassConfirmation(result *types.CommandResult) error {
+	m.auditMutex.Lock()
+	opts := &types.ValidationOptions{
+		SkipConfirmation: true,
+		BypassLevel:      types.Critical,
+		AuditLogger:      m.auditLogger,
+		UserID:           m.userID,
+		SessionID:        m.sessionID,
+		Reason:           "confirmation skipped",
+	}
+	m.auditMutex.Unlock()
+
+	safetyResult, err := m.safetyValidator.ValidateCommandWithOptions(result.Command, opts)
+	if err != nil {
+		return &types.NLShellError{
+			Type:    types.ErrTypeValidation,
+			Message: "failed to validate command safety",
+			Cause:   err,
+			Context: map[string]interface{}{
+				"command": result.Command.Generated,
+			},
+		}
+	}
+
+	result.Safety = safetyResult
+	result.Command.Validated = true
+	return nil
+}
+
 // ValidateResult validates the execution result using AI
 func (m *Manager) ValidateResult(ctx context.Context, result *types.ExecutionResult, intent string) (*types.ValidationResult, error) {
 	validation, err := m.resultValidator.ValidateResult(ctx, result, intent)
@@ -161,15 +347,20 @@ func (m *Manager) GenerateAndExecute(ctx context.Context, input string, options
 		return nil, err
 	}
 
+	return m.ExecuteResult(ctx, commandResult, input, options)
+}
+
+// ExecuteResult runs a generated or prepared command through the rest of the
+// pipeline: dry run, confirmation, execution and result validation
+func (m *Manager) ExecuteResult(ctx context.Context, commandResult *types.CommandResult, input string, options *types.ExecutionOptions) (*types.FullResult, error) {
+	sandboxCommand(commandResult.Command, options)
+	backgroundCommand(commandResult.Command, options)
+
 	// Step 2: Check if we should skip execution (dry run)
 	if options != nil && options.DryRun {
-		dryRunResult, err := m.executor.DryRun(commandResult.Command)
+		dryRunResult, err := m.previewCommand(ctx, commandResult, options)
 		if err != nil {
-			return nil, &types.NLShellError{
-				Type:    types.ErrTypeExecution,
-				Message: "failed to perform dry run",
-				Cause:   err,
-			}
+			return nil, err
 		}
 
 		return &types.FullResult{
@@ -180,27 +371,46 @@ func (m *Manager) GenerateAndExecute(ctx context.Context, input string, options
 
 	// Step 3: Check safety requirements
 	if commandResult.Safety.RequiresConfirmation && (options == nil || !options.SkipConfirmation) {
+		m.recordAudit(commandResult.Command, types.AuditActionBlocked, commandResult.Safety.DangerLevel, "confirmation required", nil)
 		return &types.FullResult{
 			CommandResult:        commandResult,
 			RequiresConfirmation: true,
 		}, nil
 	}
 
-	// If command is not safe and we're not skipping confirmation, we need to mark it as validated
-	// when the user explicitly skips confirmation (this simulates user approval)
-	if !commandResult.Command.Validated && options != nil && options.SkipConfirmation {
+	// The user explicitly skipped confirmation: bypass it (this simulates user approval)
+	// and mark unsafe commands as validated
+	if commandResult.Safety.RequiresConfirmation {
+		if err := m.BypassConfirmation(commandResult); err != nil {
+			return nil, err
+		}
+	} else if !commandResult.Command.Validated && options != nil && options.SkipConfirmation {
 		commandResult.Command.Validated = true
 	}
 
-	// Step 4: Execute command
-	executionResult, err := m.ExecuteCommand(ctx, commandResult.Command)
+	// Step 4: Execute command, streaming output if the caller asked for it
+	var stdout, stderr io.Writer
+	if options != nil {
+		stdout, stderr = options.Stdout, options.Stderr
+	}
+	executionResult, err := m.ExecuteCommandStreaming(ctx, commandResult.Command, stdout, stderr)
 	if err != nil {
 		return nil, err
 	}
+	if executionResult.Job != nil {
+		// A background job's result is validated once it finishes
+		return &types.FullResult{
+			CommandResult:   commandResult,
+			ExecutionResult: executionResult,
+		}, nil
+	}
+	if _, err := settleChanges(executionResult, options); err != nil {
+		return nil, err
+	}
 
 	// Step 5: Validate results if requested
 	var validationResult *types.ValidationResult
-	if options == nil || options.ValidateResults {
+	if options == nil || options.ValidateResults || options.MaxCorrections > 0 {
 		validationResult, err = m.ValidateResult(ctx, executionResult, input)
 		if err != nil {
 			// Don't fail the entire operation if validation fails
@@ -212,15 +422,138 @@ func (m *Manager) GenerateAndExecute(ctx context.Context, input string, options
 		}
 	}
 
-	return &types.FullResult{
+	result := &types.FullResult{
 		CommandResult:    commandResult,
 		ExecutionResult:  executionResult,
 		ValidationResult: validationResult,
-	}, nil
+	}
+
+	// Step 6: Retry with the validator's corrections if enabled
+	if options != nil && options.MaxCorrections > 0 {
+		return m.CorrectResult(ctx, result, input, options)
+	}
+	return result, nil
+}
+
+// CorrectResult runs the corrections the result validator suggests for an executed
+// command, up to options.MaxCorrections times, until a command achieves the intent.
+// Each correction is safety-checked and must be approved by options.ConfirmCorrection.
+// The returned result describes the last attempt and lists every attempt.
+func (m *Manager) CorrectResult(ctx context.Context, result *types.FullResult, input string, options *types.ExecutionOptions) (*types.FullResult, error) {
+	if result.ExecutionResult == nil {
+		return result, nil
+	}
+
+	attempts := []*types.ExecutionAttempt{{
+		CommandResult:    result.CommandResult,
+		ExecutionResult:  result.ExecutionResult,
+		ValidationResult: result.ValidationResult,
+	}}
+	tried := map[string]bool{strings.TrimSpace(result.CommandResult.Command.Generated): true}
+
+	for i := 0; i < options.MaxCorrections; i++ {
+		// A command the user interrupted did not fail, so there is nothing to correct
+		validation := result.ValidationResult
+		if validation == nil || validation.IsCorrect || result.ExecutionResult.Interrupted {
+			break
+		}
+		correction := strings.TrimSpace(validation.CorrectedCommand)
+		if correction == "" || tried[correction] {
+			break
+		}
+		tried[correction] = true
+
+		previous := result.CommandResult.Command
+		commandResult, err := m.newCommandResult(input, correction, false, previous.Context,
+			fmt.Sprintf("correction %d of %s", i+1, previous.ID))
+		if err != nil {
+			return nil, err
+		}
+		// The correction was suggested by the provider that validated the result
+		commandResult.Provider = result.CommandResult.Provider
+		commandResult.Model = result.CommandResult.Model
+
+		attempt := &types.ExecutionAttempt{CommandResult: commandResult}
+		attempts = append(attempts, attempt)
+
+		// The user approves each correction, which also confirms a dangerous one
+		if options.ConfirmCorrection == nil || !options.ConfirmCorrection(commandResult, validation) {
+			attempt.Declined = true
+			m.recordAudit(commandResult.Command, types.AuditActionBlocked, commandResult.Safety.DangerLevel, "correction declined", nil)
+			break
+		}
+		if commandResult.Safety.RequiresConfirmation {
+			m.RecordConfirmation(commandResult, true)
+		}
+		commandResult.Command.Validated = true
+		sandboxCommand(commandResult.Command, options)
+
+		executionResult, err := m.ExecuteCommandStreaming(ctx, commandResult.Command, options.Stdout, options.Stderr)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := settleChanges(executionResult, options); err != nil {
+			return nil, err
+		}
+		attempt.ExecutionResult = executionResult
+
+		validationResult, err := m.ValidateResult(ctx, executionResult, input)
+		if err != nil {
+			validationResult = &types.ValidationResult{
+				IsCorrect:   false,
+				Explanation: fmt.Sprintf("Validation failed: %v", err),
+			}
+		}
+		attempt.ValidationResult = validationResult
+
+		result = &types.FullResult{
+			CommandResult:    commandResult,
+			ExecutionResult:  executionResult,
+			ValidationResult: validationResult,
+		}
+	}
+
+	result.Attempts = attempts
+	return result, nil
 }
 
 // Helper functions
 
+// recordAudit writes an audit entry for cmd when auditing is enabled. Failures
+// are logged rather than returned so auditing never blocks a command.
+func (m *Manager) recordAudit(cmd *types.Command, action types.AuditAction, level types.DangerLevel, reason string, exitCode *int) {
+	m.auditMutex.Lock()
+	logger := m.auditLogger
+	if logger == nil {
+		m.auditMutex.Unlock()
+		return
+	}
+	entry := &types.AuditEntry{
+		Timestamp:   time.Now(),
+		Command:     cmd.Generated,
+		UserID:      m.userID,
+		Action:      action,
+		DangerLevel: level,
+		Reason:      reason,
+		SessionID:   m.sessionID,
+		CommandID:   cmd.ID,
+		ExitCode:    exitCode,
+	}
+	m.auditMutex.Unlock()
+
+	if err := logger.LogAuditEvent(entry); err != nil {
+		errors.GetGlobalLogger().LogError(errors.NewSafetyError("failed to write audit entry", err))
+	}
+}
+
+// joinWarnings summarizes safety warnings as an audit reason
+func joinWarnings(result *types.SafetyResult) string {
+	if result == nil {
+		return ""
+	}
+	return strings.Join(result.Warnings, "; ")
+}
+
 func generateCommandID() string {
 	return fmt.Sprintf("cmd_%d", time.Now().UnixNano())
 }
@@ -231,3 +564,30 @@ func (m *Manager) getCommandTimeout() time.Duration {
 	}
 	return 30 * time.Second // Default timeout
 }
+
+// getCommandLimits returns the resource limits from the user preferences
+func (m *Manager) getCommandLimits() types.ResourceLimits {
+	if m.config == nil {
+		return types.ResourceLimits{}
+	}
+	return m.config.UserPreferences.Limits
+}
+
+// getCommandShell returns the shell commands should run in: the configured shell,
+// then the shell detected by the environment plugin, then the user's $SHELL
+func (m *Manager) getCommandShell(ctx *types.Context) string {
+	if m.config != nil && m.config.UserPreferences.Shell != "" {
+		return m.config.UserPreferences.Shell
+	}
+	if ctx == nil {
+		return ""
+	}
+	if envData, ok := ctx.PluginData["environment"].(map[string]interface{}); ok {
+		if shellInfo, ok := envData["shell"].(map[string]interface{}); ok {
+			if name, ok := shellInfo["name"].(string); ok && name != "" {
+				return name
+			}
+		}
+	}
+	return ctx.Environment["SHELL"]
+}
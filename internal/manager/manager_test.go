@@ -3,10 +3,16 @@ package manager
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
+	"unicode/utf8"
 
 	"github.com/kanishka-sahoo/nl-to-shell/internal/interfaces"
+	"github.com/kanishka-sahoo/nl-to-shell/internal/safety"
 	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
 )
 
@@ -35,11 +41,13 @@ func (m *mockContextGatherer) RegisterPlugin(plugin interfaces.ContextPlugin) er
 }
 
 type mockLLMProvider struct {
-	response *types.CommandResponse
-	err      error
+	response    *types.CommandResponse
+	err         error
+	lastContext *types.Context
 }
 
 func (m *mockLLMProvider) GenerateCommand(ctx context.Context, prompt string, context *types.Context) (*types.CommandResponse, error) {
+	m.lastContext = context
 	if m.err != nil {
 		return nil, m.err
 	}
@@ -104,6 +112,10 @@ type mockExecutor struct {
 }
 
 func (m *mockExecutor) Execute(ctx context.Context, cmd *types.Command) (*types.ExecutionResult, error) {
+	return m.ExecuteStreaming(ctx, cmd, nil, nil)
+}
+
+func (m *mockExecutor) ExecuteStreaming(ctx context.Context, cmd *types.Command, stdout, stderr io.Writer) (*types.ExecutionResult, error) {
 	if m.err != nil {
 		return nil, m.err
 	}
@@ -468,3 +480,879 @@ func TestManager_GetCommandTimeout(t *testing.T) {
 		})
 	}
 }
+
+func TestManager_GetCommandShell(t *testing.T) {
+	detected := &types.Context{
+		Environment: map[string]string{"SHELL": "/bin/bash"},
+		PluginData: map[string]interface{}{
+			"environment": map[string]interface{}{
+				"shell": map[string]interface{}{"name": "zsh"},
+			},
+		},
+	}
+
+	tests := []struct {
+		name          string
+		config        *types.Config
+		context       *types.Context
+		expectedShell string
+	}{
+		{
+			name:          "nil context and config",
+			expectedShell: "",
+		},
+		{
+			name: "configured shell wins",
+			config: &types.Config{
+				UserPreferences: types.UserPreferences{Shell: "fish"},
+			},
+			context:       detected,
+			expectedShell: "fish",
+		},
+		{
+			name:          "detected shell from environment plugin",
+			context:       detected,
+			expectedShell: "zsh",
+		},
+		{
+			name: "falls back to SHELL variable",
+			context: &types.Context{
+				Environment: map[string]string{"SHELL": "/bin/bash"},
+			},
+			expectedShell: "/bin/bash",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := &Manager{config: tt.config}
+			if shell := manager.getCommandShell(tt.context); shell != tt.expectedShell {
+				t.Errorf("expected shell %q, got %q", tt.expectedShell, shell)
+			}
+		})
+	}
+}
+
+type recordingExecutor struct {
+	mockExecutor
+	stdout io.Writer
+	stderr io.Writer
+}
+
+func (r *recordingExecutor) ExecuteStreaming(ctx context.Context, cmd *types.Command, stdout, stderr io.Writer) (*types.ExecutionResult, error) {
+	r.stdout, r.stderr = stdout, stderr
+	return r.mockExecutor.ExecuteStreaming(ctx, cmd, stdout, stderr)
+}
+
+func TestManager_GenerateAndExecute_StreamsOutput(t *testing.T) {
+	executor := &recordingExecutor{}
+	manager := NewManager(
+		&mockContextGatherer{},
+		&mockLLMProvider{},
+		&mockSafetyValidator{result: &types.SafetyResult{IsSafe: true, DangerLevel: types.Safe}},
+		executor,
+		&mockResultValidator{},
+		nil,
+	)
+
+	var stdout, stderr strings.Builder
+	_, err := manager.GenerateAndExecute(context.Background(), "list files", &types.ExecutionOptions{
+		Stdout: &stdout,
+		Stderr: &stderr,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if executor.stdout != &stdout || executor.stderr != &stderr {
+		t.Error("expected the option writers to be passed to the executor")
+	}
+}
+
+// fakePendingChanges records whether sandboxed changes were applied or discarded
+type fakePendingChanges struct {
+	applied, discarded bool
+}
+
+func (f *fakePendingChanges) Apply() error {
+	f.applied = true
+	return nil
+}
+
+func (f *fakePendingChanges) Discard() error {
+	f.discarded = true
+	return nil
+}
+
+// sandboxExecutor reports a change for every command run in the sandbox
+type sandboxExecutor struct {
+	sandboxed bool
+	validated bool
+	pending   *fakePendingChanges
+}
+
+func (s *sandboxExecutor) Execute(ctx context.Context, cmd *types.Command) (*types.ExecutionResult, error) {
+	return s.ExecuteStreaming(ctx, cmd, nil, nil)
+}
+
+func (s *sandboxExecutor) ExecuteStreaming(ctx context.Context, cmd *types.Command, stdout, stderr io.Writer) (*types.ExecutionResult, error) {
+	s.sandboxed = cmd.Sandbox
+	s.pending = &fakePendingChanges{}
+	return &types.ExecutionResult{
+		Command:   cmd,
+		Success:   true,
+		Sandboxed: true,
+		Changes:   []types.FileChange{{Path: "out.txt", Kind: types.FileCreated}},
+		Pending:   s.pending,
+	}, nil
+}
+
+func (s *sandboxExecutor) DryRun(cmd *types.Command) (*types.DryRunResult, error) {
+	s.sandboxed = cmd.Sandbox
+	s.validated = cmd.Validated
+	return &types.DryRunResult{Command: cmd}, nil
+}
+
+func TestManager_GenerateAndExecute_Sandbox(t *testing.T) {
+	tests := []struct {
+		name        string
+		options     *types.ExecutionOptions
+		wantApplied bool
+	}{
+		{"applied when approved", &types.ExecutionOptions{ApplyChanges: func(*types.ExecutionResult) bool { return true }}, true},
+		{"discarded when declined", &types.ExecutionOptions{ApplyChanges: func(*types.ExecutionResult) bool { return false }}, false},
+		{"applied when confirmation is skipped", &types.ExecutionOptions{SkipConfirmation: true}, true},
+		{"discarded without approval", &types.ExecutionOptions{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			executor := &sandboxExecutor{}
+			manager := NewManager(
+				&mockContextGatherer{},
+				&mockLLMProvider{},
+				&mockSafetyValidator{result: &types.SafetyResult{IsSafe: true, DangerLevel: types.Safe}},
+				executor,
+				&mockResultValidator{},
+				nil,
+			)
+
+			tt.options.Sandbox = true
+			result, err := manager.GenerateAndExecute(context.Background(), "write a file", tt.options)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !executor.sandboxed {
+				t.Error("expected the command to run in the sandbox")
+			}
+			if executor.pending.applied != tt.wantApplied || executor.pending.discarded == tt.wantApplied {
+				t.Errorf("expected applied %v, got %+v", tt.wantApplied, executor.pending)
+			}
+			if result.ExecutionResult.ChangesApplied != tt.wantApplied || result.ExecutionResult.Pending != nil {
+				t.Errorf("expected the result to record applied %v with nothing pending, got %+v", tt.wantApplied, result.ExecutionResult)
+			}
+		})
+	}
+
+	// Dry runs ask the executor to predict changes in the sandbox
+	executor := &sandboxExecutor{}
+	manager := NewManager(&mockContextGatherer{}, &mockLLMProvider{}, &mockSafetyValidator{}, executor, &mockResultValidator{}, nil)
+	if _, err := manager.GenerateAndExecute(context.Background(), "write a file", &types.ExecutionOptions{DryRun: true, Sandbox: true}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !executor.sandboxed {
+		t.Error("expected the dry run to use the sandbox")
+	}
+
+	// A command that requires confirmation is not cleared to run in the
+	// sandbox unless confirmation is skipped
+	dangerous := &mockSafetyValidator{result: &types.SafetyResult{DangerLevel: types.Dangerous, RequiresConfirmation: true}}
+	for _, skip := range []bool{false, true} {
+		executor := &sandboxExecutor{}
+		manager := NewManager(&mockContextGatherer{}, &mockLLMProvider{}, dangerous, executor, &mockResultValidator{}, nil)
+		result, err := manager.GenerateAndExecute(context.Background(), "remove everything", &types.ExecutionOptions{DryRun: true, Sandbox: true, SkipConfirmation: skip})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result.DryRunResult == nil || executor.validated != skip {
+			t.Errorf("skip confirmation %v: expected a dry run with the command validated %v, got %v", skip, skip, executor.validated)
+		}
+	}
+}
+
+// fakeJobManager records started jobs in memory
+type fakeJobManager struct {
+	jobs   []*types.Job
+	output string
+}
+
+func (f *fakeJobManager) Start(cmd *types.Command) (*types.Job, error) {
+	job := &types.Job{ID: len(f.jobs) + 1, Command: cmd, Status: types.JobRunning, StartedAt: time.Now()}
+	f.jobs = append(f.jobs, job)
+	return job, nil
+}
+
+func (f *fakeJobManager) List() ([]*types.Job, error) { return f.jobs, nil }
+
+func (f *fakeJobManager) Update(job *types.Job) error { return nil }
+
+func (f *fakeJobManager) Output(job *types.Job, limit int64) (string, error) { return f.output, nil }
+
+// intentValidator records the intents it validates results against
+type intentValidator struct {
+	intents []string
+}
+
+func (v *intentValidator) ValidateResult(ctx context.Context, result *types.ExecutionResult, intent string) (*types.ValidationResult, error) {
+	v.intents = append(v.intents, intent+": "+result.Stdout)
+	return &types.ValidationResult{IsCorrect: result.Success}, nil
+}
+
+func TestManager_GenerateAndExecute_Background(t *testing.T) {
+	executor := &mockExecutor{err: errors.New("the command should not run in the foreground")}
+	validator := &intentValidator{}
+	jobs := &fakeJobManager{output: "built"}
+	manager := NewManager(
+		&mockContextGatherer{},
+		&mockLLMProvider{response: &types.CommandResponse{Command: "make"}},
+		&mockSafetyValidator{result: &types.SafetyResult{IsSafe: true, DangerLevel: types.Safe}},
+		executor,
+		validator,
+		nil,
+	)
+
+	// Without a job manager, background jobs are refused
+	if _, err := manager.GenerateAndExecute(context.Background(), "build it", &types.ExecutionOptions{Background: true}); err == nil {
+		t.Error("expected an error without a job manager")
+	}
+
+	manager.SetJobManager(jobs)
+	result, err := manager.GenerateAndExecute(context.Background(), "build it", &types.ExecutionOptions{Background: true, ValidateResults: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.ExecutionResult.Job == nil || len(jobs.jobs) != 1 {
+		t.Fatalf("expected the command to start as a job, got %+v", result.ExecutionResult)
+	}
+	if started := jobs.jobs[0].Command; started.Timeout != 0 || !started.Background {
+		t.Errorf("expected the job to run without the default timeout, got %+v", started)
+	}
+	if result.ValidationResult != nil || len(validator.intents) != 0 {
+		t.Error("expected a running job not to be validated")
+	}
+
+	// Jobs are validated once, after they finish
+	if validated, err := manager.ValidateJobs(context.Background()); err != nil || len(validated) != 0 {
+		t.Errorf("expected nothing to validate while the job runs, got %v, %v", validated, err)
+	}
+	job := jobs.jobs[0]
+	job.Status = types.JobSucceeded
+	job.FinishedAt = time.Now()
+	validated, err := manager.ValidateJobs(context.Background())
+	if err != nil || len(validated) != 1 || job.Validation == nil || !job.Validation.IsCorrect {
+		t.Fatalf("expected the finished job to be validated, got %v, %v", validated, err)
+	}
+	if len(validator.intents) != 1 || validator.intents[0] != "build it: built" {
+		t.Errorf("expected the job validated against its request and output, got %v", validator.intents)
+	}
+	if validated, _ := manager.ValidateJobs(context.Background()); len(validated) != 0 {
+		t.Error("expected the job to be validated only once")
+	}
+}
+
+func TestManager_GenerateCommand_MarksInteractive(t *testing.T) {
+	tests := []struct {
+		name        string
+		response    *types.CommandResponse
+		interactive bool
+	}{
+		{
+			name:     "plain command",
+			response: &types.CommandResponse{Command: "ls -la"},
+		},
+		{
+			name:        "detected pager",
+			response:    &types.CommandResponse{Command: "git log --oneline"},
+			interactive: true,
+		},
+		{
+			name:        "model hint",
+			response:    &types.CommandResponse{Command: "./setup.sh", Interactive: true},
+			interactive: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := NewManager(
+				&mockContextGatherer{},
+				&mockLLMProvider{response: tt.response},
+				&mockSafetyValidator{},
+				&mockExecutor{},
+				&mockResultValidator{},
+				&types.Config{UserPreferences: types.UserPreferences{DefaultTimeout: 30 * time.Second}},
+			)
+
+			result, err := manager.GenerateCommand(context.Background(), "do something")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if result.Command.Interactive != tt.interactive {
+				t.Errorf("expected interactive %v, got %v", tt.interactive, result.Command.Interactive)
+			}
+
+			// Interactive commands wait on the user and have no default timeout
+			if tt.interactive && result.Command.Timeout != 0 {
+				t.Errorf("expected no timeout for interactive command, got %v", result.Command.Timeout)
+			}
+			if !tt.interactive && result.Command.Timeout != 30*time.Second {
+				t.Errorf("expected default timeout, got %v", result.Command.Timeout)
+			}
+		})
+	}
+}
+
+func TestManager_GenerateCommand_PassesConversation(t *testing.T) {
+	provider := &mockLLMProvider{response: &types.CommandResponse{Command: "ls", PromptTokens: 420, Usage: types.TokenUsage{InputTokens: 450, OutputTokens: 25}}}
+	manager := NewManager(
+		&mockContextGatherer{},
+		provider,
+		&mockSafetyValidator{},
+		&mockExecutor{},
+		&mockResultValidator{},
+		&types.Config{},
+	)
+
+	var requests []string
+	for i := 0; i < maxConversationTurns+2; i++ {
+		request := fmt.Sprintf("request %d", i)
+		result, err := manager.GenerateCommand(context.Background(), request)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result.PromptTokens != 420 {
+			t.Errorf("expected prompt tokens from the provider, got %d", result.PromptTokens)
+		}
+		if result.Usage != provider.response.Usage {
+			t.Errorf("expected the provider's token usage, got %+v", result.Usage)
+		}
+
+		// Only the latest turns before this one are passed on
+		expected := requests
+		if len(expected) > maxConversationTurns {
+			expected = expected[len(expected)-maxConversationTurns:]
+		}
+		var got []string
+		for _, turn := range provider.lastContext.Conversation {
+			got = append(got, turn.Input)
+		}
+		if strings.Join(got, "|") != strings.Join(expected, "|") {
+			t.Errorf("request %d: expected earlier requests %v, got %v", i, expected, got)
+		}
+		requests = append(requests, request)
+	}
+
+	// The outcome of an executed command is recorded on its turn
+	result, err := manager.GenerateCommand(context.Background(), "list files")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := manager.ExecuteCommand(context.Background(), result.Command); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := manager.GenerateCommand(context.Background(), "count them"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	turns := provider.lastContext.Conversation
+	last := turns[len(turns)-1]
+	if last.Input != "list files" || last.Command != "ls" || last.Output != "test output" {
+		t.Errorf("expected the executed turn with its output, got %+v", last)
+	}
+	if last.ExitCode == nil || *last.ExitCode != 0 {
+		t.Errorf("expected exit code 0 on the executed turn, got %v", last.ExitCode)
+	}
+
+	// Forgetting the conversation clears the earlier turns
+	manager.ForgetConversation()
+	if _, err := manager.GenerateCommand(context.Background(), "start over"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(provider.lastContext.Conversation) != 0 {
+		t.Errorf("expected no earlier turns after forgetting, got %d", len(provider.lastContext.Conversation))
+	}
+}
+
+// directoryContextGatherer is a mockContextGatherer that gathers the context
+// of any directory
+type directoryContextGatherer struct {
+	mockContextGatherer
+}
+
+func (m *directoryContextGatherer) GatherContextIn(ctx context.Context, workingDir string) (*types.Context, error) {
+	return &types.Context{
+		WorkingDirectory: workingDir,
+		Environment:      map[string]string{"HOME": "/home/test", "PWD": workingDir, "VIRTUAL_ENV": "/old/venv"},
+	}, nil
+}
+
+func TestManager_TrackShellState(t *testing.T) {
+	provider := &mockLLMProvider{response: &types.CommandResponse{Command: "cd src && export GOFLAGS=-mod=mod"}}
+	executor := &mockExecutor{result: &types.ExecutionResult{
+		ExitCode: 0,
+		Success:  true,
+		FinalState: &types.ShellState{
+			WorkingDirectory: "/work/src",
+			Environment:      map[string]string{"GOFLAGS": "-mod=mod", "VIRTUAL_ENV": "/work/.venv"},
+			Unset:            []string{"DEBUG"},
+		},
+	}}
+	manager := NewManager(&directoryContextGatherer{}, provider, &mockSafetyValidator{}, executor, &mockResultValidator{}, &types.Config{})
+
+	// Without tracking, commands run where the context was gathered
+	result, err := manager.GenerateCommand(context.Background(), "go into src")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Command.CaptureState || result.Command.WorkingDir != "/test" || manager.ShellState() != nil {
+		t.Errorf("expected no shell state without tracking, got %+v", result.Command)
+	}
+
+	manager.TrackShellState("/work")
+	result, err = manager.GenerateCommand(context.Background(), "go into src")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.Command.CaptureState || result.Command.WorkingDir != "/work" {
+		t.Errorf("expected the command to run in the tracked directory and capture its state, got %+v", result.Command)
+	}
+	if _, err := manager.ExecuteCommand(context.Background(), result.Command); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	result, err = manager.GenerateCommand(context.Background(), "list the go files")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cmd := result.Command
+	if cmd.WorkingDir != "/work/src" || cmd.Environment["GOFLAGS"] != "-mod=mod" || cmd.Environment["HOME"] != "/home/test" {
+		t.Errorf("expected the next command to start from the left state, got dir %s and environment %v", cmd.WorkingDir, cmd.Environment)
+	}
+	if !reflect.DeepEqual(cmd.Unset, []string{"DEBUG"}) {
+		t.Errorf("expected DEBUG to be unset, got %v", cmd.Unset)
+	}
+
+	// The context shows the provider the state
+	gathered := provider.lastContext
+	if gathered.WorkingDirectory != "/work/src" || gathered.Shell == nil || gathered.Shell.Environment["GOFLAGS"] != "-mod=mod" {
+		t.Errorf("expected the context of the tracked directory with its state, got %+v", gathered)
+	}
+	if gathered.Environment["VIRTUAL_ENV"] != "/work/.venv" || gathered.Environment["PWD"] != "/work/src" {
+		t.Errorf("expected gathered variables to show their session values, got %v", gathered.Environment)
+	}
+	if _, ok := gathered.Environment["GOFLAGS"]; ok {
+		t.Error("expected variables the gatherer does not report to stay out of the context environment")
+	}
+
+	// Exporting a variable again takes it off the unset list
+	executor.result.FinalState = &types.ShellState{WorkingDirectory: "/work/src", Environment: map[string]string{"DEBUG": "1"}, Unset: []string{"GOFLAGS"}}
+	if _, err := manager.ExecuteCommand(context.Background(), cmd); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	state := manager.ShellState()
+	if !reflect.DeepEqual(state.Environment, map[string]string{"DEBUG": "1", "VIRTUAL_ENV": "/work/.venv"}) || !reflect.DeepEqual(state.Unset, []string{"GOFLAGS"}) {
+		t.Errorf("expected DEBUG exported and GOFLAGS unset, got %+v", state)
+	}
+}
+
+// streamingLLMProvider is a mockLLMProvider that streams its command in pieces
+type streamingLLMProvider struct {
+	mockLLMProvider
+	pieces []string
+}
+
+func (m *streamingLLMProvider) GenerateCommandStream(ctx context.Context, prompt string, context *types.Context, onText func(string)) (*types.CommandResponse, error) {
+	for _, piece := range m.pieces {
+		onText(piece)
+	}
+	return m.GenerateCommand(ctx, prompt, context)
+}
+
+// recordingStreamHandler records the text streamed to it
+type recordingStreamHandler struct {
+	text  []string
+	dones int
+}
+
+func (h *recordingStreamHandler) Text(text string) { h.text = append(h.text, text) }
+
+func (h *recordingStreamHandler) Done() { h.dones++ }
+
+func TestManager_GenerateCommand_StreamsResponse(t *testing.T) {
+	provider := &streamingLLMProvider{pieces: []string{`{"command": "ls`, ` -la"}`}}
+	manager := NewManager(
+		&mockContextGatherer{},
+		provider,
+		&mockSafetyValidator{},
+		&mockExecutor{},
+		&mockResultValidator{},
+		&types.Config{},
+	)
+
+	// Without a handler the response is not streamed
+	if _, err := manager.GenerateCommand(context.Background(), "list files"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	handler := &recordingStreamHandler{}
+	manager.SetStreamHandler(handler)
+	result, err := manager.GenerateCommand(context.Background(), "list files")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Command.Generated != "ls -la" {
+		t.Errorf("expected the parsed command, got %q", result.Command.Generated)
+	}
+	if strings.Join(handler.text, "") != `{"command": "ls -la"}` {
+		t.Errorf("expected the streamed response, got %q", handler.text)
+	}
+	if handler.dones != 1 {
+		t.Errorf("expected the stream to finish once, got %d", handler.dones)
+	}
+}
+
+func TestTruncateTurnOutput(t *testing.T) {
+	result := &types.ExecutionResult{Stdout: strings.Repeat("é", maxTurnOutput), Stderr: "warning"}
+
+	output := truncateTurnOutput(result)
+	if !strings.HasSuffix(output, "... (truncated)") {
+		t.Errorf("expected long output to be truncated, got %q", output[len(output)-20:])
+	}
+	if !utf8.ValidString(output) {
+		t.Error("truncated output should be valid UTF-8")
+	}
+
+	short := truncateTurnOutput(&types.ExecutionResult{Stdout: "ok\n", Stderr: "warning\n"})
+	if short != "ok\nwarning" {
+		t.Errorf("expected stdout and stderr, got %q", short)
+	}
+}
+
+type recordingAuditLogger struct {
+	entries []*types.AuditEntry
+}
+
+func (r *recordingAuditLogger) LogAuditEvent(entry *types.AuditEntry) error {
+	r.entries = append(r.entries, entry)
+	return nil
+}
+
+func (r *recordingAuditLogger) GetAuditLog(filter *types.AuditFilter) ([]*types.AuditEntry, error) {
+	return r.entries, nil
+}
+
+func (r *recordingAuditLogger) actions() []types.AuditAction {
+	actions := make([]types.AuditAction, 0, len(r.entries))
+	for _, entry := range r.entries {
+		actions = append(actions, entry.Action)
+	}
+	return actions
+}
+
+func TestManager_GenerateAndExecute_Audits(t *testing.T) {
+	tests := []struct {
+		name     string
+		command  string
+		options  *types.ExecutionOptions
+		expected []types.AuditAction
+	}{
+		{
+			name:     "executed",
+			command:  "ls -la",
+			options:  &types.ExecutionOptions{},
+			expected: []types.AuditAction{types.AuditActionGenerated, types.AuditActionExecuted},
+		},
+		{
+			name:     "blocked",
+			command:  "rm -rf /",
+			options:  &types.ExecutionOptions{},
+			expected: []types.AuditAction{types.AuditActionGenerated, types.AuditActionBlocked},
+		},
+		{
+			name:     "bypassed",
+			command:  "rm -rf /",
+			options:  &types.ExecutionOptions{SkipConfirmation: true},
+			expected: []types.AuditAction{types.AuditActionGenerated, types.AuditActionBypassed, types.AuditActionExecuted},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auditLogger := &recordingAuditLogger{}
+			manager := NewManager(
+				&mockContextGatherer{},
+				&mockLLMProvider{response: &types.CommandResponse{Command: tt.command}},
+				safety.NewValidator(),
+				&mockExecutor{result: &types.ExecutionResult{ExitCode: 3}},
+				&mockResultValidator{},
+				&types.Config{},
+			)
+			manager.SetAuditLogger(auditLogger, "session_1", "alice")
+
+			result, err := manager.GenerateAndExecute(context.Background(), "do something", tt.options)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(auditLogger.actions(), tt.expected) {
+				t.Fatalf("expected actions %v, got %v", tt.expected, auditLogger.actions())
+			}
+			for _, entry := range auditLogger.entries {
+				if entry.SessionID != "session_1" || entry.UserID != "alice" {
+					t.Errorf("expected session and user on %s entry, got %q and %q", entry.Action, entry.SessionID, entry.UserID)
+				}
+				if entry.CommandID != result.CommandResult.Command.ID {
+					t.Errorf("expected command ID %q on %s entry, got %q", result.CommandResult.Command.ID, entry.Action, entry.CommandID)
+				}
+			}
+
+			// The level travels with the command rather than being kept by the manager
+			if result.CommandResult.Command.DangerLevel != result.CommandResult.Safety.DangerLevel {
+				t.Errorf("expected the command to carry level %s, got %s", result.CommandResult.Safety.DangerLevel, result.CommandResult.Command.DangerLevel)
+			}
+
+			last := auditLogger.entries[len(auditLogger.entries)-1]
+			if last.Action == types.AuditActionExecuted {
+				if last.ExitCode == nil || *last.ExitCode != 3 {
+					t.Errorf("expected exit code 3 on executed entry, got %v", last.ExitCode)
+				}
+				if last.DangerLevel != result.CommandResult.Safety.DangerLevel {
+					t.Errorf("expected executed entry at level %s, got %s", result.CommandResult.Safety.DangerLevel, last.DangerLevel)
+				}
+			} else if last.ExitCode != nil {
+				t.Errorf("expected no exit code on %s entry", last.Action)
+			}
+		})
+	}
+}
+
+func TestManager_RecordConfirmation(t *testing.T) {
+	auditLogger := &recordingAuditLogger{}
+	manager := NewManager(&mockContextGatherer{}, &mockLLMProvider{}, &mockSafetyValidator{}, &mockExecutor{}, &mockResultValidator{}, &types.Config{})
+	manager.SetAuditLogger(auditLogger, "session_1", "alice")
+
+	declined := &types.CommandResult{
+		Command: &types.Command{ID: "cmd_1", Generated: "rm -rf build"},
+		Safety:  &types.SafetyResult{DangerLevel: types.Dangerous, RequiresConfirmation: true},
+	}
+	manager.RecordConfirmation(declined, false)
+	if declined.Command.Validated {
+		t.Error("expected declined command to stay unvalidated")
+	}
+
+	confirmed := &types.CommandResult{
+		Command: &types.Command{ID: "cmd_2", Generated: "rm -rf build"},
+		Safety:  &types.SafetyResult{DangerLevel: types.Dangerous, RequiresConfirmation: true},
+	}
+	manager.RecordConfirmation(confirmed, true)
+	if !confirmed.Command.Validated {
+		t.Error("expected confirmed command to be validated")
+	}
+
+	expected := []types.AuditAction{types.AuditActionBlocked, types.AuditActionConfirmed}
+	if !reflect.DeepEqual(auditLogger.actions(), expected) {
+		t.Errorf("expected actions %v, got %v", expected, auditLogger.actions())
+	}
+}
+
+func TestManager_PrepareCommand(t *testing.T) {
+	auditLogger := &recordingAuditLogger{}
+	llmProvider := &mockLLMProvider{}
+	manager := NewManager(&mockContextGatherer{}, llmProvider, safety.NewValidator(), &mockExecutor{}, &mockResultValidator{}, &types.Config{})
+	manager.SetAuditLogger(auditLogger, "session_1", "alice")
+
+	result, err := manager.PrepareCommand(context.Background(), "remove everything", "rm -rf /")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if llmProvider.lastContext != nil {
+		t.Error("expected the provider not to be called")
+	}
+	if result.Command.Generated != "rm -rf /" || result.Command.WorkingDir != "/test" {
+		t.Errorf("unexpected command: %+v", result.Command)
+	}
+
+	// A command run again is safety-checked like a newly generated one
+	if !result.Safety.RequiresConfirmation || result.Command.Validated {
+		t.Fatalf("expected the command to require confirmation, got %+v", result.Safety)
+	}
+	full, err := manager.ExecuteResult(context.Background(), result, "remove everything", &types.ExecutionOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !full.RequiresConfirmation || full.ExecutionResult != nil {
+		t.Error("expected execution to wait for confirmation")
+	}
+
+	if len(auditLogger.entries) == 0 || !strings.Contains(auditLogger.entries[0].Reason, "rerun from history") {
+		t.Errorf("expected the generated entry to note the rerun, got %+v", auditLogger.entries)
+	}
+}
+
+// sequenceResultValidator returns its results in order, repeating the last one
+type sequenceResultValidator struct {
+	results []*types.ValidationResult
+	calls   int
+}
+
+func (s *sequenceResultValidator) ValidateResult(ctx context.Context, result *types.ExecutionResult, intent string) (*types.ValidationResult, error) {
+	i := s.calls
+	if i >= len(s.results) {
+		i = len(s.results) - 1
+	}
+	s.calls++
+	return s.results[i], nil
+}
+
+func TestManager_GenerateAndExecute_AutoCorrects(t *testing.T) {
+	wrong := func(correction string) *types.ValidationResult {
+		return &types.ValidationResult{IsCorrect: false, Explanation: "no such file", CorrectedCommand: correction}
+	}
+
+	tests := []struct {
+		name           string
+		validations    []*types.ValidationResult
+		maxCorrections int
+		approve        bool
+		wantCommands   []string
+		wantDeclined   bool
+		wantCorrect    bool
+	}{
+		{
+			name:           "corrected",
+			validations:    []*types.ValidationResult{wrong("ls -la src"), {IsCorrect: true}},
+			maxCorrections: 3,
+			approve:        true,
+			wantCommands:   []string{"ls -la", "ls -la src"},
+			wantCorrect:    true,
+		},
+		{
+			name:           "limited attempts",
+			validations:    []*types.ValidationResult{wrong("ls a"), wrong("ls b"), wrong("ls c")},
+			maxCorrections: 2,
+			approve:        true,
+			wantCommands:   []string{"ls -la", "ls a", "ls b"},
+		},
+		{
+			name:           "repeated correction",
+			validations:    []*types.ValidationResult{wrong("ls a"), wrong("ls a")},
+			maxCorrections: 3,
+			approve:        true,
+			wantCommands:   []string{"ls -la", "ls a"},
+		},
+		{
+			name:           "declined",
+			validations:    []*types.ValidationResult{wrong("ls -la src")},
+			maxCorrections: 3,
+			wantCommands:   []string{"ls -la", "ls -la src"},
+			wantDeclined:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := NewManager(
+				&mockContextGatherer{},
+				&mockLLMProvider{},
+				&mockSafetyValidator{},
+				&mockExecutor{result: &types.ExecutionResult{ExitCode: 2}},
+				&sequenceResultValidator{results: tt.validations},
+				&types.Config{},
+			)
+
+			asked := 0
+			options := &types.ExecutionOptions{
+				MaxCorrections: tt.maxCorrections,
+				ConfirmCorrection: func(correction *types.CommandResult, validation *types.ValidationResult) bool {
+					asked++
+					return tt.approve
+				},
+			}
+
+			result, err := manager.GenerateAndExecute(context.Background(), "list the source files", options)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var commands []string
+			for _, attempt := range result.Attempts {
+				commands = append(commands, attempt.CommandResult.Command.Generated)
+			}
+			if !reflect.DeepEqual(commands, tt.wantCommands) {
+				t.Fatalf("expected attempts %v, got %v", tt.wantCommands, commands)
+			}
+			if asked != len(tt.wantCommands)-1 {
+				t.Errorf("expected the user to be asked %d times, got %d", len(tt.wantCommands)-1, asked)
+			}
+
+			last := result.Attempts[len(result.Attempts)-1]
+			if last.Declined != tt.wantDeclined {
+				t.Errorf("expected declined=%v on the last attempt", tt.wantDeclined)
+			}
+			if tt.wantDeclined {
+				if last.ExecutionResult != nil || result.CommandResult.Command.Generated != "ls -la" {
+					t.Error("a declined correction should not run")
+				}
+			} else if result.CommandResult != last.CommandResult {
+				t.Error("expected the result to describe the last attempt")
+			}
+			if result.ValidationResult.IsCorrect != tt.wantCorrect {
+				t.Errorf("expected final validation %v, got %v", tt.wantCorrect, result.ValidationResult.IsCorrect)
+			}
+		})
+	}
+}
+
+func TestManager_CorrectResult_ChecksSafety(t *testing.T) {
+	auditLogger := &recordingAuditLogger{}
+	manager := NewManager(
+		&mockContextGatherer{},
+		&mockLLMProvider{},
+		safety.NewValidator(),
+		&mockExecutor{result: &types.ExecutionResult{ExitCode: 1}},
+		&sequenceResultValidator{results: []*types.ValidationResult{
+			{IsCorrect: false, CorrectedCommand: "rm -rf build"},
+			{IsCorrect: true},
+		}},
+		&types.Config{},
+	)
+	manager.SetAuditLogger(auditLogger, "session_1", "alice")
+
+	var offered *types.CommandResult
+	options := &types.ExecutionOptions{
+		MaxCorrections: 1,
+		ConfirmCorrection: func(correction *types.CommandResult, validation *types.ValidationResult) bool {
+			offered = correction
+			return true
+		},
+	}
+
+	result, err := manager.GenerateAndExecute(context.Background(), "clean the build", options)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if offered == nil || offered.Safety == nil || !offered.Safety.RequiresConfirmation {
+		t.Fatalf("expected the correction to be safety-checked before it was offered, got %+v", offered)
+	}
+	if !result.CommandResult.Command.Validated {
+		t.Error("expected the approved correction to be validated")
+	}
+
+	expected := []types.AuditAction{
+		types.AuditActionGenerated, types.AuditActionExecuted,
+		types.AuditActionGenerated, types.AuditActionConfirmed, types.AuditActionExecuted,
+	}
+	if !reflect.DeepEqual(auditLogger.actions(), expected) {
+		t.Errorf("expected actions %v, got %v", expected, auditLogger.actions())
+	}
+}
@@ -0,0 +1,190 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+// GeneratePlan asks the provider to decompose input into an ordered sequence of commands
+func (m *Manager) GeneratePlan(ctx context.Context, input string) (*types.Plan, error) {
+	context, err := m.gatherContext(ctx)
+	if err != nil {
+		return nil, &types.NLShellError{
+			Type:    types.ErrTypeValidation,
+			Message: "failed to gather context",
+			Cause:   err,
+			Context: map[string]interface{}{
+				"input": input,
+			},
+		}
+	}
+
+	context.Conversation = m.conversationContext()
+	context.PlanMode = true
+
+	response, err := m.llmProvider.GenerateCommand(ctx, input, context)
+	if err != nil {
+		return nil, &types.NLShellError{
+			Type:    types.ErrTypeProvider,
+			Message: "failed to generate plan",
+			Cause:   err,
+			Context: map[string]interface{}{
+				"input": input,
+			},
+		}
+	}
+
+	steps := response.Steps
+	if len(steps) == 0 && response.Command != "" {
+		// The provider answered with a single command
+		steps = []types.PlanStep{{Command: response.Command, Explanation: response.Explanation}}
+	}
+	if len(steps) == 0 {
+		return nil, &types.NLShellError{
+			Type:    types.ErrTypeProvider,
+			Message: "provider returned an empty plan",
+			Context: map[string]interface{}{
+				"input": input,
+			},
+		}
+	}
+
+	plan := &types.Plan{
+		ID:          fmt.Sprintf("plan_%d", time.Now().UnixNano()),
+		Request:     input,
+		Explanation: response.Explanation,
+		Steps:       steps,
+		WorkingDir:  context.WorkingDirectory,
+		Provider:    response.Provider,
+		Model:       response.Model,
+		CreatedAt:   time.Now(),
+		Usage:       response.Usage,
+	}
+	if plan.Provider == "" {
+		plan.Provider = m.llmProvider.GetProviderInfo().Name
+	}
+
+	commands := make([]string, 0, len(steps))
+	for _, step := range steps {
+		commands = append(commands, step.Command)
+	}
+	m.addTurn(input, plan.ID, strings.Join(commands, " && "))
+	return plan, nil
+}
+
+// ExecutePlan runs the steps of plan one at a time in the current context,
+// safety-checking each step on its own and stopping at the first step that
+// fails, is declined, or needs a confirmation that was not given. Steps are
+// confirmed with options.ConfirmStep when it is set.
+func (m *Manager) ExecutePlan(ctx context.Context, plan *types.Plan, options *types.ExecutionOptions) (*types.PlanResult, error) {
+	if options == nil {
+		options = &types.ExecutionOptions{}
+	}
+
+	context, err := m.gatherContext(ctx)
+	if err != nil {
+		return nil, &types.NLShellError{
+			Type:    types.ErrTypeValidation,
+			Message: "failed to gather context",
+			Cause:   err,
+			Context: map[string]interface{}{
+				"plan": plan.ID,
+			},
+		}
+	}
+
+	result := &types.PlanResult{Plan: plan}
+	for i := range plan.Steps {
+		step := plan.Steps[i]
+		commandResult, err := m.newCommandResult(plan.Request, step.Command, false, context,
+			fmt.Sprintf("step %d of %d of %s", i+1, len(plan.Steps), plan.ID))
+		if err != nil {
+			return nil, err
+		}
+		commandResult.Provider = plan.Provider
+		commandResult.Model = plan.Model
+		if i == 0 {
+			// The tokens spent generating the plan are counted with its first step
+			commandResult.Usage = plan.Usage
+		}
+
+		sandboxCommand(commandResult.Command, options)
+
+		stepResult := &types.PlanStepResult{Step: step, CommandResult: commandResult}
+		result.Steps = append(result.Steps, stepResult)
+
+		// Dry runs preview every step without stopping
+		if options.DryRun {
+			dryRunResult, err := m.previewCommand(ctx, commandResult, options)
+			if err != nil {
+				return nil, err
+			}
+			stepResult.DryRunResult = dryRunResult
+			continue
+		}
+
+		approved, err := m.approvePlanStep(i, &step, stepResult, options)
+		if err != nil {
+			return nil, err
+		}
+		if !approved {
+			return result, nil
+		}
+
+		executionResult, err := m.ExecuteCommandStreaming(ctx, commandResult.Command, options.Stdout, options.Stderr)
+		if err != nil {
+			return nil, err
+		}
+		stepResult.ExecutionResult = executionResult
+
+		// Later steps depend on earlier ones, so stop on the first failure
+		// and when the changes of a sandboxed step are discarded
+		discarded, err := settleChanges(executionResult, options)
+		if err != nil {
+			return nil, err
+		}
+		if !executionResult.Success || executionResult.ExitCode != 0 || discarded {
+			return result, nil
+		}
+	}
+
+	result.Completed = !options.DryRun
+	return result, nil
+}
+
+// approvePlanStep confirms a plan step before it runs, recording the outcome on
+// stepResult. Without options.ConfirmStep, steps that require confirmation run
+// only when confirmation is skipped, as with a single generated command.
+func (m *Manager) approvePlanStep(index int, step *types.PlanStep, stepResult *types.PlanStepResult, options *types.ExecutionOptions) (bool, error) {
+	commandResult := stepResult.CommandResult
+
+	if options.ConfirmStep != nil {
+		if !options.ConfirmStep(index, step, commandResult) {
+			stepResult.Declined = true
+			m.recordAudit(commandResult.Command, types.AuditActionBlocked, commandResult.Safety.DangerLevel, "plan step declined", nil)
+			return false, nil
+		}
+		if commandResult.Safety.RequiresConfirmation {
+			m.RecordConfirmation(commandResult, true)
+		}
+		commandResult.Command.Validated = true
+		return true, nil
+	}
+
+	if commandResult.Safety.RequiresConfirmation {
+		if !options.SkipConfirmation {
+			stepResult.RequiresConfirmation = true
+			m.recordAudit(commandResult.Command, types.AuditActionBlocked, commandResult.Safety.DangerLevel, "confirmation required", nil)
+			return false, nil
+		}
+		if err := m.BypassConfirmation(commandResult); err != nil {
+			return false, err
+		}
+	}
+	commandResult.Command.Validated = true
+	return true, nil
+}
@@ -0,0 +1,161 @@
+package manager
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/safety"
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+// exitCodeExecutor fails the commands listed in failures with their exit code
+type exitCodeExecutor struct {
+	mockExecutor
+	failures map[string]int
+	executed []string
+}
+
+func (e *exitCodeExecutor) ExecuteStreaming(ctx context.Context, cmd *types.Command, stdout, stderr io.Writer) (*types.ExecutionResult, error) {
+	e.executed = append(e.executed, cmd.Generated)
+	code := e.failures[cmd.Generated]
+	return &types.ExecutionResult{Command: cmd, ExitCode: code, Success: code == 0}, nil
+}
+
+func TestManager_GeneratePlan(t *testing.T) {
+	llmProvider := &mockLLMProvider{response: &types.CommandResponse{
+		Explanation: "Set up and test",
+		Steps: []types.PlanStep{
+			{Command: "python3 -m venv .venv", Explanation: "Create a virtual environment"},
+			{Command: ".venv/bin/pip install -r requirements.txt", Explanation: "Install requirements"},
+			{Command: ".venv/bin/pytest", Explanation: "Run the tests"},
+		},
+	}}
+	manager := NewManager(&mockContextGatherer{}, llmProvider, &mockSafetyValidator{}, &mockExecutor{}, &mockResultValidator{}, &types.Config{})
+
+	plan, err := manager.GeneratePlan(context.Background(), "create a venv, install requirements and run the tests")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !llmProvider.lastContext.PlanMode {
+		t.Error("expected the provider to be asked for a plan")
+	}
+	if len(plan.Steps) != 3 || plan.WorkingDir != "/test" || plan.Provider != "mock" {
+		t.Errorf("unexpected plan: %+v", plan)
+	}
+
+	// A single command is a one-step plan
+	llmProvider.response = &types.CommandResponse{Command: "ls -la", Explanation: "List files"}
+	plan, err = manager.GeneratePlan(context.Background(), "list files")
+	if err != nil || len(plan.Steps) != 1 || plan.Steps[0].Command != "ls -la" {
+		t.Errorf("expected a one-step plan, got %+v (%v)", plan, err)
+	}
+
+	llmProvider.response = &types.CommandResponse{}
+	if _, err := manager.GeneratePlan(context.Background(), "nothing"); err == nil {
+		t.Error("expected an error for an empty plan")
+	}
+}
+
+func TestManager_ExecutePlan(t *testing.T) {
+	plan := &types.Plan{
+		ID:      "plan_1",
+		Request: "build and test",
+		Steps: []types.PlanStep{
+			{Command: "make build"},
+			{Command: "make test"},
+			{Command: "rm -rf build"},
+		},
+	}
+
+	tests := []struct {
+		name          string
+		failures      map[string]int
+		options       *types.ExecutionOptions
+		wantExecuted  []string
+		wantSteps     int
+		wantCompleted bool
+		check         func(t *testing.T, result *types.PlanResult)
+	}{
+		{
+			name:         "stops on failure",
+			failures:     map[string]int{"make test": 2},
+			options:      &types.ExecutionOptions{SkipConfirmation: true},
+			wantExecuted: []string{"make build", "make test"},
+			wantSteps:    2,
+		},
+		{
+			name:         "stops at unconfirmed step",
+			options:      &types.ExecutionOptions{},
+			wantExecuted: []string{"make build", "make test"},
+			wantSteps:    3,
+			check: func(t *testing.T, result *types.PlanResult) {
+				if !result.Steps[2].RequiresConfirmation {
+					t.Error("expected the dangerous step to require confirmation")
+				}
+			},
+		},
+		{
+			name:          "skip confirmation",
+			options:       &types.ExecutionOptions{SkipConfirmation: true},
+			wantExecuted:  []string{"make build", "make test", "rm -rf build"},
+			wantSteps:     3,
+			wantCompleted: true,
+		},
+		{
+			name: "declined step",
+			options: &types.ExecutionOptions{ConfirmStep: func(index int, step *types.PlanStep, result *types.CommandResult) bool {
+				return index == 0
+			}},
+			wantExecuted: []string{"make build"},
+			wantSteps:    2,
+			check: func(t *testing.T, result *types.PlanResult) {
+				if !result.Steps[1].Declined {
+					t.Error("expected the second step to be declined")
+				}
+			},
+		},
+		{
+			name:      "dry run",
+			options:   &types.ExecutionOptions{DryRun: true},
+			wantSteps: 3,
+			check: func(t *testing.T, result *types.PlanResult) {
+				for _, step := range result.Steps {
+					if step.DryRunResult == nil {
+						t.Errorf("expected a dry run of %q", step.Step.Command)
+					}
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			executor := &exitCodeExecutor{failures: tt.failures}
+			manager := NewManager(&mockContextGatherer{}, &mockLLMProvider{}, safety.NewValidator(), executor, &mockResultValidator{}, &types.Config{})
+
+			result, err := manager.ExecutePlan(context.Background(), plan, tt.options)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(executor.executed, tt.wantExecuted) {
+				t.Errorf("expected %v to run, got %v", tt.wantExecuted, executor.executed)
+			}
+			if len(result.Steps) != tt.wantSteps {
+				t.Errorf("expected %d steps reached, got %d", tt.wantSteps, len(result.Steps))
+			}
+			if result.Completed != tt.wantCompleted {
+				t.Errorf("expected completed=%v", tt.wantCompleted)
+			}
+			for _, step := range result.Steps {
+				if step.CommandResult.Safety == nil {
+					t.Errorf("expected step %q to be safety-checked", step.Step.Command)
+				}
+			}
+			if tt.check != nil {
+				tt.check(t, result)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package manager
+
+import (
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+// sandboxCommand makes cmd run in the sandbox when options ask for it
+func sandboxCommand(cmd *types.Command, options *types.ExecutionOptions) {
+	if options != nil && options.Sandbox {
+		cmd.Sandbox = true
+	}
+}
+
+// settleChanges applies or discards the file changes a sandboxed command
+// made, as options decide. It reports whether the changes were discarded.
+func settleChanges(result *types.ExecutionResult, options *types.ExecutionOptions) (bool, error) {
+	if result == nil || result.Pending == nil {
+		return false, nil
+	}
+	pending := result.Pending
+	result.Pending = nil
+
+	apply := false
+	if options != nil {
+		if options.ApplyChanges != nil {
+			apply = options.ApplyChanges(result)
+		} else {
+			apply = options.SkipConfirmation
+		}
+	}
+	if !apply {
+		return true, pending.Discard()
+	}
+
+	if err := pending.Apply(); err != nil {
+		return false, err
+	}
+	result.ChangesApplied = true
+	return false, nil
+}
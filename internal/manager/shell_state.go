@@ -0,0 +1,153 @@
+package manager
+
+import (
+	"context"
+	"os"
+	"sort"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/interfaces"
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+// TrackShellState makes the directory and environment changes of executed
+// commands, such as cd, export and source, carry over to the commands
+// generated after them, as they would in a shell. Tracking starts in
+// workingDir, or the process's working directory when it is empty.
+func (m *Manager) TrackShellState(workingDir string) {
+	if workingDir == "" {
+		workingDir, _ = os.Getwd()
+	}
+
+	m.shellStateMutex.Lock()
+	defer m.shellStateMutex.Unlock()
+
+	m.shellState = &types.ShellState{
+		WorkingDirectory: workingDir,
+		Environment:      make(map[string]string),
+	}
+}
+
+// ShellState returns a copy of the tracked directory and environment, or nil
+// when they are not tracked
+func (m *Manager) ShellState() *types.ShellState {
+	m.shellStateMutex.Lock()
+	defer m.shellStateMutex.Unlock()
+
+	return copyShellState(m.shellState)
+}
+
+// gatherContext gathers the context for a request, in the tracked directory
+// and with the tracked environment when shell state is tracked
+func (m *Manager) gatherContext(ctx context.Context) (*types.Context, error) {
+	state := m.ShellState()
+	if state == nil {
+		return m.contextGatherer.GatherContext(ctx)
+	}
+
+	var context *types.Context
+	var err error
+	if gatherer, ok := m.contextGatherer.(interfaces.DirectoryContextGatherer); ok && state.WorkingDirectory != "" {
+		context, err = gatherer.GatherContextIn(ctx, state.WorkingDirectory)
+	} else {
+		context, err = m.contextGatherer.GatherContext(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Variables the gatherer reports show their values in the session
+	if context.Environment != nil {
+		for key, value := range state.Environment {
+			if _, ok := context.Environment[key]; ok {
+				context.Environment[key] = value
+			}
+		}
+		for _, key := range state.Unset {
+			delete(context.Environment, key)
+		}
+		if _, ok := context.Environment["PWD"]; ok && state.WorkingDirectory != "" {
+			context.Environment["PWD"] = state.WorkingDirectory
+		}
+	}
+	context.Shell = state
+	return context, nil
+}
+
+// applyShellState makes cmd run in the tracked directory with the tracked
+// environment, and capture the changes it makes to them
+func (m *Manager) applyShellState(cmd *types.Command) {
+	state := m.ShellState()
+	if state == nil {
+		return
+	}
+
+	if state.WorkingDirectory != "" {
+		cmd.WorkingDir = state.WorkingDirectory
+	}
+	if len(state.Environment) > 0 {
+		env := make(map[string]string, len(cmd.Environment)+len(state.Environment))
+		for key, value := range cmd.Environment {
+			env[key] = value
+		}
+		for key, value := range state.Environment {
+			env[key] = value
+		}
+		cmd.Environment = env
+	}
+	cmd.Unset = state.Unset
+	cmd.CaptureState = true
+}
+
+// updateShellState applies the directory and environment changes an executed
+// command left behind to the tracked state
+func (m *Manager) updateShellState(result *types.ExecutionResult) {
+	if result == nil || result.FinalState == nil {
+		return
+	}
+
+	m.shellStateMutex.Lock()
+	defer m.shellStateMutex.Unlock()
+
+	if m.shellState == nil {
+		return
+	}
+	final := result.FinalState
+	if final.WorkingDirectory != "" {
+		m.shellState.WorkingDirectory = final.WorkingDirectory
+	}
+
+	unset := make(map[string]bool)
+	for _, key := range m.shellState.Unset {
+		unset[key] = true
+	}
+	for key, value := range final.Environment {
+		m.shellState.Environment[key] = value
+		delete(unset, key)
+	}
+	for _, key := range final.Unset {
+		delete(m.shellState.Environment, key)
+		unset[key] = true
+	}
+
+	m.shellState.Unset = m.shellState.Unset[:0]
+	for key := range unset {
+		m.shellState.Unset = append(m.shellState.Unset, key)
+	}
+	sort.Strings(m.shellState.Unset)
+}
+
+// copyShellState returns a deep copy of state
+func copyShellState(state *types.ShellState) *types.ShellState {
+	if state == nil {
+		return nil
+	}
+	copied := &types.ShellState{
+		WorkingDirectory: state.WorkingDirectory,
+		Environment:      make(map[string]string, len(state.Environment)),
+		Unset:            append([]string(nil), state.Unset...),
+	}
+	for key, value := range state.Environment {
+		copied.Environment[key] = value
+	}
+	return copied
+}
@@ -4,8 +4,13 @@ import (
 	"github.com/kanishka-sahoo/nl-to-shell/internal/interfaces"
 )
 
+// PluginRegistrar accepts context plugins, like a PluginManager or ContextGatherer
+type PluginRegistrar interface {
+	RegisterPlugin(plugin interfaces.ContextPlugin) error
+}
+
 // RegisterBuiltinPlugins registers all built-in context plugins with the plugin manager
-func RegisterBuiltinPlugins(pluginManager interfaces.PluginManager) error {
+func RegisterBuiltinPlugins(pluginManager PluginRegistrar) error {
 	// Register environment variable plugin
 	envPlugin := NewEnvPlugin()
 	if err := pluginManager.RegisterPlugin(envPlugin); err != nil {
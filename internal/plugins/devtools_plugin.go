@@ -7,11 +7,16 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/kanishka-sahoo/nl-to-shell/internal/interfaces"
 	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
 )
 
+// toolDetectionTimeout bounds how long a tool may take to report its version
+const toolDetectionTimeout = 2 * time.Second
+
 // DevToolsPlugin detects development tools and their versions
 type DevToolsPlugin struct{}
 
@@ -58,8 +63,11 @@ func (p *DevToolsPlugin) GatherContext(ctx context.Context, baseContext *types.C
 	}{
 		{"docker", "docker", "--version", `Docker version ([^\s,]+)`},
 		{"node", "node", "--version", `v?(.+)`},
+		{"podman", "podman", "--version", `podman version ([^\s]+)`},
 		{"npm", "npm", "--version", `(.+)`},
 		{"yarn", "yarn", "--version", `(.+)`},
+		{"pnpm", "pnpm", "--version", `(.+)`},
+		{"bun", "bun", "--version", `(.+)`},
 		{"python", "python", "--version", `Python (.+)`},
 		{"python3", "python3", "--version", `Python (.+)`},
 		{"pip", "pip", "--version", `pip ([^\s]+)`},
@@ -95,16 +103,25 @@ func (p *DevToolsPlugin) GatherContext(ctx context.Context, baseContext *types.C
 		{"az", "az", "--version", `azure-cli\s+(.+)`},
 	}
 
-	// Detect each tool
+	// Detect tools concurrently, since each version check starts a process
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
 	for _, tool := range toolsToDetect {
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		default:
-		}
+		wg.Add(1)
+		go func(name, command, versionFlag, versionPattern string) {
+			defer wg.Done()
+			toolInfo := p.detectTool(name, command, versionFlag, versionPattern)
+			mutex.Lock()
+			tools[name] = toolInfo
+			mutex.Unlock()
+		}(tool.name, tool.command, tool.versionFlag, tool.versionPattern)
+	}
+	wg.Wait()
 
-		toolInfo := p.detectTool(tool.name, tool.command, tool.versionFlag, tool.versionPattern)
-		tools[tool.name] = toolInfo
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
 	}
 
 	// Detect additional context
@@ -139,7 +156,9 @@ func (p *DevToolsPlugin) detectTool(name, command, versionFlag, versionPattern s
 	toolInfo.Available = true
 
 	// Get version information
-	cmd := exec.Command(command, versionFlag)
+	ctx, cancel := context.WithTimeout(context.Background(), toolDetectionTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, command, versionFlag)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return toolInfo
@@ -205,7 +224,7 @@ func (p *DevToolsPlugin) detectNodeRuntime() map[string]interface{} {
 		}
 
 		// Check for common Node.js files
-		nodeFiles := []string{"yarn.lock", "package-lock.json", ".nvmrc", ".node-version"}
+		nodeFiles := []string{"yarn.lock", "package-lock.json", "pnpm-lock.yaml", "bun.lockb", ".nvmrc", ".node-version"}
 		foundFiles := []string{}
 		for _, file := range nodeFiles {
 			if _, err := os.Stat(file); err == nil {
@@ -215,6 +234,16 @@ func (p *DevToolsPlugin) detectNodeRuntime() map[string]interface{} {
 		if len(foundFiles) > 0 {
 			nodeInfo["config_files"] = foundFiles
 		}
+
+		// The lock file tells which package manager the project uses
+		if manager := detectPackageManager([]lockFile{
+			{"pnpm-lock.yaml", "pnpm"},
+			{"yarn.lock", "yarn"},
+			{"bun.lockb", "bun"},
+			{"package-lock.json", "npm"},
+		}); manager != "" {
+			nodeInfo["package_manager"] = manager
+		}
 	}
 
 	// Check for other Node.js indicators
@@ -272,12 +301,37 @@ func (p *DevToolsPlugin) detectPythonRuntime() map[string]interface{} {
 		pythonInfo["conda_env"] = condaEnv
 	}
 
+	if manager := detectPackageManager([]lockFile{
+		{"uv.lock", "uv"},
+		{"poetry.lock", "poetry"},
+		{"Pipfile.lock", "pipenv"},
+		{"Pipfile", "pipenv"},
+	}); manager != "" {
+		pythonInfo["package_manager"] = manager
+	}
+
 	if len(pythonInfo) > 0 {
 		return pythonInfo
 	}
 	return nil
 }
 
+// lockFile maps a lock file to the package manager that writes it
+type lockFile struct {
+	name    string
+	manager string
+}
+
+// detectPackageManager returns the package manager of the first lock file found
+func detectPackageManager(lockFiles []lockFile) string {
+	for _, file := range lockFiles {
+		if _, err := os.Stat(file.name); err == nil {
+			return file.manager
+		}
+	}
+	return ""
+}
+
 // detectJavaRuntime detects Java runtime information
 func (p *DevToolsPlugin) detectJavaRuntime() map[string]interface{} {
 	javaInfo := make(map[string]interface{})
@@ -205,6 +205,20 @@ func TestDevToolsPlugin_detectNodeRuntime(t *testing.T) {
 	if !found {
 		t.Error("Expected yarn.lock to be in config_files")
 	}
+	if nodeInfo["package_manager"] != "yarn" {
+		t.Errorf("Expected package_manager to be yarn, got %v", nodeInfo["package_manager"])
+	}
+
+	// pnpm takes precedence over yarn when both lock files exist
+	err = os.WriteFile("pnpm-lock.yaml", []byte("lockfileVersion: 6.0"), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create pnpm-lock.yaml: %v", err)
+	}
+
+	nodeInfo = plugin.detectNodeRuntime()
+	if nodeInfo["package_manager"] != "pnpm" {
+		t.Errorf("Expected package_manager to be pnpm, got %v", nodeInfo["package_manager"])
+	}
 }
 
 func TestDevToolsPlugin_detectPythonRuntime(t *testing.T) {
@@ -3,7 +3,9 @@ package plugins
 import (
 	"context"
 	"os"
+	"os/exec"
 	"regexp"
+	"runtime"
 	"strings"
 
 	"github.com/kanishka-sahoo/nl-to-shell/internal/interfaces"
@@ -258,5 +260,35 @@ func (p *EnvPlugin) getSystemInfo() map[string]interface{} {
 		systemInfo["xdg_dirs"] = xdgDirs
 	}
 
+	systemInfo["os"] = runtime.GOOS
+	systemInfo["arch"] = runtime.GOARCH
+	if userland := p.detectUserland(); userland != "" {
+		systemInfo["userland"] = userland
+	}
+
 	return systemInfo
 }
+
+// detectUserland reports whether core utilities take GNU, BSD or BusyBox flags
+func (p *EnvPlugin) detectUserland() string {
+	switch runtime.GOOS {
+	case "windows":
+		return ""
+	case "darwin", "freebsd", "openbsd", "netbsd", "dragonfly":
+		// BSD systems may have GNU coreutils first in PATH (e.g. Homebrew's gnubin)
+		if output, err := exec.Command("ls", "--version").CombinedOutput(); err == nil && strings.Contains(string(output), "GNU") {
+			return "gnu"
+		}
+		return "bsd"
+	default:
+		output, _ := exec.Command("ls", "--version").CombinedOutput()
+		switch {
+		case strings.Contains(string(output), "GNU"):
+			return "gnu"
+		case strings.Contains(string(output), "BusyBox"):
+			return "busybox"
+		default:
+			return ""
+		}
+	}
+}
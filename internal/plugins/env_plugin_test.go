@@ -3,6 +3,7 @@ package plugins
 import (
 	"context"
 	"os"
+	"runtime"
 	"testing"
 	"time"
 
@@ -426,6 +427,10 @@ func TestEnvPlugin_getSystemInfo(t *testing.T) {
 	if xdgDirs["cache"] != "/home/user/.cache" {
 		t.Errorf("Expected XDG cache dir to be '/home/user/.cache', got '%v'", xdgDirs["cache"])
 	}
+
+	if systemInfo["os"] != runtime.GOOS || systemInfo["arch"] != runtime.GOARCH {
+		t.Errorf("Expected os/arch to be %s/%s, got %v/%v", runtime.GOOS, runtime.GOARCH, systemInfo["os"], systemInfo["arch"])
+	}
 }
 
 func TestEnvPlugin_Integration(t *testing.T) {
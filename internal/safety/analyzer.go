@@ -0,0 +1,504 @@
+package safety
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+// commandWrappers run the command given in their arguments. The value lists
+// the options that consume the following word.
+var commandWrappers = map[string][]string{
+	"sudo":    {"-u", "-g", "-C", "-h", "-p", "-r", "-t", "-U", "-D"},
+	"doas":    {"-u", "-C"},
+	"env":     {"-u", "-C", "--unset", "--chdir"},
+	"nice":    {"-n", "--adjustment"},
+	"ionice":  {"-c", "-n", "-p"},
+	"stdbuf":  {"-i", "-o", "-e"},
+	"nohup":   nil,
+	"time":    {"-f", "-o"},
+	"exec":    {"-a"},
+	"command": nil,
+	"builtin": nil,
+	"xargs":   {"-I", "-n", "-P", "-d", "-L", "-s", "-a", "-E"},
+	"timeout": {"-s", "-k", "--signal", "--kill-after"},
+	"chroot":  {"--userspec", "--groups"},
+}
+
+// wrapperPositionals are wrappers whose first positional argument is not the command
+var wrapperPositionals = map[string]bool{
+	"timeout": true, // Duration
+	"chroot":  true, // New root directory
+}
+
+// scriptInterpreters execute a script read from stdin when given no script file
+var scriptInterpreters = map[string]bool{
+	"sh": true, "bash": true, "zsh": true, "dash": true, "ksh": true, "ash": true, "fish": true,
+	"python": true, "python3": true, "perl": true, "ruby": true, "node": true, "php": true,
+}
+
+// commandStringRunners run their arguments as a command line, elsewhere or later
+var commandStringRunners = map[string]bool{
+	"ssh": true, "watch": true,
+}
+
+// posixShells accept a command string with -c
+var posixShells = map[string]bool{
+	"sh": true, "bash": true, "zsh": true, "dash": true, "ksh": true, "ash": true, "fish": true,
+}
+
+// fileDeleters remove the files named in their arguments
+var fileDeleters = map[string]bool{
+	"rm": true, "rmdir": true, "shred": true, "unlink": true,
+}
+
+// downloaders fetch remote content
+var downloaders = map[string]bool{
+	"curl": true, "wget": true,
+}
+
+// shellKeywords introduce or continue a compound command and precede a real command
+var shellKeywords = map[string]bool{
+	"if": true, "then": true, "else": true, "elif": true, "do": true,
+	"while": true, "until": true, "!": true, "{": true,
+}
+
+// shellStructureWords start or end compound commands without running anything themselves
+var shellStructureWords = map[string]bool{
+	"for": true, "select": true, "case": true, "function": true,
+	"fi": true, "done": true, "esac": true, "}": true,
+}
+
+// structureAnalyzer evaluates each simple command, substitution and pipeline
+// stage of a parsed command on its own
+type structureAnalyzer struct {
+	validator *Validator
+	level     types.DangerLevel
+	findings  []types.SafetyFinding
+	targets   []policyTarget // Every sub-command evaluated, for policy rules
+}
+
+// analyzeStructure parses the command and evaluates every sub-command on its
+// own, so quoting, wrappers, nested shells and pipelines cannot hide it
+func (v *Validator) analyzeStructure(cmd string) (types.DangerLevel, []types.SafetyFinding, []policyTarget) {
+	a := &structureAnalyzer{validator: v}
+
+	script, err := parseShell(cmd)
+	if err != nil {
+		source := strings.TrimSpace(cmd)
+		a.add(types.Warning, "Command could not be parsed for detailed safety analysis", source)
+		a.evaluateRaw(source)
+		return a.level, a.findings, a.targets
+	}
+
+	a.script(script, 0)
+	return a.level, a.findings, a.targets
+}
+
+// add records a finding for a sub-command
+func (a *structureAnalyzer) add(level types.DangerLevel, description, subCommand string) {
+	a.findings = append(a.findings, types.SafetyFinding{
+		Description: description,
+		Level:       level,
+		SubCommand:  subCommand,
+	})
+	if level > a.level {
+		a.level = level
+	}
+}
+
+// script analyzes every pipeline stage of a script
+func (a *structureAnalyzer) script(script *shellScript, depth int) {
+	if depth > maxParseDepth {
+		return
+	}
+
+	for _, pipeline := range script.Pipelines {
+		for i, stage := range pipeline.Stages {
+			var previous *shellCommand
+			if i > 0 {
+				previous = pipeline.Stages[i-1]
+			}
+			a.command(stage, previous, depth)
+		}
+	}
+}
+
+// nested parses and analyzes a command string run by another command
+func (a *structureAnalyzer) nested(src, source string, depth int) {
+	script, err := parseShellNested(src, depth)
+	if err != nil {
+		a.add(types.Warning, "Nested command could not be parsed for detailed safety analysis", source)
+		a.evaluateRaw(src)
+		return
+	}
+	a.script(script, depth+1)
+}
+
+// command analyzes a single pipeline stage. previous is the stage piping into it, if any.
+func (a *structureAnalyzer) command(cmd *shellCommand, previous *shellCommand, depth int) {
+	if cmd.Subshell != nil {
+		a.script(cmd.Subshell, depth+1)
+	}
+
+	// Command and process substitutions run on their own
+	for _, word := range commandWords(cmd) {
+		for _, sub := range word.Substitutions {
+			a.script(sub, depth+1)
+		}
+	}
+
+	words := stripKeywords(cmd.Words)
+	if len(words) == 0 && len(cmd.Redirects) == 0 {
+		return
+	}
+	if len(words) < len(cmd.Words) {
+		// Findings name the command without the keywords before it
+		stripped := *cmd
+		stripped.Words, stripped.Source = words, strippedSource(cmd)
+		cmd = &stripped
+	}
+
+	a.evaluate(words, cmd.Redirects, cmd.Source)
+	if len(words) > 0 {
+		a.structure(words, cmd, previous, depth)
+	}
+}
+
+// evaluate matches the dangerous patterns against a sub-command with its
+// quotes removed
+func (a *structureAnalyzer) evaluate(words []shellWord, redirects []shellRedirect, source string) {
+	a.targets = append(a.targets, policyTarget{text: commandText(words, redirects), source: source})
+	a.evaluateText(patternText(words, redirects), source)
+}
+
+// evaluateRaw matches the dangerous patterns against command text that could
+// not be parsed, quoted data and all
+func (a *structureAnalyzer) evaluateRaw(src string) {
+	a.evaluateText(strings.ToLower(strings.TrimSpace(src)), strings.TrimSpace(src))
+}
+
+func (a *structureAnalyzer) evaluateText(text, source string) {
+	level, findings := a.validator.evaluateText(text)
+	for _, finding := range findings {
+		a.add(finding.Level, finding.Description, source)
+	}
+	if level > a.level {
+		a.level = level
+	}
+}
+
+// structure applies the checks that depend on how commands are combined
+func (a *structureAnalyzer) structure(words []shellWord, cmd *shellCommand, previous *shellCommand, depth int) {
+	name := commandName(words[0].Value)
+	args := words[1:]
+
+	// The command that runs is not known until the word is expanded
+	if words[0].Expanded {
+		a.add(types.Warning, "Command name depends on a variable or command substitution", cmd.Source)
+	}
+
+	// Commands run by wrappers are evaluated on their own
+	if _, ok := commandWrappers[name]; ok {
+		inner := unwrapCommand(name, args)
+		if len(inner) == 0 {
+			return
+		}
+		innerSource := joinRaw(inner)
+		if name == "xargs" && fileDeleters[commandName(inner[0].Value)] {
+			a.add(types.Dangerous, "Deletion of files whose names are read from input", cmd.Source)
+		}
+		a.evaluate(inner, nil, innerSource)
+		a.structure(inner, cmd, previous, depth)
+		return
+	}
+
+	switch {
+	case name == "find":
+		for _, inner := range findExecCommands(args) {
+			if fileDeleters[commandName(inner[0].Value)] {
+				a.add(types.Dangerous, "Find command executing rm", cmd.Source)
+			}
+			a.evaluate(inner, nil, joinRaw(inner))
+			a.structure(inner, cmd, nil, depth)
+		}
+	case name == "eval":
+		a.nested(joinValues(args), cmd.Source, depth)
+	case name == "su":
+		if script, ok := commandStringArg(args); ok {
+			a.nested(script, cmd.Source, depth)
+		}
+	case commandStringRunners[name]:
+		for _, arg := range args {
+			if isData(arg) {
+				a.nested(arg.Value, cmd.Source, depth)
+			}
+		}
+	case posixShells[name]:
+		if script, ok := commandStringArg(args); ok {
+			if hasDownload(args) {
+				a.add(types.Warning, "Downloading and executing scripts", cmd.Source)
+			}
+			a.nested(script, cmd.Source, depth)
+			return
+		}
+	}
+
+	if scriptInterpreters[name] {
+		a.checkScriptSource(name, args, cmd, previous)
+	}
+
+	if fileDeleters[name] {
+		for _, arg := range args {
+			if arg.Expanded {
+				a.add(types.Dangerous, "Deletion target depends on a variable or command substitution", cmd.Source)
+				break
+			}
+		}
+	}
+}
+
+// checkScriptSource warns when an interpreter executes piped or downloaded code
+func (a *structureAnalyzer) checkScriptSource(name string, args []shellWord, cmd *shellCommand, previous *shellCommand) {
+	if hasDownload(args) {
+		a.add(types.Warning, "Downloading and executing scripts", cmd.Source)
+		return
+	}
+
+	if previous == nil || hasScriptFile(args) {
+		return
+	}
+
+	source := strippedSource(previous) + " | " + cmd.Source
+	previousWords := stripKeywords(previous.Words)
+	if len(previousWords) > 0 && downloaders[commandName(previousWords[0].Value)] {
+		a.add(types.Warning, "Downloading and executing scripts", source)
+	} else {
+		a.add(types.Warning, "Piped input is executed by "+name, source)
+	}
+}
+
+// commandWords returns the assignments, words and redirect targets of a command
+func commandWords(cmd *shellCommand) []shellWord {
+	words := append([]shellWord{}, cmd.Assignments...)
+	words = append(words, cmd.Words...)
+	for _, redirect := range cmd.Redirects {
+		words = append(words, redirect.Target)
+	}
+	return words
+}
+
+// stripKeywords removes leading shell keywords such as if, then or do. Words
+// that only structure a compound command (for, case, done, ...) yield nothing.
+func stripKeywords(words []shellWord) []shellWord {
+	for len(words) > 0 && shellKeywords[words[0].Raw] {
+		words = words[1:]
+	}
+	if len(words) > 0 && shellStructureWords[words[0].Raw] {
+		return nil
+	}
+	return words
+}
+
+// strippedSource returns the source of a command without the leading
+// keywords stripKeywords removes
+func strippedSource(cmd *shellCommand) string {
+	source := cmd.Source
+	for _, word := range cmd.Words {
+		if !shellKeywords[word.Raw] {
+			break
+		}
+		source = strings.TrimSpace(strings.TrimPrefix(source, word.Raw))
+	}
+	return source
+}
+
+// commandName normalizes a command word to its lowercase base name
+func commandName(word string) string {
+	return strings.ToLower(filepath.Base(word))
+}
+
+// commandText renders a sub-command as normalized text for pattern matching
+func commandText(words []shellWord, redirects []shellRedirect) string {
+	parts := make([]string, 0, len(words)+len(redirects))
+	for i, word := range words {
+		if i == 0 {
+			parts = append(parts, commandName(word.Value))
+		} else {
+			parts = append(parts, word.Value)
+		}
+	}
+	for _, redirect := range redirects {
+		parts = append(parts, redirect.Op+" "+redirect.Target.Value)
+	}
+	return strings.ToLower(strings.Join(parts, " "))
+}
+
+// dataSpace stands in for whitespace inside an argument in patternText. It is
+// not matched by \s, so a pattern cannot read a quoted argument as words.
+const dataSpace = "\u00a0"
+
+// patternText renders a sub-command like commandText for the dangerous
+// patterns. An argument with whitespace in it, such as a quoted message, is
+// data rather than part of the command line, so its words are kept together:
+// echo "rm -rf /" does not look like rm.
+func patternText(words []shellWord, redirects []shellRedirect) string {
+	data := make([]shellWord, len(words))
+	for i, word := range words {
+		data[i] = word
+		if i > 0 && isData(word) {
+			data[i].Value = strings.Join(strings.Fields(word.Value), dataSpace)
+		}
+	}
+	return commandText(data, redirects)
+}
+
+// isData reports whether an argument holds more than one word, which only
+// quoting or escaping produces
+func isData(word shellWord) bool {
+	return strings.ContainsAny(word.Value, " \t\n")
+}
+
+// joinRaw joins words as they were written
+func joinRaw(words []shellWord) string {
+	raw := make([]string, len(words))
+	for i, word := range words {
+		raw[i] = word.Raw
+	}
+	return strings.Join(raw, " ")
+}
+
+// joinValues joins words with quotes removed
+func joinValues(words []shellWord) string {
+	values := make([]string, len(words))
+	for i, word := range words {
+		values[i] = word.Value
+	}
+	return strings.Join(values, " ")
+}
+
+// unwrapCommand skips a wrapper's options and returns the command it runs
+func unwrapCommand(wrapper string, args []shellWord) []shellWord {
+	valueOptions := commandWrappers[wrapper]
+	skippedPositional := !wrapperPositionals[wrapper]
+
+	for len(args) > 0 {
+		arg := args[0].Value
+		switch {
+		case arg == "--":
+			return args[1:]
+		case strings.HasPrefix(arg, "-"):
+			args = args[1:]
+			for _, option := range valueOptions {
+				if arg == option && len(args) > 0 {
+					args = args[1:]
+					break
+				}
+			}
+		case wrapper == "env" && assignmentPrefix.MatchString(arg):
+			args = args[1:]
+		case !skippedPositional:
+			args = args[1:]
+			skippedPositional = true
+		default:
+			return args
+		}
+	}
+	return nil
+}
+
+// findExecCommands returns the commands run by find's -exec, -execdir, -ok and -okdir actions
+func findExecCommands(args []shellWord) [][]shellWord {
+	var commands [][]shellWord
+	for i := 0; i < len(args); i++ {
+		switch args[i].Value {
+		case "-exec", "-execdir", "-ok", "-okdir":
+			start := i + 1
+			for i++; i < len(args) && args[i].Value != ";" && args[i].Value != "+"; i++ {
+			}
+			if i > start {
+				commands = append(commands, args[start:i])
+			}
+		}
+	}
+	return commands
+}
+
+// commandStringArg returns the command string passed with -c (or an option
+// cluster containing c, such as -ec)
+func commandStringArg(args []shellWord) (string, bool) {
+	for i, arg := range args {
+		value := arg.Value
+		if !strings.HasPrefix(value, "-") || strings.HasPrefix(value, "--") {
+			continue
+		}
+		if strings.ContainsRune(value[1:], 'c') && i+1 < len(args) {
+			return args[i+1].Value, true
+		}
+	}
+	return "", false
+}
+
+// hasScriptFile reports whether an interpreter was given a script file or
+// inline code rather than reading the script from stdin
+func hasScriptFile(args []shellWord) bool {
+	for _, arg := range args {
+		value := arg.Value
+		if value == "-" || value == "-s" {
+			return false
+		}
+		if !strings.HasPrefix(value, "-") {
+			return true
+		}
+		if value == "-e" || value == "-c" || value == "-m" {
+			return true
+		}
+	}
+	return false
+}
+
+// hasDownload reports whether any argument substitutes the output of a downloader
+func hasDownload(args []shellWord) bool {
+	for _, arg := range args {
+		for _, sub := range arg.Substitutions {
+			for _, pipeline := range sub.Pipelines {
+				for _, stage := range pipeline.Stages {
+					words := stripKeywords(stage.Words)
+					if len(words) > 0 && downloaders[commandName(words[0].Value)] {
+						return true
+					}
+				}
+			}
+		}
+	}
+	return false
+}
+
+// mergeFindings removes duplicate findings. When the same warning was raised
+// for a command and for one of its sub-commands, only the more specific
+// sub-command is kept.
+func mergeFindings(findings []types.SafetyFinding) []types.SafetyFinding {
+	var merged []types.SafetyFinding
+	for i, finding := range findings {
+		keep := true
+		for j, other := range findings {
+			if i == j || other.Description != finding.Description {
+				continue
+			}
+			if other.SubCommand == finding.SubCommand {
+				// Keep the first of identical findings
+				keep = i < j
+			} else {
+				keep = !strings.Contains(finding.SubCommand, other.SubCommand)
+			}
+			if !keep {
+				break
+			}
+		}
+		if keep {
+			merged = append(merged, finding)
+		}
+	}
+	return merged
+}
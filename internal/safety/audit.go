@@ -5,83 +5,140 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 
 	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
 )
 
-// FileAuditLogger implements AuditLogger interface using file-based storage
+// AuditLogFileName is the name of the audit log in the configuration directory
+const AuditLogFileName = "audit.log"
+
+// DefaultAuditLogMaxSize is the size at which the audit log is rotated by default
+const DefaultAuditLogMaxSize = 10 * 1024 * 1024
+
+// AuditLogOptions configures hash chaining and rotation of a file audit log
+type AuditLogOptions struct {
+	HMACKey []byte // Keys entry hashes with HMAC-SHA256 when set
+	MaxSize int64  // Rotate the log before it grows past this many bytes (0 disables rotation)
+}
+
+// FileAuditLogger implements AuditLogger interface using file-based storage.
+// Each entry carries the hash of the previous one, so edits, deletions and
+// reordering break the chain and are reported by Verify; an anchor file next
+// to the log records the newest entry. Processes sharing the log take a lock
+// on a file next to it while they write.
 type FileAuditLogger struct {
 	logPath string
+	options AuditLogOptions
 	mutex   sync.Mutex
 }
 
 // NewFileAuditLogger creates a new file-based audit logger
 func NewFileAuditLogger(logPath string) (*FileAuditLogger, error) {
+	return NewFileAuditLoggerWithOptions(logPath, nil)
+}
+
+// NewFileAuditLoggerWithOptions creates a file-based audit logger that keys
+// its hash chain and rotates its file as configured by opts
+func NewFileAuditLoggerWithOptions(logPath string, opts *AuditLogOptions) (*FileAuditLogger, error) {
 	// Ensure the directory exists
 	dir := filepath.Dir(logPath)
 	if err := os.MkdirAll(dir, 0750); err != nil {
 		return nil, fmt.Errorf("failed to create audit log directory: %w", err)
 	}
 
-	return &FileAuditLogger{
+	logger := &FileAuditLogger{
 		logPath: logPath,
-	}, nil
+	}
+	if opts != nil {
+		logger.options = *opts
+	}
+	return logger, nil
 }
 
-// LogAuditEvent logs an audit event to the file
+// LogAuditEvent chains an audit event to the last entry and appends it to the file
 func (f *FileAuditLogger) LogAuditEvent(entry *types.AuditEntry) error {
 	f.mutex.Lock()
 	defer f.mutex.Unlock()
+	unlock, err := f.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
 
-	// Open file for appending
-	file, err := os.OpenFile(f.logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	// The chain is read back from disk so that processes sharing the log extend it
+	prevHash, err := f.lastHash()
 	if err != nil {
-		return fmt.Errorf("failed to open audit log file: %w", err)
+		return err
+	}
+
+	entry.PrevHash = prevHash
+	entry.Keyed = len(f.options.HMACKey) > 0
+	entry.Hash, err = hashAuditEntry(entry, f.options.HMACKey)
+	if err != nil {
+		return fmt.Errorf("failed to hash audit entry: %w", err)
 	}
-	defer file.Close()
 
 	// Marshal entry to JSON
 	data, err := json.Marshal(entry)
 	if err != nil {
 		return fmt.Errorf("failed to marshal audit entry: %w", err)
 	}
+	data = append(data, '\n')
+
+	if err := f.rotateIfNeeded(int64(len(data))); err != nil {
+		return err
+	}
+
+	// Open file for appending
+	file, err := os.OpenFile(f.logPath, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0640)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log file: %w", err)
+	}
+	defer file.Close()
+
+	// Start a new line after an entry cut short by an interrupted write
+	if partial, err := endsWithPartialLine(file); err != nil {
+		return fmt.Errorf("failed to read audit log file: %w", err)
+	} else if partial {
+		data = append([]byte{'\n'}, data...)
+	}
 
 	// Write JSON line
-	if _, err := file.Write(append(data, '\n')); err != nil {
+	if _, err := file.Write(data); err != nil {
 		return fmt.Errorf("failed to write audit entry: %w", err)
 	}
 
-	return nil
+	return f.advanceAnchor(entry)
 }
 
-// GetAuditLog retrieves audit log entries based on filter criteria
+// GetAuditLog retrieves audit log entries based on filter criteria, oldest
+// first, including entries in rotated files
 func (f *FileAuditLogger) GetAuditLog(filter *types.AuditFilter) ([]*types.AuditEntry, error) {
 	f.mutex.Lock()
 	defer f.mutex.Unlock()
-
-	file, err := os.Open(f.logPath)
+	unlock, err := f.lock()
 	if err != nil {
-		if os.IsNotExist(err) {
-			return []*types.AuditEntry{}, nil
-		}
-		return nil, fmt.Errorf("failed to open audit log file: %w", err)
+		return nil, err
 	}
-	defer file.Close()
+	defer unlock()
 
-	var entries []*types.AuditEntry
-	decoder := json.NewDecoder(file)
+	files, err := f.logFiles()
+	if err != nil {
+		return nil, err
+	}
 
-	for decoder.More() {
-		var entry types.AuditEntry
-		if err := decoder.Decode(&entry); err != nil {
+	entries := []*types.AuditEntry{}
+	for _, path := range files {
+		err := readAuditFile(path, func(line int, entry *types.AuditEntry, err error) {
 			// Skip malformed entries but continue processing
-			continue
-		}
-
-		// Apply filters
-		if f.matchesFilter(&entry, filter) {
-			entries = append(entries, &entry)
+			if err == nil && f.matchesFilter(entry, filter) {
+				entries = append(entries, entry)
+			}
+		})
+		if err != nil {
+			return nil, err
 		}
 	}
 
@@ -106,6 +163,10 @@ func (f *FileAuditLogger) matchesFilter(entry *types.AuditEntry, filter *types.A
 		return false
 	}
 
+	if filter.SessionID != "" && entry.SessionID != filter.SessionID {
+		return false
+	}
+
 	if filter.Action != nil && entry.Action != *filter.Action {
 		return false
 	}
@@ -117,6 +178,16 @@ func (f *FileAuditLogger) matchesFilter(entry *types.AuditEntry, filter *types.A
 	return true
 }
 
+// ParseAuditAction converts an action name such as "executed" to an AuditAction
+func ParseAuditAction(name string) (types.AuditAction, error) {
+	for action := types.AuditActionValidated; action <= types.AuditActionExecuted; action++ {
+		if strings.EqualFold(strings.TrimSpace(name), action.String()) {
+			return action, nil
+		}
+	}
+	return types.AuditActionValidated, fmt.Errorf("unknown audit action %q", name)
+}
+
 // NoOpAuditLogger implements AuditLogger interface as a no-op for testing
 type NoOpAuditLogger struct{}
 
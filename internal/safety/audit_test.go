@@ -0,0 +1,391 @@
+package safety
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+func writeAuditEntries(t *testing.T, logger *FileAuditLogger, commands ...string) {
+	t.Helper()
+	for _, command := range commands {
+		entry := &types.AuditEntry{
+			Timestamp: time.Now(),
+			Command:   command,
+			UserID:    "alice",
+			Action:    types.AuditActionGenerated,
+		}
+		if err := logger.LogAuditEvent(entry); err != nil {
+			t.Fatalf("failed to log audit event: %v", err)
+		}
+	}
+}
+
+func readAuditLines(t *testing.T, path string) [][]byte {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read audit log: %v", err)
+	}
+	return bytes.SplitAfter(bytes.TrimRight(data, "\n"), []byte("\n"))
+}
+
+func writeAuditLines(t *testing.T, path string, lines [][]byte) {
+	t.Helper()
+	var data []byte
+	for _, line := range lines {
+		data = append(data, bytes.TrimRight(line, "\n")...)
+		data = append(data, '\n')
+	}
+	if err := os.WriteFile(path, data, 0640); err != nil {
+		t.Fatalf("failed to write audit log: %v", err)
+	}
+}
+
+func verifyProblems(t *testing.T, logger *FileAuditLogger) []string {
+	t.Helper()
+	result, err := logger.Verify()
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	problems := make([]string, 0, len(result.Problems))
+	for _, problem := range result.Problems {
+		problems = append(problems, problem.Description)
+	}
+	return problems
+}
+
+func TestFileAuditLogger_Chain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), AuditLogFileName)
+	logger, err := NewFileAuditLogger(path)
+	if err != nil {
+		t.Fatalf("NewFileAuditLogger() error = %v", err)
+	}
+	writeAuditEntries(t, logger, "ls", "pwd", "whoami")
+
+	entries, err := logger.GetAuditLog(nil)
+	if err != nil {
+		t.Fatalf("GetAuditLog() error = %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(entries))
+	}
+	if entries[0].PrevHash != "" {
+		t.Errorf("expected the first entry to start the chain, got prev hash %q", entries[0].PrevHash)
+	}
+	for i := 1; i < len(entries); i++ {
+		if entries[i].PrevHash != entries[i-1].Hash {
+			t.Errorf("entry %d is not chained to entry %d", i, i-1)
+		}
+	}
+
+	result, err := logger.Verify()
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if !result.Valid() || result.Entries != 3 || result.HeadHash != entries[2].Hash {
+		t.Errorf("unexpected verification of intact log: %+v", result)
+	}
+}
+
+func TestFileAuditLogger_VerifyDetectsTampering(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(lines [][]byte) [][]byte
+		want   string
+	}{
+		{
+			name: "edited entry",
+			tamper: func(lines [][]byte) [][]byte {
+				lines[1] = bytes.Replace(lines[1], []byte("pwd"), []byte("rm -rf /"), 1)
+				return lines
+			},
+			want: "entry was modified",
+		},
+		{
+			name: "deleted entry",
+			tamper: func(lines [][]byte) [][]byte {
+				return append(lines[:1], lines[2:]...)
+			},
+			want: "does not follow the previous entry",
+		},
+		{
+			name: "reordered entries",
+			tamper: func(lines [][]byte) [][]byte {
+				lines[1], lines[2] = lines[2], lines[1]
+				return lines
+			},
+			want: "does not follow the previous entry",
+		},
+		{
+			name: "deleted first entry",
+			tamper: func(lines [][]byte) [][]byte {
+				return lines[1:]
+			},
+			want: "chain does not start at the first entry",
+		},
+		{
+			name: "malformed entry",
+			tamper: func(lines [][]byte) [][]byte {
+				lines[1] = []byte("{not json")
+				return lines
+			},
+			want: "entry is malformed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), AuditLogFileName)
+			logger, err := NewFileAuditLogger(path)
+			if err != nil {
+				t.Fatalf("NewFileAuditLogger() error = %v", err)
+			}
+			writeAuditEntries(t, logger, "ls", "pwd", "whoami")
+			writeAuditLines(t, path, tt.tamper(readAuditLines(t, path)))
+
+			problems := verifyProblems(t, logger)
+			if len(problems) == 0 || !strings.Contains(strings.Join(problems, "\n"), tt.want) {
+				t.Errorf("expected a problem containing %q, got %v", tt.want, problems)
+			}
+		})
+	}
+}
+
+func TestFileAuditLogger_HMACKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), AuditLogFileName)
+	keyed, err := NewFileAuditLoggerWithOptions(path, &AuditLogOptions{HMACKey: []byte("secret")})
+	if err != nil {
+		t.Fatalf("NewFileAuditLoggerWithOptions() error = %v", err)
+	}
+	writeAuditEntries(t, keyed, "ls", "pwd")
+
+	if problems := verifyProblems(t, keyed); len(problems) != 0 {
+		t.Errorf("expected keyed log to verify, got %v", problems)
+	}
+
+	// A keyed chain cannot be checked, or recomputed, without the key
+	unkeyed, _ := NewFileAuditLogger(path)
+	if problems := verifyProblems(t, unkeyed); len(problems) != 1 || !strings.Contains(problems[0], "no audit key") {
+		t.Errorf("expected a missing key problem, got %v", problems)
+	}
+	wrongKey, _ := NewFileAuditLoggerWithOptions(path, &AuditLogOptions{HMACKey: []byte("guess")})
+	if problems := verifyProblems(t, wrongKey); len(problems) != 3 || !strings.Contains(problems[0], "anchor") {
+		t.Errorf("expected the anchor and both entries to fail with the wrong key, got %v", problems)
+	}
+
+	// Entries appended without the key are flagged
+	writeAuditEntries(t, unkeyed, "whoami")
+	if problems := verifyProblems(t, keyed); len(problems) != 1 || !strings.Contains(problems[0], "unkeyed entry") {
+		t.Errorf("expected an unkeyed entry problem, got %v", problems)
+	}
+}
+
+func TestFileAuditLogger_UnkeyedForgery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), AuditLogFileName)
+	key := &AuditLogOptions{HMACKey: []byte("secret")}
+	keyed, _ := NewFileAuditLoggerWithOptions(path, key)
+	writeAuditEntries(t, keyed, "ls", "rm -rf /srv")
+
+	// Without the key, the log can only be rewritten as unkeyed entries
+	forged := filepath.Join(t.TempDir(), AuditLogFileName)
+	unkeyed, _ := NewFileAuditLogger(forged)
+	writeAuditEntries(t, unkeyed, "ls")
+	data, _ := os.ReadFile(forged)
+	if err := os.WriteFile(path, data, 0640); err != nil {
+		t.Fatal(err)
+	}
+	problems := strings.Join(verifyProblems(t, keyed), "\n")
+	if !strings.Contains(problems, "unkeyed entry in a log keyed with HMAC") {
+		t.Errorf("expected the forged unkeyed entry to be reported, got %v", problems)
+	}
+
+	// Nor does removing the anchor clear unkeyed entries
+	os.Remove(keyed.anchorPath())
+	if problems := verifyProblems(t, keyed); len(problems) != 1 || !strings.Contains(problems[0], "unkeyed entry") {
+		t.Errorf("expected the forged unkeyed entry to be reported, got %v", problems)
+	}
+}
+
+func TestFileAuditLogger_BeginKeyedChain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), AuditLogFileName)
+	unkeyed, _ := NewFileAuditLogger(path)
+	writeAuditEntries(t, unkeyed, "ls", "pwd")
+
+	keyed, _ := NewFileAuditLoggerWithOptions(path, &AuditLogOptions{HMACKey: []byte("secret")})
+	if err := keyed.BeginKeyedChain(); err != nil {
+		t.Fatalf("BeginKeyedChain() error = %v", err)
+	}
+	writeAuditEntries(t, keyed, "whoami")
+	if problems := verifyProblems(t, keyed); len(problems) != 0 {
+		t.Errorf("expected entries from before keying began to verify, got %v", problems)
+	}
+
+	writeAuditEntries(t, unkeyed, "date")
+	if problems := verifyProblems(t, keyed); len(problems) != 1 || !strings.Contains(problems[0], "unkeyed entry") {
+		t.Errorf("expected an unkeyed entry after keying began to be reported, got %v", problems)
+	}
+}
+
+func TestFileAuditLogger_Truncation(t *testing.T) {
+	for _, options := range []*AuditLogOptions{nil, {HMACKey: []byte("secret")}} {
+		path := filepath.Join(t.TempDir(), AuditLogFileName)
+		logger, _ := NewFileAuditLoggerWithOptions(path, options)
+		writeAuditEntries(t, logger, "ls", "pwd", "rm -rf /srv")
+
+		lines := readAuditLines(t, path)
+		writeAuditLines(t, path, lines[:2])
+
+		result, err := logger.Verify()
+		if err != nil {
+			t.Fatalf("Verify() error = %v", err)
+		}
+		if !result.Anchored || len(result.Problems) != 1 || !strings.Contains(result.Problems[0].Description, "newest entries were removed") {
+			t.Errorf("expected the removed entry to be reported, got %+v", result)
+		}
+	}
+}
+
+func TestFileAuditLogger_Rotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, AuditLogFileName)
+	logger, err := NewFileAuditLoggerWithOptions(path, &AuditLogOptions{MaxSize: 600})
+	if err != nil {
+		t.Fatalf("NewFileAuditLoggerWithOptions() error = %v", err)
+	}
+	commands := []string{"ls", "pwd", "whoami", "date", "uptime", "df -h"}
+	writeAuditEntries(t, logger, commands...)
+
+	sequences, err := logger.rotatedSequences()
+	if err != nil {
+		t.Fatalf("rotatedSequences() error = %v", err)
+	}
+	if len(sequences) < 2 {
+		t.Fatalf("expected the log to rotate at least twice, got %v", sequences)
+	}
+	for _, file := range append([]string{path}, logger.rotatedPath(1)) {
+		if info, err := os.Stat(file); err != nil || info.Size() > 600 {
+			t.Errorf("expected %s to exist within the size limit", file)
+		}
+	}
+
+	entries, err := logger.GetAuditLog(nil)
+	if err != nil {
+		t.Fatalf("GetAuditLog() error = %v", err)
+	}
+	if len(entries) != len(commands) {
+		t.Fatalf("expected %d entries across files, got %d", len(commands), len(entries))
+	}
+	for i, entry := range entries {
+		if entry.Command != commands[i] {
+			t.Errorf("entry %d: expected %q, got %q", i, commands[i], entry.Command)
+		}
+	}
+
+	if problems := verifyProblems(t, logger); len(problems) != 0 {
+		t.Errorf("expected the chain to continue across rotated files, got %v", problems)
+	}
+
+	// Removing a rotated file breaks the chain
+	if err := os.Remove(logger.rotatedPath(1)); err != nil {
+		t.Fatalf("failed to remove rotated file: %v", err)
+	}
+	problems := strings.Join(verifyProblems(t, logger), "\n")
+	if !strings.Contains(problems, "rotated audit log file is missing") {
+		t.Errorf("expected a missing file problem, got %v", problems)
+	}
+}
+
+func TestFileAuditLogger_LegacyEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), AuditLogFileName)
+	legacy := `{"Timestamp":"2024-01-01T00:00:00Z","Command":"ls","UserID":"alice","Action":0}`
+	if err := os.WriteFile(path, []byte(legacy+"\n"), 0640); err != nil {
+		t.Fatalf("failed to write legacy log: %v", err)
+	}
+
+	logger, _ := NewFileAuditLogger(path)
+	writeAuditEntries(t, logger, "pwd")
+
+	result, err := logger.Verify()
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if !result.Valid() || result.Unchained != 1 || result.Entries != 1 {
+		t.Errorf("expected unchained legacy entries before the chain to verify, got %+v", result)
+	}
+}
+
+func TestFileAuditLogger_ConcurrentWriters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), AuditLogFileName)
+
+	// Separate loggers share nothing but the file, like separate processes
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		logger, err := NewFileAuditLoggerWithOptions(path, &AuditLogOptions{MaxSize: 2048})
+		if err != nil {
+			t.Fatalf("NewFileAuditLoggerWithOptions() error = %v", err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 25; j++ {
+				writeAuditEntries(t, logger, "ls")
+			}
+		}()
+	}
+	wg.Wait()
+
+	logger, _ := NewFileAuditLoggerWithOptions(path, &AuditLogOptions{MaxSize: 2048})
+	result, err := logger.Verify()
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if !result.Valid() || result.Entries != 200 {
+		t.Errorf("expected concurrent writers to extend one chain, got %d entries with problems %v", result.Entries, result.Problems)
+	}
+}
+
+func TestFileAuditLogger_TruncatedEntry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), AuditLogFileName)
+	logger, _ := NewFileAuditLogger(path)
+	writeAuditEntries(t, logger, "ls", "pwd")
+
+	// A crash in the middle of a write leaves part of an entry without a newline
+	lines := readAuditLines(t, path)
+	data, _ := os.ReadFile(path)
+	data = append(data, lines[1][:len(lines[1])/2]...)
+	if err := os.WriteFile(path, data, 0640); err != nil {
+		t.Fatalf("failed to truncate audit log: %v", err)
+	}
+
+	writeAuditEntries(t, logger, "whoami")
+
+	entries, err := logger.GetAuditLog(nil)
+	if err != nil {
+		t.Fatalf("GetAuditLog() error = %v", err)
+	}
+	if len(entries) != 3 || entries[2].Command != "whoami" || entries[2].PrevHash != entries[1].Hash {
+		t.Fatalf("expected the new entry on a line of its own, chained to the last intact entry, got %+v", entries)
+	}
+
+	problems := verifyProblems(t, logger)
+	if len(problems) != 1 || !strings.Contains(problems[0], "malformed") {
+		t.Errorf("expected the truncated entry to be reported, got %v", problems)
+	}
+}
+
+func TestParseAuditAction(t *testing.T) {
+	action, err := ParseAuditAction("Executed")
+	if err != nil || action != types.AuditActionExecuted {
+		t.Errorf("ParseAuditAction(Executed) = %v, %v", action, err)
+	}
+	if _, err := ParseAuditAction("deleted"); err == nil {
+		t.Error("expected an error for an unknown action")
+	}
+}
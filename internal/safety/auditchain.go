@@ -0,0 +1,548 @@
+package safety
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+// AuditChainProblem describes a place where an audit log's hash chain is broken
+type AuditChainProblem struct {
+	File        string
+	Line        int // 0 when the problem concerns a whole file
+	Description string
+}
+
+// AuditVerification is the result of verifying an audit log's hash chain
+type AuditVerification struct {
+	Files     []string // Log files checked, oldest first
+	Entries   int      // Chained entries checked
+	Unchained int      // Entries at the start of the log written before hash chaining
+	Keyed     bool     // Whether any entry is HMAC-keyed
+	HeadHash  string   // Hash of the newest entry; keep a copy elsewhere to detect truncation
+	Anchored  bool     // Whether the head was checked against the anchor file
+	Problems  []AuditChainProblem
+}
+
+// Valid reports whether the chain verified without problems
+func (v *AuditVerification) Valid() bool {
+	return len(v.Problems) == 0
+}
+
+// Verify checks the hash chain across the audit log and its rotated files,
+// reporting edited, deleted, inserted and reordered entries. Entries removed
+// from the end of the log are found through the anchor file, which only the
+// audit key protects from being rewritten along with them.
+func (f *FileAuditLogger) Verify() (*AuditVerification, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	unlock, err := f.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	result := &AuditVerification{}
+	report := func(file string, line int, format string, args ...interface{}) {
+		result.Problems = append(result.Problems, AuditChainProblem{
+			File:        file,
+			Line:        line,
+			Description: fmt.Sprintf(format, args...),
+		})
+	}
+
+	sequences, err := f.rotatedSequences()
+	if err != nil {
+		return nil, err
+	}
+	expected := 1
+	for _, seq := range sequences {
+		for ; expected < seq; expected++ {
+			report(f.rotatedPath(expected), 0, "rotated audit log file is missing")
+		}
+		expected = seq + 1
+	}
+
+	files, err := f.logFiles()
+	if err != nil {
+		return nil, err
+	}
+	result.Files = files
+
+	// With the audit key, unkeyed entries are only accepted up to the entry
+	// the anchor records keying to have begun after
+	keyed := len(f.options.HMACKey) > 0
+	anchor, err := f.verifiedAnchor()
+	if err != nil {
+		report(f.anchorPath(), 0, "%v", err)
+	}
+	unkeyedAllowed := anchor != nil && anchor.Keyed && anchor.KeyedFrom != ""
+	headSeen := anchor == nil || anchor.Head == ""
+
+	prevHash := ""
+	chained := false
+	resync := false
+	missingKeyReported := false
+	for _, path := range files {
+		err := readAuditFile(path, func(line int, entry *types.AuditEntry, err error) {
+			if err != nil {
+				report(path, line, "entry is malformed: %v", err)
+				// The next entry cannot be checked against an unreadable one
+				resync = true
+				return
+			}
+
+			if entry.Hash == "" {
+				if chained {
+					report(path, line, "entry has no hash")
+					resync = true
+				} else {
+					result.Unchained++
+				}
+				return
+			}
+
+			result.Entries++
+			switch {
+			case entry.Keyed:
+				result.Keyed = true
+				if len(f.options.HMACKey) == 0 {
+					if !missingKeyReported {
+						report(path, line, "entries are HMAC-keyed but no audit key is configured")
+						missingKeyReported = true
+					}
+				} else if sum, err := hashAuditEntry(entry, f.options.HMACKey); err != nil || sum != entry.Hash {
+					report(path, line, "entry was modified (hash mismatch)")
+				}
+			case keyed && !unkeyedAllowed:
+				report(path, line, "unkeyed entry in a log keyed with HMAC")
+			case result.Keyed:
+				report(path, line, "unkeyed entry follows HMAC-keyed entries")
+			default:
+				if sum, err := hashAuditEntry(entry, nil); err != nil || sum != entry.Hash {
+					report(path, line, "entry was modified (hash mismatch)")
+				}
+			}
+			if unkeyedAllowed && entry.Hash == anchor.KeyedFrom {
+				unkeyedAllowed = false
+			}
+			if anchor != nil && entry.Hash == anchor.Head {
+				headSeen = true
+			}
+
+			if !resync && entry.PrevHash != prevHash {
+				if chained {
+					report(path, line, "entry does not follow the previous entry (entries were deleted, inserted or reordered)")
+				} else {
+					report(path, line, "chain does not start at the first entry (earlier entries were deleted)")
+				}
+			}
+
+			chained = true
+			resync = false
+			prevHash = entry.Hash
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if keyed && unkeyedAllowed {
+		report(f.anchorPath(), 0, "the entry keying began after is missing")
+	}
+	if !headSeen {
+		report(f.anchorPath(), 0, "newest entries were removed (the log ends before the anchored head)")
+	}
+	result.HeadHash = prevHash
+	result.Anchored = anchor != nil
+	return result, nil
+}
+
+// auditAnchor records the head of the chain outside the log, so that entries
+// removed from its end are noticed, and where keying began. With an audit key
+// it carries an HMAC, so it cannot be rewritten along with the log.
+type auditAnchor struct {
+	Head      string `json:"head"`       // Hash of the newest entry
+	Keyed     bool   `json:"keyed"`      // Whether KeyedFrom is known
+	KeyedFrom string `json:"keyed_from"` // Hash of the last entry before keying began, "" for the start
+	MAC       string `json:"mac,omitempty"`
+}
+
+// anchorPath returns the path of the anchor file
+func (f *FileAuditLogger) anchorPath() string {
+	return f.logPath + ".anchor"
+}
+
+// sum returns the anchor's HMAC under key
+func (a *auditAnchor) sum(key []byte) string {
+	h := hmac.New(sha256.New, key)
+	fmt.Fprintf(h, "%s\n%v\n%s", a.Head, a.Keyed, a.KeyedFrom)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// readAnchor returns the anchor, or nil when there is none
+func (f *FileAuditLogger) readAnchor() (*auditAnchor, error) {
+	data, err := os.ReadFile(f.anchorPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read audit log anchor: %w", err)
+	}
+	var anchor auditAnchor
+	if err := json.Unmarshal(data, &anchor); err != nil {
+		return nil, fmt.Errorf("audit log anchor is malformed: %v", err)
+	}
+	return &anchor, nil
+}
+
+// verifiedAnchor returns the anchor when it can be trusted: with an audit key,
+// only one carrying its HMAC. The error describes an anchor that was rejected.
+func (f *FileAuditLogger) verifiedAnchor() (*auditAnchor, error) {
+	anchor, err := f.readAnchor()
+	if err != nil || anchor == nil {
+		return nil, err
+	}
+	if len(f.options.HMACKey) > 0 && !hmac.Equal([]byte(anchor.MAC), []byte(anchor.sum(f.options.HMACKey))) {
+		return nil, fmt.Errorf("audit log anchor was modified or written without the audit key")
+	}
+	return anchor, nil
+}
+
+// writeAnchor replaces the anchor, signing it with the audit key if there is one
+func (f *FileAuditLogger) writeAnchor(anchor *auditAnchor) error {
+	anchor.MAC = ""
+	if len(f.options.HMACKey) > 0 {
+		anchor.MAC = anchor.sum(f.options.HMACKey)
+	}
+	data, err := json.Marshal(anchor)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit log anchor: %w", err)
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(f.logPath), ".audit-anchor-*")
+	if err != nil {
+		return fmt.Errorf("failed to write audit log anchor: %w", err)
+	}
+	defer os.Remove(temp.Name())
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		return fmt.Errorf("failed to write audit log anchor: %w", err)
+	}
+	if err := temp.Close(); err != nil {
+		return fmt.Errorf("failed to write audit log anchor: %w", err)
+	}
+	if err := os.Rename(temp.Name(), f.anchorPath()); err != nil {
+		return fmt.Errorf("failed to write audit log anchor: %w", err)
+	}
+	return nil
+}
+
+// advanceAnchor moves the anchor to a newly appended entry. A keyed log keeps
+// where keying began; a new log is keyed from its start. A signed anchor is
+// left alone by a logger without the key, which could not sign it again.
+func (f *FileAuditLogger) advanceAnchor(entry *types.AuditEntry) error {
+	next := &auditAnchor{Head: entry.Hash}
+	if len(f.options.HMACKey) == 0 {
+		if current, err := f.readAnchor(); err == nil && current != nil && current.MAC != "" {
+			return nil
+		}
+		return f.writeAnchor(next)
+	}
+
+	current, err := f.verifiedAnchor()
+	switch {
+	case err == nil && current != nil && current.Keyed:
+		next.Keyed, next.KeyedFrom = true, current.KeyedFrom
+	case entry.PrevHash == "":
+		next.Keyed = true
+	}
+	return f.writeAnchor(next)
+}
+
+// BeginKeyedChain records that keying begins after the newest entry, so that
+// the unkeyed entries written before an audit key was set up still verify.
+// Unkeyed entries after it are reported.
+func (f *FileAuditLogger) BeginKeyedChain() error {
+	if len(f.options.HMACKey) == 0 {
+		return fmt.Errorf("no audit key is configured")
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	unlock, err := f.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	head, err := f.lastHash()
+	if err != nil {
+		return err
+	}
+	return f.writeAnchor(&auditAnchor{Head: head, Keyed: true, KeyedFrom: head})
+}
+
+// hashAuditEntry returns the hex digest of entry with its Hash field cleared,
+// an HMAC-SHA256 under key for keyed entries and SHA-256 otherwise
+func hashAuditEntry(entry *types.AuditEntry, key []byte) (string, error) {
+	unhashed := *entry
+	unhashed.Hash = ""
+	data, err := json.Marshal(&unhashed)
+	if err != nil {
+		return "", err
+	}
+
+	var h hash.Hash
+	if entry.Keyed {
+		h = hmac.New(sha256.New, key)
+	} else {
+		h = sha256.New()
+	}
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// lock takes the lock every process writing the log holds while it reads the
+// chain's head, rotates and appends, so that concurrent writers extend one
+// chain. It is on a file of its own, as rotation renames the log.
+func (f *FileAuditLogger) lock() (unlock func(), err error) {
+	file, err := os.OpenFile(f.logPath+".lock", os.O_CREATE|os.O_RDWR, 0640)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log lock: %w", err)
+	}
+	if err := lockFile(file); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to lock audit log: %w", err)
+	}
+	return func() {
+		unlockFile(file)
+		file.Close()
+	}, nil
+}
+
+// lastHash returns the hash of the newest well-formed entry, or "" to start a
+// new chain when the log has none. A malformed entry, such as one cut short by
+// a crash, is skipped: the chain continues from the entry before it, and
+// Verify reports the malformed line.
+func (f *FileAuditLogger) lastHash() (string, error) {
+	files, err := f.logFiles()
+	if err != nil {
+		return "", err
+	}
+
+	for i := len(files) - 1; i >= 0; i-- {
+		line, err := lastLine(files[i])
+		if err != nil {
+			return "", fmt.Errorf("failed to read audit log file: %w", err)
+		}
+		if line == nil {
+			continue
+		}
+		var entry types.AuditEntry
+		if err := json.Unmarshal(line, &entry); err == nil {
+			return entry.Hash, nil
+		}
+
+		// Look further back for the last entry that can be chained to
+		hash, found := "", false
+		err = readAuditFile(files[i], func(_ int, entry *types.AuditEntry, err error) {
+			if err == nil {
+				hash, found = entry.Hash, true
+			}
+		})
+		if err != nil {
+			return "", err
+		}
+		if found {
+			return hash, nil
+		}
+	}
+
+	return "", nil
+}
+
+// endsWithPartialLine reports whether file ends without a newline, as it does
+// when a write was interrupted
+func endsWithPartialLine(file *os.File) (bool, error) {
+	info, err := file.Stat()
+	if err != nil || info.Size() == 0 {
+		return false, err
+	}
+	last := make([]byte, 1)
+	if _, err := file.ReadAt(last, info.Size()-1); err != nil {
+		return false, err
+	}
+	return last[0] != '\n', nil
+}
+
+// rotateIfNeeded moves the log aside when appending incoming bytes would grow
+// it past the configured maximum size. Rotated files are numbered from 1,
+// oldest first, and the chain continues into the new file.
+func (f *FileAuditLogger) rotateIfNeeded(incoming int64) error {
+	if f.options.MaxSize <= 0 {
+		return nil
+	}
+
+	info, err := os.Stat(f.logPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to stat audit log file: %w", err)
+	}
+	if info.Size() == 0 || info.Size()+incoming <= f.options.MaxSize {
+		return nil
+	}
+
+	sequences, err := f.rotatedSequences()
+	if err != nil {
+		return err
+	}
+	next := 1
+	if len(sequences) > 0 {
+		next = sequences[len(sequences)-1] + 1
+	}
+
+	if err := os.Rename(f.logPath, f.rotatedPath(next)); err != nil {
+		return fmt.Errorf("failed to rotate audit log file: %w", err)
+	}
+	return nil
+}
+
+// logFiles returns the rotated log files followed by the current one, oldest first
+func (f *FileAuditLogger) logFiles() ([]string, error) {
+	sequences, err := f.rotatedSequences()
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]string, 0, len(sequences)+1)
+	for _, seq := range sequences {
+		files = append(files, f.rotatedPath(seq))
+	}
+	return append(files, f.logPath), nil
+}
+
+// rotatedSequences returns the sequence numbers of the rotated log files, in order
+func (f *FileAuditLogger) rotatedSequences() ([]int, error) {
+	entries, err := os.ReadDir(filepath.Dir(f.logPath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to list audit log files: %w", err)
+	}
+
+	prefix := filepath.Base(f.logPath) + "."
+	var sequences []int
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		seq, err := strconv.Atoi(strings.TrimPrefix(name, prefix))
+		if err != nil || seq < 1 {
+			continue
+		}
+		sequences = append(sequences, seq)
+	}
+
+	sort.Ints(sequences)
+	return sequences, nil
+}
+
+// rotatedPath returns the path of the rotated log file with the given sequence number
+func (f *FileAuditLogger) rotatedPath(seq int) string {
+	return fmt.Sprintf("%s.%d", f.logPath, seq)
+}
+
+// readAuditFile calls fn with each entry of an audit log file and its line
+// number, or with the decoding error of a malformed line. A missing file has
+// no entries.
+func readAuditFile(path string, fn func(line int, entry *types.AuditEntry, err error)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to open audit log file: %w", err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for lineNumber := 1; ; lineNumber++ {
+		data, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(data)) > 0 {
+			var entry types.AuditEntry
+			if decodeErr := json.Unmarshal(data, &entry); decodeErr != nil {
+				fn(lineNumber, nil, decodeErr)
+			} else {
+				fn(lineNumber, &entry, nil)
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read audit log file: %w", err)
+		}
+	}
+}
+
+// lastLine returns the last non-empty line of a file, reading backwards so
+// large logs are not read in full. It returns nil for a missing or empty file.
+func lastLine(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	const chunkSize = 4096
+	var tail []byte
+	for offset := info.Size(); offset > 0; {
+		n := int64(chunkSize)
+		if offset < n {
+			n = offset
+		}
+		offset -= n
+
+		chunk := make([]byte, n)
+		if _, err := file.ReadAt(chunk, offset); err != nil && err != io.EOF {
+			return nil, err
+		}
+		tail = append(chunk, tail...)
+
+		trimmed := bytes.TrimRight(tail, "\r\n\t ")
+		if i := bytes.LastIndexByte(trimmed, '\n'); i >= 0 {
+			return trimmed[i+1:], nil
+		}
+		if offset == 0 && len(trimmed) > 0 {
+			return trimmed, nil
+		}
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,449 @@
+package safety
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+const (
+	// PolicyFileName is the name of the user's policy file in the config directory
+	PolicyFileName = "policy.json"
+	// RepoPolicyFileName is the name of policy files picked up from the working
+	// directory and its parents
+	RepoPolicyFileName = ".nl-to-shell-policy.json"
+)
+
+// PolicyAction is what a policy rule does to the commands it matches
+type PolicyAction string
+
+const (
+	// PolicyActionDeny flags matching commands at the rule's level (Critical by default)
+	PolicyActionDeny PolicyAction = "deny"
+	// PolicyActionAllow lets matching commands run without confirmation, up to
+	// the rule's level (Warning by default)
+	PolicyActionAllow PolicyAction = "allow"
+	// PolicyActionLevel sets the danger level of matching commands
+	PolicyActionLevel PolicyAction = "level"
+)
+
+// Policy is a set of safety rules loaded from a policy file
+type Policy struct {
+	Rules        []PolicyRule `json:"rules"`
+	TrustedRepos []string     `json:"trusted_repos,omitempty"` // Directory globs whose repository policies may relax rules (user policy only)
+}
+
+// PolicyRule is a single rule of a policy file
+type PolicyRule struct {
+	Name        string       `json:"name"`
+	Pattern     string       `json:"pattern"` // Regular expression matched against each command and sub-command
+	Action      PolicyAction `json:"action"`
+	Level       string       `json:"level,omitempty"`       // safe, warning, dangerous or critical
+	Directories []string     `json:"directories,omitempty"` // Directory globs the rule is limited to; a trailing /** includes subdirectories
+	Description string       `json:"description,omitempty"`
+
+	pattern *regexp.Regexp
+	level   types.DangerLevel
+	baseDir string
+}
+
+// LoadPolicy reads and compiles a policy file
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var policy Policy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("invalid policy file: %w", err)
+	}
+
+	baseDir := filepath.Dir(path)
+	for i := range policy.Rules {
+		if err := policy.Rules[i].compile(baseDir); err != nil {
+			return nil, err
+		}
+	}
+
+	return &policy, nil
+}
+
+// compile validates the rule and prepares it for matching
+func (r *PolicyRule) compile(baseDir string) error {
+	if r.Name == "" {
+		return fmt.Errorf("policy rule with pattern %q has no name", r.Pattern)
+	}
+	if r.Pattern == "" {
+		return fmt.Errorf("policy rule '%s' has no pattern", r.Name)
+	}
+
+	pattern, err := regexp.Compile(r.Pattern)
+	if err != nil {
+		return fmt.Errorf("policy rule '%s' has an invalid pattern: %w", r.Name, err)
+	}
+	r.pattern = pattern
+	r.baseDir = baseDir
+
+	switch r.Action {
+	case PolicyActionDeny:
+		r.level = types.Critical
+	case PolicyActionAllow:
+		r.level = types.Warning
+	case PolicyActionLevel:
+		if r.Level == "" {
+			return fmt.Errorf("policy rule '%s' sets a level but does not name one", r.Name)
+		}
+	default:
+		return fmt.Errorf("policy rule '%s' has unknown action %q", r.Name, r.Action)
+	}
+
+	if r.Level != "" {
+		level, err := ParseDangerLevel(r.Level)
+		if err != nil {
+			return fmt.Errorf("policy rule '%s': %w", r.Name, err)
+		}
+		r.level = level
+	}
+
+	return nil
+}
+
+// ParseDangerLevel converts a level name such as "warning" to a DangerLevel
+func ParseDangerLevel(name string) (types.DangerLevel, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "safe":
+		return types.Safe, nil
+	case "warning":
+		return types.Warning, nil
+	case "dangerous":
+		return types.Dangerous, nil
+	case "critical":
+		return types.Critical, nil
+	default:
+		return types.Safe, fmt.Errorf("unknown danger level %q", name)
+	}
+}
+
+// matches reports whether the rule matches a command as written or normalized
+func (r *PolicyRule) matches(target policyTarget) bool {
+	return r.pattern.MatchString(target.source) || r.pattern.MatchString(target.text)
+}
+
+// appliesTo reports whether the rule is in scope for the working directory
+func (r *PolicyRule) appliesTo(workingDir string) bool {
+	if len(r.Directories) == 0 {
+		return true
+	}
+	for _, glob := range r.Directories {
+		if matchDirectory(expandDirectoryGlob(glob, r.baseDir), workingDir) {
+			return true
+		}
+	}
+	return false
+}
+
+// expandDirectoryGlob expands ~ and resolves relative globs against the policy's directory
+func expandDirectoryGlob(glob, baseDir string) string {
+	glob = filepath.FromSlash(glob)
+	if glob == "~" || strings.HasPrefix(glob, "~"+string(filepath.Separator)) {
+		if home, err := os.UserHomeDir(); err == nil {
+			glob = filepath.Join(home, glob[1:])
+		}
+	}
+	if !filepath.IsAbs(glob) {
+		glob = filepath.Join(baseDir, glob)
+	}
+	return glob
+}
+
+// matchDirectory matches a directory against a glob. A glob ending in /**
+// also matches every subdirectory.
+func matchDirectory(glob, dir string) bool {
+	suffix := string(filepath.Separator) + "**"
+	recursive := strings.HasSuffix(glob, suffix)
+	glob = strings.TrimSuffix(glob, suffix)
+
+	for dir = filepath.Clean(dir); ; {
+		if matched, _ := filepath.Match(glob, dir); matched {
+			return true
+		}
+		parent := filepath.Dir(dir)
+		if !recursive || parent == dir {
+			return false
+		}
+		dir = parent
+	}
+}
+
+// policyTarget is a command or sub-command that rules are matched against
+type policyTarget struct {
+	text   string // Normalized text
+	source string // Text as written
+}
+
+// cachedPolicy is a loaded policy file and the modification time it was loaded at
+type cachedPolicy struct {
+	modTime time.Time
+	policy  *Policy
+	err     error
+}
+
+// activePolicy is a policy in effect for a working directory
+type activePolicy struct {
+	*Policy
+	trusted bool // Whether the policy may allow commands or lower their level
+}
+
+// policyLoader finds and caches the policy files that apply to a working directory
+type policyLoader struct {
+	configDir string
+	mu        sync.Mutex
+	cache     map[string]cachedPolicy
+}
+
+// newPolicyLoader creates a loader for the user's policy in configDir and
+// repository policies found from the working directory upwards
+func newPolicyLoader(configDir string) *policyLoader {
+	return &policyLoader{
+		configDir: configDir,
+		cache:     make(map[string]cachedPolicy),
+	}
+}
+
+// policiesFor returns the policies for a working directory, the user's policy
+// first and then repository policies from the outermost directory inwards, so
+// later rules take precedence. Files that fail to load are returned as errors.
+func (l *policyLoader) policiesFor(workingDir string) ([]activePolicy, []error) {
+	var policies []activePolicy
+	var errs []error
+
+	userPath := ""
+	var trustedRepos []string
+	if l.configDir != "" {
+		userPath = filepath.Join(l.configDir, PolicyFileName)
+		policy, err := l.load(userPath)
+		if err != nil {
+			errs = append(errs, err)
+		} else if policy != nil {
+			trustedRepos = policy.TrustedRepos
+			policies = append(policies, activePolicy{Policy: policy, trusted: true})
+		}
+	}
+
+	var repoPaths []string
+	for dir := filepath.Clean(workingDir); ; dir = filepath.Dir(dir) {
+		if path := filepath.Join(dir, RepoPolicyFileName); path != userPath {
+			repoPaths = append(repoPaths, path)
+		}
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+
+	for i := len(repoPaths) - 1; i >= 0; i-- {
+		policy, err := l.load(repoPaths[i])
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if policy == nil {
+			continue
+		}
+		// A repository policy may only relax the built-in rules when the user trusts it
+		trusted := false
+		for _, glob := range trustedRepos {
+			if matchDirectory(expandDirectoryGlob(glob, l.configDir), filepath.Dir(repoPaths[i])) {
+				trusted = true
+				break
+			}
+		}
+		policies = append(policies, activePolicy{Policy: policy, trusted: trusted})
+	}
+
+	return policies, errs
+}
+
+// load returns the policy at path, reloading it when the file changes. A
+// missing file yields no policy and no error.
+func (l *policyLoader) load(path string) (*Policy, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("safety policy %s could not be read: %w", path, err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if cached, ok := l.cache[path]; ok && cached.modTime.Equal(info.ModTime()) {
+		return cached.policy, cached.err
+	}
+
+	policy, err := LoadPolicy(path)
+	if err != nil {
+		err = fmt.Errorf("safety policy %s could not be loaded: %w", path, err)
+	}
+	l.cache[path] = cachedPolicy{modTime: info.ModTime(), policy: policy, err: err}
+	return policy, err
+}
+
+// applyPolicies adjusts a validation result with the policy rules in scope.
+// Allow rules run first and only apply when every warning comes from an
+// allowed sub-command; level rules then set the level, and deny rules raise it.
+// Rules that raise the level are also matched against the whole command
+// source; rules that relax it only against the parsed sub-commands.
+func (v *Validator) applyPolicies(workingDir, source string, targets []policyTarget, level types.DangerLevel, findings []types.SafetyFinding) (types.DangerLevel, []types.SafetyFinding) {
+	if v.policies == nil {
+		return level, findings
+	}
+	allTargets := append([]policyTarget{{text: strings.ToLower(source), source: source}}, targets...)
+
+	if workingDir == "" {
+		if wd, err := os.Getwd(); err == nil {
+			workingDir = wd
+		}
+	}
+
+	policies, errs := v.policies.policiesFor(workingDir)
+	for _, err := range errs {
+		findings = append(findings, types.SafetyFinding{Description: err.Error(), Level: types.Warning})
+		if level < types.Warning {
+			level = types.Warning
+		}
+	}
+
+	type scopedRule struct {
+		rule    *PolicyRule
+		trusted bool
+	}
+	var rules []scopedRule
+	for _, policy := range policies {
+		for i := range policy.Rules {
+			if policy.Rules[i].appliesTo(workingDir) {
+				rules = append(rules, scopedRule{rule: &policy.Rules[i], trusted: policy.trusted})
+			}
+		}
+	}
+
+	// Allow rules
+	if level > types.Safe && len(findings) > 0 {
+		var allowedBy []string
+		allowed := true
+		for _, finding := range findings {
+			target := policyTarget{text: strings.ToLower(finding.SubCommand), source: finding.SubCommand}
+			ruleName := ""
+			for _, scoped := range rules {
+				if scoped.trusted && scoped.rule.Action == PolicyActionAllow && finding.Level <= scoped.rule.level && scoped.rule.matches(target) {
+					ruleName = scoped.rule.Name
+					break
+				}
+			}
+			if ruleName == "" {
+				allowed = false
+				break
+			}
+			if !containsString(allowedBy, ruleName) {
+				allowedBy = append(allowedBy, ruleName)
+			}
+		}
+
+		if allowed {
+			level = types.Safe
+			findings = nil
+			for _, name := range allowedBy {
+				findings = append(findings, types.SafetyFinding{
+					Description: fmt.Sprintf("Allowed by policy rule '%s'", name),
+					Level:       types.Safe,
+				})
+			}
+		}
+	}
+
+	// Level rules; the most specific policy is applied last. Like allow rules, a
+	// rule only lowers the level when every finding above it comes from a
+	// sub-command the rule matches.
+	for _, scoped := range rules {
+		rule := scoped.rule
+		if rule.Action != PolicyActionLevel {
+			continue
+		}
+		candidates := allTargets
+		if rule.level < level {
+			if !scoped.trusted || !rule.coversFindings(findings) {
+				continue
+			}
+			candidates = targets
+		}
+		for _, target := range candidates {
+			if rule.matches(target) {
+				level = rule.level
+				findings = append(findings, types.SafetyFinding{
+					Description: fmt.Sprintf("Danger level set to %s by policy rule '%s'", rule.level, rule.Name),
+					Level:       rule.level,
+					SubCommand:  target.source,
+				})
+				break
+			}
+		}
+	}
+
+	// Deny rules
+	for _, scoped := range rules {
+		rule := scoped.rule
+		if rule.Action != PolicyActionDeny {
+			continue
+		}
+		for _, target := range allTargets {
+			if rule.matches(target) {
+				description := fmt.Sprintf("Denied by policy rule '%s'", rule.Name)
+				if rule.Description != "" {
+					description += ": " + rule.Description
+				}
+				findings = append(findings, types.SafetyFinding{
+					Description: description,
+					Level:       rule.level,
+					SubCommand:  target.source,
+				})
+				if rule.level > level {
+					level = rule.level
+				}
+			}
+		}
+	}
+
+	return level, mergeFindings(findings)
+}
+
+// coversFindings reports whether every finding above the rule's level comes
+// from a sub-command the rule matches
+func (r *PolicyRule) coversFindings(findings []types.SafetyFinding) bool {
+	for _, finding := range findings {
+		if finding.Level <= r.level {
+			continue
+		}
+		if finding.SubCommand == "" || !r.matches(policyTarget{text: strings.ToLower(finding.SubCommand), source: finding.SubCommand}) {
+			return false
+		}
+	}
+	return true
+}
+
+// containsString reports whether values contains s
+func containsString(values []string, s string) bool {
+	for _, value := range values {
+		if value == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,267 @@
+package safety
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+// writePolicy writes a policy file and returns its path
+func writePolicy(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write policy: %v", err)
+	}
+	return path
+}
+
+// validateIn validates a command as if it were run in workingDir
+func validateIn(t *testing.T, v interface {
+	ValidateCommand(*types.Command) (*types.SafetyResult, error)
+}, command, workingDir string) *types.SafetyResult {
+	t.Helper()
+	result, err := v.ValidateCommand(&types.Command{
+		ID:         "test",
+		Generated:  command,
+		WorkingDir: workingDir,
+		Timestamp:  time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("ValidateCommand(%q) returned error: %v", command, err)
+	}
+	return result
+}
+
+// hasWarning reports whether any warning contains all the given substrings
+func hasWarning(result *types.SafetyResult, parts ...string) bool {
+	for _, warning := range result.Warnings {
+		matched := true
+		for _, part := range parts {
+			if !strings.Contains(warning, part) {
+				matched = false
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+func TestLoadPolicy_Errors(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := map[string]string{
+		"invalid json":   `{"rules": [`,
+		"missing name":   `{"rules": [{"pattern": "x", "action": "deny"}]}`,
+		"missing level":  `{"rules": [{"name": "r", "pattern": "x", "action": "level"}]}`,
+		"bad pattern":    `{"rules": [{"name": "r", "pattern": "(", "action": "deny"}]}`,
+		"unknown action": `{"rules": [{"name": "r", "pattern": "x", "action": "ignore"}]}`,
+		"unknown level":  `{"rules": [{"name": "r", "pattern": "x", "action": "deny", "level": "severe"}]}`,
+	}
+
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := writePolicy(t, dir, PolicyFileName, content)
+			if _, err := LoadPolicy(path); err == nil {
+				t.Error("Expected error loading policy")
+			}
+		})
+	}
+}
+
+func TestParseDangerLevel(t *testing.T) {
+	tests := map[string]types.DangerLevel{
+		"safe":      types.Safe,
+		"Warning":   types.Warning,
+		"DANGEROUS": types.Dangerous,
+		" critical": types.Critical,
+	}
+	for name, expected := range tests {
+		level, err := ParseDangerLevel(name)
+		if err != nil || level != expected {
+			t.Errorf("ParseDangerLevel(%q) = %v, %v; want %v", name, level, err, expected)
+		}
+	}
+}
+
+func TestMatchDirectory(t *testing.T) {
+	root := filepath.FromSlash("/work/ci")
+	tests := []struct {
+		glob    string
+		dir     string
+		matched bool
+	}{
+		{root, root, true},
+		{root, filepath.Join(root, "repo"), false},
+		{filepath.Join(root, "**"), filepath.Join(root, "repo", "sub"), true},
+		{filepath.Join(root, "**"), filepath.FromSlash("/work/other"), false},
+		{filepath.FromSlash("/work/*/repo"), filepath.Join(root, "repo"), true},
+	}
+
+	for _, tt := range tests {
+		if got := matchDirectory(tt.glob, tt.dir); got != tt.matched {
+			t.Errorf("matchDirectory(%q, %q) = %v, want %v", tt.glob, tt.dir, got, tt.matched)
+		}
+	}
+}
+
+func TestPolicy_DenyRuleIsNamedInWarnings(t *testing.T) {
+	configDir := t.TempDir()
+	writePolicy(t, configDir, PolicyFileName, `{"rules": [
+		{"name": "no-force-push", "pattern": "git push .*--force", "action": "deny", "level": "dangerous", "description": "force pushes rewrite shared history"}
+	]}`)
+	validator := NewValidatorWithPolicies(configDir)
+
+	result := validateIn(t, validator, `cd repo && sh -c "git push origin main --force"`, t.TempDir())
+	if result.DangerLevel != types.Dangerous {
+		t.Errorf("Expected Dangerous, got %v", result.DangerLevel)
+	}
+	if !hasWarning(result, "no-force-push", "force pushes rewrite shared history", "(in: git push origin main --force)") {
+		t.Errorf("Expected warning naming the rule and sub-command, got %v", result.Warnings)
+	}
+}
+
+func TestPolicy_AllowRule(t *testing.T) {
+	configDir := t.TempDir()
+	writePolicy(t, configDir, PolicyFileName, `{"rules": [
+		{"name": "build-scripts", "pattern": "^chmod 755 ", "action": "allow"}
+	]}`)
+	validator := NewValidatorWithPolicies(configDir)
+	workingDir := t.TempDir()
+
+	result := validateIn(t, validator, "chmod 755 build.sh", workingDir)
+	if result.DangerLevel != types.Safe || result.RequiresConfirmation {
+		t.Errorf("Expected allowed command to be safe, got %v (warnings: %v)", result.DangerLevel, result.Warnings)
+	}
+	if !hasWarning(result, "Allowed by policy rule 'build-scripts'") {
+		t.Errorf("Expected warning naming the allow rule, got %v", result.Warnings)
+	}
+
+	// Other sub-commands are still checked
+	result = validateIn(t, validator, "chmod 755 build.sh && rm -rf /etc", workingDir)
+	if result.DangerLevel != types.Critical {
+		t.Errorf("Expected Critical for combined command, got %v", result.DangerLevel)
+	}
+
+	// Allow rules do not cover levels above their own
+	result = validateIn(t, validator, "chmod 755 /etc/passwd", workingDir)
+	if result.DangerLevel == types.Safe {
+		t.Errorf("Expected chmod on system files to remain flagged, got %v", result.DangerLevel)
+	}
+}
+
+func TestPolicy_LevelRuleScopedToDirectory(t *testing.T) {
+	configDir := t.TempDir()
+	sandbox := t.TempDir()
+	writePolicy(t, configDir, PolicyFileName, `{"rules": [
+		{"name": "ci-prune", "pattern": "^docker system prune", "action": "level", "level": "safe", "directories": ["`+filepath.ToSlash(sandbox)+`/**"]},
+		{"name": "prune", "pattern": "^docker system prune", "action": "level", "level": "warning"}
+	]}`)
+	validator := NewValidatorWithPolicies(configDir)
+
+	result := validateIn(t, validator, "docker system prune -af", t.TempDir())
+	if result.DangerLevel != types.Warning {
+		t.Errorf("Expected Warning outside the sandbox, got %v", result.DangerLevel)
+	}
+
+	nested := filepath.Join(sandbox, "job")
+	if err := os.Mkdir(nested, 0700); err != nil {
+		t.Fatal(err)
+	}
+	result = validateIn(t, validator, "docker system prune -af", nested)
+	// Both rules match inside the sandbox; the later one wins
+	if result.DangerLevel != types.Warning || !hasWarning(result, "'prune'") {
+		t.Errorf("Expected the later rule to apply, got %v (warnings: %v)", result.DangerLevel, result.Warnings)
+	}
+}
+
+func TestPolicy_LevelRuleOnlyLowersMatchingSubCommands(t *testing.T) {
+	configDir := t.TempDir()
+	writePolicy(t, configDir, PolicyFileName, `{"rules": [
+		{"name": "prune-is-safe", "pattern": "docker system prune", "action": "level", "level": "safe"}
+	]}`)
+	validator := NewValidatorWithPolicies(configDir)
+	workingDir := t.TempDir()
+
+	result := validateIn(t, validator, "docker system prune -af", workingDir)
+	if result.DangerLevel != types.Safe || !hasWarning(result, "prune-is-safe") {
+		t.Errorf("Expected the level rule to apply, got %v (warnings: %v)", result.DangerLevel, result.Warnings)
+	}
+
+	// Neither another sub-command nor a comment can borrow the rule
+	for _, command := range []string{
+		"docker system prune -f && rm -rf /",
+		"rm -rf / # docker system prune",
+	} {
+		result = validateIn(t, validator, command, workingDir)
+		if result.DangerLevel != types.Critical || !result.RequiresConfirmation {
+			t.Errorf("%q: expected Critical, got %v (warnings: %v)", command, result.DangerLevel, result.Warnings)
+		}
+	}
+}
+
+func TestPolicy_RepositoryPolicies(t *testing.T) {
+	configDir := t.TempDir()
+	repo := t.TempDir()
+	writePolicy(t, repo, RepoPolicyFileName, `{"rules": [
+		{"name": "repo-allow-chmod", "pattern": "^chmod", "action": "allow"},
+		{"name": "repo-deny-deploy", "pattern": "^make deploy", "action": "deny"}
+	]}`)
+
+	// Untrusted repositories can only tighten the rules
+	validator := NewValidatorWithPolicies(configDir)
+	result := validateIn(t, validator, "chmod 755 build.sh", repo)
+	if result.DangerLevel == types.Safe {
+		t.Error("Expected untrusted repository policy not to allow commands")
+	}
+	result = validateIn(t, validator, "make deploy", repo)
+	if result.DangerLevel != types.Critical || !hasWarning(result, "repo-deny-deploy") {
+		t.Errorf("Expected repository deny rule to apply, got %v (warnings: %v)", result.DangerLevel, result.Warnings)
+	}
+
+	// Trusted repositories may relax them
+	writePolicy(t, configDir, PolicyFileName, `{"trusted_repos": ["`+filepath.ToSlash(repo)+`"], "rules": []}`)
+	validator = NewValidatorWithPolicies(configDir)
+	result = validateIn(t, validator, "chmod 755 build.sh", filepath.Join(repo))
+	if result.DangerLevel != types.Safe {
+		t.Errorf("Expected trusted repository policy to allow command, got %v (warnings: %v)", result.DangerLevel, result.Warnings)
+	}
+}
+
+func TestPolicy_InvalidPolicyIsReported(t *testing.T) {
+	configDir := t.TempDir()
+	writePolicy(t, configDir, PolicyFileName, `{"rules": [`)
+	validator := NewValidatorWithPolicies(configDir)
+
+	result := validateIn(t, validator, "ls", t.TempDir())
+	if result.DangerLevel != types.Warning || !hasWarning(result, "could not be loaded") {
+		t.Errorf("Expected a warning about the invalid policy, got %v (warnings: %v)", result.DangerLevel, result.Warnings)
+	}
+}
+
+func TestPolicy_ReloadsChangedFile(t *testing.T) {
+	configDir := t.TempDir()
+	path := writePolicy(t, configDir, PolicyFileName, `{"rules": []}`)
+	validator := NewValidatorWithPolicies(configDir)
+	workingDir := t.TempDir()
+
+	if result := validateIn(t, validator, "make deploy", workingDir); result.DangerLevel != types.Safe {
+		t.Fatalf("Expected Safe before the rule is added, got %v", result.DangerLevel)
+	}
+
+	writePolicy(t, configDir, PolicyFileName, `{"rules": [{"name": "deploy", "pattern": "^make deploy", "action": "deny"}]}`)
+	later := time.Now().Add(time.Second)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	if result := validateIn(t, validator, "make deploy", workingDir); result.DangerLevel != types.Critical {
+		t.Errorf("Expected the updated policy to apply, got %v", result.DangerLevel)
+	}
+}
@@ -0,0 +1,474 @@
+package safety
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// assignmentPrefix matches a leading NAME=value assignment
+var assignmentPrefix = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*=`)
+
+// shellScript is a sequence of pipelines joined by ;, &, &&, || or newlines
+type shellScript struct {
+	Pipelines []*shellPipeline
+}
+
+// shellPipeline is one or more commands connected by pipes
+type shellPipeline struct {
+	Stages []*shellCommand
+}
+
+// shellCommand is a simple command or a subshell / brace group
+type shellCommand struct {
+	Assignments []shellWord
+	Words       []shellWord
+	Redirects   []shellRedirect
+	Subshell    *shellScript
+	Source      string // Source text of the command
+}
+
+// shellWord is a single word of a command after quote removal
+type shellWord struct {
+	Raw           string         // Source text, including quotes
+	Value         string         // Text with quotes removed; expansions are kept verbatim
+	Expanded      bool           // Whether the word contains a parameter, arithmetic or command expansion
+	Substitutions []*shellScript // Parsed command and process substitutions
+}
+
+// shellRedirect is an I/O redirection such as > file or 2>&1
+type shellRedirect struct {
+	Op     string
+	Target shellWord
+}
+
+// maxParseDepth bounds the nesting of subshells and substitutions
+const maxParseDepth = 32
+
+// shellParser is a recursive-descent parser for the subset of POSIX shell
+// syntax that matters for safety analysis
+type shellParser struct {
+	src      string
+	pos      int
+	depth    int
+	heredocs []string // Here-document delimiters waiting for the end of the line
+}
+
+// parseShell parses a command string into a shell script
+func parseShell(src string) (*shellScript, error) {
+	return parseShellNested(src, 0)
+}
+
+// parseScript parses pipelines until the terminator (0 for end of input)
+func (p *shellParser) parseScript(terminator byte) (*shellScript, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxParseDepth {
+		return nil, fmt.Errorf("command is nested too deeply")
+	}
+
+	script := &shellScript{}
+	for {
+		p.skipSeparators()
+		if p.pos >= len(p.src) {
+			if terminator != 0 {
+				return nil, fmt.Errorf("missing %q", terminator)
+			}
+			return script, nil
+		}
+		if terminator != 0 && p.src[p.pos] == terminator {
+			return script, nil
+		}
+
+		start := p.pos
+		pipeline, err := p.parsePipeline(terminator)
+		if err != nil {
+			return nil, err
+		}
+		if p.pos == start {
+			return nil, fmt.Errorf("unexpected %q at offset %d", p.src[p.pos], p.pos)
+		}
+		if len(pipeline.Stages) > 0 {
+			script.Pipelines = append(script.Pipelines, pipeline)
+		}
+	}
+}
+
+// skipSeparators skips blanks, comments, list operators and here-document bodies
+func (p *shellParser) skipSeparators() {
+	for p.pos < len(p.src) {
+		switch c := p.src[p.pos]; {
+		case c == ' ' || c == '\t' || c == ';':
+			p.pos++
+		case c == '\n':
+			p.pos++
+			p.skipHeredocs()
+		case c == '#':
+			p.skipComment()
+		case strings.HasPrefix(p.src[p.pos:], "&&") || strings.HasPrefix(p.src[p.pos:], "||"):
+			p.pos += 2
+		case c == '&' && !strings.HasPrefix(p.src[p.pos:], "&>"):
+			p.pos++
+		case c == '\\' && p.pos+1 < len(p.src) && p.src[p.pos+1] == '\n':
+			p.pos += 2
+		default:
+			return
+		}
+	}
+}
+
+// skipBlanks skips spaces, tabs and line continuations within a command
+func (p *shellParser) skipBlanks() {
+	for p.pos < len(p.src) {
+		if p.src[p.pos] == ' ' || p.src[p.pos] == '\t' {
+			p.pos++
+		} else if strings.HasPrefix(p.src[p.pos:], "\\\n") {
+			p.pos += 2
+		} else {
+			return
+		}
+	}
+}
+
+// skipComment skips to the end of the line
+func (p *shellParser) skipComment() {
+	for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+		p.pos++
+	}
+}
+
+// skipHeredocs skips the bodies of pending here-documents, which are data
+func (p *shellParser) skipHeredocs() {
+	for _, delimiter := range p.heredocs {
+		for p.pos < len(p.src) {
+			end := strings.IndexByte(p.src[p.pos:], '\n')
+			line := p.src[p.pos:]
+			if end >= 0 {
+				line = p.src[p.pos : p.pos+end]
+				p.pos += end + 1
+			} else {
+				p.pos = len(p.src)
+			}
+			if strings.TrimLeft(line, "\t") == delimiter {
+				break
+			}
+		}
+	}
+	p.heredocs = nil
+}
+
+// parsePipeline parses commands separated by | or |&
+func (p *shellParser) parsePipeline(terminator byte) (*shellPipeline, error) {
+	pipeline := &shellPipeline{}
+	for {
+		cmd, err := p.parseCommand(terminator)
+		if err != nil {
+			return nil, err
+		}
+		if cmd != nil {
+			pipeline.Stages = append(pipeline.Stages, cmd)
+		}
+
+		p.skipBlanks()
+		if p.pos < len(p.src) && p.src[p.pos] == '|' && !strings.HasPrefix(p.src[p.pos:], "||") {
+			p.pos++
+			if p.pos < len(p.src) && p.src[p.pos] == '&' {
+				p.pos++
+			}
+			// A pipe may be followed by a newline
+			for p.pos < len(p.src) && strings.IndexByte(" \t\n", p.src[p.pos]) >= 0 {
+				p.pos++
+			}
+			continue
+		}
+		return pipeline, nil
+	}
+}
+
+// parseCommand parses a simple command or subshell and its redirections
+func (p *shellParser) parseCommand(terminator byte) (*shellCommand, error) {
+	p.skipBlanks()
+	start, end := p.pos, -1
+	cmd := &shellCommand{}
+
+	if p.pos < len(p.src) && p.src[p.pos] == '(' {
+		p.pos++
+		subshell, err := p.parseScript(')')
+		if err != nil {
+			return nil, err
+		}
+		p.pos++ // Closing parenthesis
+		cmd.Subshell = subshell
+	}
+
+	for {
+		p.skipBlanks()
+		if p.pos >= len(p.src) {
+			break
+		}
+		c := p.src[p.pos]
+		if c == terminator || c == '\n' || c == ';' || c == '|' || c == ')' {
+			break
+		}
+		if c == '&' && !strings.HasPrefix(p.src[p.pos:], "&>") {
+			break
+		}
+		if c == '#' {
+			end = p.pos // A comment is not part of the command
+			p.skipComment()
+			break
+		}
+		if c == '(' {
+			return nil, fmt.Errorf("unexpected ( at offset %d", p.pos)
+		}
+
+		if op := p.redirectOperator(); op != "" {
+			p.pos += len(op)
+			p.skipBlanks()
+			target, err := p.parseWord()
+			if err != nil {
+				return nil, err
+			}
+			if target.Raw == "" {
+				return nil, fmt.Errorf("missing redirection target at offset %d", p.pos)
+			}
+			if kind := strings.TrimLeft(op, "0123456789"); kind == "<<" || kind == "<<-" {
+				p.heredocs = append(p.heredocs, target.Value)
+			}
+			cmd.Redirects = append(cmd.Redirects, shellRedirect{Op: op, Target: target})
+			continue
+		}
+
+		word, err := p.parseWord()
+		if err != nil {
+			return nil, err
+		}
+		if len(cmd.Words) == 0 && cmd.Subshell == nil && assignmentPrefix.MatchString(word.Raw) {
+			cmd.Assignments = append(cmd.Assignments, word)
+		} else {
+			cmd.Words = append(cmd.Words, word)
+		}
+	}
+
+	if end < 0 {
+		end = p.pos
+	}
+	cmd.Source = strings.TrimSpace(p.src[start:end])
+	if cmd.Subshell == nil && len(cmd.Words) == 0 && len(cmd.Assignments) == 0 && len(cmd.Redirects) == 0 {
+		return nil, nil
+	}
+	return cmd, nil
+}
+
+// redirectOperators lists redirection operators, longest first
+var redirectOperators = []string{"&>>", "<<<", "<<-", "&>", ">>", "<<", ">&", "<&", ">|", "<>", ">", "<"}
+
+// redirectOperator returns the redirection operator at the current position,
+// including a leading file descriptor number, or "" if there is none
+func (p *shellParser) redirectOperator() string {
+	i := p.pos
+	for i < len(p.src) && p.src[i] >= '0' && p.src[i] <= '9' {
+		i++
+	}
+	rest := p.src[i:]
+	// Process substitution is a word, not a redirection
+	if i == p.pos && (strings.HasPrefix(rest, "<(") || strings.HasPrefix(rest, ">(")) {
+		return ""
+	}
+	for _, op := range redirectOperators {
+		if strings.HasPrefix(rest, op) {
+			if op[0] == '&' && i != p.pos {
+				return ""
+			}
+			return p.src[p.pos:i] + op
+		}
+	}
+	return ""
+}
+
+// parseWord parses a single word, removing quotes and parsing substitutions
+func (p *shellParser) parseWord() (shellWord, error) {
+	start := p.pos
+	var word shellWord
+	var value strings.Builder
+
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if strings.IndexByte(" \t\n;&|)", c) >= 0 {
+			break
+		}
+		if c == '<' || c == '>' {
+			if p.pos+1 < len(p.src) && p.src[p.pos+1] == '(' {
+				// Process substitution
+				subStart := p.pos
+				p.pos += 2
+				sub, err := p.parseScript(')')
+				if err != nil {
+					return word, err
+				}
+				p.pos++
+				word.Substitutions = append(word.Substitutions, sub)
+				word.Expanded = true
+				value.WriteString(p.src[subStart:p.pos])
+				continue
+			}
+			break
+		}
+		if c == '(' {
+			return word, fmt.Errorf("unexpected ( at offset %d", p.pos)
+		}
+
+		switch c {
+		case '\\':
+			p.pos++
+			if p.pos >= len(p.src) {
+				return word, fmt.Errorf("trailing backslash")
+			}
+			if p.src[p.pos] != '\n' {
+				value.WriteByte(p.src[p.pos])
+			}
+			p.pos++
+		case '\'':
+			end := strings.IndexByte(p.src[p.pos+1:], '\'')
+			if end < 0 {
+				return word, fmt.Errorf("unclosed single quote")
+			}
+			value.WriteString(p.src[p.pos+1 : p.pos+1+end])
+			p.pos += end + 2
+		case '"':
+			if err := p.parseDoubleQuoted(&word, &value); err != nil {
+				return word, err
+			}
+		case '$', '`':
+			if err := p.parseExpansion(&word, &value); err != nil {
+				return word, err
+			}
+		default:
+			value.WriteByte(c)
+			p.pos++
+		}
+	}
+
+	word.Raw = p.src[start:p.pos]
+	word.Value = value.String()
+	return word, nil
+}
+
+// parseDoubleQuoted parses a double-quoted section of a word
+func (p *shellParser) parseDoubleQuoted(word *shellWord, value *strings.Builder) error {
+	p.pos++ // Opening quote
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		switch {
+		case c == '"':
+			p.pos++
+			return nil
+		case c == '\\' && p.pos+1 < len(p.src) && strings.IndexByte("$`\"\\\n", p.src[p.pos+1]) >= 0:
+			if p.src[p.pos+1] != '\n' {
+				value.WriteByte(p.src[p.pos+1])
+			}
+			p.pos += 2
+		case c == '$' || c == '`':
+			if err := p.parseExpansion(word, value); err != nil {
+				return err
+			}
+		default:
+			value.WriteByte(c)
+			p.pos++
+		}
+	}
+	return fmt.Errorf("unclosed double quote")
+}
+
+// parseExpansion parses $name, ${...}, $((...)), $(...) and `...`. The source
+// text of the expansion is kept in the word's value.
+func (p *shellParser) parseExpansion(word *shellWord, value *strings.Builder) error {
+	start := p.pos
+	rest := p.src[p.pos:]
+
+	switch {
+	case strings.HasPrefix(rest, "$(("):
+		end := strings.Index(rest, "))")
+		if end < 0 {
+			return fmt.Errorf("unclosed arithmetic expansion")
+		}
+		p.pos += end + 2
+		word.Expanded = true
+	case strings.HasPrefix(rest, "$("):
+		p.pos += 2
+		sub, err := p.parseScript(')')
+		if err != nil {
+			return err
+		}
+		p.pos++
+		word.Substitutions = append(word.Substitutions, sub)
+		word.Expanded = true
+	case strings.HasPrefix(rest, "${"):
+		end := strings.IndexByte(rest, '}')
+		if end < 0 {
+			return fmt.Errorf("unclosed parameter expansion")
+		}
+		p.pos += end + 1
+		word.Expanded = true
+	case strings.HasPrefix(rest, "$'"):
+		// ANSI-C quoting; escapes are kept as written
+		end := strings.IndexByte(rest[2:], '\'')
+		if end < 0 {
+			return fmt.Errorf("unclosed single quote")
+		}
+		value.WriteString(rest[2 : 2+end])
+		p.pos += end + 3
+		return nil
+	case rest[0] == '`':
+		end := 1
+		for end < len(rest) && rest[end] != '`' {
+			if rest[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end >= len(rest) {
+			return fmt.Errorf("unclosed backquote")
+		}
+		sub, err := parseShellNested(strings.ReplaceAll(rest[1:end], "\\`", "`"), p.depth)
+		if err != nil {
+			return err
+		}
+		p.pos += end + 1
+		word.Substitutions = append(word.Substitutions, sub)
+		word.Expanded = true
+	default:
+		// $name or a special parameter; a lone $ is literal
+		end := 1
+		if end < len(rest) && strings.IndexByte("@*#?-$!0123456789", rest[end]) >= 0 {
+			end++
+		} else {
+			for end < len(rest) && (rest[end] == '_' || isAlphanumeric(rest[end])) {
+				end++
+			}
+		}
+		p.pos += end
+		word.Expanded = end > 1
+	}
+
+	value.WriteString(p.src[start:p.pos])
+	return nil
+}
+
+// parseShellNested parses a command string found inside another command
+func parseShellNested(src string, depth int) (*shellScript, error) {
+	p := &shellParser{src: src, depth: depth}
+	script, err := p.parseScript(0)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.src) {
+		return nil, fmt.Errorf("unexpected %q at offset %d", p.src[p.pos], p.pos)
+	}
+	return script, nil
+}
+
+// isAlphanumeric reports whether c is an ASCII letter or digit
+func isAlphanumeric(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
@@ -0,0 +1,111 @@
+package safety
+
+import (
+	"testing"
+)
+
+func TestParseShell_Structure(t *testing.T) {
+	script, err := parseShell(`FOO=1 ls -la | grep "a b" > out.txt && (cd /tmp; rm x) ; echo $(date) done`)
+	if err != nil {
+		t.Fatalf("parseShell returned error: %v", err)
+	}
+
+	if len(script.Pipelines) != 3 {
+		t.Fatalf("Expected 3 pipelines, got %d", len(script.Pipelines))
+	}
+
+	first := script.Pipelines[0]
+	if len(first.Stages) != 2 {
+		t.Fatalf("Expected 2 pipeline stages, got %d", len(first.Stages))
+	}
+	if len(first.Stages[0].Assignments) != 1 || first.Stages[0].Words[0].Value != "ls" {
+		t.Errorf("Expected assignment followed by ls, got %+v", first.Stages[0])
+	}
+	grep := first.Stages[1]
+	if grep.Words[1].Value != "a b" {
+		t.Errorf("Expected quotes to be removed, got %q", grep.Words[1].Value)
+	}
+	if len(grep.Redirects) != 1 || grep.Redirects[0].Op != ">" || grep.Redirects[0].Target.Value != "out.txt" {
+		t.Errorf("Expected redirect to out.txt, got %+v", grep.Redirects)
+	}
+
+	subshell := script.Pipelines[1].Stages[0].Subshell
+	if subshell == nil || len(subshell.Pipelines) != 2 {
+		t.Fatalf("Expected subshell with 2 commands, got %+v", subshell)
+	}
+
+	echo := script.Pipelines[2].Stages[0]
+	if len(echo.Words) != 3 || len(echo.Words[1].Substitutions) != 1 || !echo.Words[1].Expanded {
+		t.Errorf("Expected command substitution in echo, got %+v", echo.Words)
+	}
+}
+
+func TestParseShell_Words(t *testing.T) {
+	tests := []struct {
+		input    string
+		value    string
+		expanded bool
+		subs     int
+	}{
+		{`r'm'`, "rm", false, 0},
+		{`"r"m`, "rm", false, 0},
+		{`\rm`, "rm", false, 0},
+		{`$HOME/x`, "$HOME/x", true, 0},
+		{`"${DIR}"`, "${DIR}", true, 0},
+		{"`date`", "`date`", true, 1},
+		{`"$(whoami)"`, "$(whoami)", true, 1},
+		{`<(ls)`, "<(ls)", true, 1},
+		{`$((1+2))`, "$((1+2))", true, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			script, err := parseShell("cmd " + tt.input)
+			if err != nil {
+				t.Fatalf("parseShell returned error: %v", err)
+			}
+			word := script.Pipelines[0].Stages[0].Words[1]
+			if word.Value != tt.value {
+				t.Errorf("Expected value %q, got %q", tt.value, word.Value)
+			}
+			if word.Expanded != tt.expanded {
+				t.Errorf("Expected expanded %v, got %v", tt.expanded, word.Expanded)
+			}
+			if len(word.Substitutions) != tt.subs {
+				t.Errorf("Expected %d substitutions, got %d", tt.subs, len(word.Substitutions))
+			}
+		})
+	}
+}
+
+func TestParseShell_HeredocBodyIsSkipped(t *testing.T) {
+	script, err := parseShell("cat <<EOF > notes.txt\nrm -rf /\nEOF\necho done")
+	if err != nil {
+		t.Fatalf("parseShell returned error: %v", err)
+	}
+
+	if len(script.Pipelines) != 2 {
+		t.Fatalf("Expected 2 commands, got %d", len(script.Pipelines))
+	}
+	if got := script.Pipelines[1].Stages[0].Words[0].Value; got != "echo" {
+		t.Errorf("Expected echo after the here-document, got %q", got)
+	}
+}
+
+func TestParseShell_Errors(t *testing.T) {
+	inputs := []string{
+		`echo 'unclosed`,
+		`echo "unclosed`,
+		`echo $(date`,
+		"echo `date",
+		`(ls`,
+		`ls )`,
+		`echo >`,
+	}
+
+	for _, input := range inputs {
+		if _, err := parseShell(input); err == nil {
+			t.Errorf("Expected error for %q", input)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package safety
 
 import (
+	"fmt"
 	"regexp"
 	"strings"
 	"time"
@@ -12,6 +13,7 @@ import (
 // Validator implements the SafetyValidator interface
 type Validator struct {
 	patterns []types.DangerousPattern
+	policies *policyLoader
 }
 
 // NewValidator creates a new safety validator
@@ -21,6 +23,15 @@ func NewValidator() interfaces.SafetyValidator {
 	return v
 }
 
+// NewValidatorWithPolicies creates a safety validator that also applies the
+// user's policy file in configDir and repository policy files found from each
+// command's working directory upwards
+func NewValidatorWithPolicies(configDir string) interfaces.SafetyValidator {
+	v := &Validator{policies: newPolicyLoader(configDir)}
+	v.initializePatterns()
+	return v
+}
+
 // ValidateCommand validates a command for safety
 func (v *Validator) ValidateCommand(cmd *types.Command) (*types.SafetyResult, error) {
 	if cmd == nil {
@@ -37,7 +48,7 @@ func (v *Validator) ValidateCommand(cmd *types.Command) (*types.SafetyResult, er
 		commandText = cmd.Original
 	}
 
-	return v.validateCommandString(commandText), nil
+	return v.validateCommandString(commandText, cmd.WorkingDir), nil
 }
 
 // ValidateCommandWithOptions validates a command for safety with bypass options
@@ -70,7 +81,8 @@ func (v *Validator) ValidateCommandWithOptions(cmd *types.Command, opts *types.V
 		UserID:      opts.UserID,
 		DangerLevel: result.DangerLevel,
 		Reason:      opts.Reason,
-		SessionID:   "", // TODO: Add session tracking
+		SessionID:   opts.SessionID,
+		CommandID:   cmd.ID,
 	}
 
 	// Determine if bypass should be applied
@@ -115,7 +127,7 @@ func (v *Validator) ValidateCommandWithOptions(cmd *types.Command, opts *types.V
 
 // IsDangerous checks if a command string is dangerous
 func (v *Validator) IsDangerous(cmd string) bool {
-	result := v.validateCommandString(cmd)
+	result := v.validateCommandString(cmd, "")
 	return result.DangerLevel > types.Safe
 }
 
@@ -125,7 +137,7 @@ func (v *Validator) GetDangerousPatterns() []types.DangerousPattern {
 }
 
 // validateCommandString performs the actual validation logic
-func (v *Validator) validateCommandString(cmd string) *types.SafetyResult {
+func (v *Validator) validateCommandString(cmd, workingDir string) *types.SafetyResult {
 	if cmd == "" {
 		return &types.SafetyResult{
 			IsSafe:               true,
@@ -135,16 +147,50 @@ func (v *Validator) validateCommandString(cmd string) *types.SafetyResult {
 		}
 	}
 
-	// Normalize the command for analysis
-	normalizedCmd := strings.TrimSpace(strings.ToLower(cmd))
+	// Evaluate every sub-command on its own so that quoting, wrappers, nested
+	// shells and pipelines cannot hide a dangerous command, and quoted data
+	// does not pass for one. Text that cannot be parsed is matched as written.
+	source := strings.TrimSpace(cmd)
+	maxDangerLevel, findings, targets := v.analyzeStructure(cmd)
+	findings = mergeFindings(findings)
+
+	// Finally apply the user's and the repository's policy rules
+	maxDangerLevel, findings = v.applyPolicies(workingDir, source, targets, maxDangerLevel, findings)
 
-	var maxDangerLevel types.DangerLevel = types.Safe
 	var warnings []string
+	for _, finding := range findings {
+		if finding.SubCommand == "" || finding.SubCommand == source {
+			warnings = append(warnings, finding.Description)
+		} else {
+			warnings = append(warnings, fmt.Sprintf("%s (in: %s)", finding.Description, finding.SubCommand))
+		}
+	}
+
+	// Determine if confirmation is required
+	requiresConfirmation := maxDangerLevel >= types.Warning
+
+	return &types.SafetyResult{
+		IsSafe:               maxDangerLevel == types.Safe,
+		DangerLevel:          maxDangerLevel,
+		Warnings:             warnings,
+		RequiresConfirmation: requiresConfirmation,
+		Findings:             findings,
+	}
+}
+
+// evaluateText matches the dangerous patterns and context-aware rules against
+// normalized command text
+func (v *Validator) evaluateText(normalizedCmd string) (types.DangerLevel, []types.SafetyFinding) {
+	var maxDangerLevel types.DangerLevel = types.Safe
+	var findings []types.SafetyFinding
 
 	// Check against all patterns
 	for _, pattern := range v.patterns {
 		if pattern.Pattern.MatchString(normalizedCmd) {
-			warnings = append(warnings, pattern.Description)
+			findings = append(findings, types.SafetyFinding{
+				Description: pattern.Description,
+				Level:       pattern.Level,
+			})
 
 			if pattern.Level > maxDangerLevel {
 				maxDangerLevel = pattern.Level
@@ -153,21 +199,15 @@ func (v *Validator) validateCommandString(cmd string) *types.SafetyResult {
 	}
 
 	// Apply context-aware analysis to refine danger level
-	contextAwareDangerLevel, contextWarnings := v.analyzeCommandContext(normalizedCmd, maxDangerLevel)
-	if contextAwareDangerLevel != maxDangerLevel {
-		maxDangerLevel = contextAwareDangerLevel
+	dangerLevel, contextWarnings := v.analyzeCommandContext(normalizedCmd, maxDangerLevel)
+	for _, warning := range contextWarnings {
+		findings = append(findings, types.SafetyFinding{
+			Description: warning,
+			Level:       dangerLevel,
+		})
 	}
-	warnings = append(warnings, contextWarnings...)
 
-	// Determine if confirmation is required
-	requiresConfirmation := maxDangerLevel >= types.Warning
-
-	return &types.SafetyResult{
-		IsSafe:               maxDangerLevel == types.Safe,
-		DangerLevel:          maxDangerLevel,
-		Warnings:             warnings,
-		RequiresConfirmation: requiresConfirmation,
-	}
+	return dangerLevel, findings
 }
 
 // initializePatterns initializes the dangerous command patterns
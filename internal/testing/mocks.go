@@ -2,6 +2,7 @@ package testing
 
 import (
 	"context"
+	"io"
 	"time"
 
 	"github.com/kanishka-sahoo/nl-to-shell/internal/interfaces"
@@ -118,8 +119,9 @@ func (m *MockSafetyValidator) IsDangerous(command string) bool {
 
 // MockExecutor is a mock implementation of interfaces.CommandExecutor
 type MockExecutor struct {
-	ExecuteFunc func(ctx context.Context, command *types.Command) (*types.ExecutionResult, error)
-	DryRunFunc  func(command *types.Command) (*types.DryRunResult, error)
+	ExecuteFunc          func(ctx context.Context, command *types.Command) (*types.ExecutionResult, error)
+	ExecuteStreamingFunc func(ctx context.Context, command *types.Command, stdout, stderr io.Writer) (*types.ExecutionResult, error)
+	DryRunFunc           func(command *types.Command) (*types.DryRunResult, error)
 }
 
 func (m *MockExecutor) Execute(ctx context.Context, command *types.Command) (*types.ExecutionResult, error) {
@@ -136,6 +138,23 @@ func (m *MockExecutor) Execute(ctx context.Context, command *types.Command) (*ty
 	}, nil
 }
 
+func (m *MockExecutor) ExecuteStreaming(ctx context.Context, command *types.Command, stdout, stderr io.Writer) (*types.ExecutionResult, error) {
+	if m.ExecuteStreamingFunc != nil {
+		return m.ExecuteStreamingFunc(ctx, command, stdout, stderr)
+	}
+	result, err := m.Execute(ctx, command)
+	if err == nil && result != nil {
+		if stdout != nil {
+			io.WriteString(stdout, result.Stdout)
+		}
+		if stderr != nil {
+			io.WriteString(stderr, result.Stderr)
+		}
+		result.Streamed = stdout != nil || stderr != nil
+	}
+	return result, err
+}
+
 func (m *MockExecutor) DryRun(command *types.Command) (*types.DryRunResult, error) {
 	if m.DryRunFunc != nil {
 		return m.DryRunFunc(command)
@@ -166,8 +185,15 @@ func (m *MockResultValidator) ValidateResult(ctx context.Context, result *types.
 type MockCommandManager struct {
 	GenerateCommandFunc    func(ctx context.Context, input string) (*types.CommandResult, error)
 	ExecuteCommandFunc     func(ctx context.Context, command *types.Command) (*types.ExecutionResult, error)
+	ExecuteStreamingFunc   func(ctx context.Context, command *types.Command, stdout, stderr io.Writer) (*types.ExecutionResult, error)
 	GenerateAndExecuteFunc func(ctx context.Context, input string, options *types.ExecutionOptions) (*types.FullResult, error)
 	ValidateResultFunc     func(ctx context.Context, result *types.ExecutionResult, originalInput string) (*types.ValidationResult, error)
+	RecordConfirmationFunc func(result *types.CommandResult, confirmed bool)
+	BypassConfirmationFunc func(result *types.CommandResult) error
+	DryRunFunc             func(ctx context.Context, cmd *types.Command) (*types.DryRunResult, error)
+	CorrectResultFunc      func(ctx context.Context, result *types.FullResult, input string, options *types.ExecutionOptions) (*types.FullResult, error)
+	ForgetConversationFunc func()
+	ValidateJobsFunc       func(ctx context.Context) ([]*types.Job, error)
 }
 
 func (m *MockCommandManager) GenerateCommand(ctx context.Context, input string) (*types.CommandResult, error) {
@@ -197,6 +223,13 @@ func (m *MockCommandManager) ExecuteCommand(ctx context.Context, command *types.
 	}, nil
 }
 
+func (m *MockCommandManager) ExecuteCommandStreaming(ctx context.Context, command *types.Command, stdout, stderr io.Writer) (*types.ExecutionResult, error) {
+	if m.ExecuteStreamingFunc != nil {
+		return m.ExecuteStreamingFunc(ctx, command, stdout, stderr)
+	}
+	return m.ExecuteCommand(ctx, command)
+}
+
 func (m *MockCommandManager) GenerateAndExecute(ctx context.Context, input string, options *types.ExecutionOptions) (*types.FullResult, error) {
 	if m.GenerateAndExecuteFunc != nil {
 		return m.GenerateAndExecuteFunc(ctx, input, options)
@@ -225,6 +258,54 @@ func (m *MockCommandManager) ValidateResult(ctx context.Context, result *types.E
 	}, nil
 }
 
+func (m *MockCommandManager) RecordConfirmation(result *types.CommandResult, confirmed bool) {
+	if m.RecordConfirmationFunc != nil {
+		m.RecordConfirmationFunc(result, confirmed)
+		return
+	}
+	if confirmed {
+		result.Command.Validated = true
+	}
+}
+
+func (m *MockCommandManager) BypassConfirmation(result *types.CommandResult) error {
+	if m.BypassConfirmationFunc != nil {
+		return m.BypassConfirmationFunc(result)
+	}
+	result.Command.Validated = true
+	return nil
+}
+
+func (m *MockCommandManager) DryRun(ctx context.Context, cmd *types.Command) (*types.DryRunResult, error) {
+	if m.DryRunFunc != nil {
+		return m.DryRunFunc(ctx, cmd)
+	}
+	return &types.DryRunResult{
+		Command:  cmd,
+		Analysis: "Mock dry run analysis",
+	}, nil
+}
+
+func (m *MockCommandManager) CorrectResult(ctx context.Context, result *types.FullResult, input string, options *types.ExecutionOptions) (*types.FullResult, error) {
+	if m.CorrectResultFunc != nil {
+		return m.CorrectResultFunc(ctx, result, input, options)
+	}
+	return result, nil
+}
+
+func (m *MockCommandManager) ForgetConversation() {
+	if m.ForgetConversationFunc != nil {
+		m.ForgetConversationFunc()
+	}
+}
+
+func (m *MockCommandManager) ValidateJobs(ctx context.Context) ([]*types.Job, error) {
+	if m.ValidateJobsFunc != nil {
+		return m.ValidateJobsFunc(ctx)
+	}
+	return nil, nil
+}
+
 // MockConfigManager is a mock implementation of interfaces.ConfigManager
 type MockConfigManager struct {
 	LoadFunc                  func() (*types.Config, error)
@@ -2,22 +2,70 @@ package types
 
 import (
 	"fmt"
+	"io"
 	"regexp"
 	"time"
 )
 
 // Command represents a shell command to be executed
 type Command struct {
-	ID          string
-	Original    string   // Original natural language input
-	Generated   string   // Generated shell command
-	Validated   bool     // Whether it passed safety validation
-	Context     *Context // Context used for generation
-	Timestamp   time.Time
-	Args        []string
-	WorkingDir  string
-	Environment map[string]string
-	Timeout     time.Duration
+	ID           string
+	Original     string      // Original natural language input
+	Generated    string      // Generated shell command
+	Validated    bool        // Whether it passed safety validation
+	DangerLevel  DangerLevel // Danger level found by safety validation
+	Context      *Context    // Context used for generation
+	Timestamp    time.Time
+	Args         []string
+	WorkingDir   string
+	Environment  map[string]string
+	Timeout      time.Duration
+	Shell        string   // Shell used to interpret the command (empty selects the executor default)
+	Interactive  bool     // Whether the command needs the user's terminal (pagers, editors, prompts)
+	Unset        []string // Environment variables removed before the command runs
+	CaptureState bool     // Report the directory and environment changes the command leaves behind
+	Sandbox      bool     // Run isolated, holding file changes in the working directory until applied
+	Background   bool     // Start detached as a background job instead of waiting for the command
+	Limits       ResourceLimits
+}
+
+// ResourceLimits caps what a command may use; zero values mean no limit.
+// Output is capped everywhere, the other limits are rlimits applied on Linux.
+type ResourceLimits struct {
+	MaxOutput int64         // Bytes of stdout and of stderr kept in the result; the rest is spilled to a file
+	CPUTime   time.Duration // CPU time, after which the command is killed
+	Memory    int64         // Bytes of address space each process may use
+	FileSize  int64         // Largest file in bytes the command may write
+	Processes int           // Processes the user may have at once, the user's other processes included
+}
+
+// ResourceLimit names a limit from ResourceLimits
+type ResourceLimit int
+
+const (
+	LimitOutput ResourceLimit = iota
+	LimitCPUTime
+	LimitMemory
+	LimitFileSize
+	LimitProcesses
+)
+
+// String returns the string representation of ResourceLimit
+func (l ResourceLimit) String() string {
+	switch l {
+	case LimitOutput:
+		return "output"
+	case LimitCPUTime:
+		return "cpu time"
+	case LimitMemory:
+		return "memory"
+	case LimitFileSize:
+		return "file size"
+	case LimitProcesses:
+		return "processes"
+	default:
+		return "unknown"
+	}
 }
 
 // Context holds environmental information for command generation
@@ -27,6 +75,26 @@ type Context struct {
 	GitInfo          *GitContext
 	Environment      map[string]string
 	PluginData       map[string]interface{}
+	Conversation     []ConversationTurn // Earlier turns in the session, oldest first
+	PlanMode         bool               // Ask the provider for an ordered plan of steps instead of one command
+	Shell            *ShellState        // State left by earlier commands in the session; nil outside sessions
+}
+
+// ShellState is the working directory and environment that commands run in a
+// session leave behind for the commands after them
+type ShellState struct {
+	WorkingDirectory string
+	Environment      map[string]string // Variables exported or changed by earlier commands
+	Unset            []string          // Variables unset by earlier commands
+}
+
+// ConversationTurn represents an earlier request in the session and its outcome,
+// passed to the provider so follow-up requests can refer to it
+type ConversationTurn struct {
+	Input    string
+	Command  string
+	Output   string // Truncated output of the command; empty when it did not run
+	ExitCode *int   // Set when the command ran
 }
 
 // FileInfo represents file system information
@@ -52,17 +120,136 @@ type CommandResult struct {
 	Safety       *SafetyResult
 	Confidence   float64
 	Alternatives []string
+	PromptTokens int    // Estimated tokens of the prompt sent to the provider
+	Provider     string // Provider and model that generated the command
+	Model        string
+	Usage        TokenUsage // Tokens the provider reported for generating the command
 }
 
 // ExecutionResult represents the result of command execution
 type ExecutionResult struct {
-	Command  *Command
-	ExitCode int
-	Stdout   string
-	Stderr   string
-	Duration time.Duration
-	Success  bool
-	Error    error
+	Command    *Command
+	ExitCode   int
+	Stdout     string
+	Stderr     string
+	Duration   time.Duration
+	Success    bool
+	Error      error
+	Streamed   bool        // Whether stdout/stderr were already written to a live sink while running
+	FinalState *ShellState // Directory and environment changes the command left behind, when captured
+
+	// A command that is interrupted by the user, or that runs out of time, is
+	// stopped along with every process it started
+	Interrupted bool
+	TimedOut    bool
+
+	// LimitsExceeded lists the resource limits the command ran into. When its
+	// output was truncated, the full output is in StdoutFile or StderrFile.
+	LimitsExceeded []ResourceLimit
+	StdoutFile     string
+	StderrFile     string
+
+	// A sandboxed command's changes to its working directory are held until
+	// applied. Pending is nil once they are applied or discarded, or when
+	// the command changed nothing.
+	Sandboxed      bool
+	Changes        []FileChange
+	Pending        PendingChanges
+	ChangesApplied bool
+
+	// Job is the background job a command was started as. Its outcome is
+	// recorded in the job once it finishes, rather than in this result.
+	Job *Job
+}
+
+// JobStatus is the state of a background job
+type JobStatus int
+
+const (
+	JobRunning JobStatus = iota
+	JobSucceeded
+	JobFailed
+	JobKilled
+	JobLost // The job's process went away without recording how it ended
+)
+
+// String returns the string representation of JobStatus
+func (s JobStatus) String() string {
+	switch s {
+	case JobRunning:
+		return "running"
+	case JobSucceeded:
+		return "succeeded"
+	case JobFailed:
+		return "failed"
+	case JobKilled:
+		return "killed"
+	case JobLost:
+		return "lost"
+	default:
+		return "unknown"
+	}
+}
+
+// ExecutorSettings are the configured executor settings a command runs with
+// outside the process that generated it
+type ExecutorSettings struct {
+	Shell  string         // Shell used when the command does not specify one
+	Mode   ExecutionMode  // Whether commands run through the shell or directly
+	Limits ResourceLimits // Limits for commands that do not set their own
+}
+
+// Job is a command running detached from the CLI. Its output goes to LogFile
+// and its status is kept on disk, so it outlives the CLI that started it.
+type Job struct {
+	ID         int
+	Command    *Command
+	Executor   ExecutorSettings // Settings the command runs with, as configured when the job started
+	PID        int              // Process supervising the job; 0 until it has started
+	LogFile    string
+	Status     JobStatus
+	ExitCode   int
+	Error      string // Why the job could not run to completion
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Validation *ValidationResult // Verdict on the finished job's result; nil until validated
+}
+
+// FileChangeKind describes how a command changed a file
+type FileChangeKind int
+
+const (
+	FileCreated FileChangeKind = iota
+	FileModified
+	FileDeleted
+)
+
+// String returns the string representation of FileChangeKind
+func (k FileChangeKind) String() string {
+	switch k {
+	case FileCreated:
+		return "created"
+	case FileModified:
+		return "modified"
+	case FileDeleted:
+		return "deleted"
+	default:
+		return "unknown"
+	}
+}
+
+// FileChange is a change a sandboxed command made under its working directory
+type FileChange struct {
+	Path  string // Relative to the working directory
+	Kind  FileChangeKind
+	IsDir bool
+}
+
+// PendingChanges holds the file changes of a sandboxed command until they are
+// applied to the real working directory or discarded
+type PendingChanges interface {
+	Apply() error
+	Discard() error
 }
 
 // ValidationResult represents AI validation of execution results
@@ -71,6 +258,7 @@ type ValidationResult struct {
 	Explanation      string
 	Suggestions      []string
 	CorrectedCommand string
+	Usage            TokenUsage // Tokens the provider reported for the validation
 }
 
 // SafetyResult represents the result of safety validation
@@ -81,6 +269,14 @@ type SafetyResult struct {
 	RequiresConfirmation bool
 	Bypassed             bool
 	AuditEntry           *AuditEntry
+	Findings             []SafetyFinding // Warnings with the sub-command that triggered each one
+}
+
+// SafetyFinding is a single safety warning and the sub-command that triggered it
+type SafetyFinding struct {
+	Description string
+	Level       DangerLevel
+	SubCommand  string // Simple command, substitution or pipeline stage the warning applies to
 }
 
 // ValidationOptions controls how safety validation is performed
@@ -90,6 +286,7 @@ type ValidationOptions struct {
 	AuditLogger      AuditLogger // Logger for audit events
 	UserID           string      // User performing the bypass
 	Reason           string      // Reason for bypass
+	SessionID        string      // Session the command belongs to
 }
 
 // AuditEntry represents a security audit log entry
@@ -102,6 +299,11 @@ type AuditEntry struct {
 	Reason      string
 	SourceIP    string
 	SessionID   string
+	CommandID   string
+	ExitCode    *int   // Set for executed commands
+	PrevHash    string // Hash of the previous entry in the log, empty for the first entry
+	Hash        string // Hash of this entry, including PrevHash
+	Keyed       bool   // Whether Hash is an HMAC keyed with the audit secret
 }
 
 // AuditAction represents the type of audit action
@@ -112,6 +314,9 @@ const (
 	AuditActionBypassed
 	AuditActionBlocked
 	AuditActionOverridden
+	AuditActionGenerated
+	AuditActionConfirmed
+	AuditActionExecuted
 )
 
 // String returns the string representation of AuditAction
@@ -125,6 +330,12 @@ func (a AuditAction) String() string {
 		return "Blocked"
 	case AuditActionOverridden:
 		return "Overridden"
+	case AuditActionGenerated:
+		return "Generated"
+	case AuditActionConfirmed:
+		return "Confirmed"
+	case AuditActionExecuted:
+		return "Executed"
 	default:
 		return "Unknown"
 	}
@@ -141,10 +352,39 @@ type AuditFilter struct {
 	StartTime   *time.Time
 	EndTime     *time.Time
 	UserID      string
+	SessionID   string
 	Action      *AuditAction
 	DangerLevel *DangerLevel
 }
 
+// HistoryEntry represents a command recorded in the persistent history
+type HistoryEntry struct {
+	ID            int // Sequence number, starting at 1
+	Timestamp     time.Time
+	SessionID     string
+	CommandID     string
+	Input         string // Natural language request
+	Command       string // Generated command
+	Provider      string
+	Model         string
+	WorkingDir    string
+	DangerLevel   DangerLevel
+	Executed      bool
+	ExitCode      *int // Set for executed commands
+	Duration      time.Duration
+	ResultCorrect *bool      // Validation verdict; nil when the result was not validated
+	Usage         TokenUsage // Tokens used to generate and validate the command
+	Cost          float64    // Cost of Usage in US dollars, at the prices when it was recorded
+}
+
+// HistoryFilter represents filtering criteria for the command history
+type HistoryFilter struct {
+	Query     string // Case-insensitive text matched against the request, command and directory
+	Since     *time.Time
+	SessionID string
+	Limit     int // Keep only the newest entries; 0 keeps all
+}
+
 // BypassConfig represents bypass configuration for safety validation
 type BypassConfig struct {
 	Enabled       bool
@@ -193,6 +433,8 @@ type DryRunResult struct {
 	Analysis    string
 	Predictions []string
 	Safety      *SafetyResult
+	Changes     []FileChange // Files the command changed when run in the sandbox
+	Sandboxed   bool         // Whether Changes comes from running the command in the sandbox
 }
 
 // CommandResponse represents the response from an LLM provider
@@ -201,6 +443,42 @@ type CommandResponse struct {
 	Explanation  string
 	Confidence   float64
 	Alternatives []string
+	Interactive  bool // Model hint that the command needs the user's terminal
+	PromptTokens int  // Estimated tokens of the prompt sent to the provider
+	Provider     string
+	Model        string
+	Steps        []PlanStep // Ordered steps, when a plan was requested
+	Usage        TokenUsage // Tokens of every API call made for the response; zero for cached responses
+}
+
+// TokenUsage counts the tokens of one or more provider API calls
+type TokenUsage struct {
+	InputTokens  int
+	OutputTokens int
+}
+
+// Add adds the tokens of other to u
+func (u *TokenUsage) Add(other TokenUsage) {
+	u.InputTokens += other.InputTokens
+	u.OutputTokens += other.OutputTokens
+}
+
+// Total returns the number of input and output tokens
+func (u TokenUsage) Total() int {
+	return u.InputTokens + u.OutputTokens
+}
+
+// ModelPrice is the price of a model's tokens in US dollars per million tokens
+type ModelPrice struct {
+	InputPerMillion  float64
+	OutputPerMillion float64
+}
+
+// StreamHandler receives the text of a command response while a streaming
+// provider generates it. Done is called once the response is complete.
+type StreamHandler interface {
+	Text(text string)
+	Done()
 }
 
 // ValidationResponse represents the response from result validation
@@ -209,6 +487,7 @@ type ValidationResponse struct {
 	Explanation string
 	Suggestions []string
 	Correction  string
+	Usage       TokenUsage // Tokens of every API call made for the response; zero for cached responses
 }
 
 // ProviderInfo contains information about an LLM provider
@@ -220,18 +499,31 @@ type ProviderInfo struct {
 
 // Config represents the application configuration
 type Config struct {
-	DefaultProvider string
-	Providers       map[string]ProviderConfig
-	UserPreferences UserPreferences
-	UpdateSettings  UpdateSettings
+	DefaultProvider   string
+	FallbackProviders []string // Providers tried in order when the default provider is unreachable, rejects its credentials, is rate-limited or fails
+	Providers         map[string]ProviderConfig
+	UserPreferences   UserPreferences
+	UpdateSettings    UpdateSettings
+	Prices            map[string]ModelPrice // Token prices overriding the built-in table, keyed by model or provider/model
 }
 
 // ProviderConfig represents configuration for a specific provider
 type ProviderConfig struct {
-	APIKey       string
-	BaseURL      string
-	DefaultModel string
-	Timeout      time.Duration
+	Type              string // Provider type, such as openai-compatible; empty uses the provider's name
+	APIKey            string
+	BaseURL           string
+	DefaultModel      string
+	Timeout           time.Duration
+	PromptTokenBudget int               // Maximum system prompt tokens; 0 uses the default, within the model's context window
+	Headers           map[string]string // Extra HTTP headers sent with every request
+	Capabilities      ProviderCapabilities
+}
+
+// ProviderCapabilities lists optional API features a provider's server supports
+type ProviderCapabilities struct {
+	JSONMode    bool // Accepts response_format to constrain responses to JSON
+	JSONSchema  bool // Accepts response_format with a JSON schema responses must match
+	NoStreaming bool // Cannot stream responses
 }
 
 // UserPreferences stores user-specific settings
@@ -243,6 +535,28 @@ type UserPreferences struct {
 	EnablePlugins    bool
 	AutoUpdate       bool
 	Bypass           BypassConfig
+	Shell            string        // Shell used to run generated commands (bash, zsh, sh, fish, ...)
+	ExecutionMode    ExecutionMode // How generated commands are launched
+	SpendLimits      SpendLimits   // Caps on what provider calls may cost
+	ResponseCache    ResponseCacheSettings
+	Sandbox          bool // Run generated commands isolated and ask before applying their file changes
+	Limits           ResourceLimits
+}
+
+// ResponseCacheSettings controls the encrypted on-disk cache of generated
+// commands; zero values use the defaults
+type ResponseCacheSettings struct {
+	Disabled   bool          // Keep generated commands in memory only
+	TTL        time.Duration // How long a generated command is reused
+	MaxSize    int64         // Maximum total size of the cached responses in bytes
+	MaxEntries int           // Maximum number of cached responses
+}
+
+// SpendLimits caps the cost of provider calls, as recorded in the history
+type SpendLimits struct {
+	DailyLimit   float64 // US dollars per calendar day; 0 for no limit
+	MonthlyLimit float64 // US dollars per calendar month; 0 for no limit
+	Refuse       bool    // Refuse to call providers once a limit is reached, rather than warn
 }
 
 // UpdateSettings controls update behavior
@@ -280,6 +594,54 @@ type ExecutionOptions struct {
 	SkipConfirmation bool
 	ValidateResults  bool
 	Timeout          time.Duration
+	Stdout           io.Writer // Receives command stdout while it runs (optional)
+	Stderr           io.Writer // Receives command stderr while it runs (optional)
+
+	// MaxCorrections enables auto-correction: a command that the result validator
+	// finds wrong is replaced by its suggested correction, up to this many times.
+	// Each correction is safety-checked and run only if ConfirmCorrection approves it.
+	MaxCorrections    int
+	ConfirmCorrection func(correction *CommandResult, validation *ValidationResult) bool
+
+	// ConfirmStep, when set, is asked before each step of a plan runs
+	ConfirmStep func(index int, step *PlanStep, result *CommandResult) bool
+
+	// Sandbox runs commands isolated and makes dry runs run them in the
+	// sandbox to report the files they would change. ApplyChanges is asked
+	// whether to apply a sandboxed command's changes; without it they are
+	// applied only when SkipConfirmation is set.
+	Sandbox      bool
+	ApplyChanges func(result *ExecutionResult) bool
+
+	// Background starts the command as a background job; its result is
+	// validated once it finishes
+	Background bool
+}
+
+// ExecutionMode controls whether commands are run through a shell or executed directly
+type ExecutionMode int
+
+const (
+	// ExecutionModeAuto runs a command through the shell only when it uses shell syntax
+	ExecutionModeAuto ExecutionMode = iota
+	// ExecutionModeShell always runs commands through the shell
+	ExecutionModeShell
+	// ExecutionModeDirect always executes commands directly without a shell
+	ExecutionModeDirect
+)
+
+// String returns the string representation of ExecutionMode
+func (m ExecutionMode) String() string {
+	switch m {
+	case ExecutionModeAuto:
+		return "Auto"
+	case ExecutionModeShell:
+		return "Shell"
+	case ExecutionModeDirect:
+		return "Direct"
+	default:
+		return "Unknown"
+	}
 }
 
 // FullResult represents the complete result of command generation and execution
@@ -289,6 +651,53 @@ type FullResult struct {
 	ValidationResult     *ValidationResult
 	DryRunResult         *DryRunResult
 	RequiresConfirmation bool
+	Attempts             []*ExecutionAttempt // Every command run for the request when auto-correction is enabled, first to last
+}
+
+// PlanStep represents one command of a multi-step plan
+type PlanStep struct {
+	Command     string
+	Explanation string
+}
+
+// Plan represents an ordered sequence of commands that together fulfil a request
+type Plan struct {
+	ID          string
+	Request     string
+	Explanation string
+	Steps       []PlanStep
+	WorkingDir  string
+	Provider    string
+	Model       string
+	CreatedAt   time.Time
+	Usage       TokenUsage // Tokens spent generating the plan
+}
+
+// PlanStepResult represents the outcome of one step of a plan
+type PlanStepResult struct {
+	Step                 PlanStep
+	CommandResult        *CommandResult // The step's command and its safety check
+	ExecutionResult      *ExecutionResult
+	DryRunResult         *DryRunResult
+	Declined             bool // The user declined to run the step
+	RequiresConfirmation bool // The step needs confirmation and none was given
+}
+
+// PlanResult represents the outcome of running a plan. Steps holds the steps that
+// were reached; a plan stops at the first step that fails or is not approved.
+type PlanResult struct {
+	Plan      *Plan
+	Steps     []*PlanStepResult
+	Completed bool
+}
+
+// ExecutionAttempt represents one command run for a request: the generated
+// command or a correction of the previous attempt
+type ExecutionAttempt struct {
+	CommandResult    *CommandResult
+	ExecutionResult  *ExecutionResult // Nil when the correction was declined
+	ValidationResult *ValidationResult
+	Declined         bool
 }
 
 // ErrorType represents different types of errors
@@ -16,6 +16,9 @@ func TestAuditAction_String(t *testing.T) {
 		{"Bypassed", AuditActionBypassed, "Bypassed"},
 		{"Blocked", AuditActionBlocked, "Blocked"},
 		{"Overridden", AuditActionOverridden, "Overridden"},
+		{"Generated", AuditActionGenerated, "Generated"},
+		{"Confirmed", AuditActionConfirmed, "Confirmed"},
+		{"Executed", AuditActionExecuted, "Executed"},
 		{"Unknown", AuditAction(999), "Unknown"},
 	}
 
@@ -50,6 +53,50 @@ func TestDangerLevel_String(t *testing.T) {
 	}
 }
 
+func TestExecutionMode_String(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     ExecutionMode
+		expected string
+	}{
+		{"Auto", ExecutionModeAuto, "Auto"},
+		{"Shell", ExecutionModeShell, "Shell"},
+		{"Direct", ExecutionModeDirect, "Direct"},
+		{"Unknown", ExecutionMode(999), "Unknown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.mode.String(); got != tt.expected {
+				t.Errorf("ExecutionMode.String() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestJobStatus_String(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   JobStatus
+		expected string
+	}{
+		{"Running", JobRunning, "running"},
+		{"Succeeded", JobSucceeded, "succeeded"},
+		{"Failed", JobFailed, "failed"},
+		{"Killed", JobKilled, "killed"},
+		{"Lost", JobLost, "lost"},
+		{"Unknown", JobStatus(999), "unknown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.status.String(); got != tt.expected {
+				t.Errorf("JobStatus.String() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
 func TestErrorType_String(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -0,0 +1,119 @@
+// Package usage prices the tokens providers report and totals what the
+// commands in the history have cost
+package usage
+
+import (
+	"strings"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+// DefaultPrices are the list prices of common models in US dollars per million
+// tokens. A key prices its model and the model's dated or tagged variants, so
+// gpt-4o also prices gpt-4o-2024-08-06. A key naming a provider prices all of
+// its models.
+var DefaultPrices = map[string]types.ModelPrice{
+	// OpenAI
+	"gpt-3.5-turbo": {InputPerMillion: 0.50, OutputPerMillion: 1.50},
+	"gpt-4":         {InputPerMillion: 30.00, OutputPerMillion: 60.00},
+	"gpt-4-turbo":   {InputPerMillion: 10.00, OutputPerMillion: 30.00},
+	"gpt-4o":        {InputPerMillion: 2.50, OutputPerMillion: 10.00},
+	"gpt-4o-mini":   {InputPerMillion: 0.15, OutputPerMillion: 0.60},
+	"gpt-4.1":       {InputPerMillion: 2.00, OutputPerMillion: 8.00},
+	"gpt-4.1-mini":  {InputPerMillion: 0.40, OutputPerMillion: 1.60},
+	"gpt-4.1-nano":  {InputPerMillion: 0.10, OutputPerMillion: 0.40},
+
+	// Anthropic
+	"claude-3-haiku":    {InputPerMillion: 0.25, OutputPerMillion: 1.25},
+	"claude-3-sonnet":   {InputPerMillion: 3.00, OutputPerMillion: 15.00},
+	"claude-3-opus":     {InputPerMillion: 15.00, OutputPerMillion: 75.00},
+	"claude-3-5-haiku":  {InputPerMillion: 0.80, OutputPerMillion: 4.00},
+	"claude-3-5-sonnet": {InputPerMillion: 3.00, OutputPerMillion: 15.00},
+	"claude-3-7-sonnet": {InputPerMillion: 3.00, OutputPerMillion: 15.00},
+	"claude-sonnet-4":   {InputPerMillion: 3.00, OutputPerMillion: 15.00},
+	"claude-opus-4":     {InputPerMillion: 15.00, OutputPerMillion: 75.00},
+
+	// Google
+	"gemini-pro":       {InputPerMillion: 0.50, OutputPerMillion: 1.50},
+	"gemini-1.5-flash": {InputPerMillion: 0.075, OutputPerMillion: 0.30},
+	"gemini-1.5-pro":   {InputPerMillion: 1.25, OutputPerMillion: 5.00},
+	"gemini-2.0-flash": {InputPerMillion: 0.10, OutputPerMillion: 0.40},
+
+	// Models run locally cost nothing
+	"ollama": {},
+}
+
+// PriceTable looks up the prices of models
+type PriceTable struct {
+	prices map[string]types.ModelPrice
+}
+
+// NewPriceTable creates a price table of DefaultPrices with overrides applied.
+// Override keys are models, provider/model pairs or provider names.
+func NewPriceTable(overrides map[string]types.ModelPrice) *PriceTable {
+	prices := make(map[string]types.ModelPrice, len(DefaultPrices)+len(overrides))
+	for key, price := range DefaultPrices {
+		prices[key] = price
+	}
+	for key, price := range overrides {
+		prices[key] = price
+	}
+	return &PriceTable{prices: prices}
+}
+
+// Price returns the price of model from provider. A provider/model key is
+// preferred to a model key, which is preferred to a provider key. Models of
+// aggregators such as OpenRouter, named vendor/model, are also looked up by
+// the model alone. It returns false when the model has no price.
+func (t *PriceTable) Price(provider, model string) (types.ModelPrice, bool) {
+	candidates := []string{provider + "/" + model, model}
+	if i := strings.LastIndex(model, "/"); i >= 0 {
+		candidates = append(candidates, model[i+1:])
+	}
+	for _, candidate := range candidates {
+		if price, ok := t.lookup(candidate); ok {
+			return price, true
+		}
+	}
+
+	price, ok := t.prices[provider]
+	return price, ok
+}
+
+// lookup returns the price of the longest key that is name or a variant of it
+func (t *PriceTable) lookup(name string) (types.ModelPrice, bool) {
+	if price, ok := t.prices[name]; ok {
+		return price, true
+	}
+
+	best := ""
+	for key := range t.prices {
+		if len(key) > len(best) && isVariant(name, key) {
+			best = key
+		}
+	}
+	if best == "" {
+		return types.ModelPrice{}, false
+	}
+	return t.prices[best], true
+}
+
+// isVariant reports whether name is a dated or tagged variant of model, such
+// as claude-3-haiku-20240307 of claude-3-haiku or llama3.2:latest of llama3.2
+func isVariant(name, model string) bool {
+	if !strings.HasPrefix(name, model) || len(name) == len(model) {
+		return false
+	}
+	separator := name[len(model)]
+	return separator == '-' || separator == ':' || separator == '@'
+}
+
+// Cost returns the cost of usage of model from provider in US dollars, and
+// false when the model has no price
+func (t *PriceTable) Cost(provider, model string, usage types.TokenUsage) (float64, bool) {
+	price, ok := t.Price(provider, model)
+	if !ok {
+		return 0, false
+	}
+	return (float64(usage.InputTokens)*price.InputPerMillion + float64(usage.OutputTokens)*price.OutputPerMillion) / 1e6, true
+}
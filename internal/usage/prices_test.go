@@ -0,0 +1,60 @@
+package usage
+
+import (
+	"math"
+	"testing"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+func TestPriceTable_Price(t *testing.T) {
+	table := NewPriceTable(map[string]types.ModelPrice{
+		"gpt-4o":                   {InputPerMillion: 2, OutputPerMillion: 8},
+		"openrouter/openai/gpt-4o": {InputPerMillion: 3, OutputPerMillion: 12},
+		"local-vllm":               {},
+	})
+
+	tests := []struct {
+		name      string
+		provider  string
+		model     string
+		wantInput float64
+		wantFound bool
+	}{
+		{"exact model", "openai", "gpt-4o-mini", 0.15, true},
+		{"override", "openai", "gpt-4o", 2, true},
+		{"dated variant", "anthropic", "claude-3-haiku-20240307", 0.25, true},
+		{"longest prefix wins", "openai", "gpt-4-turbo-2024-04-09", 10, true},
+		{"prefix must end at a separator", "openai", "gpt-4omni", 0, false},
+		{"provider and model override", "openrouter", "openai/gpt-4o", 3, true},
+		{"aggregator model", "openrouter", "anthropic/claude-3-5-sonnet", 3, true},
+		{"free provider", "ollama", "llama3.2:latest", 0, true},
+		{"provider override", "local-vllm", "qwen2.5-coder", 0, true},
+		{"unknown model", "openai", "o9-preview", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			price, found := table.Price(tt.provider, tt.model)
+			if found != tt.wantFound || price.InputPerMillion != tt.wantInput {
+				t.Errorf("Price(%q, %q) = %+v, %v; want input %g, %v", tt.provider, tt.model, price, found, tt.wantInput, tt.wantFound)
+			}
+		})
+	}
+}
+
+func TestPriceTable_Cost(t *testing.T) {
+	table := NewPriceTable(nil)
+
+	cost, ok := table.Cost("openai", "gpt-4o", types.TokenUsage{InputTokens: 1000, OutputTokens: 200})
+	if !ok {
+		t.Fatal("expected gpt-4o to have a price")
+	}
+	if want := 0.0045; math.Abs(cost-want) > 1e-12 {
+		t.Errorf("Cost() = %g, want %g", cost, want)
+	}
+
+	if cost, ok := table.Cost("openai", "unknown-model", types.TokenUsage{InputTokens: 1000}); ok || cost != 0 {
+		t.Errorf("expected no cost for an unknown model, got %g, %v", cost, ok)
+	}
+}
@@ -0,0 +1,134 @@
+package usage
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+// Grouping selects how Summarize groups history entries
+type Grouping string
+
+const (
+	ByDay      Grouping = "day"      // Calendar day, in local time
+	ByProvider Grouping = "provider" // Provider that answered
+	ByModel    Grouping = "model"    // Provider and model, as provider/model
+)
+
+// ParseGrouping returns the grouping named by name
+func ParseGrouping(name string) (Grouping, error) {
+	switch grouping := Grouping(name); grouping {
+	case ByDay, ByProvider, ByModel:
+		return grouping, nil
+	default:
+		return "", fmt.Errorf("unknown grouping %q (expected day, provider or model)", name)
+	}
+}
+
+// Row totals the usage of one group of history entries
+type Row struct {
+	Key      string
+	Requests int // Entries that used tokens
+	Usage    types.TokenUsage
+	Cost     float64
+}
+
+// Summarize totals the usage of the entries that used tokens by grouping,
+// ordered by key
+func Summarize(entries []*types.HistoryEntry, grouping Grouping) []Row {
+	rows := make(map[string]*Row)
+	for _, entry := range entries {
+		if entry.Usage.Total() == 0 {
+			continue
+		}
+
+		key := groupKey(entry, grouping)
+		row, ok := rows[key]
+		if !ok {
+			row = &Row{Key: key}
+			rows[key] = row
+		}
+		row.Requests++
+		row.Usage.Add(entry.Usage)
+		row.Cost += entry.Cost
+	}
+
+	result := make([]Row, 0, len(rows))
+	for _, row := range rows {
+		result = append(result, *row)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Key < result[j].Key })
+	return result
+}
+
+// groupKey returns the key of the group entry belongs to
+func groupKey(entry *types.HistoryEntry, grouping Grouping) string {
+	provider := entry.Provider
+	if provider == "" {
+		provider = "unknown"
+	}
+	switch grouping {
+	case ByProvider:
+		return provider
+	case ByModel:
+		if entry.Model == "" {
+			return provider
+		}
+		return provider + "/" + entry.Model
+	default:
+		return entry.Timestamp.Local().Format("2006-01-02")
+	}
+}
+
+// Spend is what provider calls have cost in the current day and month
+type Spend struct {
+	Today     float64
+	ThisMonth float64
+}
+
+// MonthStart returns the start of the calendar month of now, in local time
+func MonthStart(now time.Time) time.Time {
+	now = now.Local()
+	return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+}
+
+// SpendAt totals the cost of the entries recorded in the day and month of now
+func SpendAt(entries []*types.HistoryEntry, now time.Time) Spend {
+	now = now.Local()
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	monthStart := MonthStart(now)
+
+	var spend Spend
+	for _, entry := range entries {
+		if entry.Timestamp.Before(monthStart) {
+			continue
+		}
+		spend.ThisMonth += entry.Cost
+		if !entry.Timestamp.Before(dayStart) {
+			spend.Today += entry.Cost
+		}
+	}
+	return spend
+}
+
+// ReachedLimit describes the first limit spend has reached, or returns "" when
+// spend is within the limits
+func ReachedLimit(limits types.SpendLimits, spend Spend) string {
+	if limits.DailyLimit > 0 && spend.Today >= limits.DailyLimit {
+		return fmt.Sprintf("daily spend limit of %s reached (%s spent today)", FormatCost(limits.DailyLimit), FormatCost(spend.Today))
+	}
+	if limits.MonthlyLimit > 0 && spend.ThisMonth >= limits.MonthlyLimit {
+		return fmt.Sprintf("monthly spend limit of %s reached (%s spent this month)", FormatCost(limits.MonthlyLimit), FormatCost(spend.ThisMonth))
+	}
+	return ""
+}
+
+// FormatCost formats a cost in US dollars, keeping the precision of small costs
+func FormatCost(cost float64) string {
+	if cost != 0 && cost < 0.01 {
+		return fmt.Sprintf("$%.4f", cost)
+	}
+	return fmt.Sprintf("$%.2f", cost)
+}
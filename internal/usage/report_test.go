@@ -0,0 +1,103 @@
+package usage
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kanishka-sahoo/nl-to-shell/internal/types"
+)
+
+func TestSummarize(t *testing.T) {
+	day1 := time.Date(2024, 3, 4, 10, 0, 0, 0, time.Local)
+	day2 := day1.Add(24 * time.Hour)
+	entries := []*types.HistoryEntry{
+		{Timestamp: day1, Provider: "openai", Model: "gpt-4o", Usage: types.TokenUsage{InputTokens: 100, OutputTokens: 10}, Cost: 0.5},
+		{Timestamp: day1, Provider: "anthropic", Model: "claude-3-haiku", Usage: types.TokenUsage{InputTokens: 200, OutputTokens: 20}, Cost: 0.25},
+		{Timestamp: day2, Provider: "openai", Model: "gpt-4o", Usage: types.TokenUsage{InputTokens: 300, OutputTokens: 30}, Cost: 1},
+		{Timestamp: day2, Provider: "openai", Model: "gpt-4o"}, // Rerun without a provider call
+	}
+
+	tests := []struct {
+		grouping Grouping
+		want     []Row
+	}{
+		{ByDay, []Row{
+			{Key: "2024-03-04", Requests: 2, Usage: types.TokenUsage{InputTokens: 300, OutputTokens: 30}, Cost: 0.75},
+			{Key: "2024-03-05", Requests: 1, Usage: types.TokenUsage{InputTokens: 300, OutputTokens: 30}, Cost: 1},
+		}},
+		{ByProvider, []Row{
+			{Key: "anthropic", Requests: 1, Usage: types.TokenUsage{InputTokens: 200, OutputTokens: 20}, Cost: 0.25},
+			{Key: "openai", Requests: 2, Usage: types.TokenUsage{InputTokens: 400, OutputTokens: 40}, Cost: 1.5},
+		}},
+		{ByModel, []Row{
+			{Key: "anthropic/claude-3-haiku", Requests: 1, Usage: types.TokenUsage{InputTokens: 200, OutputTokens: 20}, Cost: 0.25},
+			{Key: "openai/gpt-4o", Requests: 2, Usage: types.TokenUsage{InputTokens: 400, OutputTokens: 40}, Cost: 1.5},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.grouping), func(t *testing.T) {
+			rows := Summarize(entries, tt.grouping)
+			if len(rows) != len(tt.want) {
+				t.Fatalf("Summarize() = %+v, want %+v", rows, tt.want)
+			}
+			for i := range rows {
+				if rows[i] != tt.want[i] {
+					t.Errorf("row %d = %+v, want %+v", i, rows[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseGrouping(t *testing.T) {
+	if grouping, err := ParseGrouping("model"); err != nil || grouping != ByModel {
+		t.Errorf("ParseGrouping(model) = %v, %v", grouping, err)
+	}
+	if _, err := ParseGrouping("week"); err == nil {
+		t.Error("expected an error for an unknown grouping")
+	}
+}
+
+func TestSpendAtAndReachedLimit(t *testing.T) {
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.Local)
+	entries := []*types.HistoryEntry{
+		{Timestamp: time.Date(2024, 2, 28, 12, 0, 0, 0, time.Local), Cost: 10}, // Last month
+		{Timestamp: time.Date(2024, 3, 1, 9, 0, 0, 0, time.Local), Cost: 4},
+		{Timestamp: time.Date(2024, 3, 15, 8, 0, 0, 0, time.Local), Cost: 0.5},
+		{Timestamp: time.Date(2024, 3, 15, 11, 0, 0, 0, time.Local), Cost: 0.25},
+	}
+
+	spend := SpendAt(entries, now)
+	if spend.Today != 0.75 || spend.ThisMonth != 4.75 {
+		t.Fatalf("SpendAt() = %+v, want 0.75 today and 4.75 this month", spend)
+	}
+
+	tests := []struct {
+		name   string
+		limits types.SpendLimits
+		want   string
+	}{
+		{"no limits", types.SpendLimits{}, ""},
+		{"within limits", types.SpendLimits{DailyLimit: 1, MonthlyLimit: 10}, ""},
+		{"daily limit", types.SpendLimits{DailyLimit: 0.5, MonthlyLimit: 10}, "daily spend limit of $0.50 reached ($0.75 spent today)"},
+		{"monthly limit", types.SpendLimits{DailyLimit: 1, MonthlyLimit: 4}, "monthly spend limit of $4.00 reached"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ReachedLimit(tt.limits, spend)
+			if (tt.want == "") != (got == "") || !strings.HasPrefix(got, tt.want) {
+				t.Errorf("ReachedLimit() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatCost(t *testing.T) {
+	for cost, want := range map[float64]string{0: "$0.00", 0.0045: "$0.0045", 1.5: "$1.50"} {
+		if got := FormatCost(cost); got != want {
+			t.Errorf("FormatCost(%g) = %q, want %q", cost, got, want)
+		}
+	}
+}
@@ -53,11 +53,12 @@ func (rv *ResultValidator) ValidateResult(ctx context.Context, result *types.Exe
 		Explanation:      response.Explanation,
 		Suggestions:      response.Suggestions,
 		CorrectedCommand: response.Correction,
+		Usage:            response.Usage,
 	}
 
 	// If the result is incorrect and no correction was provided, generate one
 	if !response.IsCorrect && response.Correction == "" {
-		correction, err := rv.generateCorrection(ctx, result, intent, response.Explanation)
+		correction, err := rv.generateCorrection(ctx, result, intent, response.Explanation, &validationResult.Usage)
 		if err == nil {
 			validationResult.CorrectedCommand = correction
 		}
@@ -128,8 +129,9 @@ func (rv *ResultValidator) formatOutput(result *types.ExecutionResult) string {
 	return output.String()
 }
 
-// generateCorrection attempts to generate a corrected command when validation fails
-func (rv *ResultValidator) generateCorrection(ctx context.Context, result *types.ExecutionResult, intent string, explanation string) (string, error) {
+// generateCorrection attempts to generate a corrected command when validation
+// fails, adding the tokens it uses to usage
+func (rv *ResultValidator) generateCorrection(ctx context.Context, result *types.ExecutionResult, intent string, explanation string, usage *types.TokenUsage) (string, error) {
 	// Build a prompt for correction generation
 	var prompt strings.Builder
 
@@ -153,6 +155,7 @@ func (rv *ResultValidator) generateCorrection(ctx context.Context, result *types
 	if err != nil {
 		return "", err
 	}
+	usage.Add(response.Usage)
 
 	return response.Command, nil
 }
@@ -185,8 +188,17 @@ func (arv *AdvancedResultValidator) ValidateResult(ctx context.Context, result *
 
 	// If auto-correction is enabled and the result is incorrect, attempt correction
 	if arv.enableAutoCorrection && !validationResult.IsCorrect {
-		correctedCommand, err := arv.generateImprovedCorrection(ctx, result, intent, validationResult.Explanation)
-		if err == nil && correctedCommand != "" {
+		// Keep the provider's correction if it is usable rather than asking again
+		correctedCommand := validationResult.CorrectedCommand
+		if !arv.isReasonableCorrection(result.Command, &types.Command{Generated: correctedCommand}, intent) {
+			correctedCommand, err = arv.generateImprovedCorrection(ctx, result, intent, validationResult.Explanation, &validationResult.Usage)
+			if err != nil {
+				correctedCommand = ""
+			}
+			// Never propose a correction that failed the sanity checks
+			validationResult.CorrectedCommand = ""
+		}
+		if correctedCommand != "" {
 			validationResult.CorrectedCommand = correctedCommand
 
 			// Add suggestion about the correction
@@ -201,10 +213,11 @@ func (arv *AdvancedResultValidator) ValidateResult(ctx context.Context, result *
 	return validationResult, nil
 }
 
-// generateImprovedCorrection generates a more sophisticated correction using multiple validation passes
-func (arv *AdvancedResultValidator) generateImprovedCorrection(ctx context.Context, result *types.ExecutionResult, intent string, explanation string) (string, error) {
+// generateImprovedCorrection generates a more sophisticated correction using
+// multiple validation passes, adding the tokens it uses to usage
+func (arv *AdvancedResultValidator) generateImprovedCorrection(ctx context.Context, result *types.ExecutionResult, intent string, explanation string, usage *types.TokenUsage) (string, error) {
 	// First attempt: basic correction
-	correction, err := arv.generateCorrection(ctx, result, intent, explanation)
+	correction, err := arv.generateCorrection(ctx, result, intent, explanation, usage)
 	if err != nil {
 		return "", err
 	}
@@ -228,7 +241,7 @@ func (arv *AdvancedResultValidator) generateImprovedCorrection(ctx context.Conte
 		}
 	}
 
-	return correction, nil
+	return "", nil
 }
 
 // isReasonableCorrection performs basic sanity checks on the proposed correction
@@ -479,6 +479,7 @@ func TestResultValidator_generateCorrection(t *testing.T) {
 				Command:     "ls -la",
 				Explanation: "Corrected command",
 				Confidence:  0.9,
+				Usage:       types.TokenUsage{InputTokens: 120, OutputTokens: 15},
 			}, nil
 		},
 	}
@@ -497,10 +498,14 @@ func TestResultValidator_generateCorrection(t *testing.T) {
 		Error:    errors.New("command failed"),
 	}
 
-	correction, err := validator.generateCorrection(ctx, executionResult, "list files", "Directory not found")
+	usage := types.TokenUsage{InputTokens: 200, OutputTokens: 30}
+	correction, err := validator.generateCorrection(ctx, executionResult, "list files", "Directory not found", &usage)
 	if err != nil {
 		t.Errorf("Unexpected error: %v", err)
 	}
+	if usage.InputTokens != 320 || usage.OutputTokens != 45 {
+		t.Errorf("Expected the correction's tokens to be added to the usage, got %+v", usage)
+	}
 	if correction == "" {
 		t.Error("Expected non-empty correction")
 	}
@@ -508,3 +513,32 @@ func TestResultValidator_generateCorrection(t *testing.T) {
 		t.Errorf("Expected correction 'ls -la', got %q", correction)
 	}
 }
+
+func TestAdvancedResultValidator_ValidateResult_RejectsUnreasonableCorrection(t *testing.T) {
+	provider := &MockLLMProvider{
+		validateResultFunc: func(ctx context.Context, command, output, intent string) (*types.ValidationResponse, error) {
+			return &types.ValidationResponse{
+				IsCorrect:   false,
+				Explanation: "No such file or directory",
+				Correction:  command,
+			}, nil
+		},
+		generateCommandFunc: func(ctx context.Context, prompt string, context *types.Context) (*types.CommandResponse, error) {
+			return &types.CommandResponse{Command: "ls /nonexistent"}, nil
+		},
+	}
+
+	validator := NewAdvancedResultValidator(provider, true)
+	executionResult := &types.ExecutionResult{
+		Command:  &types.Command{ID: "test", Generated: "ls /nonexistent", Context: &types.Context{}},
+		ExitCode: 2,
+	}
+
+	result, err := validator.ValidateResult(context.Background(), executionResult, "list files")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.CorrectedCommand != "" {
+		t.Errorf("Expected a correction repeating the failed command to be dropped, got %q", result.CorrectedCommand)
+	}
+}